@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPIKeys lists the API keys the caller administers; ?include_revoked=true
+// adds revoked ones
+func (h *BankHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := services.ListAPIKeys(adminScope(c), c.Query("include_revoked") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys, "count": len(keys)})
+}
+
+type CreateAPIKeyRequest struct {
+	TenantID  string   `json:"tenant_id"` // operator only; defaults to the caller's tenant
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"` // requests per minute
+	ExpiresIn string   `json:"expires_in"` // duration such as 720h
+}
+
+// CreateAPIKey issues a key. The response holds the full key, which is not
+// shown again.
+func (h *BankHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var expiresIn time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be a duration such as 720h"})
+			return
+		}
+		expiresIn = d
+	}
+	tenant, ok := requestedTenant(c, req.TenantID)
+	if !ok {
+		return
+	}
+	issued, err := h.APIKeys.Issue(services.APIKeyRequest{
+		TenantID:  tenant,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		ExpiresIn: expiresIn,
+		CreatedBy: authUser(c),
+	})
+	if errors.Is(err, services.ErrTenantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.After = "API_KEY_ISSUED", issued.APIKey
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RevokeAPIKey disables a key at once
+func (h *BankHandler) RevokeAPIKey(c *gin.Context) {
+	rec := audit(c)
+	rec.Action = "API_KEY_REVOKED"
+	before, err := services.GetAPIKey(c.Param("id"))
+	if err == nil && !inAdminScope(c, before.TenantID) {
+		err = services.ErrAPIKeyNotFound
+	}
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rec.Before = before
+	key, err := services.RevokeAPIKey(c.Param("id"), authUser(c))
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrAPIKeyRevoked) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "api_key": key})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rec.After = key
+	c.JSON(http.StatusOK, key)
+}
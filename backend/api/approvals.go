@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApprovals is the approval queue, filtered by ?status= (e.g. PENDING) and ?kind=
+func (h *BankHandler) GetApprovals(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	approvals, err := services.ListApprovals(authTenant(c), c.Query("status"), c.Query("kind"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approvals": approvals, "count": len(approvals)})
+}
+
+// GetApproval returns one proposal
+func (h *BankHandler) GetApproval(c *gin.Context) {
+	var id int64
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid approval id"})
+		return
+	}
+	approval, err := services.GetApproval(authTenant(c), id)
+	if errors.Is(err, services.ErrApprovalNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+type ApprovalDecisionRequest struct {
+	Reviewer string `json:"reviewer"`
+	Reason   string `json:"reason"` // required to reject
+}
+
+// ApproveApproval applies a proposal on behalf of a second, reviewing user
+func (h *BankHandler) ApproveApproval(c *gin.Context) {
+	h.decideApproval(c, true)
+}
+
+// RejectApproval discards a proposal with a reason
+func (h *BankHandler) RejectApproval(c *gin.Context) {
+	h.decideApproval(c, false)
+}
+
+func (h *BankHandler) decideApproval(c *gin.Context, approve bool) {
+	var id int64
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid approval id"})
+		return
+	}
+	var req ApprovalDecisionRequest
+	_ = c.ShouldBindJSON(&req)
+	if user := authUser(c); user != "" {
+		req.Reviewer = user
+	}
+	if req.Reviewer == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reviewer is required"})
+		return
+	}
+
+	rec := audit(c)
+	rec.Action, rec.Actor = "APPROVAL_REJECT", req.Reviewer
+	if approve {
+		rec.Action = "APPROVAL_APPROVE"
+	}
+	if before, err := services.GetApproval(authTenant(c), id); err == nil {
+		rec.Before = before
+		if before.Kind == models.ApprovalVerdict {
+			rec.TransactionID = before.Target
+		}
+	}
+
+	var result any
+	approval, err := services.DecideApproval(authTenant(c), id, req.Reviewer, approve, req.Reason, func(a models.Approval) (err error) {
+		result, err = h.applyApproval(a)
+		return err
+	})
+	switch {
+	case errors.Is(err, services.ErrApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "approval": approval})
+	case errors.Is(err, services.ErrSelfApproval), errors.Is(err, services.ErrNotReviewer):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil && approval != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		rec.After = gin.H{"approval": approval, "result": result}
+		c.JSON(http.StatusOK, gin.H{"approval": approval, "result": result})
+	}
+}
+
+// applyApproval carries out an approved proposal within the tenant that made it
+func (h *BankHandler) applyApproval(a models.Approval) (any, error) {
+	switch a.Kind {
+	case models.ApprovalVerdict:
+		var req VerifyTransactionRequest
+		if err := json.Unmarshal(a.Payload, &req); err != nil {
+			return nil, err
+		}
+		statusChange, err := h.applyVerdict(a.TenantID, a.Target, req)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"status": "verified", "id": a.Target, "verdict": req.Verdict, "account_status_change": statusChange}, nil
+	case models.ApprovalOverride:
+		var override models.Override
+		if err := json.Unmarshal(a.Payload, &override); err != nil {
+			return nil, err
+		}
+		override.TenantID = a.TenantID
+		return h.Overrides.CreateOverride(override)
+	case models.ApprovalOverrideRevoke:
+		var req RevokeOverrideRequest
+		if err := json.Unmarshal(a.Payload, &req); err != nil {
+			return nil, err
+		}
+		var id int64
+		fmt.Sscanf(a.Target, "%d", &id)
+		return h.Overrides.RevokeOverride(a.TenantID, id, req.RevokedBy)
+	}
+	return nil, fmt.Errorf("unknown approval kind %q", a.Kind)
+}
+
+// proposalResponse answers a request that was queued for approval
+func proposalResponse(c *gin.Context, approval *models.Approval, err error) {
+	if errors.Is(err, services.ErrApprovalPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "pending_approval", "approval": approval})
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// auditRecord collects what a handler knows about the change it made. The
+// AuditTrail middleware writes it once the handler returns.
+type auditRecord struct {
+	Action        string
+	TransactionID string
+	Actor         string
+	Before        any
+	After         any
+}
+
+const (
+	auditKey        = "audit"
+	requestIDKey    = "request_id"
+	requestIDHeader = "X-Request-ID"
+)
+
+// validRequestID limits caller-supplied request IDs to something safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// AuditTrail tags every request with a request ID (the caller's X-Request-ID
+// when valid) and writes an audit entry for each mutating API request, with
+// whatever actor, action and before/after state the handler recorded.
+func AuditTrail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			requestID = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+
+		path := c.Request.URL.Path
+		switch {
+		case c.Request.Method == http.MethodGet, c.Request.Method == http.MethodHead, c.Request.Method == http.MethodOptions:
+			c.Next()
+			return
+		case !strings.HasPrefix(path, "/api/"):
+			c.Next()
+			return
+		}
+
+		rec := &auditRecord{}
+		c.Set(auditKey, rec)
+		c.Next()
+
+		if rec.Action == "" {
+			route := c.FullPath()
+			if route == "" {
+				route = path
+			}
+			rec.Action = c.Request.Method + " " + route
+		}
+		if rec.Actor == "" {
+			rec.Actor = authUser(c)
+		}
+		if rec.Actor == "" {
+			rec.Actor = "anonymous"
+		}
+		services.WriteAudit(models.AuditEntry{
+			TransactionID: rec.TransactionID,
+			TenantID:      authTenant(c),
+			Action:        rec.Action,
+			Actor:         rec.Actor,
+			IP:            c.ClientIP(),
+			RequestID:     requestID,
+			Status:        c.Writer.Status(),
+			Before:        services.AuditState(rec.Before),
+			After:         services.AuditState(rec.After),
+		})
+	}
+}
+
+// audit returns the request's audit record for the handler to fill in. Outside
+// the middleware it returns a record nobody writes.
+func audit(c *gin.Context) *auditRecord {
+	if rec, ok := c.Get(auditKey); ok {
+		return rec.(*auditRecord)
+	}
+	return &auditRecord{}
+}
+
+// AuditRuleChange is called before a rule update is proxied to the AI service
+// and records the rule as it was and the change requested
+func (h *BankHandler) AuditRuleChange(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var change struct {
+		ID      string `json:"id"`
+		Enabled *bool  `json:"enabled"`
+	}
+	_ = json.Unmarshal(body, &change)
+	rec := audit(c)
+	rec.Action, rec.After = "RULE_CHANGE", change
+	if rule, err := h.AI.GetRule(change.ID); err == nil && rule != nil {
+		rec.Before = rule
+	}
+}
+
+// GetAuditLog queries the audit trail by ?transaction_id=, ?actor=, ?action=
+// (prefix), ?request_id= and ?since=/?until= (RFC 3339), newest first.
+// Auditors see their own tenant's requests; the operator sees every entry, or
+// one tenant's with ?tenant_id=.
+func (h *BankHandler) GetAuditLog(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	f := services.AuditFilter{
+		TenantID:      adminScope(c),
+		TransactionID: c.Query("transaction_id"),
+		Actor:         c.Query("actor"),
+		Action:        c.Query("action"),
+		RequestID:     c.Query("request_id"),
+		Limit:         limit,
+	}
+	if f.TenantID == "" {
+		f.TenantID = strings.ToUpper(c.Query("tenant_id"))
+	}
+	for param, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	entries, err := services.ListAudit(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "count": len(entries)})
+}
+
+// VerifyAuditLog re-hashes the whole chain. A broken chain is reported with
+// 409 and the first entry that fails.
+func (h *BankHandler) VerifyAuditLog(c *gin.Context) {
+	result, err := services.VerifyAuditChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !result.Valid {
+		c.JSON(http.StatusConflict, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	usernameKey = "username"
+	roleKey     = "role"
+	apiKeyKey   = "api_key"
+	tenantKey   = "tenant"
+)
+
+// RequireAuth rejects requests without a valid access token or API key and
+// records the caller and their tenant on the context for handlers and the
+// audit trail. API keys come in X-API-Key or as the bearer token; they act as
+// "apikey:<key id>" and have no role, only scopes.
+func (h *BankHandler) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := apiKeyCredential(c); key != "" {
+			h.requireAPIKey(c, key)
+			return
+		}
+		user, err := h.Auth.Authenticate(bearerToken(c))
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="synapse"`)
+			if errors.Is(err, services.ErrInvalidToken) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if user.PasswordChangeRequired && !passwordChangeRoutes[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": services.ErrPasswordChangeRequired.Error(), "password_change_required": true})
+			return
+		}
+		c.Set(usernameKey, user.Username)
+		c.Set(roleKey, user.Role)
+		c.Set(tenantKey, user.TenantID)
+		c.Next()
+	}
+}
+
+// passwordChangeRoutes are all a session may use while its user still has to
+// replace an initial password
+var passwordChangeRoutes = map[string]bool{
+	"/api/me/password": true,
+	"/api/logout":      true,
+}
+
+func (h *BankHandler) requireAPIKey(c *gin.Context, credential string) {
+	key, reset, err := h.APIKeys.Authenticate(credential)
+	switch {
+	case errors.Is(err, services.ErrRateLimited):
+		c.Set(usernameKey, "apikey:"+key.KeyID)
+		c.Header("Retry-After", fmt.Sprint(int(time.Until(reset).Seconds())+1))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "rate_limit": key.RateLimit})
+		return
+	case errors.Is(err, services.ErrInvalidToken):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid, expired or revoked api key"})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("X-RateLimit-Limit", fmt.Sprint(key.RateLimit))
+	c.Header("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+	c.Set(usernameKey, "apikey:"+key.KeyID)
+	c.Set(tenantKey, key.TenantID)
+	c.Set(apiKeyKey, key)
+	c.Next()
+}
+
+// apiKeyCredential returns the API key presented with the request, if any
+func apiKeyCredential(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+	if token := bearerToken(c); services.IsAPIKey(token) {
+		return token
+	}
+	return ""
+}
+
+// authAPIKey is the API key the request was made with, nil for users
+func authAPIKey(c *gin.Context) *models.APIKey {
+	if v, ok := c.Get(apiKeyKey); ok {
+		return v.(*models.APIKey)
+	}
+	return nil
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// authUser is the signed-in username, empty on routes without RequireAuth.
+// Handlers use it in place of any analyst or reviewer named in the body.
+func authUser(c *gin.Context) string {
+	return c.GetString(usernameKey)
+}
+
+// authTenant is the bank the caller belongs to. Every read and write a
+// handler makes is scoped to it.
+func authTenant(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// adminScope is the tenant whose users and keys the caller may manage: their
+// own, or every tenant ("") for the operator
+func adminScope(c *gin.Context) string {
+	if services.IsOperatorTenant(authTenant(c)) {
+		return ""
+	}
+	return authTenant(c)
+}
+
+// inAdminScope reports whether something belonging to tenant is within the
+// caller's adminScope
+func inAdminScope(c *gin.Context, tenant string) bool {
+	scope := adminScope(c)
+	return scope == "" || scope == tenant
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken exchanges a refresh token for a new token pair
+func (h *BankHandler) RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+	rec := audit(c)
+	rec.Action = "TOKEN_REFRESH"
+	pair, err := h.Auth.Refresh(req.RefreshToken, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidToken) {
+		rec.Action = "TOKEN_REFRESH_FAILED"
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rec.Actor = pair.Username
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout revokes the current session, or every session of the user with ?all=true
+func (h *BankHandler) Logout(c *gin.Context) {
+	rec := audit(c)
+	rec.Action = "LOGOUT"
+	if c.Query("all") == "true" {
+		n, err := services.RevokeUserSessions(authUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		rec.Action, rec.After = "LOGOUT_ALL", gin.H{"revoked_sessions": n}
+		c.JSON(http.StatusOK, gin.H{"status": "logged_out", "revoked_sessions": n})
+		return
+	}
+	if err := h.Auth.Logout(bearerToken(c)); err != nil && !errors.Is(err, services.ErrInvalidToken) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// GetSessions lists the signed-in user's open sessions
+func (h *BankHandler) GetSessions(c *gin.Context) {
+	sessions, err := services.ListSessions(authUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "count": len(sessions)})
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword sets a new password for the signed-in user
+func (h *BankHandler) ChangePassword(c *gin.Context) {
+	if authAPIKey(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "api keys have no password"})
+		return
+	}
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and new_password are required"})
+		return
+	}
+	rec := audit(c)
+	rec.Action = "PASSWORD_CHANGED"
+	err := services.ChangePassword(authUser(c), req.CurrentPassword, req.NewPassword)
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials):
+		rec.Action = "PASSWORD_CHANGE_FAILED"
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		rec.Action = "PASSWORD_CHANGE_FAILED"
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "changed"})
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// raiseAlerts files alerts for the scoring decision, each watchlist hit and the
+// detectors that fired on this transaction. Failures are logged; they never
+// affect the decision already made.
+func (h *BankHandler) raiseAlerts(txn models.Transaction, analysis models.AnalysisResult, hits []models.WatchlistHit, riskContext map[string]any, locationEvidence []string) {
+	var alerts []models.Alert
+	switch analysis.Action {
+	case "Block", "Review":
+		severity := models.SeverityMedium
+		if analysis.Action == "Block" {
+			severity = models.SeverityHigh
+		}
+		summary := fmt.Sprintf("%s at risk score %.0f", analysis.Action, analysis.RiskScore)
+		if len(analysis.Reasons) > 0 {
+			summary += ": " + strings.Join(analysis.Reasons, "; ")
+		}
+		alerts = append(alerts, models.Alert{
+			Source:    models.AlertSourceDecision,
+			Type:      strings.ToUpper(analysis.Action),
+			Severity:  severity,
+			AccountID: txn.ReceiverAccount,
+			Summary:   summary,
+		})
+	}
+	for _, hit := range hits {
+		severity := models.SeverityMedium
+		if hit.Action == "Block" {
+			severity = models.SeverityCritical
+		}
+		alerts = append(alerts, models.Alert{
+			Source:    models.AlertSourceWatchlist,
+			Type:      "WATCHLIST_HIT",
+			Severity:  severity,
+			AccountID: hit.AccountID,
+			Summary: fmt.Sprintf("Hit #%d: %s %s %q matches %q on %s (score %.2f)",
+				hit.HitID, hit.Role, hit.MatchedField, hit.MatchedValue, hit.EntryName, hit.ListName, hit.Score),
+		})
+	}
+	if n, _ := riskContext["round_trip_cycle_count"].(int64); n > 0 {
+		alerts = append(alerts, models.Alert{
+			Source:    models.AlertSourceDetector,
+			Type:      "ROUND_TRIP_CYCLE",
+			Severity:  models.SeverityHigh,
+			AccountID: txn.ReceiverAccount,
+			Summary:   fmt.Sprintf("%s is on %d round-tripping cycles", txn.ReceiverAccount, n),
+		})
+	}
+	if len(locationEvidence) > 0 {
+		alerts = append(alerts, models.Alert{
+			Source:    models.AlertSourceDetector,
+			Type:      "LOCATION_ANOMALY",
+			Severity:  models.SeverityMedium,
+			AccountID: txn.SenderAccount,
+			Summary:   strings.Join(locationEvidence, "; "),
+		})
+	}
+
+	for _, a := range alerts {
+		a.TransactionID, a.TenantID = txn.TransactionID, txn.TenantID
+		if _, err := services.RaiseAlert(a); err != nil {
+			log.Printf("Failed to raise %s alert for %s: %v", a.Type, txn.TransactionID, err)
+		}
+	}
+}
+
+// GetAlerts lists alerts, filtered by ?source=, ?account= and ?case_id=
+func (h *BankHandler) GetAlerts(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	alerts, err := services.ListAlerts(authTenant(c), c.Query("source"), c.Query("account"), c.Query("case_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts, "count": len(alerts)})
+}
+
+// GetCases lists cases, filtered by ?status=, ?assignee=, ?priority= and ?account=
+func (h *BankHandler) GetCases(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	cases, err := services.ListCases(services.CaseFilter{
+		TenantID:  authTenant(c),
+		Status:    c.Query("status"),
+		Assignee:  c.Query("assignee"),
+		Priority:  c.Query("priority"),
+		AccountID: c.Query("account"),
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cases": cases, "count": len(cases)})
+}
+
+// GetCase returns a case with its links, notes and alerts
+func (h *BankHandler) GetCase(c *gin.Context) {
+	kase, err := services.GetCase(authTenant(c), c.Param("id"))
+	caseResponse(c, kase, err)
+}
+
+type AssignCaseRequest struct {
+	Assignee string `json:"assignee"`
+	By       string `json:"by"`
+}
+
+// AssignCase sets the analyst working a case
+func (h *BankHandler) AssignCase(c *gin.Context) {
+	var req AssignCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Assignee == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "assignee is required"})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.By = user
+	}
+	audit(c).Actor = req.By
+	kase, err := services.AssignCase(authTenant(c), c.Param("id"), req.Assignee, req.By)
+	caseResponse(c, kase, err)
+}
+
+type CaseStatusRequest struct {
+	Status   string `json:"status"`
+	Priority string `json:"priority"`
+	By       string `json:"by"`
+}
+
+// UpdateCaseStatus moves a case between open, investigating and escalated
+// and/or changes its priority
+func (h *BankHandler) UpdateCaseStatus(c *gin.Context) {
+	var req CaseStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Status == "" && req.Priority == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status or priority is required"})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.By = user
+	}
+	audit(c).Actor = req.By
+	var kase *models.Case
+	var err error
+	if req.Priority != "" {
+		kase, err = services.SetCasePriority(authTenant(c), c.Param("id"), req.Priority, req.By)
+	}
+	if err == nil && req.Status != "" {
+		kase, err = services.SetCaseStatus(authTenant(c), c.Param("id"), req.Status, req.By)
+	}
+	caseResponse(c, kase, err)
+}
+
+type CloseCaseRequest struct {
+	Resolution string `json:"resolution"`
+	By         string `json:"by"`
+}
+
+// CloseCase closes a case with a resolution
+func (h *BankHandler) CloseCase(c *gin.Context) {
+	var req CloseCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Resolution == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolution is required"})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.By = user
+	}
+	audit(c).Actor = req.By
+	kase, err := services.CloseCase(authTenant(c), c.Param("id"), req.Resolution, req.By)
+	caseResponse(c, kase, err)
+}
+
+type CaseNoteRequest struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+// AddCaseNote appends a note to a case
+func (h *BankHandler) AddCaseNote(c *gin.Context) {
+	var req CaseNoteRequest
+	_ = c.ShouldBindJSON(&req)
+	if user := authUser(c); user != "" {
+		req.Author = user
+	}
+	if req.Author == "" || strings.TrimSpace(req.Text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "author and text are required"})
+		return
+	}
+	audit(c).Actor = req.Author
+	note, err := services.AddCaseNote(authTenant(c), c.Param("id"), req.Author, req.Text)
+	if errors.Is(err, services.ErrCaseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+type LinkCaseRequest struct {
+	Transactions []string `json:"transactions"`
+	Accounts     []string `json:"accounts"`
+	By           string   `json:"by"`
+}
+
+// LinkCaseItems attaches transactions and accounts to a case
+func (h *BankHandler) LinkCaseItems(c *gin.Context) {
+	var req LinkCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.By = user
+	}
+	audit(c).Actor = req.By
+	kase, err := services.LinkCaseItems(authTenant(c), c.Param("id"), req.Transactions, req.Accounts, req.By)
+	caseResponse(c, kase, err)
+}
+
+func caseResponse(c *gin.Context, kase *models.Case, err error) {
+	switch {
+	case errors.Is(err, services.ErrCaseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, kase)
+	}
+}
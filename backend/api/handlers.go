@@ -2,11 +2,15 @@ package api
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/rand"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,8 +21,21 @@ import (
 )
 
 type BankHandler struct {
-	Neo4j *services.Neo4jService
-	AI    *services.AIClients
+	Neo4j         *services.Neo4jService
+	AI            *services.AIClients
+	Communities   *services.CommunityJob
+	Propagation   *services.RiskPropagationJob
+	Geo           *services.GeoService
+	Calendar      *services.BusinessCalendar
+	FX            *services.FXService
+	Watchlists    *services.WatchlistService
+	Overrides     *services.OverrideService
+	AccountStatus *services.AccountStatusService
+	Holds         *services.HoldService
+	Reports       *services.STRService
+	Auth          *services.AuthService
+	APIKeys       *services.APIKeyService
+	OIDC          *services.OIDCService
 }
 
 var (
@@ -29,27 +46,92 @@ var (
 
 func NewBankHandler(neo4j *services.Neo4jService, ai *services.AIClients) *BankHandler {
 	return &BankHandler{
-		Neo4j: neo4j,
-		AI:    ai,
+		Neo4j:         neo4j,
+		AI:            ai,
+		Geo:           services.NewGeoService(),
+		Calendar:      services.NewBusinessCalendar(),
+		FX:            services.NewFXService(),
+		Watchlists:    services.NewWatchlistService(),
+		Overrides:     services.NewOverrideService(),
+		AccountStatus: services.NewAccountStatusService(neo4j),
+		Holds:         services.NewHoldService(),
+		Reports:       services.NewSTRService(),
+		Auth:          services.NewAuthService(),
+		APIKeys:       services.NewAPIKeyService(),
+		OIDC:          services.NewOIDCService(),
 	}
 }
 
 // processAndSave encapsulates the logic of analyzing and saving a transaction
 // Now includes graph-aware context for intelligent compound scoring
 func (h *BankHandler) processAndSave(txn models.Transaction) (*models.AnalysisResult, error) {
-	// 1. Query receiver's historical context from Neo4j for graph-aware scoring
 	ctx := context.Background()
-	receiverContext, err := h.Neo4j.GetAccountRiskContext(ctx, txn.ReceiverAccount)
+
+	// Normalise to the base currency before anything compares or aggregates amounts
+	unknownCurrency, err := h.FX.Normalize(&txn)
 	if err != nil {
-		// If context query fails, proceed with empty context (graceful degradation)
-		receiverContext = map[string]any{}
+		return nil, err
+	}
+
+	// 0. Resolve accounts to parties when KYC attributes are supplied
+	for account, attrs := range map[string]*models.PartyAttributes{txn.SenderAccount: txn.SenderParty, txn.ReceiverAccount: txn.ReceiverParty} {
+		if attrs == nil {
+			continue
+		}
+		if _, err := h.Neo4j.ResolveParty(ctx, txn.TenantID, account, *attrs); err != nil {
+			log.Printf("Party resolution failed for %s: %v", account, err)
+		}
 	}
 
-	// 2. Analyze with AI (passing graph context for compound scoring)
-	analysis, err := h.AI.AnalyzeTransactionWithContext(txn, receiverContext)
+	// Flag night, weekend and holiday activity in Bangkok business time
+	if txn.Timestamp.IsZero() {
+		txn.Timestamp = time.Now()
+	}
+	flags := h.Calendar.Classify(txn.Timestamp)
+	txn.Calendar = &flags
+
+	// Screen both counterparties against watchlists and sanctions lists
+	hits, err := h.Watchlists.ScreenTransaction(txn)
 	if err != nil {
-		return nil, err
+		log.Printf("Watchlist screening failed for %s: %v", txn.TransactionID, err)
+	}
+
+	// Analyst overrides on the accounts, device or IPs, strictest first
+	overrides, err := h.Overrides.MatchOverrides(txn)
+	if err != nil {
+		log.Printf("Override lookup failed for %s: %v", txn.TransactionID, err)
+	}
+
+	// 1. Query receiver's historical context from Neo4j for graph-aware scoring
+	receiverContext, err := h.Neo4j.GetAccountRiskContext(ctx, txn.TenantID, txn.ReceiverAccount)
+	if err != nil {
+		// If context query fails, proceed with empty context (graceful degradation)
+		receiverContext = map[string]any{}
 	}
+	h.Neo4j.AddTransactionFeatures(ctx, txn, receiverContext)
+	locationEvidence := h.Geo.AddLocationFeatures(txn, receiverContext)
+	h.Calendar.AddActivityFeatures(txn.TenantID, txn.ReceiverAccount, "", receiverContext)
+	h.Calendar.AddActivityFeatures(txn.TenantID, txn.SenderAccount, "sender_", receiverContext)
+	receiverContext["watchlist_hit_count"] = int64(len(hits))
+
+	// 2. Analyze with AI (passing graph context for compound scoring).
+	// An always-block override decides the outcome, so the model is not consulted.
+	var analysis models.AnalysisResult
+	if len(overrides) > 0 && overrides[0].Action == "Block" {
+		analysis = models.AnalysisResult{
+			TransactionID: txn.TransactionID,
+			RiskScore:     100,
+			Reasons:       []string{},
+			Timestamp:     time.Now().Format(time.RFC3339),
+		}
+	} else {
+		analysis, err = h.AI.AnalyzeTransactionWithContext(txn, receiverContext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	analysis.Reasons = append(analysis.Reasons, locationEvidence...)
 
 	// 3. Determine final action
 	if analysis.RiskScore > 80 {
@@ -59,6 +141,45 @@ func (h *BankHandler) processAndSave(txn models.Transaction) (*models.AnalysisRe
 	} else {
 		analysis.Action = "Allow"
 	}
+	// An always-allow replaces the score-based action but does not lift the FX and
+	// watchlist holds below (sanctions hits are cleared by adjudication); always-review
+	// and always-block only ever make the action stricter.
+	for _, ov := range overrides {
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("Override #%d: always %s %s %s (%s, by %s)",
+			ov.OverrideID, ov.Action, ov.EntityType, ov.EntityID, ov.Reason, ov.Author))
+		services.RecordAudit(txn.TransactionID, fmt.Sprintf("OVERRIDE_APPLIED #%d %s:%s %s", ov.OverrideID, ov.EntityType, ov.EntityID, ov.Action))
+	}
+	if len(overrides) > 0 {
+		if overrides[0].Action == "Allow" {
+			analysis.Action = "Allow"
+		} else {
+			analysis.Action = stricterAction(analysis.Action, overrides[0].Action)
+		}
+	}
+	if unknownCurrency && h.FX.UnknownPolicy == services.UnknownCurrencyReview {
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("No FX rate for %s, amount was not converted to %s", txn.OriginalCurrency, h.FX.BaseCurrency))
+		analysis.Action = stricterAction(analysis.Action, "Review")
+	}
+	if action, reason, err := h.AccountStatus.InboundHold(txn.TenantID, txn.ReceiverAccount); err != nil {
+		log.Printf("Account status lookup failed for %s: %v", txn.ReceiverAccount, err)
+	} else if action != "" {
+		analysis.Reasons = append(analysis.Reasons, reason)
+		analysis.Action = stricterAction(analysis.Action, action)
+	}
+	for _, hit := range hits {
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("Watchlist hit: %s %s %q matches %q on %s (score %.2f)",
+			hit.Role, hit.MatchedField, hit.MatchedValue, hit.EntryName, hit.ListName, hit.Score))
+		analysis.Action = stricterAction(analysis.Action, hit.Action)
+	}
+
+	h.raiseAlerts(txn, analysis, hits, receiverContext, locationEvidence)
+
+	// Review means the transfer waits for an analyst (or the SLA timeout)
+	if analysis.Action == "Review" {
+		if _, err := h.Holds.Place(txn, analysis); err != nil {
+			log.Printf("Failed to hold %s: %v", txn.TransactionID, err)
+		}
+	}
 
 	// 4. Save to Neo4j (async for performance)
 	go func() {
@@ -69,22 +190,52 @@ func (h *BankHandler) processAndSave(txn models.Transaction) (*models.AnalysisRe
 	return &analysis, nil
 }
 
+// stricterAction returns whichever of two actions holds the transaction more firmly
+func stricterAction(a, b string) string {
+	rank := map[string]int{"Allow": 0, "Review": 1, "Block": 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
 func (h *BankHandler) IngestTransaction(c *gin.Context) {
 	var txn models.Transaction
 	if err := c.ShouldBindJSON(&txn); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	txn.APIKeyID = ""
+	if key := authAPIKey(c); key != nil {
+		txn.APIKeyID = key.KeyID
+	}
+	// The submitting bank owns the transaction; a transaction ID another bank
+	// already used is refused rather than overwritten
+	txn.TenantID = authTenant(c)
+	if owner, err := services.TransactionTenant(txn.TransactionID); err == nil && owner != "" && owner != txn.TenantID {
+		c.JSON(http.StatusConflict, gin.H{"error": "transaction_id is already in use"})
+		return
+	}
 
 	analysis, err := h.processAndSave(txn)
+	if errors.Is(err, services.ErrUnknownCurrency) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze transaction"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.FraudCheckResponse{
-		AnalysisResult: *analysis,
-	})
+	rec := audit(c)
+	rec.Action, rec.TransactionID = "INGEST", analysis.TransactionID
+	rec.After = gin.H{"action": analysis.Action, "risk_score": analysis.RiskScore, "reasons": analysis.Reasons, "api_key_id": txn.APIKeyID}
+
+	resp := models.FraudCheckResponse{AnalysisResult: *analysis}
+	if analysis.Action == "Review" {
+		resp.Hold, _ = services.GetHold(txn.TenantID, analysis.TransactionID)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *BankHandler) GetGraph(c *gin.Context) {
@@ -100,7 +251,7 @@ func (h *BankHandler) GetGraph(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	data, err := h.Neo4j.GetRecentTransactions(ctx, limit, minRisk)
+	data, err := h.Neo4j.GetRecentTransactions(ctx, authTenant(c), limit, minRisk)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -110,15 +261,367 @@ func (h *BankHandler) GetGraph(c *gin.Context) {
 
 func (h *BankHandler) GetAccountDetails(c *gin.Context) {
 	accountID := c.Param("id")
+	tenant := authTenant(c)
 	ctx := context.Background()
-	data, err := h.Neo4j.GetAccountHistory(ctx, accountID)
+	data, err := h.Neo4j.GetAccountHistory(ctx, tenant, accountID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+
+	// Aggregate across all accounts of the resolved party
+	if party, err := services.GetAccountParty(tenant, accountID); err == nil && party != nil {
+		summary, _ := services.PartyRiskSummary(tenant, party)
+		data["party"] = gin.H{
+			"party_id": party.PartyID,
+			"name":     party.Name,
+			"accounts": party.Accounts,
+			"summary":  summary,
+		}
+	}
+
+	// Mule flagging from confirmed-fraud verdicts
+	if status, err := services.GetAccountStatus(tenant, accountID); err == nil {
+		data["status"] = status
+	}
+	if history, err := services.AccountStatusHistory(tenant, accountID, 50); err == nil {
+		data["status_history"] = history
+	}
+
+	// Overrides currently in force on the account
+	if overrides, err := services.ListOverrides(tenant, "account", accountID, true, 100); err == nil {
+		data["overrides"] = overrides
+	}
 	c.JSON(http.StatusOK, data)
 }
 
+// GetAccountCycles lists round-tripping cycles the account participates in.
+// Query params max_length and window_hours narrow the configured defaults.
+func (h *BankHandler) GetAccountCycles(c *gin.Context) {
+	accountID := c.Param("id")
+	opts := h.Neo4j.Cycles
+	if l := c.Query("max_length"); l != "" {
+		var maxLength int
+		fmt.Sscanf(l, "%d", &maxLength)
+		if maxLength >= 2 && maxLength < opts.MaxLength {
+			opts.MaxLength = maxLength
+		}
+	}
+	if w := c.Query("window_hours"); w != "" {
+		var hours float64
+		fmt.Sscanf(w, "%f", &hours)
+		if window := time.Duration(hours * float64(time.Hour)); window > 0 && window < opts.Window {
+			opts.Window = window
+		}
+	}
+
+	ctx := context.Background()
+	cycles, err := h.Neo4j.FindCycles(ctx, authTenant(c), accountID, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if cycles == nil {
+		cycles = []models.Cycle{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"account_id": accountID,
+		"cycles":     cycles,
+		"count":      len(cycles),
+	})
+}
+
+// GetAccountSubgraph returns the k-hop transfer neighbourhood around an account.
+// Pass the returned frontier as ?from=A,B to continue expanding outward.
+func (h *BankHandler) GetAccountSubgraph(c *gin.Context) {
+	opts := services.SubgraphOptions{Hops: 2, MaxNodes: 200}
+	if v := c.Query("hops"); v != "" {
+		fmt.Sscanf(v, "%d", &opts.Hops)
+	}
+	if opts.Hops < 1 { opts.Hops = 1 }
+	if opts.Hops > 4 { opts.Hops = 4 }
+
+	if v := c.Query("max_nodes"); v != "" {
+		fmt.Sscanf(v, "%d", &opts.MaxNodes)
+	}
+	if opts.MaxNodes < 1 { opts.MaxNodes = 1 }
+	if opts.MaxNodes > 1000 { opts.MaxNodes = 1000 }
+
+	if v := c.Query("min_amount"); v != "" {
+		fmt.Sscanf(v, "%f", &opts.MinAmount)
+	}
+	if v := c.Query("since"); v != "" {
+		since, err := parseTimeParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		opts.Since = since
+	}
+
+	start := []string{c.Param("id")}
+	if from := c.Query("from"); from != "" {
+		start = strings.Split(from, ",")
+	}
+
+	ctx := context.Background()
+	sub, err := h.Neo4j.GetSubgraph(ctx, authTenant(c), start, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// parseTimeParam accepts a full RFC3339 timestamp or a plain date
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// GetMoneyPaths finds how money moved from one account to another.
+// Each hop must happen no earlier than the previous one; k > 1 returns alternatives.
+func (h *BankHandler) GetMoneyPaths(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" || from == to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be two different account IDs"})
+		return
+	}
+
+	opts := services.DefaultPathOptions()
+	if v := c.Query("k"); v != "" {
+		fmt.Sscanf(v, "%d", &opts.K)
+	}
+	if opts.K < 1 { opts.K = 1 }
+	if opts.K > 10 { opts.K = 10 }
+
+	if v := c.Query("max_hops"); v != "" {
+		fmt.Sscanf(v, "%d", &opts.MaxHops)
+	}
+	if opts.MaxHops < 1 { opts.MaxHops = 1 }
+	if opts.MaxHops > 8 { opts.MaxHops = 8 }
+
+	if v := c.Query("since"); v != "" {
+		since, err := parseTimeParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		opts.Since = since
+	}
+
+	ctx := context.Background()
+	paths, err := h.Neo4j.FindMoneyPaths(ctx, authTenant(c), from, to, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from,
+		"to":    to,
+		"paths": paths,
+		"count": len(paths),
+	})
+}
+
+// GetDeviceAccounts lists accounts that have used a device
+func (h *BankHandler) GetDeviceAccounts(c *gin.Context) {
+	h.getLinkedAccounts(c, services.EntityDevice)
+}
+
+// GetIPAccounts lists accounts that have transacted from an IP address
+func (h *BankHandler) GetIPAccounts(c *gin.Context) {
+	h.getLinkedAccounts(c, services.EntityIP)
+}
+
+func (h *BankHandler) getLinkedAccounts(c *gin.Context, entityType string) {
+	entityID := c.Param("id")
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		since = t
+	}
+
+	ctx := context.Background()
+	accounts, err := h.Neo4j.GetLinkedAccounts(ctx, authTenant(c), entityType, entityID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entity_type": entityType,
+		"entity_id":   entityID,
+		"accounts":    accounts,
+		"count":       len(accounts),
+	})
+}
+
+// SetAccountParty resolves an account to a party from KYC attributes
+func (h *BankHandler) SetAccountParty(c *gin.Context) {
+	accountID := c.Param("id")
+	var attrs models.PartyAttributes
+	if err := c.ShouldBindJSON(&attrs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := context.Background()
+	partyID, err := h.Neo4j.ResolveParty(ctx, authTenant(c), accountID, attrs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve party: " + err.Error()})
+		return
+	}
+	if partyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one of national_id_hash, name, phone or promptpay_proxy is required"})
+		return
+	}
+
+	party, err := services.GetParty(authTenant(c), partyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, party)
+}
+
+// GetParty returns a resolved party, its accounts and aggregated risk
+func (h *BankHandler) GetParty(c *gin.Context) {
+	party, err := services.GetParty(authTenant(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Party not found"})
+		return
+	}
+	summary, _ := services.PartyRiskSummary(authTenant(c), party)
+	c.JSON(http.StatusOK, gin.H{
+		"party":   party,
+		"summary": summary,
+	})
+}
+
+// GetCommunities lists detected account clusters with their risk summary
+func (h *BankHandler) GetCommunities(c *gin.Context) {
+	minSize := 2
+	if m := c.Query("min_size"); m != "" {
+		fmt.Sscanf(m, "%d", &minSize)
+	}
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit > 1000 { limit = 1000 }
+
+	communities, err := services.ListCommunities(authTenant(c), minSize, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"communities": communities,
+		"count":       len(communities),
+	})
+}
+
+// RecomputeCommunities runs community detection on the caller's graph immediately
+// instead of waiting for the schedule
+func (h *BankHandler) RecomputeCommunities(c *gin.Context) {
+	count, err := h.Communities.RecomputeTenant(context.Background(), authTenant(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute communities: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "recomputed", "count": count})
+}
+
+// GetHolidays lists the public holidays used for off-hours features
+func (h *BankHandler) GetHolidays(c *gin.Context) {
+	holidays := h.Calendar.Holidays()
+	c.JSON(http.StatusOK, gin.H{"holidays": holidays, "count": len(holidays)})
+}
+
+// UploadHolidays replaces the holiday list with a JSON array of {date, name} or CSV date,name rows.
+// Substitution days are not derived; include them in the upload.
+func (h *BankHandler) UploadHolidays(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holidays, err := services.ParseHolidays(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Calendar.ReplaceHolidays(holidays); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "updated", "count": len(holidays)})
+}
+
+// GetFXRates lists the stored FX rate tables
+func (h *BankHandler) GetFXRates(c *gin.Context) {
+	rates := h.FX.Rates()
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency":  h.FX.BaseCurrency,
+		"unknown_policy": h.FX.UnknownPolicy,
+		"rates":          rates,
+		"count":          len(rates),
+	})
+}
+
+// UploadFXRates upserts a daily rate table, as a JSON array of {currency, effective_date, rate}
+// or CSV currency,effective_date,rate rows. Rates are units of base currency per unit.
+func (h *BankHandler) UploadFXRates(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rates, err := services.ParseFXRates(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.FX.SaveRates(rates, "api"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "updated", "count": len(rates)})
+}
+
+// GetMotifs lists detected fan-in, fan-out and scatter-gather bursts
+func (h *BankHandler) GetMotifs(c *gin.Context) {
+	motifType := strings.ToUpper(c.Query("type"))
+	switch motifType {
+	case "", models.MotifFanIn, models.MotifFanOut, models.MotifScatterGather:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be FAN_IN, FAN_OUT or SCATTER_GATHER"})
+		return
+	}
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 { limit = 1 }
+	if limit > 1000 { limit = 1000 }
+
+	motifs, err := services.ListMotifs(authTenant(c), motifType, c.Query("account"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"motifs": motifs,
+		"count":  len(motifs),
+	})
+}
+
 // --- Test Bench Handlers ---
 
 type GenerateRequest struct {
@@ -137,6 +640,10 @@ func (h *BankHandler) GenerateISO20022Data(c *gin.Context) {
 	if req.Count > 1000 { req.Count = 1000 }
 
 	banks := []string{"SCB", "KBANK", "KTB", "BBL", "BAY", "TTB"}
+	// The operator simulates every bank, each submitting as its own tenant;
+	// a participating bank only generates its own outgoing transfers
+	tenant := authTenant(c)
+	operator := services.IsOperatorTenant(tenant)
 	
 	progressMu.Lock()
 	simProgress = 0
@@ -155,6 +662,9 @@ func (h *BankHandler) GenerateISO20022Data(c *gin.Context) {
 				defer func() { <-sem }()
 
 				sourceBank := banks[rand.Intn(len(banks))]
+				if !operator {
+					sourceBank = tenant
+				}
 				targetBank := banks[rand.Intn(len(banks))]
 				for sourceBank == targetBank {
 					targetBank = banks[rand.Intn(len(banks))]
@@ -203,6 +713,7 @@ func (h *BankHandler) GenerateISO20022Data(c *gin.Context) {
 				}
 
 				txn := models.Transaction{
+					TenantID:        sourceBank,
 					TransactionID:   fmt.Sprintf("TXID-%d-%d", time.Now().UnixNano(), idx),
 					Amount:          math.Round(amount*100) / 100,
 					Currency:        "THB",
@@ -239,8 +750,21 @@ func (h *BankHandler) GenerateISO20022Data(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Started background generation of %d %s transactions", req.Count, req.Scenario)})
 }
 
+// ResetData wipes every tenant's graph and cases, so only the operator may
 func (h *BankHandler) ResetData(c *gin.Context) {
+	if !services.IsOperatorTenant(authTenant(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the operator can reset the platform"})
+		return
+	}
 	ctx := context.Background()
+	rec := audit(c)
+	rec.Action = "RESET"
+	before := currentStats(authTenant(c))
+	var txnCount int
+	_ = db.DB.QueryRow("SELECT count(*) FROM graph_transactions").Scan(&txnCount)
+	before["transactions"] = txnCount
+	rec.Before = before
+
 	// 1. Reset Neo4j
 	err := h.Neo4j.ResetDatabase(ctx)
 	if err != nil {
@@ -249,10 +773,11 @@ func (h *BankHandler) ResetData(c *gin.Context) {
 	}
 
 	// 2. Reset SQLite Stats
-	_, err = db.DB.Exec("UPDATE verification_stats SET checked = 0, false_positives = 0 WHERE id = 1")
+	_, err = db.DB.Exec("UPDATE verification_stats SET checked = 0, false_positives = 0")
 	if err != nil {
 		fmt.Println("Failed to reset stats:", err)
 	}
+	rec.After = currentStats(authTenant(c))
 
 	c.JSON(http.StatusOK, gin.H{"message": "System reset successful (Graph & Stats wiped)"})
 }
@@ -281,22 +806,50 @@ func (h *BankHandler) Login(c *gin.Context) {
         return
     }
 
-    var id int
-    err := db.DB.QueryRow("SELECT id FROM users WHERE username = ? AND password = ?", req.Username, req.Password).Scan(&id)
-    if err != nil {
+    rec := audit(c)
+    rec.Action, rec.Actor = "LOGIN", req.Username
+
+    pair, err := h.Auth.Login(req.Username, req.Password, c.ClientIP())
+    var mfa *services.MFARequiredError
+    switch {
+    case errors.As(err, &mfa):
+        rec.Action = "LOGIN_MFA_CHALLENGE"
+        c.JSON(http.StatusOK, mfa.Challenge)
+        return
+    case errors.Is(err, services.ErrInvalidCredentials):
+        rec.Action = "LOGIN_FAILED"
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
+    case errors.Is(err, services.ErrAccountLocked), errors.Is(err, services.ErrTooManyAttempts):
+        rec.Action = "LOGIN_LOCKED"
+        c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+        return
+    case errors.Is(err, services.ErrPasswordLogin):
+        rec.Action = "LOGIN_FAILED"
+        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        return
+    case errors.Is(err, services.ErrAccountDisabled):
+        rec.Action = "LOGIN_DISABLED"
+        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "token": "valid-token-123",
-        "username": req.Username,
-    })
+    c.JSON(http.StatusOK, pair)
+}
+
+// currentStats reads the tenant's verification counters, zero when missing
+func currentStats(tenant string) gin.H {
+    var checked, falsePositives int
+    _ = db.DB.QueryRow("SELECT checked, false_positives FROM verification_stats WHERE tenant_id = ?", tenant).Scan(&checked, &falsePositives)
+    return gin.H{"checked": checked, "false_positives": falsePositives}
 }
 
 func (h *BankHandler) GetStats(c *gin.Context) {
     var checked, falsePositives int
-    err := db.DB.QueryRow("SELECT checked, false_positives FROM verification_stats WHERE id = 1").Scan(&checked, &falsePositives)
+    err := db.DB.QueryRow("SELECT checked, false_positives FROM verification_stats WHERE tenant_id = ?", authTenant(c)).Scan(&checked, &falsePositives)
     if err != nil {
          c.JSON(http.StatusOK, gin.H{"checked": 0, "false_positives": 0})
         return
@@ -308,31 +861,13 @@ func (h *BankHandler) GetStats(c *gin.Context) {
     })
 }
 
-type UpdateStatsRequest struct {
-    Verdict string `json:"verdict"` // 'FRAUD_CONFIRMED' | 'FALSE_POSITIVE'
-}
-
-func (h *BankHandler) UpdateStats(c *gin.Context) {
-    var req UpdateStatsRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    tx, _ := db.DB.Begin()
-    _, _ = tx.Exec("UPDATE verification_stats SET checked = checked + 1 WHERE id = 1")
-    if req.Verdict == "FALSE_POSITIVE" {
-        _, _ = tx.Exec("UPDATE verification_stats SET false_positives = false_positives + 1 WHERE id = 1")
-    }
-    tx.Commit()
-
-    c.JSON(http.StatusOK, gin.H{"status": "updated"})
-}
-
 type VerifyTransactionRequest struct {
     Verdict string `json:"verdict"` // 'CONFIRMED_FRAUD' | 'FALSE_POSITIVE'
+    Analyst string `json:"analyst"`
 }
 
+// VerifyTransaction proposes a verdict. It is applied by applyVerdict only once
+// a second user with a reviewer role approves it (see ApproveApproval).
 func (h *BankHandler) VerifyTransaction(c *gin.Context) {
     txnID := c.Param("id")
     var req VerifyTransactionRequest
@@ -340,21 +875,88 @@ func (h *BankHandler) VerifyTransaction(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if user := authUser(c); user != "" {
+        req.Analyst = user
+    }
+    if req.Verdict != "CONFIRMED_FRAUD" && req.Verdict != "FALSE_POSITIVE" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "verdict must be CONFIRMED_FRAUD or FALSE_POSITIVE"})
+        return
+    }
+    if req.Analyst == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "analyst is required"})
+        return
+    }
 
-    ctx := context.Background()
-    err := h.Neo4j.UpdateTransactionVerification(ctx, txnID, req.Verdict)
+    rec := audit(c)
+    rec.Action, rec.TransactionID, rec.Actor = "VERIFY", txnID, req.Analyst
+    var status string
+    err := db.DB.QueryRow("SELECT coalesce(verification_status, '') FROM graph_transactions WHERE txn_id = ? AND tenant_id = ?", txnID, authTenant(c)).Scan(&status)
+    if err == sql.ErrNoRows {
+        c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+        return
+    }
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update verification: " + err.Error()})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    rec.Before = gin.H{"verification_status": status}
+
+    approval, err := services.ProposeApproval(authTenant(c), models.ApprovalVerdict, txnID, req, req.Analyst)
+    if errors.Is(err, services.ErrApprovalPending) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    rec.After = gin.H{"verification_status": status, "proposed_verdict": req.Verdict, "approval_id": approval.ApprovalID}
+    c.JSON(http.StatusAccepted, gin.H{"status": "pending_approval", "id": txnID, "verdict": req.Verdict, "approval": approval})
+}
+
+// applyVerdict records an approved verdict on one of tenant's transactions in
+// both stores and the tenant's stats
+func (h *BankHandler) applyVerdict(tenant, txnID string, req VerifyTransactionRequest) (*models.AccountStatusChange, error) {
+    ctx := context.Background()
+    err := h.Neo4j.UpdateTransactionVerification(ctx, tenant, txnID, req.Verdict)
+    if err != nil {
+        return nil, fmt.Errorf("failed to update verification: %w", err)
+    }
 
     // Update SQLite stats
-    tx, _ := db.DB.Begin()
-    _, _ = tx.Exec("UPDATE verification_stats SET checked = checked + 1 WHERE id = 1")
-    if req.Verdict == "FALSE_POSITIVE" {
-        _, _ = tx.Exec("UPDATE verification_stats SET false_positives = false_positives + 1 WHERE id = 1")
+    if err := countVerdict(tenant, req.Verdict); err != nil {
+        return nil, fmt.Errorf("failed to update verification stats: %w", err)
+    }
+
+    // Flag (or clear) the receiving account as a suspected mule
+    statusChange, err := h.AccountStatus.ApplyVerdict(ctx, tenant, txnID, req.Verdict, req.Analyst)
+    if err != nil {
+        log.Printf("Failed to update account status for %s: %v", txnID, err)
     }
-    tx.Commit()
 
-    c.JSON(http.StatusOK, gin.H{"status": "verified", "id": txnID, "verdict": req.Verdict})
+    // Verdicts change the fraud seeds, so re-diffuse risk in the background
+    h.Propagation.Trigger()
+
+    return statusChange, nil
+}
+
+// countVerdict adds a verdict to tenant's verification stats
+func countVerdict(tenant, verdict string) error {
+    tx, err := db.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.Exec("INSERT INTO verification_stats (tenant_id) SELECT ? WHERE NOT EXISTS (SELECT 1 FROM verification_stats WHERE tenant_id = ?)", tenant, tenant); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("UPDATE verification_stats SET checked = checked + 1 WHERE tenant_id = ?", tenant); err != nil {
+        return err
+    }
+    if verdict == "FALSE_POSITIVE" {
+        if _, err := tx.Exec("UPDATE verification_stats SET false_positives = false_positives + 1 WHERE tenant_id = ?", tenant); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
 }
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/services"
+)
+
+func TestCountVerdict(t *testing.T) {
+	tenant, err := services.CreateTenant(fmt.Sprintf("T_%d", time.Now().UnixNano()%1e9), "Test Bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, verdict := range []string{"CONFIRMED_FRAUD", "FALSE_POSITIVE", "CONFIRMED_FRAUD"} {
+		if err := countVerdict(tenant.TenantID, verdict); err != nil {
+			t.Fatalf("countVerdict(%s): %v", verdict, err)
+		}
+	}
+	var checked, falsePositives int
+	err = db.DB.QueryRow("SELECT checked, false_positives FROM verification_stats WHERE tenant_id = ?", tenant.TenantID).Scan(&checked, &falsePositives)
+	if err != nil || checked != 3 || falsePositives != 1 {
+		t.Errorf("stats = %d checked, %d false positives, %v; want 3 and 1", checked, falsePositives, err)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetHolds lists holds oldest first, filtered by ?status= and ?older_than=
+// (a duration such as 15m: only holds waiting at least that long)
+func (h *BankHandler) GetHolds(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	var olderThan time.Duration
+	if v := c.Query("older_than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "older_than must be a duration such as 15m"})
+			return
+		}
+		olderThan = d
+	}
+
+	holds, err := services.ListHolds(authTenant(c), c.Query("status"), olderThan, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"holds": holds, "count": len(holds)})
+}
+
+// GetHold returns the hold on one transaction
+func (h *BankHandler) GetHold(c *gin.Context) {
+	hold, err := services.GetHold(authTenant(c), c.Param("id"))
+	if errors.Is(err, services.ErrHoldNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+type HoldDecisionRequest struct {
+	Analyst string `json:"analyst"`
+	Note    string `json:"note"`
+}
+
+// ReleaseHold lets a held transaction through
+func (h *BankHandler) ReleaseHold(c *gin.Context) {
+	h.decideHold(c, models.HoldReleased)
+}
+
+// RejectHold stops a held transaction
+func (h *BankHandler) RejectHold(c *gin.Context) {
+	h.decideHold(c, models.HoldRejected)
+}
+
+func (h *BankHandler) decideHold(c *gin.Context, status string) {
+	var req HoldDecisionRequest
+	_ = c.ShouldBindJSON(&req)
+	if user := authUser(c); user != "" {
+		req.Analyst = user
+	}
+	if req.Analyst == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "analyst is required"})
+		return
+	}
+
+	rec := audit(c)
+	rec.Action, rec.TransactionID, rec.Actor = "HOLD_DECISION", c.Param("id"), req.Analyst
+	if before, err := services.GetHold(authTenant(c), c.Param("id")); err == nil {
+		rec.Before = before
+	}
+	hold, err := h.Holds.Decide(authTenant(c), c.Param("id"), status, req.Analyst, req.Note)
+	if errors.Is(err, services.ErrHoldNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrHoldDecided) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "hold": hold})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rec.After = hold
+	c.JSON(http.StatusOK, hold)
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"bank-fraud-demo/db"
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain runs the package's tests against a fresh SQLite database in a
+// temporary directory, since db.InitDB opens ./data/synapse.db
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "synapse-api-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	db.InitDB()
+	code := m.Run()
+	db.DB.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
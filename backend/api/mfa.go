@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"` // TOTP code or recovery code
+}
+
+// LoginMFA completes a login that answered with mfa_required
+func (h *BankHandler) LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Actor = "LOGIN_MFA", h.Auth.MFA.ChallengeUsername(req.MFAToken)
+	pair, err := h.Auth.CompleteMFA(req.MFAToken, req.Code)
+	if err != nil {
+		rec.Action = "LOGIN_MFA_FAILED"
+		mfaError(c, err)
+		return
+	}
+	if len(pair.RecoveryCodes) > 0 {
+		rec.Action = "MFA_ENROLLED"
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// LoginMFAEnroll returns a new TOTP secret for a user whose role requires MFA
+// and who is enrolling during login
+func (h *BankHandler) LoginMFAEnroll(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token is required"})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Actor = "MFA_ENROLL_STARTED", h.Auth.MFA.ChallengeUsername(req.MFAToken)
+	enrollment, err := h.Auth.EnrollMFA(req.MFAToken)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// mfaUser is the signed-in user for self-service MFA; API keys have none
+func mfaUser(c *gin.Context) (string, bool) {
+	if authAPIKey(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "api keys cannot use multi-factor authentication"})
+		return "", false
+	}
+	return authUser(c), true
+}
+
+// GetMFA returns the signed-in user's MFA status
+func (h *BankHandler) GetMFA(c *gin.Context) {
+	username, ok := mfaUser(c)
+	if !ok {
+		return
+	}
+	status, err := h.Auth.MFA.Status(username, c.GetString(roleKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollMFA starts TOTP enrolment for the signed-in user
+func (h *BankHandler) EnrollMFA(c *gin.Context) {
+	username, ok := mfaUser(c)
+	if !ok {
+		return
+	}
+	rec := audit(c)
+	rec.Action = "MFA_ENROLL_STARTED"
+	enrollment, err := h.Auth.MFA.Enroll(username)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// ConfirmMFA activates the enrolled secret and returns the recovery codes
+func (h *BankHandler) ConfirmMFA(c *gin.Context) {
+	username, ok := mfaUser(c)
+	if !ok {
+		return
+	}
+	var req MFACodeRequest
+	_ = c.ShouldBindJSON(&req)
+	rec := audit(c)
+	rec.Action = "MFA_ENROLLED"
+	codes, err := h.Auth.MFA.Confirm(username, req.Code)
+	if err != nil {
+		rec.Action = "MFA_CONFIRM_FAILED"
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "enabled", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the signed-in user's recovery codes
+func (h *BankHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	username, ok := mfaUser(c)
+	if !ok {
+		return
+	}
+	var req MFACodeRequest
+	_ = c.ShouldBindJSON(&req)
+	rec := audit(c)
+	rec.Action = "MFA_RECOVERY_CODES_REGENERATED"
+	codes, err := h.Auth.MFA.RegenerateRecoveryCodes(username, req.Code)
+	if err != nil {
+		rec.Action = "MFA_RECOVERY_CODES_FAILED"
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA turns MFA off for the signed-in user when their role allows it
+func (h *BankHandler) DisableMFA(c *gin.Context) {
+	username, ok := mfaUser(c)
+	if !ok {
+		return
+	}
+	var req MFACodeRequest
+	_ = c.ShouldBindJSON(&req)
+	rec := audit(c)
+	rec.Action = "MFA_DISABLED"
+	if err := h.Auth.MFA.Disable(username, c.GetString(roleKey), req.Code); err != nil {
+		rec.Action = "MFA_DISABLE_FAILED"
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "disabled"})
+}
+
+// ResetUserMFA removes another user's MFA so they can enrol again, for
+// example after losing their phone. Their sessions are ended.
+func (h *BankHandler) ResetUserMFA(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+	rec := audit(c)
+	rec.Action = "MFA_RESET"
+	if before, err := h.Auth.MFA.Status(user.Username, user.Role); err == nil {
+		rec.Before = before
+	}
+	removed, err := services.ResetMFA(user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	revoked, err := services.RevokeUserSessions(user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	after, _ := h.Auth.MFA.Status(user.Username, user.Role)
+	rec.After = after
+	c.JSON(http.StatusOK, gin.H{"username": user.Username, "mfa_removed": removed, "revoked_sessions": revoked, "mfa": after})
+}
+
+func mfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMFAInvalidCode), errors.Is(err, services.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequiredByRole), errors.Is(err, services.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFANotEnrolled), errors.Is(err, services.ErrMFAAlreadyEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAccountLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuthMethods tells the login page which sign-in options to offer
+func (h *BankHandler) GetAuthMethods(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"password": h.Auth.PasswordLogin,
+		"sso": gin.H{
+			"enabled":   h.OIDC.Enabled(),
+			"name":      h.OIDC.ProviderName,
+			"login_url": "/api/oidc/login",
+		},
+	})
+}
+
+// oidcStateCookie holds the signed state of a sign-on in progress, so the
+// callback only completes in the browser that started it
+const oidcStateCookie = "synapse_oidc_state"
+
+// OIDCLogin sends the browser to the identity provider
+func (h *BankHandler) OIDCLogin(c *gin.Context) {
+	target, state, err := h.OIDC.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		h.finishSSO(c, url.Values{"error": {err.Error()}})
+		return
+	}
+	h.setStateCookie(c, state, int(h.OIDC.LoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, target)
+}
+
+// setStateCookie sets the state cookie, or clears it with maxAge < 0. It is
+// Lax so that it survives the top-level redirect back from the provider.
+func (h *BankHandler) setStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCCallback is where the identity provider returns the browser. It opens a
+// session and hands the tokens to the front end in the URL fragment of
+// PostLoginURL, which never reaches a server log.
+func (h *BankHandler) OIDCCallback(c *gin.Context) {
+	entry := models.AuditEntry{
+		Action:    "LOGIN_SSO_FAILED",
+		Actor:     "anonymous",
+		IP:        c.ClientIP(),
+		RequestID: c.GetString(requestIDKey),
+	}
+	fail := func(status int, msg string, after any) {
+		entry.Status, entry.After = status, services.AuditState(gin.H{"error": msg, "identity": after})
+		services.WriteAudit(entry)
+		h.finishSSO(c, url.Values{"error": {msg}})
+	}
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "", -1)
+	if e := c.Query("error"); e != "" {
+		fail(http.StatusUnauthorized, "identity provider: "+e+" "+c.Query("error_description"), nil)
+		return
+	}
+	identity, err := h.OIDC.Exchange(c.Request.Context(), cookie, c.Query("state"), c.Query("code"))
+	if identity != nil {
+		entry.Actor = identity.Username
+	}
+	if err != nil {
+		fail(http.StatusUnauthorized, err.Error(), identity)
+		return
+	}
+	user, err := services.ProvisionExternalUser(identity, h.OIDC.Tenant, "oidc")
+	if errors.Is(err, services.ErrAccountDisabled) {
+		fail(http.StatusForbidden, err.Error(), identity)
+		return
+	}
+	if errors.Is(err, services.ErrUserExists) {
+		fail(http.StatusConflict, "username "+identity.Username+" belongs to another account", identity)
+		return
+	}
+	if err != nil {
+		fail(http.StatusInternalServerError, err.Error(), identity)
+		return
+	}
+	pair, err := h.Auth.LoginExternal(*user, c.ClientIP())
+	if err != nil {
+		fail(http.StatusInternalServerError, err.Error(), identity)
+		return
+	}
+
+	entry.Action, entry.Status, entry.After = "LOGIN_SSO", http.StatusOK, services.AuditState(identity)
+	services.WriteAudit(entry)
+	h.finishSSO(c, url.Values{
+		"token":         {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+		"username":      {pair.Username},
+		"role":          {pair.Role},
+	})
+}
+
+func (h *BankHandler) finishSSO(c *gin.Context, fragment url.Values) {
+	c.Redirect(http.StatusFound, h.OIDC.PostLoginURL+"#"+fragment.Encode())
+}
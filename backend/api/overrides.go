@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetOverrides lists overrides, filtered by ?entity_type= and ?entity_id=.
+// Only those in force are returned unless ?all=true.
+func (h *BankHandler) GetOverrides(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	overrides, err := services.ListOverrides(authTenant(c), c.Query("entity_type"), c.Query("entity_id"), c.Query("all") != "true", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides, "count": len(overrides)})
+}
+
+// CreateOverride proposes an always allow/review/block override on an account,
+// device or IP. It takes effect once a reviewer approves it.
+func (h *BankHandler) CreateOverride(c *gin.Context) {
+	var req models.Override
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.Author = user
+	}
+	req.TenantID = authTenant(c)
+	override, err := services.ValidateOverride(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Actor = "OVERRIDE_PROPOSED", override.Author
+	approval, err := services.ProposeApproval(authTenant(c), models.ApprovalOverride, override.EntityType+":"+override.EntityID, override, override.Author)
+	if err == nil {
+		rec.After = approval
+	}
+	proposalResponse(c, approval, err)
+}
+
+type RevokeOverrideRequest struct {
+	RevokedBy string `json:"revoked_by"`
+}
+
+// RevokeOverride proposes ending an override before its expiry
+func (h *BankHandler) RevokeOverride(c *gin.Context) {
+	var id int64
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid override id"})
+		return
+	}
+	var req RevokeOverrideRequest
+	_ = c.ShouldBindJSON(&req)
+	if user := authUser(c); user != "" {
+		req.RevokedBy = user
+	}
+	if req.RevokedBy == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "revoked_by is required"})
+		return
+	}
+
+	override, err := services.GetOverride(authTenant(c), id)
+	if errors.Is(err, services.ErrOverrideNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if override.Status != models.OverrideActive {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrOverrideInactive.Error(), "override": override})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Actor, rec.Before = "OVERRIDE_REVOKE_PROPOSED", req.RevokedBy, override
+	approval, err := services.ProposeApproval(authTenant(c), models.ApprovalOverrideRevoke, fmt.Sprint(id), req, req.RevokedBy)
+	if err == nil {
+		rec.After = approval
+	}
+	proposalResponse(c, approval, err)
+}
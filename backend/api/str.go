@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateSTR files a new version of the AMLO suspicious transaction report for
+// a case or a confirmed transaction. Missing required fields are returned as
+// a list of problems and nothing is stored.
+func (h *BankHandler) GenerateSTR(c *gin.Context) {
+	var req services.STRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.Analyst = user
+	}
+	audit(c).Actor = req.Analyst
+	report, err := h.Reports.GenerateSTR(authTenant(c), req)
+	var invalid *services.STRValidationError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "STR report is incomplete", "problems": invalid.Problems})
+	case errors.Is(err, services.ErrCaseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+// GetSTRs lists stored report versions, filtered by ?case_id=, ?transaction_id= and ?report_id=
+func (h *BankHandler) GetSTRs(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	reports, err := services.ListSTRs(authTenant(c), c.Query("case_id"), c.Query("transaction_id"), c.Query("report_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports, "count": len(reports)})
+}
+
+// GetSTR returns a report, the latest version unless ?version= is given, as
+// ?format=json (default), xml or html for printing
+func (h *BankHandler) GetSTR(c *gin.Context) {
+	version := 0
+	if v := c.Query("version"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &version); err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+	}
+	report, err := services.GetSTR(authTenant(c), c.Param("id"), version)
+	if errors.Is(err, services.ErrSTRNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-v%d", report.ReportID, report.Version)
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		body, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename+".json"))
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	case "xml":
+		body, err := xml.MarshalIndent(report, "", "  ")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename+".xml"))
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), body...))
+	case "html":
+		var buf bytes.Buffer
+		if err := services.RenderSTRHTML(&buf, report); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, xml or html"})
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// requestedTenant resolves the tenant_id an admin asked for: empty means the
+// caller's own, and only the operator may name another. On refusal it writes
+// the response and returns false.
+func requestedTenant(c *gin.Context, tenantID string) (string, bool) {
+	tenantID = strings.ToUpper(strings.TrimSpace(tenantID))
+	if tenantID == "" || tenantID == authTenant(c) {
+		return authTenant(c), true
+	}
+	if !inAdminScope(c, tenantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the operator can act for another tenant"})
+		return "", false
+	}
+	return tenantID, true
+}
+
+// GetTenants lists the participating banks
+func (h *BankHandler) GetTenants(c *gin.Context) {
+	tenants, err := services.ListTenants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants, "count": len(tenants), "tenant_id": authTenant(c)})
+}
+
+type CreateTenantRequest struct {
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+}
+
+// CreateTenant registers a participating bank. Only the operator can.
+func (h *BankHandler) CreateTenant(c *gin.Context) {
+	if !services.IsOperatorTenant(authTenant(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the operator can add tenants"})
+		return
+	}
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tenant, err := services.CreateTenant(req.TenantID, req.Name)
+	if errors.Is(err, services.ErrTenantExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.After = "TENANT_CREATED", tenant
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// RequireOperator limits a route to the operator tenant. Watchlists, FX
+// rates, holidays, detection rules and the batch jobs over every bank's graph
+// are shared by all tenants, so no single participating bank may change them.
+// It runs after RequireAuth.
+func (h *BankHandler) RequireOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.IsOperatorTenant(authTenant(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only the operator can change settings shared by every bank"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// consortiumScope is the bank the consortium view is seen from: the caller's,
+// whose own data is left out of the signals, or every bank ("") for the
+// operator, which runs the platform
+func consortiumScope(c *gin.Context) string {
+	if services.IsOperatorTenant(authTenant(c)) {
+		return ""
+	}
+	return authTenant(c)
+}
+
+// consortiumFloor is the fewest other banks an account must have been seen
+// by for the caller: ConsortiumMinReporters, or 1 for the operator only
+func consortiumFloor(c *gin.Context) int {
+	if consortiumScope(c) == "" {
+		return 1
+	}
+	return services.ConsortiumMinReporters
+}
+
+// GetConsortiumAccounts is the cross-bank view: accounts the caller has seen
+// that at least ?min_reporters= other banks have too (default and, for
+// participating banks, minimum ConsortiumMinReporters), with only their
+// shared risk signals
+func (h *BankHandler) GetConsortiumAccounts(c *gin.Context) {
+	minReporters := services.ConsortiumMinReporters
+	if m := c.Query("min_reporters"); m != "" {
+		fmt.Sscanf(m, "%d", &minReporters)
+	}
+	if floor := consortiumFloor(c); minReporters < floor {
+		minReporters = floor
+	}
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	accounts, err := services.ConsortiumAccounts(consortiumScope(c), minReporters, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts, "count": len(accounts)})
+}
+
+// GetConsortiumAccount returns the shared risk signals for one account. An
+// account the caller has not seen, or too few other banks have, is not
+// found, as if no bank had.
+func (h *BankHandler) GetConsortiumAccount(c *gin.Context) {
+	account, err := services.GetConsortiumAccount(consortiumScope(c), c.Param("id"), consortiumFloor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if account == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "too few participating banks have seen this account"})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// asTenant is middleware standing in for RequireAuth with a caller of tenant
+func asTenant(tenant string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(tenantKey, tenant)
+		c.Next()
+	}
+}
+
+func TestRequireOperator(t *testing.T) {
+	tests := []struct {
+		tenant string
+		want   int
+	}{
+		{services.DefaultTenant, http.StatusOK},
+		{"SCB", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			h := &BankHandler{}
+			r := gin.New()
+			r.POST("/fx/rates", asTenant(tt.tenant), h.RequireOperator(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/fx/rates", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsortiumScope(t *testing.T) {
+	tests := []struct {
+		tenant    string
+		wantScope string
+		wantFloor int
+	}{
+		{services.DefaultTenant, "", 1},
+		{"SCB", "SCB", services.ConsortiumMinReporters},
+		{"NOPE", "NOPE", services.ConsortiumMinReporters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set(tenantKey, tt.tenant)
+			if got := consortiumScope(c); got != tt.wantScope {
+				t.Errorf("consortiumScope = %q, want %q", got, tt.wantScope)
+			}
+			if got := consortiumFloor(c); got != tt.wantFloor {
+				t.Errorf("consortiumFloor = %d, want %d", got, tt.wantFloor)
+			}
+		})
+	}
+}
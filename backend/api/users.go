@@ -0,0 +1,181 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects signed-in users whose role does not grant the
+// permission, and API keys holding none of the scopes. Without scopes the
+// routes are closed to API keys. It runs after RequireAuth.
+func (h *BankHandler) RequirePermission(permission string, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := authAPIKey(c); key != nil {
+			if len(scopes) == 0 {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api keys cannot call this endpoint"})
+				return
+			}
+			if !services.HasScope(key, scopes...) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks the required scope", "required_scopes": scopes})
+				return
+			}
+			c.Next()
+			return
+		}
+		if !services.HasPermission(c.GetString(roleKey), permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "required_permission": permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetRoles lists roles and their permissions
+func (h *BankHandler) GetRoles(c *gin.Context) {
+	roles, err := services.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles, "count": len(roles)})
+}
+
+// GetUsers lists the users the caller administers
+func (h *BankHandler) GetUsers(c *gin.Context) {
+	users, err := services.ListUsers(adminScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "count": len(users)})
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	TenantID string `json:"tenant_id"` // operator only; defaults to the caller's tenant
+}
+
+// CreateUser adds a user with a role
+func (h *BankHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username, password and role are required"})
+		return
+	}
+	tenant, ok := requestedTenant(c, req.TenantID)
+	if !ok {
+		return
+	}
+	user, err := services.CreateUser(req.Username, req.Password, req.Role, tenant)
+	if errors.Is(err, services.ErrTenantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUserExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.After = "USER_CREATED", user
+	c.JSON(http.StatusCreated, user)
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// SetUserRole assigns a user's role. Admins cannot change their own role, so
+// the last admin cannot lock everyone out by accident.
+func (h *BankHandler) SetUserRole(c *gin.Context) {
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+		return
+	}
+	before, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+	user, err := services.SetUserRole(before.ID, req.Role)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Before, rec.After = "USER_ROLE_CHANGED", before, user
+	c.JSON(http.StatusOK, user)
+}
+
+// DisableUser blocks a user from signing in and ends their sessions
+func (h *BankHandler) DisableUser(c *gin.Context) {
+	h.setUserDisabled(c, true)
+}
+
+// EnableUser lets a disabled user sign in again
+func (h *BankHandler) EnableUser(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+func (h *BankHandler) setUserDisabled(c *gin.Context, disabled bool) {
+	before, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+	user, err := services.SetUserDisabled(before.ID, disabled)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+	rec := audit(c)
+	rec.Action, rec.Before, rec.After = "USER_ENABLED", before, user
+	if disabled {
+		rec.Action = "USER_DISABLED"
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// targetUser loads the user named by :id for a change, refusing changes to
+// the caller's own account. Users of other tenants are not found unless the
+// caller is the operator.
+func (h *BankHandler) targetUser(c *gin.Context) (*models.User, bool) {
+	var id int64
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return nil, false
+	}
+	user, err := services.GetUser(id)
+	if err == nil && !inAdminScope(c, user.TenantID) {
+		err = services.ErrUserNotFound
+	}
+	if err != nil {
+		userError(c, err)
+		return nil, false
+	}
+	if user.Username == authUser(c) {
+		c.JSON(http.StatusConflict, gin.H{"error": "you cannot change your own role or status"})
+		return nil, false
+	}
+	return user, true
+}
+
+func userError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"bank-fraud-demo/models"
+	"bank-fraud-demo/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetWatchlists lists the screening lists with their entry counts
+func (h *BankHandler) GetWatchlists(c *gin.Context) {
+	lists := h.Watchlists.Watchlists()
+	c.JSON(http.StatusOK, gin.H{"watchlists": lists, "count": len(lists)})
+}
+
+// CreateWatchlist adds an empty list; entries are imported separately
+func (h *BankHandler) CreateWatchlist(c *gin.Context) {
+	var req models.Watchlist
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, err := h.Watchlists.CreateWatchlist(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c).After = list
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteWatchlist removes a list and its entries
+func (h *BankHandler) DeleteWatchlist(c *gin.Context) {
+	if err := h.Watchlists.DeleteWatchlist(c.Param("id")); err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "list_id": c.Param("id")})
+}
+
+// GetWatchlistEntries lists the entries of a list
+func (h *BankHandler) GetWatchlistEntries(c *gin.Context) {
+	limit := 500
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 10000 {
+		limit = 10000
+	}
+
+	entries, err := h.Watchlists.Entries(c.Param("id"), limit)
+	if err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "count": len(entries)})
+}
+
+// ImportWatchlist loads entries from a CSV or XML body (see services.ParseWatchlistEntries).
+// ?replace=true swaps the list's contents instead of appending.
+func (h *BankHandler) ImportWatchlist(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := services.ParseWatchlistEntries(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list file: " + err.Error()})
+		return
+	}
+	if err := h.Watchlists.AddEntries(c.Param("id"), entries, c.Query("replace") == "true"); err != nil {
+		watchlistError(c, err)
+		return
+	}
+	audit(c).After = gin.H{"list_id": c.Param("id"), "imported": len(entries), "replaced": c.Query("replace") == "true"}
+	c.JSON(http.StatusOK, gin.H{"status": "imported", "list_id": c.Param("id"), "count": len(entries)})
+}
+
+// AddWatchlistEntry adds a single entry to a list
+func (h *BankHandler) AddWatchlistEntry(c *gin.Context) {
+	var entry models.WatchlistEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Watchlists.AddEntries(c.Param("id"), []models.WatchlistEntry{entry}, false); err != nil {
+		watchlistError(c, err)
+		return
+	}
+	audit(c).After = entry
+	c.JSON(http.StatusOK, gin.H{"status": "added", "list_id": c.Param("id")})
+}
+
+// DeleteWatchlistEntry removes one entry from a list
+func (h *BankHandler) DeleteWatchlistEntry(c *gin.Context) {
+	var entryID int64
+	if _, err := fmt.Sscanf(c.Param("entry_id"), "%d", &entryID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entry id"})
+		return
+	}
+	if err := h.Watchlists.DeleteEntry(c.Param("id"), entryID); err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "entry_id": entryID})
+}
+
+// ScreenSubject screens an ad-hoc name, account or proxy without recording hits
+func (h *BankHandler) ScreenSubject(c *gin.Context) {
+	var subject services.ScreeningSubject
+	if err := c.ShouldBindJSON(&subject); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	matches := h.Watchlists.Screen(subject)
+	c.JSON(http.StatusOK, gin.H{"matches": matches, "count": len(matches)})
+}
+
+// GetWatchlistHits lists recorded hits, filtered by ?status= and ?account=
+func (h *BankHandler) GetWatchlistHits(c *gin.Context) {
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	hits, err := services.ListWatchlistHits(authTenant(c), strings.ToUpper(c.Query("status")), c.Query("account"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hits": hits, "count": len(hits)})
+}
+
+type AdjudicateHitRequest struct {
+	Status  string `json:"status"` // CONFIRMED | DISMISSED
+	Analyst string `json:"analyst"`
+	Note    string `json:"note"`
+}
+
+// AdjudicateWatchlistHit confirms or dismisses a hit
+func (h *BankHandler) AdjudicateWatchlistHit(c *gin.Context) {
+	var hitID int64
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &hitID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hit id"})
+		return
+	}
+	var req AdjudicateHitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user := authUser(c); user != "" {
+		req.Analyst = user
+	}
+	if strings.TrimSpace(req.Analyst) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "analyst is required"})
+		return
+	}
+
+	audit(c).Actor = req.Analyst
+	hit, err := services.AdjudicateHit(authTenant(c), hitID, strings.ToUpper(req.Status), req.Analyst, req.Note)
+	if errors.Is(err, services.ErrHitNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hit)
+}
+
+func watchlistError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrWatchlistNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
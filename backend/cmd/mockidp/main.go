@@ -0,0 +1,280 @@
+// Command mockidp is a minimal OpenID Connect provider for trying the Synapse
+// single sign-on locally. It serves discovery, a login page listing a few
+// demo users, a token endpoint that enforces PKCE, and the JWKS of an RSA key
+// generated at startup. Never expose it beyond localhost.
+//
+//	go run ./cmd/mockidp
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=synapse \
+//	OIDC_REDIRECT_URL=http://localhost:8080/api/oidc/callback \
+//	OIDC_GROUP_ROLES=synapse-admins=admin,fraud-supervisors=supervisor,fraud-analysts=analyst \
+//	go run .
+//
+// Adding login_hint=<email> to the authorize URL skips the login page, which
+// lets the flow be scripted with curl.
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type user struct {
+	Email  string
+	Name   string
+	Groups []string
+}
+
+var users = []user{
+	{"alice@bank.example", "Alice Analyst", []string{"fraud-analysts"}},
+	{"sam@bank.example", "Sam Supervisor", []string{"fraud-supervisors", "fraud-analysts"}},
+	{"root@bank.example", "Ada Admin", []string{"synapse-admins"}},
+	{"eve@bank.example", "Eve Marketing", []string{"marketing"}},
+}
+
+// grant is an issued authorization code waiting to be redeemed
+type grant struct {
+	user        user
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	expires     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	kid          string
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, as the backend reaches it")
+	clientID := flag.String("client-id", "synapse", "the only client accepted")
+	clientSecret := flag.String("client-secret", "", "client secret; empty accepts a public client")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := &provider{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		kid:          randomString(8),
+		codes:        map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	log.Printf("Mock OIDC provider %s listening on %s (client %q)", p.issuer, *addr, p.clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+	})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock identity provider</title>
+<body style="font-family: sans-serif; max-width: 32em; margin: 4em auto">
+<h2>Mock identity provider</h2>
+<p>Sign in to <b>{{.ClientID}}</b> as:</p>
+<form method="post">
+{{range $k, $v := .Query}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+{{range .Users}}<p><button name="login_hint" value="{{.Email}}">{{.Name}}</button> {{.Email}} <small>{{.Groups}}</small></p>{{end}}
+</form>`))
+
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != p.clientID || redirectURI == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	reply := target.Query()
+	reply.Set("state", q.Get("state"))
+	if q.Get("response_type") != "code" || q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		reply.Set("error", "invalid_request")
+		reply.Set("error_description", "authorization code with S256 PKCE is required")
+		target.RawQuery = reply.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	var chosen *user
+	for i := range users {
+		if users[i].Email == q.Get("login_hint") {
+			chosen = &users[i]
+		}
+	}
+	if chosen == nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = loginPage.Execute(w, map[string]any{"ClientID": p.clientID, "Query": q, "Users": users})
+		return
+	}
+
+	code := randomString(16)
+	p.mu.Lock()
+	p.codes[code] = grant{
+		user:        *chosen,
+		clientID:    p.clientID,
+		redirectURI: redirectURI,
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+	reply.Set("code", code)
+	target.RawQuery = reply.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		tokenError(w, "invalid_request", "POST a form")
+		return
+	}
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(secret), []byte(p.clientSecret)) != 1 {
+		tokenError(w, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	p.mu.Lock()
+	g, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok || time.Now().After(g.expires) || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, expired or reused code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	idToken, err := p.sign(map[string]any{
+		"iss":                p.issuer,
+		"sub":                "mock|" + g.user.Email,
+		"aud":                g.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"email":              g.user.Email,
+		"email_verified":     true,
+		"name":               g.user.Name,
+		"preferred_username": g.user.Email,
+		"groups":             g.user.Groups,
+	})
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(16),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": p.kid,
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// sign returns an RS256 JWT
+func (p *provider) sign(claims map[string]any) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": p.kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,12 +1,16 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"log"
 	"os"
+	"strings"
     "fmt"
 
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var DB *sql.DB
@@ -22,6 +26,7 @@ func InitDB() {
 
 	createTables()
     seedData()
+    migratePasswords()
 }
 
 func createTables() {
@@ -42,6 +47,10 @@ func createTables() {
             action TEXT,
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
+        `CREATE TRIGGER IF NOT EXISTS audit_logs_no_update BEFORE UPDATE ON audit_logs
+            BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END;`,
+        `CREATE TRIGGER IF NOT EXISTS audit_logs_no_delete BEFORE DELETE ON audit_logs
+            BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END;`,
         `CREATE TABLE IF NOT EXISTS graph_transactions (
             txn_id TEXT PRIMARY KEY,
             sender_account TEXT,
@@ -55,17 +64,487 @@ func createTables() {
         );`,
         `CREATE INDEX IF NOT EXISTS idx_graph_sender ON graph_transactions(sender_account);`,
         `CREATE INDEX IF NOT EXISTS idx_graph_receiver ON graph_transactions(receiver_account);`,
+        `CREATE TABLE IF NOT EXISTS communities (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            community_id TEXT NOT NULL,
+            size INTEGER,
+            volume REAL,
+            txn_count INTEGER,
+            fraud_confirmed_count INTEGER,
+            avg_risk REAL,
+            updated_at DATETIME,
+            PRIMARY KEY (tenant_id, community_id)
+        );`,
+        `CREATE TABLE IF NOT EXISTS account_communities (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            account_id TEXT NOT NULL,
+            community_id TEXT,
+            updated_at DATETIME,
+            PRIMARY KEY (tenant_id, account_id)
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_account_communities_community ON account_communities(tenant_id, community_id);`,
+        `CREATE TABLE IF NOT EXISTS account_entities (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            entity_type TEXT NOT NULL,
+            entity_id TEXT NOT NULL,
+            account_id TEXT NOT NULL,
+            first_seen DATETIME,
+            last_seen DATETIME,
+            txn_count INTEGER DEFAULT 0,
+            PRIMARY KEY (tenant_id, entity_type, entity_id, account_id)
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_account_entities_account ON account_entities(tenant_id, account_id);`,
+        `CREATE TABLE IF NOT EXISTS parties (
+            party_id TEXT PRIMARY KEY,
+            national_id_hash TEXT,
+            name TEXT,
+            normalized_name TEXT,
+            phone TEXT,
+            promptpay_proxy TEXT,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_parties_national_id ON parties(national_id_hash);`,
+        `CREATE INDEX IF NOT EXISTS idx_parties_phone ON parties(phone);`,
+        `CREATE INDEX IF NOT EXISTS idx_parties_promptpay ON parties(promptpay_proxy);`,
+        `CREATE TABLE IF NOT EXISTS party_name_tokens (
+            token TEXT NOT NULL,
+            party_id TEXT NOT NULL,
+            normalized_name TEXT NOT NULL,
+            PRIMARY KEY (token, party_id, normalized_name)
+        );`,
+        `CREATE TABLE IF NOT EXISTS party_accounts (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            account_id TEXT NOT NULL,
+            party_id TEXT NOT NULL,
+            match_rule TEXT,
+            confidence REAL,
+            linked_at DATETIME,
+            PRIMARY KEY (tenant_id, account_id)
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_party_accounts_party ON party_accounts(party_id);`,
+        `CREATE TABLE IF NOT EXISTS motifs (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            motif_type TEXT NOT NULL,
+            center_account TEXT NOT NULL,
+            source_account TEXT,
+            participants TEXT,
+            txn_ids TEXT,
+            txn_count INTEGER,
+            total_amount REAL,
+            started_at DATETIME,
+            ended_at DATETIME,
+            detected_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_motifs_center ON motifs(center_account, motif_type);`,
+        `CREATE TABLE IF NOT EXISTS last_locations (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            subject_type TEXT NOT NULL,
+            subject_id TEXT NOT NULL,
+            country TEXT,
+            city TEXT,
+            latitude REAL,
+            longitude REAL,
+            source TEXT,
+            txn_id TEXT,
+            seen_at DATETIME,
+            PRIMARY KEY (tenant_id, subject_type, subject_id)
+        );`,
+        `CREATE TABLE IF NOT EXISTS holidays (
+            date TEXT PRIMARY KEY,
+            name TEXT
+        );`,
+        `CREATE TABLE IF NOT EXISTS fx_rates (
+            currency TEXT NOT NULL,
+            effective_date TEXT NOT NULL,
+            rate REAL NOT NULL,
+            source TEXT,
+            uploaded_at DATETIME,
+            PRIMARY KEY (currency, effective_date)
+        );`,
+        `CREATE TABLE IF NOT EXISTS watchlists (
+            list_id TEXT PRIMARY KEY,
+            name TEXT NOT NULL,
+            source TEXT,
+            action TEXT,
+            created_at DATETIME,
+            updated_at DATETIME
+        );`,
+        `CREATE TABLE IF NOT EXISTS watchlist_entries (
+            entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            list_id TEXT NOT NULL,
+            reference TEXT,
+            name TEXT,
+            aliases TEXT,
+            account_id TEXT,
+            promptpay_proxy TEXT,
+            national_id_hash TEXT,
+            added_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_watchlist_entries_list ON watchlist_entries(list_id);`,
+        `CREATE TABLE IF NOT EXISTS watchlist_hits (
+            hit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            txn_id TEXT,
+            role TEXT,
+            account_id TEXT,
+            list_id TEXT,
+            list_name TEXT,
+            entry_id INTEGER,
+            entry_name TEXT,
+            matched_field TEXT,
+            matched_value TEXT,
+            matched_alias TEXT,
+            score REAL,
+            action TEXT,
+            status TEXT DEFAULT 'OPEN',
+            adjudicated_by TEXT,
+            note TEXT,
+            adjudicated_at DATETIME,
+            created_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_watchlist_hits_status ON watchlist_hits(status, created_at);`,
+        `CREATE INDEX IF NOT EXISTS idx_watchlist_hits_entry ON watchlist_hits(entry_id, matched_field, matched_value);`,
+        `CREATE TABLE IF NOT EXISTS overrides (
+            override_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            entity_type TEXT NOT NULL,
+            entity_id TEXT NOT NULL,
+            action TEXT NOT NULL,
+            reason TEXT,
+            author TEXT,
+            status TEXT DEFAULT 'ACTIVE',
+            expires_at DATETIME,
+            created_at DATETIME,
+            revoked_by TEXT,
+            revoked_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_overrides_entity ON overrides(entity_type, entity_id, status);`,
+        `CREATE TABLE IF NOT EXISTS account_status (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            account_id TEXT NOT NULL,
+            status TEXT NOT NULL,
+            reason TEXT,
+            txn_id TEXT,
+            updated_at DATETIME,
+            PRIMARY KEY (tenant_id, account_id)
+        );`,
+        `CREATE TABLE IF NOT EXISTS account_status_history (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            account_id TEXT NOT NULL,
+            status TEXT NOT NULL,
+            previous_status TEXT,
+            txn_id TEXT,
+            reason TEXT,
+            changed_by TEXT,
+            changed_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_account_status_history_account ON account_status_history(account_id);`,
+        `CREATE TABLE IF NOT EXISTS holds (
+            txn_id TEXT PRIMARY KEY,
+            status TEXT NOT NULL,
+            sender_account TEXT,
+            receiver_account TEXT,
+            amount REAL,
+            currency TEXT,
+            risk_score REAL,
+            reasons TEXT,
+            held_at DATETIME,
+            due_at DATETIME,
+            decided_by TEXT,
+            note TEXT,
+            decided_at DATETIME,
+            callback_url TEXT,
+            callback_status TEXT,
+            callback_attempts INTEGER DEFAULT 0,
+            callback_error TEXT
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_holds_status ON holds(status, held_at);`,
+        `CREATE TABLE IF NOT EXISTS cases (
+            case_id TEXT PRIMARY KEY,
+            title TEXT,
+            group_type TEXT NOT NULL,
+            group_key TEXT NOT NULL,
+            status TEXT NOT NULL,
+            priority TEXT NOT NULL,
+            assignee TEXT,
+            alert_count INTEGER DEFAULT 0,
+            resolution TEXT,
+            created_at DATETIME,
+            updated_at DATETIME,
+            closed_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_cases_group ON cases(group_type, group_key, status);`,
+        `CREATE TABLE IF NOT EXISTS alerts (
+            alert_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            source TEXT NOT NULL,
+            type TEXT NOT NULL,
+            severity TEXT NOT NULL,
+            txn_id TEXT,
+            account_id TEXT NOT NULL,
+            summary TEXT,
+            case_id TEXT NOT NULL,
+            created_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_alerts_case ON alerts(case_id);`,
+        `CREATE INDEX IF NOT EXISTS idx_alerts_account ON alerts(account_id, type);`,
+        `CREATE TABLE IF NOT EXISTS case_notes (
+            note_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            case_id TEXT NOT NULL,
+            author TEXT,
+            text TEXT,
+            created_at DATETIME
+        );`,
+        `CREATE TABLE IF NOT EXISTS case_links (
+            case_id TEXT NOT NULL,
+            link_type TEXT NOT NULL,
+            ref TEXT NOT NULL,
+            PRIMARY KEY (case_id, link_type, ref)
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_case_links_ref ON case_links(link_type, ref);`,
+        `CREATE TABLE IF NOT EXISTS approvals (
+            approval_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            kind TEXT NOT NULL,
+            target TEXT NOT NULL,
+            payload TEXT,
+            status TEXT NOT NULL,
+            proposed_by TEXT NOT NULL,
+            proposed_at DATETIME,
+            decided_by TEXT,
+            decided_at DATETIME,
+            rejection_reason TEXT
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_approvals_status ON approvals(status, proposed_at);`,
+        `CREATE TABLE IF NOT EXISTS roles (
+            role TEXT PRIMARY KEY,
+            description TEXT
+        );`,
+        `CREATE TABLE IF NOT EXISTS role_permissions (
+            role TEXT NOT NULL,
+            permission TEXT NOT NULL,
+            PRIMARY KEY (role, permission)
+        );`,
+        `CREATE TABLE IF NOT EXISTS sessions (
+            session_id TEXT PRIMARY KEY,
+            username TEXT NOT NULL,
+            access_hash TEXT NOT NULL UNIQUE,
+            refresh_hash TEXT NOT NULL UNIQUE,
+            previous_refresh_hash TEXT,
+            ip TEXT,
+            created_at DATETIME,
+            expires_at DATETIME,
+            refresh_expires_at DATETIME,
+            revoked_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(username);`,
+        `CREATE INDEX IF NOT EXISTS idx_sessions_previous ON sessions(previous_refresh_hash);`,
+        `CREATE TABLE IF NOT EXISTS user_mfa (
+            username TEXT PRIMARY KEY,
+            secret TEXT NOT NULL,
+            confirmed_at DATETIME,
+            last_used_step INTEGER DEFAULT 0,
+            created_at DATETIME
+        );`,
+        `CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            username TEXT NOT NULL,
+            code_hash TEXT NOT NULL UNIQUE,
+            used_at DATETIME
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_mfa_recovery_user ON mfa_recovery_codes(username);`,
+        `CREATE TABLE IF NOT EXISTS api_keys (
+            key_id TEXT PRIMARY KEY,
+            name TEXT NOT NULL,
+            key_hash TEXT NOT NULL,
+            scopes TEXT NOT NULL,
+            rate_limit INTEGER NOT NULL,
+            expires_at DATETIME,
+            created_by TEXT,
+            created_at DATETIME,
+            last_used_at DATETIME,
+            revoked_at DATETIME,
+            revoked_by TEXT
+        );`,
+        `CREATE TABLE IF NOT EXISTS str_reports (
+            report_id TEXT NOT NULL,
+            version INTEGER NOT NULL,
+            case_id TEXT,
+            txn_id TEXT,
+            analyst TEXT,
+            body TEXT NOT NULL,
+            generated_at DATETIME,
+            PRIMARY KEY (report_id, version)
+        );`,
+        `CREATE INDEX IF NOT EXISTS idx_str_reports_case ON str_reports(case_id);`,
+        `CREATE TABLE IF NOT EXISTS account_risk (
+            tenant_id TEXT NOT NULL DEFAULT 'SYNAPSE',
+            account_id TEXT NOT NULL,
+            propagated_risk REAL DEFAULT 0,
+            updated_at DATETIME,
+            PRIMARY KEY (tenant_id, account_id)
+        );`,
+        `CREATE TABLE IF NOT EXISTS tenants (
+            tenant_id TEXT PRIMARY KEY,
+            name TEXT NOT NULL,
+            operator INTEGER DEFAULT 0,
+            created_at DATETIME
+        );`,
 	}
 
+	rekeyed := renamePreTenantTables()
+
 	for _, query := range queries {
 		_, err := DB.Exec(query)
 		if err != nil {
 			log.Printf("Error creating table: %v", err)
 		}
 	}
+
+	// Columns added to existing tables. SQLite has no ADD COLUMN IF NOT EXISTS,
+	// so "duplicate column" means the database is already up to date.
+	columns := []string{
+		`ALTER TABLE graph_transactions ADD COLUMN currency TEXT`,
+		`ALTER TABLE graph_transactions ADD COLUMN original_amount REAL`,
+		`ALTER TABLE graph_transactions ADD COLUMN original_currency TEXT`,
+		`ALTER TABLE graph_transactions ADD COLUMN fx_rate REAL`,
+		`ALTER TABLE graph_transactions ADD COLUMN api_key_id TEXT`,
+		`ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'analyst'`,
+		`ALTER TABLE users ADD COLUMN disabled INTEGER DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN created_at DATETIME`,
+		`ALTER TABLE users ADD COLUMN auth_source TEXT DEFAULT 'local'`,
+		`ALTER TABLE users ADD COLUMN failed_logins INTEGER DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN last_failed_at DATETIME`,
+		`ALTER TABLE users ADD COLUMN locked_until DATETIME`,
+		`ALTER TABLE audit_logs ADD COLUMN actor TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN ip TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN request_id TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN status INTEGER`,
+		`ALTER TABLE audit_logs ADD COLUMN before_state TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN after_state TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN prev_hash TEXT`,
+		`ALTER TABLE audit_logs ADD COLUMN hash TEXT`,
+		`ALTER TABLE graph_transactions ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE users ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE api_keys ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE parties ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE motifs ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE watchlist_hits ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE overrides ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE account_status_history ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE holds ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE cases ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE alerts ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE approvals ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE str_reports ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE verification_stats ADD COLUMN tenant_id TEXT DEFAULT 'SYNAPSE'`,
+		`ALTER TABLE audit_logs ADD COLUMN tenant_id TEXT`,
+		`ALTER TABLE users ADD COLUMN oidc_issuer TEXT`,
+		`ALTER TABLE users ADD COLUMN oidc_subject TEXT`,
+		`ALTER TABLE users ADD COLUMN password_change_required INTEGER DEFAULT 0`,
+	}
+	for _, query := range columns {
+		_, err := DB.Exec(query)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			log.Printf("Error adding column: %v", err)
+		}
+	}
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc ON users(oidc_issuer, oidc_subject) WHERE oidc_subject IS NOT NULL`); err != nil {
+		log.Printf("Error creating index: %v", err)
+	}
+
+	copyPreTenantTables(rekeyed)
+}
+
+// tenantKeyedTables are keyed by account (or community) alone before
+// tenants existed; the same account ID at two banks now needs two rows
+var tenantKeyedTables = []string{
+	"communities", "account_communities", "account_entities", "party_accounts",
+	"last_locations", "account_status", "account_risk",
+}
+
+// renamePreTenantTables moves any tenantKeyedTables still in the old layout
+// aside, with their indexes dropped so createTables can rebuild them under
+// the same names. It returns the tables moved.
+func renamePreTenantTables() []string {
+	var moved []string
+	for _, table := range tenantKeyedTables {
+		var hasTable, hasTenant int
+		DB.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&hasTable)
+		DB.QueryRow("SELECT count(*) FROM pragma_table_info(?) WHERE name = 'tenant_id'", table).Scan(&hasTenant)
+		if hasTable == 0 || hasTenant > 0 {
+			continue
+		}
+		var indexes []string
+		rows, err := DB.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table)
+		if err == nil {
+			for rows.Next() {
+				var name string
+				if rows.Scan(&name) == nil {
+					indexes = append(indexes, name)
+				}
+			}
+			rows.Close()
+		}
+		for _, name := range indexes {
+			if _, err := DB.Exec(`DROP INDEX IF EXISTS "` + name + `"`); err != nil {
+				log.Printf("Error dropping index %s: %v", name, err)
+			}
+		}
+		if _, err := DB.Exec(`ALTER TABLE ` + table + ` RENAME TO ` + table + `_pre_tenant`); err != nil {
+			log.Printf("Error moving %s aside for tenant keys: %v", table, err)
+			continue
+		}
+		moved = append(moved, table)
+	}
+	return moved
+}
+
+// copyPreTenantTables fills the rebuilt tables from the moved-aside ones,
+// assigning every existing row to the operator tenant
+func copyPreTenantTables(tables []string) {
+	for _, table := range tables {
+		var cols []string
+		rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table+"_pre_tenant")
+		if err != nil {
+			log.Printf("Error reading %s_pre_tenant: %v", table, err)
+			continue
+		}
+		for rows.Next() {
+			var name string
+			if rows.Scan(&name) == nil {
+				cols = append(cols, name)
+			}
+		}
+		rows.Close()
+
+		list := strings.Join(cols, ", ")
+		if _, err := DB.Exec(`INSERT INTO ` + table + ` (tenant_id, ` + list + `) SELECT 'SYNAPSE', ` + list + ` FROM ` + table + `_pre_tenant`); err != nil {
+			log.Printf("Error copying %s into tenant keys: %v", table, err)
+			continue
+		}
+		if _, err := DB.Exec(`DROP TABLE ` + table + `_pre_tenant`); err != nil {
+			log.Printf("Error dropping %s_pre_tenant: %v", table, err)
+		}
+		log.Printf("Rekeyed %s by tenant", table)
+	}
 }
 
 func seedData() {
+    // Participating banks. SYNAPSE is the operator: it runs the platform and
+    // owns everything recorded before tenants existed.
+    tenants := [][2]string{
+        {"SYNAPSE", "Synapse Bank"},
+        {"SCB", "Siam Commercial Bank"},
+        {"KBANK", "Kasikornbank"},
+        {"KTB", "Krungthai Bank"},
+        {"BBL", "Bangkok Bank"},
+        {"BAY", "Bank of Ayudhya"},
+        {"TTB", "TMBThanachart Bank"},
+    }
+    for _, t := range tenants {
+        _, err := DB.Exec("INSERT OR IGNORE INTO tenants (tenant_id, name, operator, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)", t[0], t[1], t[0] == "SYNAPSE")
+        if err != nil {
+            fmt.Println("Error seeding tenant:", err)
+        }
+    }
+
     // Check if stats exist
     var count int
     DB.QueryRow("SELECT count(*) FROM verification_stats").Scan(&count)
@@ -76,13 +555,108 @@ func seedData() {
         }
     }
 
-    // Check if user exists
+    // Demo users; seeded in plaintext and bcrypt-hashed by migratePasswords
     DB.QueryRow("SELECT count(*) FROM users WHERE username = 'analyst@synapse.bank'").Scan(&count)
     if count == 0 {
-        // Plaintext for demo, or simple hash
         _, err := DB.Exec("INSERT INTO users (username, password) VALUES ('analyst@synapse.bank', 'secure')")
         if err != nil {
             fmt.Println("Error seeding user:", err)
         }
     }
+
+    // Reviewer for four-eyes approval of verdicts and overrides
+    seedPrivilegedUser("supervisor@synapse.bank", "supervisor", "INITIAL_SUPERVISOR_PASSWORD")
+
+    // Administrator for user management and the test bench
+    seedPrivilegedUser("admin@synapse.bank", "admin", "INITIAL_ADMIN_PASSWORD")
+
+    // Service account the demo traffic generator ingests with
+    seedPrivilegedUser("ingest@synapse.bank", "ingest-service", "INITIAL_INGEST_PASSWORD")
+}
+
+// demoPassword is what the privileged accounts were once seeded with. It is
+// public, so seedPrivilegedUser rotates any account still using it.
+const demoPassword = "secure"
+
+// seedPrivilegedUser creates a privileged account with the initial password
+// from envKey, or with a generated one that is logged once. Either way the
+// password must be changed at first sign-in.
+func seedPrivilegedUser(username, role, envKey string) {
+	var stored string
+	err := DB.QueryRow("SELECT password FROM users WHERE username = ?", username).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error reading %s: %v", username, err)
+		return
+	}
+	exists := err == nil
+	if exists && stored != demoPassword && bcrypt.CompareHashAndPassword([]byte(stored), []byte(demoPassword)) != nil {
+		return
+	}
+
+	password, generated := os.Getenv(envKey), false
+	if password == "" {
+		buf := make([]byte, 18)
+		if _, err := rand.Read(buf); err != nil {
+			log.Printf("Error generating a password for %s: %v", username, err)
+			return
+		}
+		password, generated = base64.RawURLEncoding.EncodeToString(buf), true
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing the password for %s: %v", username, err)
+		return
+	}
+	if exists {
+		_, err = DB.Exec("UPDATE users SET password = ?, password_change_required = 1 WHERE username = ?", string(hash), username)
+	} else {
+		_, err = DB.Exec("INSERT INTO users (username, password, role, password_change_required) VALUES (?, ?, ?, 1)", username, string(hash), role)
+	}
+	if err != nil {
+		log.Printf("Error seeding %s: %v", username, err)
+		return
+	}
+
+	action := "Created"
+	if exists {
+		action = "Replaced the public demo password of"
+	}
+	if generated {
+		log.Printf("%s %s with the one-time password %s (set %s to choose it); it must be changed at first sign-in", action, username, password, envKey)
+	} else {
+		log.Printf("%s %s with the password from %s; it must be changed at first sign-in", action, username, envKey)
+	}
+}
+
+// migratePasswords replaces any plaintext password left from before hashing
+// (including the demo seeds) with its bcrypt hash
+func migratePasswords() {
+	rows, err := DB.Query("SELECT id, password FROM users WHERE password NOT LIKE '$2_$%'")
+	if err != nil {
+		log.Printf("Error reading passwords to migrate: %v", err)
+		return
+	}
+	plain := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var password string
+		if rows.Scan(&id, &password) == nil {
+			plain[id] = password
+		}
+	}
+	rows.Close()
+
+	for id, password := range plain {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("Error hashing password for user %d: %v", id, err)
+			continue
+		}
+		if _, err := DB.Exec("UPDATE users SET password = ? WHERE id = ?", string(hash), id); err != nil {
+			log.Printf("Error migrating password for user %d: %v", id, err)
+		}
+	}
+	if len(plain) > 0 {
+		log.Printf("Migrated %d plaintext passwords to bcrypt", len(plain))
+	}
 }
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
@@ -36,6 +39,9 @@ func main() {
 
 	// Init Database
     db.InitDB()
+	if err := services.SeedRoles(); err != nil {
+		log.Printf("Warning: could not seed roles: %v", err)
+	}
 
 	// Init Services
 	neo4jSvc, err := services.NewNeo4jService(neo4jUri, neo4jUser, neo4jPass)
@@ -44,48 +50,348 @@ func main() {
 		// Don't fatal here, let it retry or fail on request for demo robustness
 	} else {
 		defer neo4jSvc.Close(nil)
+		if err := neo4jSvc.BackfillTenant(context.Background()); err != nil {
+			log.Printf("Warning: could not move existing graph data into tenant %s: %v", services.DefaultTenant, err)
+		}
+		neo4jSvc.Cycles.MaxLength = envInt("CYCLE_MAX_LENGTH", neo4jSvc.Cycles.MaxLength)
+		neo4jSvc.Cycles.MaxFanOut = envInt("CYCLE_MAX_FANOUT", neo4jSvc.Cycles.MaxFanOut)
+		neo4jSvc.Cycles.Window = envDuration("CYCLE_WINDOW", neo4jSvc.Cycles.Window)
+		neo4jSvc.Cycles.Timeout = envDuration("CYCLE_TIMEOUT", neo4jSvc.Cycles.Timeout)
+		neo4jSvc.Cycles.CacheTTL = envDuration("CYCLE_CACHE_TTL", neo4jSvc.Cycles.CacheTTL)
+		neo4jSvc.SharedEntityWindow = envDuration("SHARED_ENTITY_WINDOW", neo4jSvc.SharedEntityWindow)
+		neo4jSvc.Motifs.FanInMin = envInt("MOTIF_FAN_IN_MIN", neo4jSvc.Motifs.FanInMin)
+		neo4jSvc.Motifs.FanOutMin = envInt("MOTIF_FAN_OUT_MIN", neo4jSvc.Motifs.FanOutMin)
+		neo4jSvc.Motifs.ScatterGatherMin = envInt("MOTIF_SCATTER_GATHER_MIN", neo4jSvc.Motifs.ScatterGatherMin)
+		neo4jSvc.Motifs.Window = envDuration("MOTIF_WINDOW", neo4jSvc.Motifs.Window)
 	}
 
 	aiClient := services.NewAIClient(aiServiceUrl)
 	handler := api.NewBankHandler(neo4jSvc, aiClient)
 
+	// Offline IP geolocation and impossible-travel thresholds
+	if path := strings.TrimSpace(os.Getenv("GEOIP_DB")); path != "" {
+		ips, err := services.LoadIPDatabase(path)
+		if err != nil {
+			log.Printf("Warning: Could not load IP database %s: %v. IP geolocation disabled.", path, err)
+		} else {
+			handler.Geo.IPs = ips
+		}
+	}
+	handler.Geo.MaxSpeedKmh = envFloat("IMPOSSIBLE_TRAVEL_KMH", handler.Geo.MaxSpeedKmh)
+	handler.Geo.MinDistanceKm = envFloat("IMPOSSIBLE_TRAVEL_MIN_KM", handler.Geo.MinDistanceKm)
+
+	// FX normalisation to the base currency
+	if base := strings.ToUpper(strings.TrimSpace(os.Getenv("BASE_CURRENCY"))); base != "" {
+		handler.FX.BaseCurrency = base
+	}
+	switch policy := strings.ToLower(strings.TrimSpace(os.Getenv("UNKNOWN_CURRENCY_POLICY"))); policy {
+	case services.UnknownCurrencyReject, services.UnknownCurrencyReview, services.UnknownCurrencyAccept:
+		handler.FX.UnknownPolicy = policy
+	case "":
+	default:
+		log.Printf("Warning: Unknown UNKNOWN_CURRENCY_POLICY %q, using %s", policy, handler.FX.UnknownPolicy)
+	}
+	if err := handler.FX.Load(); err != nil {
+		log.Printf("Warning: Could not load FX rates: %v", err)
+	}
+	if path := strings.TrimSpace(os.Getenv("FX_RATES_FILE")); path != "" {
+		if err := handler.FX.LoadRatesFile(path); err != nil {
+			log.Printf("Warning: Could not load FX rates from %s: %v", path, err)
+		}
+	}
+
+	// Watchlist and sanctions screening
+	handler.Watchlists.MatchThreshold = envFloat("WATCHLIST_MATCH_THRESHOLD", handler.Watchlists.MatchThreshold)
+	handler.Watchlists.StrongThreshold = envFloat("WATCHLIST_STRONG_THRESHOLD", handler.Watchlists.StrongThreshold)
+	if err := handler.Watchlists.Load(); err != nil {
+		log.Printf("Warning: Could not load watchlists: %v", err)
+	}
+
+	// Transfers into accounts flagged by confirmed-fraud verdicts
+	switch action := strings.TrimSpace(os.Getenv("MULE_INBOUND_ACTION")); action {
+	case "Review", "Block":
+		handler.AccountStatus.InboundAction = action
+	case "":
+	default:
+		log.Printf("Warning: Unknown MULE_INBOUND_ACTION %q, using %s", action, handler.AccountStatus.InboundAction)
+	}
+
+	// Hold and release of Review transactions
+	handler.Holds.SLA = envDuration("HOLD_SLA", handler.Holds.SLA)
+	handler.Holds.Interval = envDuration("HOLD_SWEEP_INTERVAL", handler.Holds.Interval)
+	handler.Holds.WebhookURL = strings.TrimSpace(os.Getenv("HOLD_WEBHOOK_URL"))
+	handler.Holds.WebhookSecret = os.Getenv("HOLD_WEBHOOK_SECRET")
+	handler.Holds.MaxCallbackAttempts = envInt("HOLD_CALLBACK_ATTEMPTS", handler.Holds.MaxCallbackAttempts)
+	switch policy := strings.ToLower(strings.TrimSpace(os.Getenv("HOLD_TIMEOUT_POLICY"))); policy {
+	case services.HoldTimeoutRelease, services.HoldTimeoutReject:
+		handler.Holds.TimeoutPolicy = policy
+	case "":
+	default:
+		log.Printf("Warning: Unknown HOLD_TIMEOUT_POLICY %q, using %s", policy, handler.Holds.TimeoutPolicy)
+	}
+	handler.Holds.Start(context.Background())
+
+	// Analyst overrides, swept for expiry in the background
+	handler.Overrides.Interval = envDuration("OVERRIDE_EXPIRY_INTERVAL", handler.Overrides.Interval)
+	handler.Overrides.Start(context.Background())
+
+	// Sessions and failed-login lockout
+	handler.Auth.AccessTTL = envDuration("AUTH_TOKEN_TTL", handler.Auth.AccessTTL)
+	handler.Auth.RefreshTTL = envDuration("AUTH_REFRESH_TTL", handler.Auth.RefreshTTL)
+	handler.Auth.MaxFailures = envInt("LOGIN_MAX_FAILURES", handler.Auth.MaxFailures)
+	handler.Auth.FailureWindow = envDuration("LOGIN_FAILURE_WINDOW", handler.Auth.FailureWindow)
+	handler.Auth.LockoutDuration = envDuration("LOGIN_LOCKOUT", handler.Auth.LockoutDuration)
+	handler.Auth.IPMaxFailures = envInt("LOGIN_IP_MAX_FAILURES", handler.Auth.IPMaxFailures)
+	handler.Auth.Start(context.Background())
+
+	// API keys for machine-to-machine callers
+	handler.APIKeys.DefaultTTL = envDuration("API_KEY_TTL", handler.APIKeys.DefaultTTL)
+	handler.APIKeys.DefaultRateLimit = envInt("API_KEY_RATE_LIMIT", handler.APIKeys.DefaultRateLimit)
+
+	// Single sign-on through the corporate OpenID Connect provider
+	handler.OIDC.Issuer = strings.TrimSpace(os.Getenv("OIDC_ISSUER"))
+	handler.OIDC.ClientID = strings.TrimSpace(os.Getenv("OIDC_CLIENT_ID"))
+	handler.OIDC.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	handler.OIDC.RedirectURL = strings.TrimSpace(os.Getenv("OIDC_REDIRECT_URL"))
+	if scopes := strings.Fields(os.Getenv("OIDC_SCOPES")); len(scopes) > 0 {
+		handler.OIDC.Scopes = scopes
+	}
+	if claim := strings.TrimSpace(os.Getenv("OIDC_USERNAME_CLAIM")); claim != "" {
+		handler.OIDC.UsernameClaim = claim
+	}
+	if claim := strings.TrimSpace(os.Getenv("OIDC_GROUPS_CLAIM")); claim != "" {
+		handler.OIDC.GroupsClaim = claim
+	}
+	// OIDC_GROUP_ROLES is "group=role,group=role", checked in order
+	for _, pair := range strings.Split(os.Getenv("OIDC_GROUP_ROLES"), ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		handler.OIDC.GroupRoles = append(handler.OIDC.GroupRoles, services.GroupRole{Group: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+	}
+	handler.OIDC.DefaultRole = strings.TrimSpace(os.Getenv("OIDC_DEFAULT_ROLE"))
+	if tenant := strings.TrimSpace(os.Getenv("OIDC_TENANT")); tenant != "" {
+		handler.OIDC.Tenant = strings.ToUpper(tenant)
+	}
+	if name := strings.TrimSpace(os.Getenv("OIDC_PROVIDER_NAME")); name != "" {
+		handler.OIDC.ProviderName = name
+	}
+	if u := strings.TrimSpace(os.Getenv("OIDC_POST_LOGIN_URL")); u != "" {
+		handler.OIDC.PostLoginURL = u
+	}
+	if handler.OIDC.Enabled() {
+		log.Printf("Single sign-on enabled with %s", handler.OIDC.Issuer)
+	}
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("PASSWORD_LOGIN_ENABLED"))); err == nil {
+		handler.Auth.PasswordLogin = v
+	}
+
+	// Roles that must use a second factor with password sign-in; "none" turns the policy off
+	if v, ok := os.LookupEnv("MFA_REQUIRED_ROLES"); ok {
+		handler.Auth.MFA.RequiredRoles = nil
+		for _, role := range strings.Split(v, ",") {
+			if role = strings.TrimSpace(role); role != "" && role != "none" {
+				handler.Auth.MFA.RequiredRoles = append(handler.Auth.MFA.RequiredRoles, role)
+			}
+		}
+	}
+	if issuer := strings.TrimSpace(os.Getenv("MFA_ISSUER")); issuer != "" {
+		handler.Auth.MFA.Issuer = issuer
+	}
+
+	// Reporting institution named on AMLO suspicious transaction reports
+	if name := strings.TrimSpace(os.Getenv("STR_INSTITUTION_NAME")); name != "" {
+		handler.Reports.InstitutionName = name
+	}
+	handler.Reports.InstitutionCode = strings.TrimSpace(os.Getenv("STR_INSTITUTION_CODE"))
+
+	// Business calendar for night, weekend and holiday features
+	handler.Calendar.NightStartHour = envInt("NIGHT_START_HOUR", handler.Calendar.NightStartHour)
+	handler.Calendar.NightEndHour = envInt("NIGHT_END_HOUR", handler.Calendar.NightEndHour)
+	handler.Calendar.ActivityWindow = envDuration("OFF_HOURS_WINDOW", handler.Calendar.ActivityWindow)
+	if path := strings.TrimSpace(os.Getenv("HOLIDAYS_FILE")); path != "" {
+		if err := handler.Calendar.LoadHolidaysFile(path); err != nil {
+			log.Printf("Warning: Could not load holidays from %s: %v", path, err)
+		}
+	} else if err := handler.Calendar.LoadStoredHolidays(); err != nil {
+		log.Printf("Warning: Could not load stored holidays: %v", err)
+	}
+
+	// Background community detection over the account graph
+	communityJob := services.NewCommunityJob(neo4jSvc)
+	communityJob.Interval = envDuration("COMMUNITY_INTERVAL", communityJob.Interval)
+	communityJob.Window = envDuration("COMMUNITY_WINDOW", communityJob.Window)
+	communityJob.Start(context.Background())
+	handler.Communities = communityJob
+
+	// Risk propagation from confirmed fraud, re-run whenever a verdict is recorded
+	propagationJob := services.NewRiskPropagationJob(neo4jSvc)
+	propagationJob.Timeout = envDuration("PROPAGATION_TIMEOUT", propagationJob.Timeout)
+	propagationJob.Start(context.Background())
+	handler.Propagation = propagationJob
+
 	// Setup Router
 	r := gin.Default()
 
+	// ClientIP drives login throttling and the audit trail, so X-Forwarded-For
+	// is only believed from the proxies listed in TRUSTED_PROXIES
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+
 	// CORS Setup
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // For demo purposes
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
 		AllowCredentials: true,
 	}))
 
-	// Routes
-	apiGroup := r.Group("/api/bank")
+	// Request IDs and the audit trail for every mutating API call
+	r.Use(api.AuditTrail())
+
+	// Routes. Every group needs a signed-in user; each subgroup then needs
+	// the permission its role must grant (see services.defaultRoles).
+	apiGroup := r.Group("/api/bank", handler.RequireAuth())
 	{
-		apiGroup.POST("/transaction", handler.IngestTransaction)
-		apiGroup.GET("/graph", handler.GetGraph)
-		apiGroup.GET("/account/:id", handler.GetAccountDetails)
-        apiGroup.POST("/transaction/:id/verify", handler.VerifyTransaction)
+		// API keys with read:graph may also call these
+		graph := apiGroup.Group("", handler.RequirePermission(services.PermRead, services.ScopeReadGraph))
+		graph.GET("/graph", handler.GetGraph)
+		graph.GET("/account/:id", handler.GetAccountDetails)
+		graph.GET("/account/:id/cycles", handler.GetAccountCycles)
+		graph.GET("/account/:id/subgraph", handler.GetAccountSubgraph)
+		graph.GET("/party/:id", handler.GetParty)
+		graph.GET("/path", handler.GetMoneyPaths)
+		graph.GET("/device/:id/accounts", handler.GetDeviceAccounts)
+		graph.GET("/ip/:id/accounts", handler.GetIPAccounts)
+		graph.GET("/communities", handler.GetCommunities)
+		graph.GET("/motifs", handler.GetMotifs)
+		// Cross-bank view: aggregates over every tenant, never their transactions
+		graph.GET("/consortium/accounts", handler.GetConsortiumAccounts)
+		graph.GET("/consortium/accounts/:id", handler.GetConsortiumAccount)
+
+		read := apiGroup.Group("", handler.RequirePermission(services.PermRead))
+		read.GET("/calendar/holidays", handler.GetHolidays)
+		read.GET("/fx/rates", handler.GetFXRates)
+		read.GET("/watchlists", handler.GetWatchlists)
+		read.GET("/watchlists/:id/entries", handler.GetWatchlistEntries)
+		read.GET("/watchlist/hits", handler.GetWatchlistHits)
+		read.GET("/approvals", handler.GetApprovals)
+		read.GET("/approvals/:id", handler.GetApproval)
+		read.GET("/alerts", handler.GetAlerts)
+		read.GET("/cases", handler.GetCases)
+		read.GET("/cases/:id", handler.GetCase)
+		read.GET("/str", handler.GetSTRs)
+		read.GET("/str/:id", handler.GetSTR)
+		read.GET("/holds", handler.GetHolds)
+		read.GET("/holds/:id", handler.GetHold)
+		read.GET("/overrides", handler.GetOverrides)
+
+		ingest := apiGroup.Group("", handler.RequirePermission(services.PermIngest, services.ScopeIngest))
+		ingest.POST("/transaction", handler.IngestTransaction)
+
+		investigate := apiGroup.Group("", handler.RequirePermission(services.PermInvestigate))
+		investigate.POST("/account/:id/party", handler.SetAccountParty)
+		investigate.POST("/watchlist/screen", handler.ScreenSubject)
+		investigate.POST("/watchlist/hits/:id/adjudicate", handler.AdjudicateWatchlistHit)
+		investigate.POST("/cases/:id/assign", handler.AssignCase)
+		investigate.POST("/cases/:id/status", handler.UpdateCaseStatus)
+		investigate.POST("/cases/:id/close", handler.CloseCase)
+		investigate.POST("/cases/:id/notes", handler.AddCaseNote)
+		investigate.POST("/cases/:id/links", handler.LinkCaseItems)
+		investigate.POST("/str", handler.GenerateSTR)
+		investigate.POST("/holds/:id/release", handler.ReleaseHold)
+		investigate.POST("/holds/:id/reject", handler.RejectHold)
+		investigate.POST("/overrides", handler.CreateOverride)
+		investigate.POST("/overrides/:id/revoke", handler.RevokeOverride)
+		apiGroup.POST("/transaction/:id/verify", handler.RequirePermission(services.PermInvestigate, services.ScopeVerify), handler.VerifyTransaction)
+
+		approve := apiGroup.Group("", handler.RequirePermission(services.PermApprove))
+		approve.POST("/approvals/:id/approve", handler.ApproveApproval)
+		approve.POST("/approvals/:id/reject", handler.RejectApproval)
+
+		// Shared by every bank, so only the operator may change them
+		configure := apiGroup.Group("", handler.RequirePermission(services.PermConfigure), handler.RequireOperator())
+		configure.POST("/calendar/holidays", handler.UploadHolidays)
+		configure.POST("/fx/rates", handler.UploadFXRates)
+		configure.POST("/watchlists", handler.CreateWatchlist)
+		configure.DELETE("/watchlists/:id", handler.DeleteWatchlist)
+		configure.POST("/watchlists/:id/entries", handler.AddWatchlistEntry)
+		configure.DELETE("/watchlists/:id/entries/:entry_id", handler.DeleteWatchlistEntry)
+		configure.POST("/watchlists/:id/import", handler.ImportWatchlist)
+		configure.POST("/communities/recompute", handler.RecomputeCommunities)
+
+		auditors := apiGroup.Group("", handler.RequirePermission(services.PermAudit))
+		auditors.GET("/audit", handler.GetAuditLog)
+		auditors.GET("/audit/verify", handler.VerifyAuditLog)
 	}
 
-	testGroup := r.Group("/api/test")
+	adminGroup := r.Group("/api/admin", handler.RequireAuth(), handler.RequirePermission(services.PermManageUsers))
 	{
-		testGroup.POST("/generate", handler.GenerateISO20022Data)
-		testGroup.POST("/reset", handler.ResetData)
-		testGroup.GET("/progress", handler.GetSimulationProgress)
+		adminGroup.GET("/roles", handler.GetRoles)
+		adminGroup.GET("/users", handler.GetUsers)
+		adminGroup.POST("/users", handler.CreateUser)
+		adminGroup.POST("/users/:id/role", handler.SetUserRole)
+		adminGroup.POST("/users/:id/disable", handler.DisableUser)
+		adminGroup.POST("/users/:id/enable", handler.EnableUser)
+		adminGroup.POST("/users/:id/mfa/reset", handler.ResetUserMFA)
+		adminGroup.GET("/api-keys", handler.GetAPIKeys)
+		adminGroup.POST("/api-keys", handler.CreateAPIKey)
+		adminGroup.POST("/api-keys/:id/revoke", handler.RevokeAPIKey)
+		adminGroup.GET("/tenants", handler.GetTenants)
+		adminGroup.POST("/tenants", handler.CreateTenant)
+	}
+
+	// The test bench generates synthetic traffic and wipes the database of
+	// every bank, so only the operator may use it. It is compiled out of
+	// production builds (-tags production) and can be switched off elsewhere
+	// with TEST_BENCH_ENABLED=false.
+	if testBenchEnabled() {
+		testGroup := r.Group("/api/test", handler.RequireAuth(), handler.RequirePermission(services.PermTestBench), handler.RequireOperator())
+		{
+			testGroup.POST("/generate", handler.GenerateISO20022Data)
+			testGroup.POST("/reset", handler.ResetData)
+			testGroup.GET("/progress", handler.GetSimulationProgress)
+		}
+	} else {
+		log.Println("Test bench routes are disabled")
 	}
 
     // Platform Routes (Auth, Stats)
     r.POST("/api/login", handler.Login)
-    r.GET("/api/stats", handler.GetStats)
-    r.POST("/api/stats", handler.UpdateStats)
+    r.POST("/api/login/mfa", handler.LoginMFA)
+    r.POST("/api/login/mfa/enroll", handler.LoginMFAEnroll)
+    r.POST("/api/token/refresh", handler.RefreshToken)
+    r.GET("/api/auth/methods", handler.GetAuthMethods)
+    r.GET("/api/oidc/login", handler.OIDCLogin)
+    r.GET("/api/oidc/callback", handler.OIDCCallback)
+    r.POST("/api/logout", handler.RequireAuth(), handler.Logout)
+    r.GET("/api/sessions", handler.RequireAuth(), handler.GetSessions)
+    r.POST("/api/me/password", handler.RequireAuth(), handler.ChangePassword)
+    r.GET("/api/mfa", handler.RequireAuth(), handler.GetMFA)
+    r.POST("/api/mfa/enroll", handler.RequireAuth(), handler.EnrollMFA)
+    r.POST("/api/mfa/confirm", handler.RequireAuth(), handler.ConfirmMFA)
+    r.POST("/api/mfa/recovery-codes", handler.RequireAuth(), handler.RegenerateRecoveryCodes)
+    r.POST("/api/mfa/disable", handler.RequireAuth(), handler.DisableMFA)
+    r.GET("/api/stats", handler.RequireAuth(), handler.RequirePermission(services.PermRead), handler.GetStats)
 
     // Serve Frontend Static Files (Production)
     // Reverse proxy for AI service on same port
     aiProxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "localhost:5001"})
-    r.Any("/api/ai/*any", func(c *gin.Context) {
+    r.Any("/api/ai/*any", handler.RequireAuth(), handler.RequirePermission(services.PermRead), func(c *gin.Context) {
+        if c.Request.Method == "POST" && c.Param("any") == "/rules/update" {
+            if handler.RequirePermission(services.PermConfigure)(c); c.IsAborted() {
+                return
+            }
+            if handler.RequireOperator()(c); c.IsAborted() {
+                return
+            }
+            if handler.AuditRuleChange(c); c.IsAborted() {
+                return
+            }
+        }
         // Trim the /api/ai prefix before proxying
         c.Request.URL.Path = strings.TrimPrefix(c.Request.URL.Path, "/api/ai")
         aiProxy.ServeHTTP(c.Writer, c.Request)
@@ -105,3 +411,52 @@ func main() {
 		log.Fatal("Failed to run server: ", err)
 	}
 }
+
+// envInt reads an integer setting, keeping the default when unset or invalid
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil {
+		return v
+	}
+	return def
+}
+
+// envDuration reads a Go duration setting such as "72h", keeping the default when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key))); err == nil {
+		return v
+	}
+	return def
+}
+
+// envFloat reads a decimal setting, keeping the default when unset or invalid
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// trustedProxies reads TRUSTED_PROXIES, comma-separated addresses or CIDRs of
+// the reverse proxies in front of the server. Unset, no proxy is trusted and
+// the client address is always the connection's.
+func trustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// testBenchEnabled reports whether the test bench routes are served: only in
+// non-production builds, and unless TEST_BENCH_ENABLED turns them off
+func testBenchEnabled() bool {
+	if !testBenchCompiled {
+		return false
+	}
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("TEST_BENCH_ENABLED"))); err == nil {
+		return v
+	}
+	return true
+}
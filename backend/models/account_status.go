@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Account statuses
+const (
+	AccountActive        = "ACTIVE"
+	AccountSuspectedMule = "SUSPECTED_MULE"
+)
+
+// AccountStatusChange is one entry in an account's status history
+type AccountStatusChange struct {
+	ID             int64     `json:"id"`
+	AccountID      string    `json:"account_id"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status"`
+	TransactionID  string    `json:"transaction_id,omitempty"` // the verdict that caused the change
+	Reason         string    `json:"reason"`
+	ChangedBy      string    `json:"changed_by,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
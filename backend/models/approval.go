@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Approval kinds: the actions that need a second pair of eyes
+const (
+	ApprovalVerdict        = "VERDICT"         // CONFIRMED_FRAUD / FALSE_POSITIVE on a transaction
+	ApprovalOverride       = "OVERRIDE"        // a new allow/review/block override
+	ApprovalOverrideRevoke = "OVERRIDE_REVOKE" // ending an override early
+)
+
+// Approval statuses
+const (
+	ApprovalPending  = "PENDING"
+	ApprovalApproved = "APPROVED"
+	ApprovalRejected = "REJECTED"
+)
+
+// Approval is an action proposed by one analyst (the maker) that only takes
+// effect once a different user with a reviewer role (the checker) approves it
+type Approval struct {
+	ApprovalID      int64           `json:"approval_id"`
+	TenantID        string          `json:"tenant_id"`
+	Kind            string          `json:"kind"`
+	Target          string          `json:"target"`  // transaction ID, overridden entity or override ID
+	Payload         json.RawMessage `json:"payload"` // what will be applied on approval
+	Status          string          `json:"status"`
+	ProposedBy      string          `json:"proposed_by"`
+	ProposedAt      time.Time       `json:"proposed_at"`
+	DecidedBy       string          `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time      `json:"decided_at,omitempty"`
+	RejectionReason string          `json:"rejection_reason,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is one row of the tamper-evident audit trail. Hash covers every
+// other field plus PrevHash, the hash of the entry before it, so editing or
+// deleting any row breaks the chain from that point on.
+type AuditEntry struct {
+	ID            int64           `json:"id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	TenantID      string          `json:"tenant_id,omitempty"` // caller's bank; empty for system entries
+	Action        string          `json:"action"`
+	Actor         string          `json:"actor"`
+	IP            string          `json:"ip,omitempty"`
+	RequestID     string          `json:"request_id,omitempty"`
+	Status        int             `json:"status,omitempty"` // HTTP status for request entries
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	PrevHash      string          `json:"prev_hash"`
+	Hash          string          `json:"hash"`
+}
+
+// AuditVerification is the result of re-hashing the audit chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	Legacy   int    `json:"legacy"` // rows written before chaining, not covered
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+	HeadHash string `json:"head_hash,omitempty"`
+}
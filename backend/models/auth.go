@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// User is an account that can sign in to the platform
+type User struct {
+	ID         int64      `json:"id"`
+	Username   string     `json:"username"`
+	Role       string     `json:"role"`
+	Disabled   bool       `json:"disabled"`
+	AuthSource string     `json:"auth_source"` // local (password) or oidc
+	TenantID   string     `json:"tenant_id"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+
+	// PasswordChangeRequired limits the user's sessions to changing the
+	// password, as for seeded accounts with an initial password
+	PasswordChangeRequired bool `json:"password_change_required"`
+}
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	Role        string   `json:"role"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Session is a signed-in user's pair of tokens. Only SHA-256 hashes of the
+// tokens are stored, so a database leak does not hand out live sessions.
+type Session struct {
+	SessionID        string     `json:"session_id"`
+	Username         string     `json:"username"`
+	IP               string     `json:"ip,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"` // access token
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TokenPair is returned by login and refresh. The access token goes in the
+// Authorization header; the refresh token is exchanged for a new pair before
+// the access token expires and is single-use.
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int       `json:"expires_in"` // seconds
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Username         string    `json:"username"`
+	Role             string    `json:"role"`
+	TenantID         string    `json:"tenant_id"`
+	RecoveryCodes    []string  `json:"recovery_codes,omitempty"` // only when MFA enrolment completes at login
+
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"` // only POST /api/me/password and logout work until it is changed
+}
+
+// MFAChallenge is returned by login instead of tokens when the password was
+// right but a second factor is needed. The client sends MFAToken back with a
+// TOTP or recovery code; with EnrollmentRequired it first enrols.
+type MFAChallenge struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int    `json:"expires_in"` // seconds
+}
+
+// MFAEnrollment is the TOTP secret to load into an authenticator app, as text
+// and as an otpauth:// URI (usually shown as a QR code)
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	Digits     int    `json:"digits"`
+	Period     int    `json:"period"` // seconds
+}
+
+// MFAStatus is a user's second-factor state
+type MFAStatus struct {
+	Username          string     `json:"username"`
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending"` // enrolment started but not confirmed
+	ConfirmedAt       *time.Time `json:"confirmed_at,omitempty"`
+	Required          bool       `json:"required"` // by the role policy
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// APIKey lets a system such as core banking call the API without an
+// interactive login. KeyID is the public prefix of the key; the secret part
+// is only ever stored as a hash.
+type APIKey struct {
+	KeyID      string     `json:"key_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"` // requests per minute
+	TenantID   string     `json:"tenant_id"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+}
+
+// IssuedAPIKey is returned once, when the key is created. The full key cannot
+// be recovered afterwards.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
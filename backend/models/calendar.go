@@ -0,0 +1,17 @@
+package models
+
+// Holiday is a public holiday on a calendar date in the business time zone
+type Holiday struct {
+	Date string `json:"date"` // 2006-01-02
+	Name string `json:"name"`
+}
+
+// CalendarFlags describe when a transaction happened in local business time
+type CalendarFlags struct {
+	LocalTime   string `json:"local_time"`
+	IsNight     bool   `json:"is_night"`
+	IsWeekend   bool   `json:"is_weekend"`
+	IsHoliday   bool   `json:"is_holiday"`
+	HolidayName string `json:"holiday_name,omitempty"`
+	IsOffHours  bool   `json:"is_off_hours"` // night, weekend or holiday
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Alert sources
+const (
+	AlertSourceDecision  = "DECISION"  // the transaction was scored Review or Block
+	AlertSourceWatchlist = "WATCHLIST" // a counterparty matched a watchlist
+	AlertSourceDetector  = "DETECTOR"  // a graph or behavioural detector fired
+)
+
+// Severities double as case priorities
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+// Case statuses
+const (
+	CaseOpen          = "OPEN"
+	CaseInvestigating = "INVESTIGATING"
+	CaseEscalated     = "ESCALATED"
+	CaseClosed        = "CLOSED"
+)
+
+// Alert is one signal worth an analyst's attention. Every alert is filed into a case.
+type Alert struct {
+	AlertID       int64     `json:"alert_id"`
+	TenantID      string    `json:"tenant_id"`
+	Source        string    `json:"source"`
+	Type          string    `json:"type"` // e.g. BLOCK, REVIEW, WATCHLIST_HIT, FAN_IN, ROUND_TRIP_CYCLE
+	Severity      string    `json:"severity"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	AccountID     string    `json:"account_id"`
+	Summary       string    `json:"summary"`
+	CaseID        string    `json:"case_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Case groups the alerts on one account, or on one community when the account
+// belongs to one, for investigation
+type Case struct {
+	CaseID       string     `json:"case_id"`
+	TenantID     string     `json:"tenant_id"`
+	Title        string     `json:"title"`
+	GroupType    string     `json:"group_type"` // account or community
+	GroupKey     string     `json:"group_key"`
+	Status       string     `json:"status"`
+	Priority     string     `json:"priority"`
+	Assignee     string     `json:"assignee,omitempty"`
+	AlertCount   int        `json:"alert_count"`
+	Resolution   string     `json:"resolution,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	Transactions []string   `json:"transactions,omitempty"`
+	Accounts     []string   `json:"accounts,omitempty"`
+	Reports      []string   `json:"reports,omitempty"` // STR report IDs filed from this case
+	Notes        []CaseNote `json:"notes,omitempty"`
+	Alerts       []Alert    `json:"alerts,omitempty"`
+}
+
+// CaseNote is an analyst's note on a case
+type CaseNote struct {
+	NoteID    int64     `json:"note_id"`
+	CaseID    string    `json:"case_id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
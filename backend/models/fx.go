@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// FXRate converts one unit of Currency into the base currency from EffectiveDate onwards
+type FXRate struct {
+	Currency      string    `json:"currency"`
+	EffectiveDate string    `json:"effective_date"` // 2006-01-02
+	Rate          float64   `json:"rate"`
+	Source        string    `json:"source,omitempty"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// GeoLocation is a resolved place. Latitude and Longitude are only meaningful when HasCoordinates is set.
+type GeoLocation struct {
+	Country        string  `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	City           string  `json:"city,omitempty"`
+	Latitude       float64 `json:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty"`
+	HasCoordinates bool    `json:"has_coordinates"`
+	Source         string  `json:"source,omitempty"` // "location" or "ip"
+}
+
+// LastLocation is the most recent place an account or device was seen transacting from
+type LastLocation struct {
+	GeoLocation
+	TransactionID string    `json:"transaction_id"`
+	SeenAt        time.Time `json:"seen_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// TransferEdge is a single TRANSFERRED relationship between two accounts
+type TransferEdge struct {
+	TxnID     string    `json:"txn_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    float64   `json:"amount"`
+	Timestamp time.Time `json:"timestamp"`
+	RiskScore float64   `json:"risk_score"`
+
+	VerificationStatus string `json:"verification_status,omitempty"`
+}
+
+// Cycle is a round-trip of funds: money leaving an account and returning to it
+// through one or more intermediaries, with every hop later than the previous one
+type Cycle struct {
+	Accounts        []string       `json:"accounts"` // starts and ends with the same account
+	Edges           []TransferEdge `json:"edges"`
+	TotalAmount     float64        `json:"total_amount"`
+	StartedAt       time.Time      `json:"started_at"`
+	EndedAt         time.Time      `json:"ended_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+}
+
+// Community is a cluster of accounts that transact mostly among themselves
+type Community struct {
+	CommunityID         string    `json:"community_id"`
+	Size                int       `json:"size"`
+	Volume              float64   `json:"volume"` // total amount moved between members
+	TxnCount            int       `json:"txn_count"`
+	FraudConfirmedCount int       `json:"fraud_confirmed_count"`
+	AvgRisk             float64   `json:"avg_risk"`
+	Members             []string  `json:"members"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// SubgraphNode is an account reached while expanding a neighbourhood
+type SubgraphNode struct {
+	ID             string  `json:"id"`
+	Hops           int     `json:"hops"` // distance from the nearest starting account
+	PropagatedRisk float64 `json:"propagated_risk"`
+	CommunityID    string  `json:"community_id,omitempty"`
+	MaxEdgeRisk    float64 `json:"max_edge_risk"`
+}
+
+// Subgraph is a bounded k-hop neighbourhood. Frontier lists accounts that were
+// reached but not expanded; pass them back as the start set to continue.
+type Subgraph struct {
+	Nodes     []SubgraphNode `json:"nodes"`
+	Edges     []TransferEdge `json:"edges"`
+	Frontier  []string       `json:"frontier"`
+	Truncated bool           `json:"truncated"` // the node cap stopped the expansion early
+}
+
+// MoneyPath is a time-respecting chain of transfers from one account to another
+type MoneyPath struct {
+	Accounts        []string       `json:"accounts"`
+	Edges           []TransferEdge `json:"edges"` // one per hop, in order
+	HopCount        int            `json:"hop_count"`
+	MinAmount       float64        `json:"min_amount"` // the most that can have flowed end to end
+	StartedAt       time.Time      `json:"started_at"`
+	EndedAt         time.Time      `json:"ended_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+}
+
+// LinkedAccount is an account seen using a given device or IP address
+type LinkedAccount struct {
+	AccountID string    `json:"account_id"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	TxnCount  int64     `json:"txn_count"`
+}
+
+// Motif types
+const (
+	MotifFanIn         = "FAN_IN"         // many senders to one account in a burst
+	MotifFanOut        = "FAN_OUT"        // one account to many receivers in a burst
+	MotifScatterGather = "SCATTER_GATHER" // one account to many intermediaries back to one account
+)
+
+// Motif is a detected temporal transfer pattern
+type Motif struct {
+	MotifID       int64     `json:"motif_id"`
+	Type          string    `json:"type"`
+	CenterAccount string    `json:"center_account"`           // receiver for fan-in and scatter-gather, sender for fan-out
+	SourceAccount string    `json:"source_account,omitempty"` // origin of a scatter-gather
+	Participants  []string  `json:"participants"`             // the "many" side
+	TxnIDs        []string  `json:"txn_ids"`
+	TxnCount      int       `json:"txn_count"`
+	TotalAmount   float64   `json:"total_amount"`
+	StartedAt     time.Time `json:"started_at"`
+	EndedAt       time.Time `json:"ended_at"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
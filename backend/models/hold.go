@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Hold statuses
+const (
+	HoldHeld     = "HELD"
+	HoldReleased = "RELEASED"
+	HoldRejected = "REJECTED"
+)
+
+// Callback delivery statuses
+const (
+	CallbackPending   = "PENDING"
+	CallbackDelivered = "DELIVERED"
+	CallbackFailed    = "FAILED"
+	CallbackNone      = "NONE" // no callback URL configured
+)
+
+// Hold is a transaction stopped for analyst review. It is released or rejected
+// by an analyst, or by the timeout policy once DueAt passes, and the decision is
+// posted back to the originating core banking system.
+type Hold struct {
+	TransactionID    string     `json:"transaction_id"`
+	TenantID         string     `json:"tenant_id"`
+	Status           string     `json:"status"`
+	SenderAccount    string     `json:"sender_account"`
+	ReceiverAccount  string     `json:"receiver_account"`
+	Amount           float64    `json:"amount"`
+	Currency         string     `json:"currency"`
+	RiskScore        float64    `json:"risk_score"`
+	Reasons          []string   `json:"reasons"`
+	HeldAt           time.Time  `json:"held_at"`
+	DueAt            time.Time  `json:"due_at"`
+	DecidedBy        string     `json:"decided_by,omitempty"` // analyst, or "timeout"
+	Note             string     `json:"note,omitempty"`
+	DecidedAt        *time.Time `json:"decided_at,omitempty"`
+	CallbackURL      string     `json:"callback_url,omitempty"`
+	CallbackStatus   string     `json:"callback_status"`
+	CallbackAttempts int        `json:"callback_attempts"`
+	CallbackError    string     `json:"callback_error,omitempty"`
+}
+
+// HoldDecision is the body posted to the callback URL once a hold is decided
+type HoldDecision struct {
+	TransactionID string    `json:"transaction_id"`
+	Decision      string    `json:"decision"` // RELEASED or REJECTED
+	DecidedBy     string    `json:"decided_by"`
+	Note          string    `json:"note,omitempty"`
+	DecidedAt     time.Time `json:"decided_at"`
+}
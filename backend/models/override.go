@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Override statuses
+const (
+	OverrideActive  = "ACTIVE"
+	OverrideExpired = "EXPIRED"
+	OverrideRevoked = "REVOKED"
+)
+
+// Override pins the action for every transaction touching an account, device
+// or IP, whatever the model scores it: an allowlisted payroll account, a
+// known mule that must always be blocked, or a device held for review
+type Override struct {
+	OverrideID int64      `json:"override_id"`
+	TenantID   string     `json:"tenant_id"`
+	EntityType string     `json:"entity_type"` // account, device or ip
+	EntityID   string     `json:"entity_id"`
+	Action     string     `json:"action"` // Allow, Review or Block
+	Reason     string     `json:"reason"`
+	Author     string     `json:"author"`
+	Status     string     `json:"status"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // nil never expires
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
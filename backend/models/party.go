@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Party is a resolved customer owning one or more accounts, possibly across banks
+type Party struct {
+	PartyID        string         `json:"party_id"`
+	NationalIDHash string         `json:"national_id_hash,omitempty"`
+	Name           string         `json:"name,omitempty"`
+	Phone          string         `json:"phone,omitempty"`
+	PromptPayProxy string         `json:"promptpay_proxy,omitempty"`
+	Accounts       []PartyAccount `json:"accounts"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// PartyAccount records how an account was resolved to its party
+type PartyAccount struct {
+	AccountID  string    `json:"account_id"`
+	MatchRule  string    `json:"match_rule"` // national_id, promptpay, phone, fuzzy_name or new
+	Confidence float64   `json:"confidence"`
+	LinkedAt   time.Time `json:"linked_at"`
+}
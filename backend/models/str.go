@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// STRReport is a suspicious transaction report for the Anti-Money Laundering
+// Office, assembled from a confirmed transaction or a case. Each regeneration
+// for the same subject is stored as a new version under the same ReportID.
+type STRReport struct {
+	XMLName       xml.Name         `json:"-" xml:"SuspiciousTransactionReport"`
+	ReportID      string           `json:"report_id" xml:"ReportID"`
+	Version       int              `json:"version" xml:"Version"`
+	CaseID        string           `json:"case_id,omitempty" xml:"CaseID,omitempty"`
+	TransactionID string           `json:"transaction_id,omitempty" xml:"TransactionID,omitempty"`
+	Institution   STRInstitution   `json:"institution" xml:"ReportingInstitution"`
+	Analyst       string           `json:"analyst" xml:"PreparedBy"`
+	Narrative     string           `json:"narrative" xml:"Narrative"`
+	Reasons       []string         `json:"reasons" xml:"SuspicionIndicators>Indicator"`
+	Parties       []STRParty       `json:"parties" xml:"Parties>Party"`
+	Accounts      []STRAccount     `json:"accounts" xml:"Accounts>Account"`
+	Transactions  []STRTransaction `json:"transactions" xml:"Transactions>Transaction"`
+	TotalAmount   float64          `json:"total_amount" xml:"TotalAmount"`
+	Currency      string           `json:"currency" xml:"Currency"`
+	GeneratedAt   time.Time        `json:"generated_at" xml:"GeneratedAt"`
+}
+
+// STRInstitution identifies the reporting bank
+type STRInstitution struct {
+	Name string `json:"name" xml:"Name"`
+	Code string `json:"code,omitempty" xml:"Code,omitempty"`
+}
+
+// STRParty is a person or business behind the reported accounts
+type STRParty struct {
+	PartyID        string   `json:"party_id" xml:"PartyID"`
+	Name           string   `json:"name,omitempty" xml:"Name,omitempty"`
+	NationalIDHash string   `json:"national_id_hash,omitempty" xml:"NationalIDHash,omitempty"`
+	Phone          string   `json:"phone,omitempty" xml:"Phone,omitempty"`
+	PromptPayProxy string   `json:"promptpay_proxy,omitempty" xml:"PromptPayProxy,omitempty"`
+	Accounts       []string `json:"accounts" xml:"Accounts>AccountID"`
+}
+
+// STRAccount is an account involved in the reported activity
+type STRAccount struct {
+	AccountID string `json:"account_id" xml:"AccountID"`
+	Role      string `json:"role" xml:"Role"` // sender, receiver or both
+	Status    string `json:"status" xml:"Status"`
+	PartyID   string `json:"party_id,omitempty" xml:"PartyID,omitempty"`
+}
+
+// STRTransaction is one reported transfer
+type STRTransaction struct {
+	TransactionID      string    `json:"transaction_id" xml:"TransactionID"`
+	Timestamp          time.Time `json:"timestamp" xml:"Timestamp"`
+	SenderAccount      string    `json:"sender_account" xml:"SenderAccount"`
+	ReceiverAccount    string    `json:"receiver_account" xml:"ReceiverAccount"`
+	Amount             float64   `json:"amount" xml:"Amount"`
+	Currency           string    `json:"currency" xml:"Currency"`
+	OriginalAmount     float64   `json:"original_amount,omitempty" xml:"OriginalAmount,omitempty"`
+	OriginalCurrency   string    `json:"original_currency,omitempty" xml:"OriginalCurrency,omitempty"`
+	RiskScore          float64   `json:"risk_score" xml:"RiskScore"`
+	Action             string    `json:"action" xml:"Action"`
+	VerificationStatus string    `json:"verification_status" xml:"VerificationStatus"`
+	Reasons            []string  `json:"reasons" xml:"Reasons>Reason"`
+}
+
+// STRSummary is one stored report version, without its body
+type STRSummary struct {
+	ReportID      string    `json:"report_id"`
+	Version       int       `json:"version"`
+	CaseID        string    `json:"case_id,omitempty"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Analyst       string    `json:"analyst"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
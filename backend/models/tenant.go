@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Tenant is a participating bank. Every transaction, account, case and user
+// belongs to exactly one; the operator tenant runs the platform itself.
+type Tenant struct {
+	TenantID  string    `json:"tenant_id"`
+	Name      string    `json:"name"`
+	Operator  bool      `json:"operator"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ConsortiumAccount is the risk an account carries across all participating
+// banks. It only holds signals that are safe to share: no amounts,
+// counterparties, transaction IDs or the names of the reporting banks.
+type ConsortiumAccount struct {
+	AccountID      string    `json:"account_id"`
+	Reporters      int       `json:"reporters"` // other banks that have seen the account
+	Transactions   int       `json:"transactions"`
+	MaxRisk        float64   `json:"max_risk"`
+	AvgRisk        float64   `json:"avg_risk"`
+	ConfirmedFraud int       `json:"confirmed_fraud"` // transactions verified as fraud
+	SuspectedMule  int       `json:"suspected_mule"`  // other banks holding it as a suspected mule
+	LastSeen       time.Time `json:"last_seen"`
+}
@@ -15,6 +15,31 @@ type Transaction struct {
 	Channel         string    `json:"channel"`
 	Location        string    `json:"location"`
 	TransactionType string    `json:"transaction_type"`
+
+	// Where the hold/release decision is posted when the transaction goes to Review;
+	// falls back to the configured HOLD_WEBHOOK_URL
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	// Optional KYC details used to resolve accounts to parties
+	SenderParty   *PartyAttributes `json:"sender_party,omitempty"`
+	ReceiverParty *PartyAttributes `json:"receiver_party,omitempty"`
+
+	// Set by the backend before scoring. Amount and Currency are then in the
+	// base currency and the submitted values move to OriginalAmount/OriginalCurrency.
+	Calendar         *CalendarFlags `json:"calendar,omitempty"`
+	OriginalAmount   float64        `json:"original_amount,omitempty"`
+	OriginalCurrency string         `json:"original_currency,omitempty"`
+	FXRate           float64        `json:"fx_rate,omitempty"`    // 0 when no rate was found
+	APIKeyID         string         `json:"api_key_id,omitempty"` // key that submitted it; empty for signed-in users
+	TenantID         string         `json:"tenant_id,omitempty"`  // participating bank that submitted it
+}
+
+// PartyAttributes identify the person or business holding an account
+type PartyAttributes struct {
+	NationalIDHash string `json:"national_id_hash,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	PromptPayProxy string `json:"promptpay_proxy,omitempty"`
 }
 
 type AnalysisResult struct {
@@ -31,4 +56,5 @@ type FraudCheckRequest struct {
 
 type FraudCheckResponse struct {
 	AnalysisResult AnalysisResult `json:"analysis_result"`
+	Hold           *Hold          `json:"hold,omitempty"` // set when the transaction is held for review
 }
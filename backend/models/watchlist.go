@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Watchlist is an imported screening list such as an internal blacklist,
+// the AMLO designated-person list or a UN sanctions list
+type Watchlist struct {
+	ListID     string    `json:"list_id"`
+	Name       string    `json:"name"`
+	Source     string    `json:"source"` // INTERNAL, AMLO, UN, OTHER
+	Action     string    `json:"action"` // Review or Block, applied to confident hits
+	EntryCount int       `json:"entry_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WatchlistEntry is a listed person, organisation or account
+type WatchlistEntry struct {
+	EntryID        int64     `json:"entry_id"`
+	ListID         string    `json:"list_id"`
+	Reference      string    `json:"reference,omitempty"` // identifier on the source list
+	Name           string    `json:"name"`
+	Aliases        []string  `json:"aliases,omitempty"`
+	AccountID      string    `json:"account_id,omitempty"`
+	PromptPayProxy string    `json:"promptpay_proxy,omitempty"`
+	NationalIDHash string    `json:"national_id_hash,omitempty"`
+	AddedAt        time.Time `json:"added_at"`
+}
+
+// Hit statuses
+const (
+	HitOpen      = "OPEN"
+	HitConfirmed = "CONFIRMED"
+	HitDismissed = "DISMISSED"
+)
+
+// WatchlistMatch is one screening match before it is recorded as a hit
+type WatchlistMatch struct {
+	ListID       string  `json:"list_id"`
+	ListName     string  `json:"list_name"`
+	EntryID      int64   `json:"entry_id"`
+	EntryName    string  `json:"entry_name"`
+	MatchedField string  `json:"matched_field"` // account, name, promptpay, national_id
+	MatchedValue string  `json:"matched_value"`
+	MatchedAlias string  `json:"matched_alias,omitempty"`
+	Score        float64 `json:"score"`
+	Action       string  `json:"action"` // Review or Block
+}
+
+// WatchlistHit is a recorded match of a transaction counterparty awaiting adjudication
+type WatchlistHit struct {
+	WatchlistMatch
+	HitID         int64      `json:"hit_id"`
+	TransactionID string     `json:"transaction_id"`
+	Role          string     `json:"role"` // sender or receiver
+	AccountID     string     `json:"account_id"`
+	Status        string     `json:"status"`
+	AdjudicatedBy string     `json:"adjudicated_by,omitempty"`
+	Note          string     `json:"note,omitempty"`
+	AdjudicatedAt *time.Time `json:"adjudicated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		env        string
+		want       []string
+		clientIP   string // seen for a request from 10.0.0.2 forwarded for 203.0.113.9
+		proxyValid bool
+	}{
+		{"", nil, "10.0.0.2", true},
+		{" , ", nil, "10.0.0.2", true},
+		{"10.0.0.2", []string{"10.0.0.2"}, "203.0.113.9", true},
+		{"10.0.0.0/8, 192.168.1.1", []string{"10.0.0.0/8", "192.168.1.1"}, "203.0.113.9", true},
+		{"192.168.1.1", []string{"192.168.1.1"}, "10.0.0.2", true},
+		{"not an address", []string{"not an address"}, "", false},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.env)
+			got := trustedProxies()
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("trustedProxies() = %q, want %q", got, tt.want)
+			}
+
+			r := gin.New()
+			if err := r.SetTrustedProxies(got); (err == nil) != tt.proxyValid {
+				t.Fatalf("SetTrustedProxies error = %v", err)
+			}
+			if !tt.proxyValid {
+				return
+			}
+			var clientIP string
+			r.GET("/", func(c *gin.Context) { clientIP = c.ClientIP() })
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.2:4321"
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			if clientIP != tt.clientIP {
+				t.Errorf("ClientIP = %s, want %s", clientIP, tt.clientIP)
+			}
+		})
+	}
+}
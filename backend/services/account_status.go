@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// AccountStatusService flags the receiving account of confirmed fraud as a
+// suspected mule and holds later transfers into it. The status is kept in
+// SQLite and mirrored onto the Neo4j Account node.
+type AccountStatusService struct {
+	Neo4j *Neo4jService
+	// InboundAction is applied to transfers into a suspected mule: Review or Block
+	InboundAction string
+}
+
+func NewAccountStatusService(neo4j *Neo4jService) *AccountStatusService {
+	return &AccountStatusService{Neo4j: neo4j, InboundAction: "Block"}
+}
+
+// ApplyVerdict updates the receiver's status after tenant's transaction txnID
+// was verified. CONFIRMED_FRAUD flags the receiver; FALSE_POSITIVE restores it
+// to ACTIVE once none of its inbound transfers remain confirmed as fraud.
+// Returns the change made, or nil when the status was already right or txnID
+// is not one of tenant's.
+func (a *AccountStatusService) ApplyVerdict(ctx context.Context, tenant, txnID, verdict, changedBy string) (*models.AccountStatusChange, error) {
+	var receiver string
+	err := db.DB.QueryRow("SELECT receiver_account FROM graph_transactions WHERE txn_id = ? AND tenant_id = ?", txnID, tenant).Scan(&receiver)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetAccountStatus(tenant, receiver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch verdict {
+	case "CONFIRMED_FRAUD":
+		if current == models.AccountSuspectedMule {
+			return nil, nil
+		}
+		return a.setStatus(ctx, tenant, models.AccountStatusChange{
+			AccountID:      receiver,
+			Status:         models.AccountSuspectedMule,
+			PreviousStatus: current,
+			TransactionID:  txnID,
+			Reason:         fmt.Sprintf("Received confirmed fraud in transaction %s", txnID),
+			ChangedBy:      changedBy,
+		})
+	case "FALSE_POSITIVE":
+		if current != models.AccountSuspectedMule {
+			return nil, nil
+		}
+		var confirmed int
+		if err := db.DB.QueryRow(`
+			SELECT count(*) FROM graph_transactions
+			WHERE tenant_id = ? AND receiver_account = ? AND verification_status = 'CONFIRMED_FRAUD'
+		`, tenant, receiver).Scan(&confirmed); err != nil {
+			return nil, err
+		}
+		if confirmed > 0 {
+			return nil, nil
+		}
+		return a.setStatus(ctx, tenant, models.AccountStatusChange{
+			AccountID:      receiver,
+			Status:         models.AccountActive,
+			PreviousStatus: current,
+			TransactionID:  txnID,
+			Reason:         fmt.Sprintf("Transaction %s reversed to FALSE_POSITIVE; no confirmed fraud remains", txnID),
+			ChangedBy:      changedBy,
+		})
+	}
+	return nil, nil
+}
+
+func (a *AccountStatusService) setStatus(ctx context.Context, tenant string, change models.AccountStatusChange) (*models.AccountStatusChange, error) {
+	change.ChangedAt = time.Now()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+		INSERT INTO account_status (tenant_id, account_id, status, reason, txn_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, account_id) DO UPDATE SET
+			status = excluded.status, reason = excluded.reason, txn_id = excluded.txn_id, updated_at = excluded.updated_at
+	`, tenant, change.AccountID, change.Status, change.Reason, change.TransactionID, change.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`
+		INSERT INTO account_status_history (tenant_id, account_id, status, previous_status, txn_id, reason, changed_by, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, tenant, change.AccountID, change.Status, change.PreviousStatus, change.TransactionID, change.Reason, change.ChangedBy, change.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	change.ID, _ = res.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if a.Neo4j != nil && a.Neo4j.Connected {
+		if err := a.saveNeo4jStatus(ctx, tenant, change); err != nil {
+			log.Printf("Failed to write status of %s to Neo4j: %v", change.AccountID, err)
+		}
+	}
+	RecordAudit(change.TransactionID, fmt.Sprintf("ACCOUNT_STATUS %s %s -> %s", change.AccountID, change.PreviousStatus, change.Status))
+	return &change, nil
+}
+
+func (a *AccountStatusService) saveNeo4jStatus(ctx context.Context, tenant string, change models.AccountStatusChange) error {
+	session := a.Neo4j.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MERGE (a:Account {tenant: $tenant, id: $account_id})
+			SET a.status = $status,
+			    a.status_reason = $reason,
+			    a.status_updated_at = $timestamp
+		`
+		return tx.Run(ctx, query, map[string]any{
+			"tenant":     tenant,
+			"account_id": change.AccountID,
+			"status":     change.Status,
+			"reason":     change.Reason,
+			"timestamp":  change.ChangedAt.Format(time.RFC3339),
+		})
+	})
+	return err
+}
+
+// InboundHold returns the action and reason to apply to a transfer into one of
+// tenant's accounts, or "" when its status does not restrict inbound transfers
+func (a *AccountStatusService) InboundHold(tenant, accountID string) (string, string, error) {
+	status, err := GetAccountStatus(tenant, accountID)
+	if err != nil || status != models.AccountSuspectedMule {
+		return "", "", err
+	}
+	return a.InboundAction, fmt.Sprintf("Receiver %s is flagged %s after confirmed fraud", accountID, status), nil
+}
+
+// GetAccountStatus returns the status tenant holds for the account, ACTIVE when
+// never flagged
+func GetAccountStatus(tenant, accountID string) (string, error) {
+	var status string
+	err := db.DB.QueryRow("SELECT status FROM account_status WHERE tenant_id = ? AND account_id = ?", tenant, accountID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.AccountActive, nil
+	}
+	return status, err
+}
+
+// AccountStatusHistory returns the account's status changes within tenant, newest first
+func AccountStatusHistory(tenant, accountID string, limit int) ([]models.AccountStatusChange, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, account_id, status, previous_status, coalesce(txn_id, ''), reason, coalesce(changed_by, ''), changed_at
+		FROM account_status_history
+		WHERE tenant_id = ? AND account_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, tenant, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.AccountStatusChange{}
+	for rows.Next() {
+		var c models.AccountStatusChange
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.Status, &c.PreviousStatus, &c.TransactionID, &c.Reason, &c.ChangedBy, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// setVerification records an analyst verdict on a transfer, as VerifyTransaction does
+func setVerification(t *testing.T, txnID, status string) {
+	t.Helper()
+	if _, err := db.DB.Exec("UPDATE graph_transactions SET verification_status = ? WHERE txn_id = ?", status, txnID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyVerdict(t *testing.T) {
+	ctx := context.Background()
+	a := NewAccountStatusService(&Neo4jService{})
+	mule := testID("MULE")
+	at := time.Now().Add(-time.Hour)
+	first := addTransfer(t, testID("VIC"), mule, 5000, at)
+	second := addTransfer(t, testID("VIC"), mule, 7000, at.Add(time.Minute))
+
+	steps := []struct {
+		name    string
+		txnID   string
+		verdict string
+		change  string // status the receiver moves to, "" for no change
+		status  string
+	}{
+		{"confirmed fraud flags the receiver", first, "CONFIRMED_FRAUD", models.AccountSuspectedMule, models.AccountSuspectedMule},
+		{"a second confirmation changes nothing", second, "CONFIRMED_FRAUD", "", models.AccountSuspectedMule},
+		{"other confirmed fraud keeps the flag", first, "FALSE_POSITIVE", "", models.AccountSuspectedMule},
+		{"last reversal restores the receiver", second, "FALSE_POSITIVE", models.AccountActive, models.AccountActive},
+		{"reversal of an active account", second, "FALSE_POSITIVE", "", models.AccountActive},
+		{"unknown transaction", testID("TXN"), "CONFIRMED_FRAUD", "", models.AccountActive},
+	}
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			setVerification(t, s.txnID, s.verdict)
+			change, err := a.ApplyVerdict(ctx, DefaultTenant, s.txnID, s.verdict, "analyst")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (change == nil) != (s.change == "") || (change != nil && (change.Status != s.change || change.TransactionID != s.txnID)) {
+				t.Errorf("change = %+v, want status %q", change, s.change)
+			}
+			if status, err := GetAccountStatus(DefaultTenant, mule); err != nil || status != s.status {
+				t.Errorf("status = %q, %v; want %q", status, err, s.status)
+			}
+		})
+	}
+
+	history, err := AccountStatusHistory(DefaultTenant, mule, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Status != models.AccountActive || history[0].PreviousStatus != models.AccountSuspectedMule ||
+		history[1].Status != models.AccountSuspectedMule || history[1].ChangedBy != "analyst" {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestApplyVerdictByTenant(t *testing.T) {
+	a := NewAccountStatusService(&Neo4jService{})
+	bank := newTestTenant(t)
+	mule := testID("MULE")
+	txnID := addTenantTransfer(t, bank, testID("VIC"), mule, 5000, time.Now().Add(-time.Hour))
+	setVerification(t, txnID, "CONFIRMED_FRAUD")
+
+	if change, err := a.ApplyVerdict(context.Background(), DefaultTenant, txnID, "CONFIRMED_FRAUD", "analyst"); change != nil || err != nil {
+		t.Errorf("ApplyVerdict on another tenant's transaction = %+v, %v", change, err)
+	}
+	if status, err := GetAccountStatus(bank, mule); err != nil || status != models.AccountActive {
+		t.Errorf("status = %q, %v; want it left %q", status, err, models.AccountActive)
+	}
+	change, err := a.ApplyVerdict(context.Background(), bank, txnID, "CONFIRMED_FRAUD", "analyst")
+	if err != nil || change == nil || change.Status != models.AccountSuspectedMule {
+		t.Errorf("ApplyVerdict = %+v, %v", change, err)
+	}
+}
+
+func TestInboundHold(t *testing.T) {
+	a := NewAccountStatusService(&Neo4jService{})
+	a.InboundAction = "Review"
+	mule, clean := testID("MULE"), testID("ACC")
+	txnID := addTransfer(t, testID("VIC"), mule, 5000, time.Now().Add(-time.Hour))
+	setVerification(t, txnID, "CONFIRMED_FRAUD")
+	if _, err := a.ApplyVerdict(context.Background(), DefaultTenant, txnID, "CONFIRMED_FRAUD", "analyst"); err != nil {
+		t.Fatal(err)
+	}
+
+	if action, reason, err := a.InboundHold(DefaultTenant, mule); action != "Review" || reason == "" || err != nil {
+		t.Errorf("InboundHold(mule) = %q, %q, %v", action, reason, err)
+	}
+	if action, _, err := a.InboundHold(DefaultTenant, clean); action != "" || err != nil {
+		t.Errorf("InboundHold(clean) = %q, %v", action, err)
+	}
+}
@@ -31,13 +31,19 @@ type AnalysisRequest struct {
 // AnalyzeTransactionWithContext sends transaction + graph context to AI service
 func (c *AIClients) AnalyzeTransactionWithContext(txn models.Transaction, receiverContext map[string]any) (models.AnalysisResult, error) {
 	// Rule-Based Pre-check (Hybrid Approach)
-	// If amount > 100,000, flag immediately as High Risk
+	// If amount > 100,000, flag immediately as High Risk.
+	// txn.Amount has been normalised to the base currency (THB) by FXService.
 	if txn.Amount > 100000 {
+		reason := "Amount exceeds 100,000 THB threshold"
+		if txn.OriginalCurrency != "" && txn.OriginalCurrency != txn.Currency {
+			reason = fmt.Sprintf("Amount exceeds 100,000 THB threshold (%.2f %s = %.2f %s)",
+				txn.OriginalAmount, txn.OriginalCurrency, txn.Amount, txn.Currency)
+		}
 		return models.AnalysisResult{
 			TransactionID: txn.TransactionID,
 			RiskScore:     90.0,
 			Action:        "Review",
-			Reasons:       []string{"Amount exceeds 100,000 THB threshold"},
+			Reasons:       []string{reason},
 			Timestamp:     time.Now().Format(time.RFC3339),
 		}, nil
 	}
@@ -81,3 +87,33 @@ func (c *AIClients) AnalyzeTransactionWithContext(txn models.Transaction, receiv
 func (c *AIClients) AnalyzeTransaction(txn models.Transaction) (models.AnalysisResult, error) {
 	return c.AnalyzeTransactionWithContext(txn, nil)
 }
+
+// GetRule fetches one rule's current settings from the AI service's rule
+// engine, or nil if it is not found
+func (c *AIClients) GetRule(ruleID string) (map[string]any, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/rules")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AI service returned status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Groups []struct {
+			Rules []map[string]any `json:"rules"`
+		} `json:"groups"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	for _, group := range body.Groups {
+		for _, rule := range group.Rules {
+			if id, _ := rule["id"].(string); id == ruleID {
+				return rule, nil
+			}
+		}
+	}
+	return nil, nil
+}
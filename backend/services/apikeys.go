@@ -0,0 +1,275 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// Scopes an API key can be granted. Keys never get user permissions; each
+// route that machines may call names the scope it accepts.
+const (
+	ScopeIngest    = "ingest"     // POST /api/bank/transaction
+	ScopeReadGraph = "read:graph" // graph, account, path and entity lookups
+	ScopeVerify    = "verify"     // post a verdict on a transaction
+)
+
+var apiKeyScopes = []string{ScopeIngest, ScopeReadGraph, ScopeVerify}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key is already revoked")
+	ErrRateLimited    = errors.New("api key rate limit exceeded")
+)
+
+// APIKeyService issues and checks API keys. A key looks like
+// sk_<8 hex>.<secret>: the sk_ part is the key ID shown in listings and logs,
+// and only a SHA-256 hash of the whole key is stored. Each key has a
+// per-minute request budget counted in memory.
+type APIKeyService struct {
+	DefaultTTL       time.Duration // expiry when the request names none; 0 = never
+	DefaultRateLimit int           // requests per minute when the request names none
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		DefaultTTL:       90 * 24 * time.Hour,
+		DefaultRateLimit: 600,
+		windows:          map[string]*rateWindow{},
+	}
+}
+
+// APIKeyRequest describes a key to issue. ExpiresIn overrides DefaultTTL.
+type APIKeyRequest struct {
+	TenantID  string // the bank whose data the key reads and writes
+	Name      string
+	Scopes    []string
+	RateLimit int
+	ExpiresIn time.Duration
+	CreatedBy string
+}
+
+// Issue creates a key and returns it in full, the only time it is available
+func (s *APIKeyService) Issue(req APIKeyRequest) (*models.IssuedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required (%s)", strings.Join(apiKeyScopes, ", "))
+	}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q (valid: %s)", scope, strings.Join(apiKeyScopes, ", "))
+		}
+		scopes = appendUnique(scopes, scope)
+	}
+	if _, err := GetTenant(req.TenantID); err != nil {
+		return nil, err
+	}
+	if req.RateLimit < 0 || req.ExpiresIn < 0 {
+		return nil, errors.New("rate_limit and expires_in cannot be negative")
+	}
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = s.DefaultRateLimit
+	}
+	ttl := req.ExpiresIn
+	if ttl == 0 {
+		ttl = s.DefaultTTL
+	}
+
+	id := make([]byte, 4)
+	_, _ = rand.Read(id)
+	now := time.Now().UTC()
+	issued := &models.IssuedAPIKey{
+		APIKey: models.APIKey{
+			KeyID:     "sk_" + hex.EncodeToString(id),
+			Name:      name,
+			Scopes:    scopes,
+			RateLimit: rateLimit,
+			TenantID:  req.TenantID,
+			CreatedBy: req.CreatedBy,
+			CreatedAt: now,
+		},
+	}
+	issued.Key = issued.KeyID + "." + newToken("")
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		issued.ExpiresAt = &expires
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO api_keys (key_id, tenant_id, name, key_hash, scopes, rate_limit, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, issued.KeyID, issued.TenantID, issued.Name, tokenHash(issued.Key), strings.Join(scopes, ","), rateLimit, issued.ExpiresAt, issued.CreatedBy, now)
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// Authenticate resolves a presented key and charges it one request. It
+// returns ErrInvalidToken for unknown, revoked or expired keys and
+// ErrRateLimited with the time the budget resets.
+func (s *APIKeyService) Authenticate(key string) (*models.APIKey, time.Time, error) {
+	key = strings.TrimSpace(key)
+	keyID, _, ok := strings.Cut(key, ".")
+	if !ok || !IsAPIKey(key) {
+		return nil, time.Time{}, ErrInvalidToken
+	}
+	var hash string
+	apiKey, err := scanAPIKey(db.DB.QueryRow(`SELECT `+apiKeyColumns+`, key_hash FROM api_keys WHERE key_id = ?`, keyID), &hash)
+	if err == sql.ErrNoRows {
+		return nil, time.Time{}, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	now := time.Now().UTC()
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(tokenHash(key))) != 1 ||
+		apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now)) {
+		return nil, time.Time{}, ErrInvalidToken
+	}
+
+	s.mu.Lock()
+	w := s.windows[keyID]
+	fresh := w == nil || now.Sub(w.start) >= time.Minute
+	if fresh {
+		w = &rateWindow{start: now}
+		s.windows[keyID] = w
+	}
+	w.count++
+	count, reset := w.count, w.start.Add(time.Minute)
+	s.mu.Unlock()
+	if count > apiKey.RateLimit {
+		return &apiKey, reset, ErrRateLimited
+	}
+
+	// last_used_at is refreshed once per rate window rather than per request
+	if fresh {
+		_, _ = db.DB.Exec("UPDATE api_keys SET last_used_at = ? WHERE key_id = ?", now, keyID)
+	}
+	return &apiKey, reset, nil
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a
+// session token
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, "sk_")
+}
+
+// HasScope reports whether the key was granted any of the scopes
+func HasScope(key *models.APIKey, scopes ...string) bool {
+	for _, granted := range key.Scopes {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ListAPIKeys returns tenant's keys, or every key when tenant is empty, newest
+// first, hiding revoked ones unless asked
+func ListAPIKeys(tenant string, includeRevoked bool) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE (? = '' OR tenant_id = ?)`
+	if !includeRevoked {
+		query += ` AND revoked_at IS NULL`
+	}
+	rows, err := db.DB.Query(query+` ORDER BY created_at DESC`, tenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKey loads one key by ID
+func GetAPIKey(keyID string) (*models.APIKey, error) {
+	k, err := scanAPIKey(db.DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_id = ?`, keyID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// RevokeAPIKey stops a key from working immediately
+func RevokeAPIKey(keyID, by string) (*models.APIKey, error) {
+	res, err := db.DB.Exec("UPDATE api_keys SET revoked_at = ?, revoked_by = ? WHERE key_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), by, keyID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := GetAPIKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return key, ErrAPIKeyRevoked
+	}
+	return key, nil
+}
+
+// apiKeyColumns is the column list read by scanAPIKey
+const apiKeyColumns = `key_id, coalesce(tenant_id, 'SYNAPSE'), name, scopes, rate_limit, expires_at, coalesce(created_by, ''), created_at, last_used_at, revoked_at, coalesce(revoked_by, '')`
+
+// scanAPIKey reads apiKeyColumns, followed by any extra columns into extra
+func scanAPIKey(row interface{ Scan(...any) error }, extra ...any) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	var expires, lastUsed, revoked sql.NullTime
+	dest := append([]any{&k.KeyID, &k.TenantID, &k.Name, &scopes, &k.RateLimit, &expires, &k.CreatedBy, &k.CreatedAt, &lastUsed, &revoked, &k.RevokedBy}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return k, err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	if expires.Valid {
+		k.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return k, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+func TestIssueAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     APIKeyRequest
+		wantErr bool
+	}{
+		{"valid", APIKeyRequest{TenantID: "SCB", Name: "core banking", Scopes: []string{ScopeIngest, ScopeIngest}}, false},
+		{"no name", APIKeyRequest{TenantID: "SCB", Name: " ", Scopes: []string{ScopeIngest}}, true},
+		{"no scopes", APIKeyRequest{TenantID: "SCB", Name: "core banking"}, true},
+		{"unknown scope", APIKeyRequest{TenantID: "SCB", Name: "core banking", Scopes: []string{"admin"}}, true},
+		{"unknown tenant", APIKeyRequest{TenantID: "NOPE", Name: "core banking", Scopes: []string{ScopeIngest}}, true},
+		{"negative rate limit", APIKeyRequest{TenantID: "SCB", Name: "core banking", Scopes: []string{ScopeIngest}, RateLimit: -1}, true},
+		{"negative expiry", APIKeyRequest{TenantID: "SCB", Name: "core banking", Scopes: []string{ScopeIngest}, ExpiresIn: -time.Hour}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewAPIKeyService()
+			issued, err := svc.Issue(tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Issue accepted %+v", tt.req)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			if !strings.HasPrefix(issued.Key, issued.KeyID+".") || !IsAPIKey(issued.Key) {
+				t.Errorf("key %q does not start with its ID %q", issued.Key, issued.KeyID)
+			}
+			if len(issued.Scopes) != 1 || issued.RateLimit != svc.DefaultRateLimit || issued.ExpiresAt == nil {
+				t.Errorf("issued %+v", issued.APIKey)
+			}
+			var stored string
+			db.DB.QueryRow("SELECT key_hash FROM api_keys WHERE key_id = ?", issued.KeyID).Scan(&stored)
+			if stored != tokenHash(issued.Key) {
+				t.Errorf("stored hash %q does not match the key", stored)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	svc := NewAPIKeyService()
+	issue := func(t *testing.T) *models.IssuedAPIKey {
+		t.Helper()
+		issued, err := svc.Issue(APIKeyRequest{TenantID: "SCB", Name: "core banking", Scopes: []string{ScopeIngest}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return issued
+	}
+
+	tests := []struct {
+		name    string
+		present func(t *testing.T, issued *models.IssuedAPIKey) string
+		want    error
+	}{
+		{"valid key", func(t *testing.T, k *models.IssuedAPIKey) string { return k.Key }, nil},
+		{"surrounding space", func(t *testing.T, k *models.IssuedAPIKey) string { return " " + k.Key + "\n" }, nil},
+		{"wrong secret", func(t *testing.T, k *models.IssuedAPIKey) string { return k.KeyID + "." + newToken("") }, ErrInvalidToken},
+		{"unknown key ID", func(t *testing.T, k *models.IssuedAPIKey) string {
+			return "sk_00000000." + strings.SplitN(k.Key, ".", 2)[1]
+		}, ErrInvalidToken},
+		{"no secret", func(t *testing.T, k *models.IssuedAPIKey) string { return k.KeyID }, ErrInvalidToken},
+		{"session token", func(t *testing.T, k *models.IssuedAPIKey) string { return newToken("sat_") }, ErrInvalidToken},
+		{"revoked", func(t *testing.T, k *models.IssuedAPIKey) string {
+			if _, err := RevokeAPIKey(k.KeyID, "admin"); err != nil {
+				t.Fatal(err)
+			}
+			return k.Key
+		}, ErrInvalidToken},
+		{"expired", func(t *testing.T, k *models.IssuedAPIKey) string {
+			db.DB.Exec("UPDATE api_keys SET expires_at = ? WHERE key_id = ?", time.Now().UTC().Add(-time.Second), k.KeyID)
+			return k.Key
+		}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issued := issue(t)
+			key, _, err := svc.Authenticate(tt.present(t, issued))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (key.KeyID != issued.KeyID || key.TenantID != "SCB") {
+				t.Errorf("authenticated as %+v", key)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	svc := NewAPIKeyService()
+	a, err := svc.Issue(APIKeyRequest{TenantID: "SCB", Name: "a", Scopes: []string{ScopeIngest}, RateLimit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := svc.Issue(APIKeyRequest{TenantID: "SCB", Name: "b", Scopes: []string{ScopeIngest}, RateLimit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		if _, _, err := svc.Authenticate(a.Key); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if used, err := GetAPIKey(a.KeyID); err != nil || used.LastUsedAt == nil {
+		t.Errorf("last use not recorded: %+v, %v", used, err)
+	}
+	_, reset, err := svc.Authenticate(a.Key)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("request 4: %v, want ErrRateLimited", err)
+	}
+	if reset.Before(start.Add(59*time.Second)) || reset.After(time.Now().Add(time.Minute)) {
+		t.Errorf("budget resets at %v, want a minute after %v", reset, start)
+	}
+	if _, _, err := svc.Authenticate(b.Key); err != nil {
+		t.Errorf("another key shares the budget: %v", err)
+	}
+
+	// A new window starts once the minute is over
+	svc.mu.Lock()
+	svc.windows[a.KeyID].start = start.Add(-time.Minute)
+	svc.mu.Unlock()
+	if _, _, err := svc.Authenticate(a.Key); err != nil {
+		t.Errorf("after the window: %v", err)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	key := &models.APIKey{Scopes: []string{ScopeIngest, ScopeVerify}}
+	tests := []struct {
+		scopes []string
+		want   bool
+	}{
+		{[]string{ScopeIngest}, true},
+		{[]string{ScopeVerify}, true},
+		{[]string{ScopeReadGraph}, false},
+		{[]string{ScopeReadGraph, ScopeVerify}, true},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := HasScope(key, tt.scopes...); got != tt.want {
+			t.Errorf("HasScope(%v) = %v, want %v", tt.scopes, got, tt.want)
+		}
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	svc := NewAPIKeyService()
+	live, err := svc.Issue(APIKeyRequest{TenantID: "KBANK", Name: "live", Scopes: []string{ScopeReadGraph}, CreatedBy: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := svc.Issue(APIKeyRequest{TenantID: "KBANK", Name: "revoked", Scopes: []string{ScopeReadGraph}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, err := RevokeAPIKey(revoked.KeyID, "admin"); err != nil || k.RevokedAt == nil || k.RevokedBy != "admin" {
+		t.Fatalf("RevokeAPIKey = %+v, %v", k, err)
+	}
+	if _, err := RevokeAPIKey(revoked.KeyID, "admin"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("second revoke = %v, want ErrAPIKeyRevoked", err)
+	}
+	if _, err := RevokeAPIKey("sk_missing", "admin"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("unknown key = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	tests := []struct {
+		tenant         string
+		includeRevoked bool
+		want           []string
+		notWant        []string
+	}{
+		{"KBANK", false, []string{live.KeyID}, []string{revoked.KeyID}},
+		{"KBANK", true, []string{live.KeyID, revoked.KeyID}, nil},
+		{"", true, []string{live.KeyID, revoked.KeyID}, nil},
+		{"SCB", true, nil, []string{live.KeyID, revoked.KeyID}},
+	}
+	for _, tt := range tests {
+		keys, err := ListAPIKeys(tt.tenant, tt.includeRevoked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed := map[string]bool{}
+		for _, k := range keys {
+			listed[k.KeyID] = true
+		}
+		for _, id := range tt.want {
+			if !listed[id] {
+				t.Errorf("ListAPIKeys(%q, %v) is missing %s", tt.tenant, tt.includeRevoked, id)
+			}
+		}
+		for _, id := range tt.notWant {
+			if listed[id] {
+				t.Errorf("ListAPIKeys(%q, %v) lists %s", tt.tenant, tt.includeRevoked, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var (
+	ErrApprovalNotFound = errors.New("approval not found")
+	ErrApprovalDecided  = errors.New("approval has already been decided")
+	ErrApprovalPending  = errors.New("an approval for this is already pending")
+	ErrSelfApproval     = errors.New("the proposer cannot approve or reject their own proposal")
+	ErrNotReviewer      = errors.New("user is not allowed to approve proposals")
+)
+
+// ProposeApproval queues an action for a second user of the same tenant to
+// approve. Only one proposal per kind and target may be pending at a time.
+func ProposeApproval(tenant, kind, target string, payload any, proposedBy string) (*models.Approval, error) {
+	if strings.TrimSpace(proposedBy) == "" {
+		return nil, errors.New("proposer is required")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	a := models.Approval{
+		TenantID:   tenant,
+		Kind:       kind,
+		Target:     target,
+		Payload:    body,
+		Status:     models.ApprovalPending,
+		ProposedBy: proposedBy,
+		ProposedAt: time.Now().UTC(),
+	}
+	res, err := db.DB.Exec(`
+		INSERT INTO approvals (tenant_id, kind, target, payload, status, proposed_by, proposed_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM approvals WHERE tenant_id = ? AND kind = ? AND target = ? AND status = ?)
+	`, a.TenantID, a.Kind, a.Target, string(a.Payload), a.Status, a.ProposedBy, a.ProposedAt,
+		a.TenantID, a.Kind, a.Target, models.ApprovalPending)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrApprovalPending
+	}
+	a.ApprovalID, _ = res.LastInsertId()
+	RecordAudit(auditTxnID(a), fmt.Sprintf("APPROVAL_PROPOSED #%d %s %s by %s: %s", a.ApprovalID, a.Kind, a.Target, a.ProposedBy, a.Payload))
+	return &a, nil
+}
+
+// DecideApproval approves or rejects one of tenant's pending proposals. The reviewer's role
+// must grant PermApprove and the reviewer must not be the proposer. On approval, apply is run and
+// the proposal goes back to PENDING if it fails, so nothing is half-applied.
+func DecideApproval(tenant string, id int64, reviewer string, approve bool, reason string, apply func(models.Approval) error) (*models.Approval, error) {
+	a, err := GetApproval(tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != models.ApprovalPending {
+		return a, ErrApprovalDecided
+	}
+	if strings.EqualFold(a.ProposedBy, reviewer) {
+		return a, ErrSelfApproval
+	}
+	if !IsReviewer(tenant, reviewer) {
+		return a, ErrNotReviewer
+	}
+	if !approve && strings.TrimSpace(reason) == "" {
+		return a, errors.New("a rejection reason is required")
+	}
+
+	status := models.ApprovalRejected
+	if approve {
+		status = models.ApprovalApproved
+	}
+	now := time.Now().UTC()
+	res, err := db.DB.Exec(`
+		UPDATE approvals SET status = ?, decided_by = ?, decided_at = ?, rejection_reason = ?
+		WHERE approval_id = ? AND status = ?
+	`, status, reviewer, now, reason, id, models.ApprovalPending)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return a, ErrApprovalDecided
+	}
+	a.Status, a.DecidedBy, a.DecidedAt, a.RejectionReason = status, reviewer, &now, reason
+
+	if approve {
+		if err := apply(*a); err != nil {
+			_, _ = db.DB.Exec(`
+				UPDATE approvals SET status = ?, decided_by = NULL, decided_at = NULL, rejection_reason = NULL
+				WHERE approval_id = ?
+			`, models.ApprovalPending, id)
+			return nil, fmt.Errorf("applying approval #%d: %w", id, err)
+		}
+		RecordAudit(auditTxnID(*a), fmt.Sprintf("APPROVAL_APPROVED #%d %s %s by %s", a.ApprovalID, a.Kind, a.Target, reviewer))
+	} else {
+		RecordAudit(auditTxnID(*a), fmt.Sprintf("APPROVAL_REJECTED #%d %s %s by %s: %s", a.ApprovalID, a.Kind, a.Target, reviewer, reason))
+	}
+	return a, nil
+}
+
+// auditTxnID is the transaction an approval is about, if any
+func auditTxnID(a models.Approval) string {
+	if a.Kind == models.ApprovalVerdict {
+		return a.Target
+	}
+	return ""
+}
+
+// IsReviewer reports whether the user is an active member of tenant whose role grants PermApprove
+func IsReviewer(tenant, username string) bool {
+	var role string
+	if err := db.DB.QueryRow("SELECT coalesce(role, '') FROM users WHERE username = ? AND tenant_id = ? AND coalesce(disabled, 0) = 0", username, tenant).Scan(&role); err != nil {
+		return false
+	}
+	return HasPermission(role, PermApprove)
+}
+
+// GetApproval loads one of tenant's proposals
+func GetApproval(tenant string, id int64) (*models.Approval, error) {
+	a, err := scanApproval(db.DB.QueryRow(`SELECT `+approvalColumns+` FROM approvals WHERE approval_id = ? AND tenant_id = ?`, id, tenant))
+	if err == sql.ErrNoRows {
+		return nil, ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListApprovals returns tenant's proposals oldest first, optionally by status and kind
+func ListApprovals(tenant, status, kind string, limit int) ([]models.Approval, error) {
+	query := `SELECT ` + approvalColumns + ` FROM approvals WHERE tenant_id = ?`
+	args := []any{tenant}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, strings.ToUpper(status))
+	}
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, strings.ToUpper(kind))
+	}
+	query += " ORDER BY proposed_at ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	approvals := []models.Approval{}
+	for rows.Next() {
+		a, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
+
+// approvalColumns is the column list read by scanApproval
+const approvalColumns = `approval_id, coalesce(tenant_id, ''), kind, target, payload, status, proposed_by, proposed_at,
+	coalesce(decided_by, ''), decided_at, coalesce(rejection_reason, '')`
+
+func scanApproval(row interface{ Scan(...any) error }) (models.Approval, error) {
+	var a models.Approval
+	var payload string
+	var decided sql.NullTime
+	err := row.Scan(&a.ApprovalID, &a.TenantID, &a.Kind, &a.Target, &payload, &a.Status, &a.ProposedBy, &a.ProposedAt,
+		&a.DecidedBy, &decided, &a.RejectionReason)
+	a.Payload = json.RawMessage(payload)
+	if decided.Valid {
+		a.DecidedAt = &decided.Time
+	}
+	return a, err
+}
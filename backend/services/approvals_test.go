@@ -0,0 +1,124 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"bank-fraud-demo/models"
+)
+
+// errAny stands for an error with no sentinel to match
+var errAny = errors.New("any error")
+
+func TestDecideApproval(t *testing.T) {
+	maker, checker, peer := newTestUser(t, "analyst").Username, newTestUser(t, "supervisor").Username, newTestUser(t, "analyst").Username
+	target := testID("TXN")
+	a, err := ProposeApproval(DefaultTenant, models.ApprovalVerdict, target, map[string]string{"status": "CONFIRMED_FRAUD"}, maker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProposeApproval(DefaultTenant, models.ApprovalVerdict, target, nil, peer); !errors.Is(err, ErrApprovalPending) {
+		t.Errorf("second pending proposal: %v", err)
+	}
+	if _, err := ProposeApproval(DefaultTenant, models.ApprovalVerdict, testID("TXN"), nil, " "); err == nil {
+		t.Error("proposal without a proposer accepted")
+	}
+
+	var applied []models.Approval
+	apply := func(a models.Approval) error {
+		applied = append(applied, a)
+		return nil
+	}
+	failing := func(models.Approval) error { return errors.New("store down") }
+
+	steps := []struct {
+		name     string
+		reviewer string
+		approve  bool
+		reason   string
+		apply    func(models.Approval) error
+		err      error // nil for success, errAny for an unnamed error
+		status   string
+	}{
+		{"the proposer", maker, true, "", apply, ErrSelfApproval, models.ApprovalPending},
+		{"the proposer in another case", "ANALYST" + maker[len("analyst"):], true, "", apply, ErrSelfApproval, models.ApprovalPending},
+		{"an analyst", peer, true, "", apply, ErrNotReviewer, models.ApprovalPending},
+		{"an unknown user", testID("nobody"), true, "", apply, ErrNotReviewer, models.ApprovalPending},
+		{"a rejection without a reason", checker, false, " ", apply, errAny, models.ApprovalPending},
+		{"apply fails", checker, true, "", failing, errAny, models.ApprovalPending},
+		{"approved", checker, true, "", apply, nil, models.ApprovalApproved},
+		{"decided twice", checker, false, "changed my mind", apply, ErrApprovalDecided, models.ApprovalApproved},
+	}
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			_, err := DecideApproval(DefaultTenant, a.ApprovalID, s.reviewer, s.approve, s.reason, s.apply)
+			switch {
+			case s.err == nil && err != nil, s.err != nil && err == nil:
+				t.Fatalf("DecideApproval = %v, want %v", err, s.err)
+			case s.err != nil && s.err != errAny && !errors.Is(err, s.err):
+				t.Fatalf("DecideApproval = %v, want %v", err, s.err)
+			}
+			got, err := GetApproval(DefaultTenant, a.ApprovalID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != s.status || (got.Status == models.ApprovalPending) != (got.DecidedAt == nil) {
+				t.Errorf("approval = %+v", got)
+			}
+		})
+	}
+
+	if len(applied) != 1 || applied[0].DecidedBy != checker || string(applied[0].Payload) != `{"status":"CONFIRMED_FRAUD"}` {
+		t.Errorf("applied = %+v", applied)
+	}
+	if n := auditCount(t, fmt.Sprintf("APPROVAL_APPROVED #%d ", a.ApprovalID)); n != 1 {
+		t.Errorf("%d approval audit entries", n)
+	}
+	if _, err := DecideApproval(DefaultTenant, -1, checker, true, "", apply); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("deciding a missing approval: %v", err)
+	}
+
+	// Once decided, the same target can be proposed again
+	again, err := ProposeApproval(DefaultTenant, models.ApprovalVerdict, target, map[string]string{"status": "FALSE_POSITIVE"}, peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := DecideApproval(DefaultTenant, again.ApprovalID, checker, false, "evidence is clear", apply)
+	if err != nil || rejected.Status != models.ApprovalRejected || rejected.RejectionReason != "evidence is clear" || len(applied) != 1 {
+		t.Errorf("rejection = %+v, %v", rejected, err)
+	}
+}
+
+func TestListApprovals(t *testing.T) {
+	maker := newTestUser(t, "analyst").Username
+	override, err := ProposeApproval(DefaultTenant, models.ApprovalOverride, testID("ACC"), models.Override{EntityType: "account", Action: "Block"}, maker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := ListApprovals(DefaultTenant, "pending", "override", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, a := range pending {
+		if a.Kind != models.ApprovalOverride || a.Status != models.ApprovalPending {
+			t.Errorf("listed %+v", a)
+		}
+		if a.ApprovalID == override.ApprovalID {
+			found = true
+			var ov models.Override
+			if err := json.Unmarshal(a.Payload, &ov); err != nil || ov.Action != "Block" {
+				t.Errorf("payload = %s, %v", a.Payload, err)
+			}
+		}
+	}
+	if !found {
+		t.Error("pending override proposal not listed")
+	}
+	if verdicts, _ := ListApprovals(DefaultTenant, "", models.ApprovalVerdict, 1000); len(verdicts) > 0 && verdicts[0].Kind != models.ApprovalVerdict {
+		t.Errorf("kind filter ignored: %+v", verdicts[0])
+	}
+}
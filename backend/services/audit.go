@@ -0,0 +1,230 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// auditMu serialises appends so each entry chains onto the one before it
+var auditMu sync.Mutex
+
+// RecordAudit appends a system entry to audit_logs. txnID may be empty for
+// actions that are not about a single transaction. Failures are logged, not
+// returned, so auditing never blocks the action being audited.
+func RecordAudit(txnID, action string) {
+	WriteAudit(models.AuditEntry{TransactionID: txnID, Action: action, Actor: "system"})
+}
+
+// WriteAudit appends an entry to the hash-chained audit trail. Failures are
+// logged, not returned, like RecordAudit.
+func WriteAudit(e models.AuditEntry) {
+	if err := appendAudit(&e); err != nil {
+		log.Printf("Failed to write audit log %q: %v", e.Action, err)
+	}
+}
+
+func appendAudit(e *models.AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastID int64
+	var lastHash string
+	err = tx.QueryRow("SELECT id, coalesce(hash, '') FROM audit_logs ORDER BY id DESC LIMIT 1").Scan(&lastID, &lastHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if lastHash == "" {
+		// Legacy rows predate chaining; the chain starts at the last hashed row
+		_ = tx.QueryRow("SELECT coalesce(hash, '') FROM audit_logs WHERE coalesce(hash, '') != '' ORDER BY id DESC LIMIT 1").Scan(&lastHash)
+	}
+
+	e.ID = lastID + 1
+	e.Timestamp = time.Now().UTC()
+	e.PrevHash = lastHash
+	e.Hash = auditHash(*e)
+	if _, err := tx.Exec(`
+		INSERT INTO audit_logs (id, txn_id, tenant_id, action, timestamp, actor, ip, request_id, status, before_state, after_state, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ID, e.TransactionID, e.TenantID, e.Action, e.Timestamp.Format(time.RFC3339Nano), e.Actor, e.IP, e.RequestID, e.Status,
+		string(e.Before), string(e.After), e.PrevHash, e.Hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// auditHash is the SHA-256 of the entry's fields and the previous hash,
+// encoded as a JSON array so no two different entries serialise the same. The
+// tenant is only hashed when set, so entries from before tenancy still verify.
+func auditHash(e models.AuditEntry) string {
+	values := []any{
+		e.ID, e.Timestamp.UTC().Format(time.RFC3339Nano), e.TransactionID, e.Action, e.Actor, e.IP, e.RequestID,
+		e.Status, string(e.Before), string(e.After), e.PrevHash,
+	}
+	if e.TenantID != "" {
+		values = append(values, e.TenantID)
+	}
+	fields, _ := json.Marshal(values)
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditState encodes a before or after snapshot for an audit entry
+func AuditState(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// VerifyAuditChain re-hashes every entry in order and reports the first one
+// whose hash or link to its predecessor no longer matches
+func VerifyAuditChain() (*models.AuditVerification, error) {
+	rows, err := db.DB.Query(`SELECT ` + auditColumns + ` FROM audit_logs ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	v := &models.AuditVerification{Valid: true}
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case e.Hash == "" && v.Checked == 0:
+			v.Legacy++
+			continue
+		case e.Hash == "":
+			v.Problem = "entry has no hash inside the chain"
+		case e.PrevHash != v.HeadHash:
+			v.Problem = "previous hash does not match the entry before it"
+		case auditHash(e) != e.Hash:
+			v.Problem = "entry contents do not match its hash"
+		}
+		if v.Problem != "" {
+			v.Valid, v.BrokenAt = false, e.ID
+			return v, nil
+		}
+		v.Checked++
+		v.HeadHash = e.Hash
+	}
+	return v, rows.Err()
+}
+
+// AuditFilter narrows ListAudit; empty fields match everything
+type AuditFilter struct {
+	TenantID      string
+	TransactionID string
+	Actor         string
+	Action        string // prefix, e.g. VERIFY or CASE_
+	RequestID     string
+	Since         time.Time
+	Until         time.Time
+	Limit         int
+}
+
+// ListAudit returns matching entries, newest first
+func ListAudit(f AuditFilter) ([]models.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_logs WHERE 1 = 1`
+	var args []any
+	if f.TenantID != "" {
+		query += " AND tenant_id = ?"
+		args = append(args, f.TenantID)
+	}
+	if f.TransactionID != "" {
+		query += " AND txn_id = ?"
+		args = append(args, f.TransactionID)
+	}
+	if f.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		query += " AND action LIKE ? ESCAPE '\\'"
+		args = append(args, strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToUpper(f.Action))+"%")
+	}
+	if f.RequestID != "" {
+		query += " AND request_id = ?"
+		args = append(args, f.RequestID)
+	}
+	if !f.Since.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, f.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !f.Until.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, f.Until.UTC().Format(time.RFC3339Nano))
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// auditColumns is the column list read by scanAudit
+const auditColumns = `id, coalesce(txn_id, ''), coalesce(tenant_id, ''), coalesce(action, ''), timestamp, coalesce(actor, ''), coalesce(ip, ''),
+	coalesce(request_id, ''), coalesce(status, 0), coalesce(before_state, ''), coalesce(after_state, ''),
+	coalesce(prev_hash, ''), coalesce(hash, '')`
+
+func scanAudit(row interface{ Scan(...any) error }) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var ts, before, after string
+	err := row.Scan(&e.ID, &e.TransactionID, &e.TenantID, &e.Action, &ts, &e.Actor, &e.IP, &e.RequestID, &e.Status,
+		&before, &after, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return e, err
+	}
+	e.Timestamp, err = parseAuditTime(ts)
+	if before != "" {
+		e.Before = json.RawMessage(before)
+	}
+	if after != "" {
+		e.After = json.RawMessage(after)
+	}
+	return e, err
+}
+
+// parseAuditTime reads chained entries (RFC 3339) and legacy ones written by
+// CURRENT_TIMESTAMP
+func parseAuditTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05Z"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised audit timestamp %q", s)
+}
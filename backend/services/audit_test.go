@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// lastAudit returns the newest audit entry
+func lastAudit(t *testing.T) models.AuditEntry {
+	t.Helper()
+	entries, err := ListAudit(AuditFilter{Limit: 1})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ListAudit = %+v, %v", entries, err)
+	}
+	return entries[0]
+}
+
+func TestWriteAudit(t *testing.T) {
+	RecordAudit("", "TEST_FIRST")
+	first := lastAudit(t)
+	WriteAudit(models.AuditEntry{TransactionID: "TXN-1", Action: "TEST_SECOND", Actor: "somchai", IP: "10.0.0.1",
+		RequestID: "req-1", Status: 200, Before: AuditState(map[string]int{"limit": 1}), After: AuditState(map[string]int{"limit": 2})})
+	second := lastAudit(t)
+
+	if first.Actor != "system" || first.Hash == "" {
+		t.Errorf("first = %+v", first)
+	}
+	if second.ID != first.ID+1 || second.PrevHash != first.Hash || second.Hash != auditHash(second) {
+		t.Errorf("second = %+v does not chain onto %+v", second, first)
+	}
+	if string(second.After) != `{"limit":2}` || second.Status != 200 || second.IP != "10.0.0.1" {
+		t.Errorf("second = %+v", second)
+	}
+	if AuditState(nil) != nil || AuditState(func() {}) != nil {
+		t.Error("AuditState of nothing should be empty")
+	}
+
+	changed := second
+	changed.Actor = "somsak"
+	if auditHash(changed) == second.Hash {
+		t.Error("hash does not cover the actor")
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	RecordAudit("", "TEST_CHAIN_A")
+	a := lastAudit(t)
+	RecordAudit("", "TEST_CHAIN_B")
+	RecordAudit("", "TEST_CHAIN_C")
+
+	v, err := VerifyAuditChain()
+	if err != nil || !v.Valid || v.Checked < 3 || v.HeadHash != lastAudit(t).Hash {
+		t.Fatalf("VerifyAuditChain = %+v, %v", v, err)
+	}
+
+	if _, err := db.DB.Exec("UPDATE audit_logs SET action = 'TEST_CHAIN_X' WHERE id = ?", a.ID); err == nil {
+		t.Fatal("audit entry edited through the database")
+	}
+
+	// Someone with the database file can still bypass the triggers; the chain
+	// must show it
+	dropAuditTriggers(t)
+	tampers := []struct {
+		name    string
+		tamper  string
+		restore string
+		args    []any
+		broken  int64
+	}{
+		{"edited action", "UPDATE audit_logs SET action = 'TEST_CHAIN_X' WHERE id = ?", "UPDATE audit_logs SET action = ? WHERE id = ?",
+			[]any{a.Action, a.ID}, a.ID},
+		{"removed hash", "UPDATE audit_logs SET hash = '' WHERE id = ?", "UPDATE audit_logs SET hash = ? WHERE id = ?",
+			[]any{a.Hash, a.ID}, a.ID},
+		{"deleted entry", "DELETE FROM audit_logs WHERE id = ?",
+			"INSERT INTO audit_logs (id, txn_id, action, timestamp, actor, ip, request_id, status, before_state, after_state, prev_hash, hash) VALUES (?, '', ?, ?, ?, '', '', 0, '', '', ?, ?)",
+			[]any{a.ID, a.Action, a.Timestamp.Format(time.RFC3339Nano), a.Actor, a.PrevHash, a.Hash}, a.ID + 1},
+	}
+	for _, tt := range tampers {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.DB.Exec(tt.tamper, a.ID); err != nil {
+				t.Fatal(err)
+			}
+			v, err := VerifyAuditChain()
+			if _, restoreErr := db.DB.Exec(tt.restore, tt.args...); restoreErr != nil {
+				t.Fatal(restoreErr)
+			}
+			if err != nil || v.Valid || v.BrokenAt != tt.broken || v.Problem == "" {
+				t.Errorf("VerifyAuditChain = %+v, %v", v, err)
+			}
+			if v, _ := VerifyAuditChain(); !v.Valid {
+				t.Fatalf("chain still broken after restoring: %+v", v)
+			}
+		})
+	}
+}
+
+// dropAuditTriggers lifts the append-only triggers until the test ends
+func dropAuditTriggers(t *testing.T) {
+	t.Helper()
+	triggers := map[string]string{}
+	rows, err := db.DB.Query("SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'audit_logs'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name, sql string
+		if err := rows.Scan(&name, &sql); err != nil {
+			t.Fatal(err)
+		}
+		triggers[name] = sql
+	}
+	rows.Close()
+	for name, sql := range triggers {
+		if _, err := db.DB.Exec("DROP TRIGGER " + name); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if _, err := db.DB.Exec(sql); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	if len(triggers) != 2 {
+		t.Fatalf("found triggers %v", triggers)
+	}
+}
+
+func TestListAudit(t *testing.T) {
+	actor := testID("auditor")
+	WriteAudit(models.AuditEntry{Action: "CASE_ASSIGNED X", Actor: actor, RequestID: actor + "-1"})
+	WriteAudit(models.AuditEntry{Action: "CASEXASSIGNED X", Actor: actor, RequestID: actor + "-2"})
+	WriteAudit(models.AuditEntry{TransactionID: actor, Action: "VERIFY X", Actor: actor, RequestID: actor + "-3"})
+
+	tests := []struct {
+		name string
+		f    AuditFilter
+		want []string // request IDs, newest first
+	}{
+		{"actor", AuditFilter{Actor: actor}, []string{actor + "-3", actor + "-2", actor + "-1"}},
+		{"underscore is literal", AuditFilter{Actor: actor, Action: "case_"}, []string{actor + "-1"}},
+		{"transaction", AuditFilter{TransactionID: actor}, []string{actor + "-3"}},
+		{"request", AuditFilter{RequestID: actor + "-2"}, []string{actor + "-2"}},
+		{"limit", AuditFilter{Actor: actor, Limit: 1}, []string{actor + "-3"}},
+		{"until the past", AuditFilter{Actor: actor, Until: time.Now().Add(-time.Hour)}, nil},
+		{"since the past", AuditFilter{Actor: actor, Action: "VERIFY", Since: time.Now().Add(-time.Hour)}, []string{actor + "-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.f.Limit == 0 {
+				tt.f.Limit = 10
+			}
+			entries, err := ListAudit(tt.f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.RequestID)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuditTime(t *testing.T) {
+	want := time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)
+	for _, s := range []string{"2025-06-01T08:30:00Z", "2025-06-01 08:30:00", "2025-06-01T08:30:00.000Z"} {
+		if got, err := parseAuditTime(s); err != nil || !got.Equal(want) {
+			t.Errorf("parseAuditTime(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := parseAuditTime("yesterday"); err == nil {
+		t.Error("parsed a bad timestamp")
+	}
+}
+
+func TestGetRule(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rules" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"groups": []any{
+			map[string]any{"rules": []any{map[string]any{"id": "velocity", "enabled": true}}},
+			map[string]any{"rules": []any{map[string]any{"id": "night", "enabled": false}}},
+		}})
+	}))
+	defer srv.Close()
+	ai := NewAIClient(srv.URL)
+
+	if rule, err := ai.GetRule("night"); err != nil || rule["enabled"] != false {
+		t.Errorf("GetRule(night) = %v, %v", rule, err)
+	}
+	if rule, err := ai.GetRule("missing"); err != nil || rule != nil {
+		t.Errorf("GetRule(missing) = %v, %v", rule, err)
+	}
+	if _, err := NewAIClient(srv.URL + "/v2").GetRule("night"); err == nil {
+		t.Error("error status not reported")
+	}
+}
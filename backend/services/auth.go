@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account is locked after too many failed logins")
+	ErrTooManyAttempts    = errors.New("too many failed logins from this address, try again later")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrPasswordLogin      = errors.New("password sign-in is disabled, use single sign-on")
+
+	ErrPasswordChangeRequired = errors.New("the password must be changed before continuing")
+)
+
+// AuthService signs users in with bcrypt-hashed passwords and issues opaque
+// access and refresh tokens tracked server-side, so any session can be
+// revoked at once. Failed logins lock the account after MaxFailures within
+// FailureWindow, and an address is throttled after IPMaxFailures.
+type AuthService struct {
+	AccessTTL       time.Duration
+	RefreshTTL      time.Duration
+	MaxFailures     int
+	FailureWindow   time.Duration
+	LockoutDuration time.Duration
+	IPMaxFailures   int
+	Interval        time.Duration // how often expired sessions are purged
+	PasswordLogin   bool          // false when users must sign in through the identity provider
+	MFA             *MFAService
+
+	mu         sync.Mutex
+	ipFailures map[string][]time.Time
+}
+
+func NewAuthService() *AuthService {
+	return &AuthService{
+		AccessTTL:       15 * time.Minute,
+		RefreshTTL:      7 * 24 * time.Hour,
+		MaxFailures:     5,
+		FailureWindow:   15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		IPMaxFailures:   20,
+		Interval:        time.Hour,
+		PasswordLogin:   true,
+		MFA:             NewMFAService(),
+		ipFailures:      map[string][]time.Time{},
+	}
+}
+
+// Start purges long-expired sessions and stale failure counts on every tick
+func (a *AuthService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := db.DB.Exec("DELETE FROM sessions WHERE refresh_expires_at < ?", time.Now().UTC().Add(-24*time.Hour)); err != nil {
+					log.Printf("Failed to purge expired sessions: %v", err)
+				}
+				a.mu.Lock()
+				for ip := range a.ipFailures {
+					a.recentIPFailures(ip)
+				}
+				a.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// HashPassword returns the bcrypt hash stored in users.password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// dummyHash is compared against when the username does not exist, so an
+// unknown user takes as long to reject as a wrong password
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Login checks a username and password and opens a session. When the user
+// has MFA, or their role requires it, it returns an *MFARequiredError whose
+// challenge is completed with CompleteMFA instead.
+func (a *AuthService) Login(username, password, ip string) (*models.TokenPair, error) {
+	if !a.PasswordLogin {
+		return nil, ErrPasswordLogin
+	}
+	a.mu.Lock()
+	throttled := a.IPMaxFailures > 0 && a.recentIPFailures(ip) >= a.IPMaxFailures
+	a.mu.Unlock()
+	if throttled {
+		return nil, ErrTooManyAttempts
+	}
+
+	var user models.User
+	var hash string
+	var lockedUntil sql.NullTime
+	err := db.DB.QueryRow(`
+		SELECT id, username, coalesce(role, ''), coalesce(tenant_id, 'SYNAPSE'), coalesce(disabled, 0), coalesce(password_change_required, 0), password, locked_until
+		FROM users WHERE username = ?
+	`, username).Scan(&user.ID, &user.Username, &user.Role, &user.TenantID, &user.Disabled, &user.PasswordChangeRequired, &hash, &lockedUntil)
+	if err == sql.ErrNoRows {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		a.recordIPFailure(ip)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if lockedUntil.Valid && lockedUntil.Time.After(now) {
+		a.recordIPFailure(ip)
+		return nil, fmt.Errorf("%w until %s", ErrAccountLocked, lockedUntil.Time.UTC().Format(time.RFC3339))
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		a.recordFailedLogin(user, ip)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	// The failure count is only cleared once the second factor is in too,
+	// so wrong codes and wrong passwords share one lockout
+	challenge, err := a.MFA.challengeFor(user, ip)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return nil, &MFARequiredError{Challenge: *challenge}
+	}
+	if err := clearFailedLogins(user.ID); err != nil {
+		return nil, err
+	}
+	return a.openSession(user, ip)
+}
+
+// recordFailedLogin counts a wrong password or second factor against the
+// account, locking it once MaxFailures land within FailureWindow
+func (a *AuthService) recordFailedLogin(user models.User, ip string) {
+	a.recordIPFailure(ip)
+	var failures int
+	var lastFailed sql.NullTime
+	err := db.DB.QueryRow("SELECT coalesce(failed_logins, 0), last_failed_at FROM users WHERE id = ?", user.ID).Scan(&failures, &lastFailed)
+	if err != nil {
+		log.Printf("Failed to record failed login for %s: %v", user.Username, err)
+		return
+	}
+	now := time.Now().UTC()
+	if !lastFailed.Valid || now.Sub(lastFailed.Time) > a.FailureWindow {
+		failures = 0
+	}
+	failures++
+	if a.MaxFailures > 0 && failures >= a.MaxFailures {
+		until := now.Add(a.LockoutDuration)
+		_, err = db.DB.Exec("UPDATE users SET failed_logins = 0, last_failed_at = ?, locked_until = ? WHERE id = ?", now, until, user.ID)
+		RecordAudit("", fmt.Sprintf("ACCOUNT_LOCKED %s until %s after %d failed logins", user.Username, until.Format(time.RFC3339), failures))
+	} else {
+		_, err = db.DB.Exec("UPDATE users SET failed_logins = ?, last_failed_at = ? WHERE id = ?", failures, now, user.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to record failed login for %s: %v", user.Username, err)
+	}
+}
+
+func clearFailedLogins(userID int64) error {
+	_, err := db.DB.Exec("UPDATE users SET failed_logins = 0, last_failed_at = NULL, locked_until = NULL WHERE id = ?", userID)
+	return err
+}
+
+// accountLockedUntil returns the end of the user's lockout, zero when none
+func accountLockedUntil(userID int64) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	if err := db.DB.QueryRow("SELECT locked_until FROM users WHERE id = ?", userID).Scan(&lockedUntil); err != nil {
+		return time.Time{}, err
+	}
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		return lockedUntil.Time, nil
+	}
+	return time.Time{}, nil
+}
+
+// EnrollMFA starts TOTP enrolment for a user whose role requires MFA but who
+// has none yet, identified by their login challenge
+func (a *AuthService) EnrollMFA(mfaToken string) (*models.MFAEnrollment, error) {
+	c, err := a.MFA.challenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !c.enroll {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	return a.MFA.Enroll(c.user.Username)
+}
+
+// CompleteMFA finishes a login challenge with a TOTP or recovery code and
+// opens the session. For a challenge that required enrolment the code
+// confirms the new secret and the recovery codes come back with the tokens.
+func (a *AuthService) CompleteMFA(mfaToken, code string) (*models.TokenPair, error) {
+	c, err := a.MFA.challenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	// Wrong codes lock the account like wrong passwords do, which also ends
+	// every challenge opened for it
+	until, err := accountLockedUntil(c.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !until.IsZero() {
+		a.MFA.endChallenge(mfaToken)
+		return nil, fmt.Errorf("%w until %s", ErrAccountLocked, until.UTC().Format(time.RFC3339))
+	}
+	var recoveryCodes []string
+	if c.enroll {
+		recoveryCodes, err = a.MFA.Confirm(c.user.Username, code)
+	} else {
+		err = a.MFA.Verify(c.user.Username, code)
+	}
+	if errors.Is(err, ErrMFAInvalidCode) {
+		a.MFA.failChallenge(mfaToken)
+		a.recordFailedLogin(c.user, c.ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.MFA.endChallenge(mfaToken)
+	if err := clearFailedLogins(c.user.ID); err != nil {
+		return nil, err
+	}
+
+	// The account may have been disabled while the challenge was open
+	user, err := GetUser(c.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	pair, err := a.openSession(*user, c.ip)
+	if err != nil {
+		return nil, err
+	}
+	pair.RecoveryCodes = recoveryCodes
+	return pair, nil
+}
+
+// LoginExternal opens a session for a user an identity provider has already
+// authenticated
+func (a *AuthService) LoginExternal(user models.User, ip string) (*models.TokenPair, error) {
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return a.openSession(user, ip)
+}
+
+// recentIPFailures prunes and counts the address's failures inside the
+// window. The caller holds a.mu.
+func (a *AuthService) recentIPFailures(ip string) int {
+	cutoff := time.Now().Add(-a.FailureWindow)
+	recent := a.ipFailures[ip][:0]
+	for _, t := range a.ipFailures[ip] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(a.ipFailures, ip)
+	} else {
+		a.ipFailures[ip] = recent
+	}
+	return len(recent)
+}
+
+func (a *AuthService) recordIPFailure(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ipFailures[ip] = append(a.ipFailures[ip], time.Now())
+}
+
+// openSession stores a new session and returns its tokens
+func (a *AuthService) openSession(user models.User, ip string) (*models.TokenPair, error) {
+	pair := a.newTokenPair(user)
+	sessionID := newToken("ses_")[:20]
+	_, err := db.DB.Exec(`
+		INSERT INTO sessions (session_id, username, access_hash, refresh_hash, ip, created_at, expires_at, refresh_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, sessionID, user.Username, tokenHash(pair.AccessToken), tokenHash(pair.RefreshToken), ip,
+		time.Now().UTC(), pair.ExpiresAt, pair.RefreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (a *AuthService) newTokenPair(user models.User) *models.TokenPair {
+	now := time.Now().UTC()
+	return &models.TokenPair{
+		AccessToken:      newToken("sat_"),
+		RefreshToken:     newToken("srt_"),
+		TokenType:        "Bearer",
+		ExpiresIn:        int(a.AccessTTL.Seconds()),
+		ExpiresAt:        now.Add(a.AccessTTL),
+		RefreshExpiresAt: now.Add(a.RefreshTTL),
+		Username:         user.Username,
+		Role:             user.Role,
+		TenantID:         user.TenantID,
+
+		PasswordChangeRequired: user.PasswordChangeRequired,
+	}
+}
+
+// Authenticate resolves an access token to its user and the user's tenant
+func (a *AuthService) Authenticate(accessToken string) (*models.User, error) {
+	if accessToken == "" {
+		return nil, ErrInvalidToken
+	}
+	var user models.User
+	err := db.DB.QueryRow(`
+		SELECT u.id, u.username, coalesce(u.role, ''), coalesce(u.tenant_id, 'SYNAPSE'), coalesce(u.password_change_required, 0)
+		FROM sessions s JOIN users u ON u.username = s.username
+		WHERE s.access_hash = ? AND s.revoked_at IS NULL AND s.expires_at > ? AND coalesce(u.disabled, 0) = 0
+	`, tokenHash(accessToken), time.Now().UTC()).Scan(&user.ID, &user.Username, &user.Role, &user.TenantID, &user.PasswordChangeRequired)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Refresh exchanges a refresh token for a new pair. Refresh tokens are single
+// use: presenting one that was already exchanged revokes the whole session,
+// since either the client or a thief is replaying it.
+func (a *AuthService) Refresh(refreshToken, ip string) (*models.TokenPair, error) {
+	hash := tokenHash(refreshToken)
+	now := time.Now().UTC()
+
+	var sessionID string
+	var user models.User
+	var revoked sql.NullTime
+	var refreshExpires time.Time
+	err := db.DB.QueryRow(`
+		SELECT s.session_id, s.revoked_at, s.refresh_expires_at, u.id, u.username, coalesce(u.role, ''), coalesce(u.tenant_id, 'SYNAPSE'), coalesce(u.disabled, 0), coalesce(u.password_change_required, 0)
+		FROM sessions s JOIN users u ON u.username = s.username
+		WHERE s.refresh_hash = ?
+	`, hash).Scan(&sessionID, &revoked, &refreshExpires, &user.ID, &user.Username, &user.Role, &user.TenantID, &user.Disabled, &user.PasswordChangeRequired)
+	if err == sql.ErrNoRows {
+		if res, err := db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE previous_refresh_hash = ? AND revoked_at IS NULL", now, hash); err == nil {
+			if n, _ := res.RowsAffected(); n > 0 {
+				RecordAudit("", "SESSION_REVOKED refresh token reused")
+			}
+		}
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revoked.Valid || !refreshExpires.After(now) || user.Disabled {
+		return nil, ErrInvalidToken
+	}
+
+	pair := a.newTokenPair(user)
+	res, err := db.DB.Exec(`
+		UPDATE sessions SET access_hash = ?, refresh_hash = ?, previous_refresh_hash = ?, ip = ?, expires_at = ?, refresh_expires_at = ?
+		WHERE session_id = ? AND refresh_hash = ?
+	`, tokenHash(pair.AccessToken), tokenHash(pair.RefreshToken), hash, ip, pair.ExpiresAt, pair.RefreshExpiresAt, sessionID, hash)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Lost a race with another refresh of the same token
+		return nil, ErrInvalidToken
+	}
+	return pair, nil
+}
+
+// ChangePassword replaces a local user's password after checking the current
+// one, and lifts any requirement to change it
+func ChangePassword(username, current, password string) error {
+	var hash, source string
+	err := db.DB.QueryRow("SELECT password, coalesce(auth_source, 'local') FROM users WHERE username = ?", username).Scan(&hash, &source)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if source != "local" {
+		return fmt.Errorf("password of a %s user is managed by the identity provider", source)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	if password == current {
+		return errors.New("new password must differ from the current one")
+	}
+	newHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.DB.Exec("UPDATE users SET password = ?, password_change_required = 0 WHERE username = ?", newHash, username)
+	return err
+}
+
+// Logout revokes the session the access token belongs to
+func (a *AuthService) Logout(accessToken string) error {
+	res, err := db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE access_hash = ? AND revoked_at IS NULL", time.Now().UTC(), tokenHash(accessToken))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// RevokeUserSessions signs a user out everywhere and returns how many
+// sessions were open
+func RevokeUserSessions(username string) (int64, error) {
+	res, err := db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE username = ? AND revoked_at IS NULL", time.Now().UTC(), username)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListSessions returns a user's sessions that can still be refreshed, newest first
+func ListSessions(username string) ([]models.Session, error) {
+	rows, err := db.DB.Query(`
+		SELECT session_id, username, coalesce(ip, ''), created_at, expires_at, refresh_expires_at
+		FROM sessions
+		WHERE username = ? AND revoked_at IS NULL AND refresh_expires_at > ?
+		ORDER BY created_at DESC
+	`, username, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.SessionID, &s.Username, &s.IP, &s.CreatedAt, &s.ExpiresAt, &s.RefreshExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// newToken returns a prefixed 256-bit random token
+func newToken(prefix string) string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return prefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
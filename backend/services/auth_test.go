@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+)
+
+func TestLogin(t *testing.T) {
+	active := newTestUser(t, "analyst")
+	disabled := newTestUser(t, "analyst")
+	if _, err := SetUserDisabled(disabled.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		passwordLogin bool
+		want          error
+	}{
+		{"correct password", active.Username, testPassword, true, nil},
+		{"wrong password", active.Username, "wrong password", true, ErrInvalidCredentials},
+		{"unknown user", "nobody@test.bank", testPassword, true, ErrInvalidCredentials},
+		{"disabled user", disabled.Username, testPassword, true, ErrAccountDisabled},
+		{"password login off", active.Username, testPassword, false, ErrPasswordLogin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuthService()
+			auth.PasswordLogin = tt.passwordLogin
+			pair, err := auth.Login(tt.username, tt.password, "198.51.100.1")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Login error = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil {
+				return
+			}
+			if pair.Username != tt.username || pair.Role != "analyst" || pair.TenantID != DefaultTenant {
+				t.Errorf("pair is for %s as %s of %s", pair.Username, pair.Role, pair.TenantID)
+			}
+			user, err := auth.Authenticate(pair.AccessToken)
+			if err != nil || user.Username != tt.username {
+				t.Errorf("Authenticate = %v, %v", user, err)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	auth := NewAuthService()
+	auth.MaxFailures = 3
+
+	for i := 0; i < auth.MaxFailures; i++ {
+		if _, err := auth.Login(user.Username, "wrong password", "198.51.100.2"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	if _, err := auth.Login(user.Username, testPassword, "198.51.100.2"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("correct password while locked: %v, want ErrAccountLocked", err)
+	}
+
+	// Once the lockout has passed the right password works and clears the count
+	if _, err := db.DB.Exec("UPDATE users SET locked_until = ? WHERE id = ?", time.Now().Add(-time.Second), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Login(user.Username, testPassword, "198.51.100.2"); err != nil {
+		t.Fatalf("login after lockout: %v", err)
+	}
+	var failures int
+	db.DB.QueryRow("SELECT failed_logins FROM users WHERE id = ?", user.ID).Scan(&failures)
+	if failures != 0 {
+		t.Errorf("failed_logins = %d after a successful login", failures)
+	}
+}
+
+func TestLoginIPThrottle(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	auth := NewAuthService()
+	auth.IPMaxFailures = 2
+
+	for i := 0; i < auth.IPMaxFailures; i++ {
+		auth.Login("nobody@test.bank", "wrong password", "198.51.100.3")
+	}
+	if _, err := auth.Login(user.Username, testPassword, "198.51.100.3"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("throttled address: %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := auth.Login(user.Username, testPassword, "198.51.100.4"); err != nil {
+		t.Fatalf("other address: %v", err)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	auth := NewAuthService()
+	first, err := auth.Login(user.Username, testPassword, "198.51.100.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := auth.Refresh(first.RefreshToken, "198.51.100.5")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, err := auth.Authenticate(first.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("replaced access token still works: %v", err)
+	}
+	if _, err := auth.Authenticate(second.AccessToken); err != nil {
+		t.Errorf("new access token: %v", err)
+	}
+
+	// Reusing the exchanged refresh token revokes the whole session
+	if _, err := auth.Refresh(first.RefreshToken, "203.0.113.9"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reused refresh token: %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.Authenticate(second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("session survived refresh token reuse: %v", err)
+	}
+	if _, err := auth.Refresh(second.RefreshToken, "198.51.100.5"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh after reuse: %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	auth := NewAuthService()
+
+	tests := []struct {
+		name  string
+		setup func(refreshToken string)
+	}{
+		{"unknown token", func(string) {}},
+		{"expired session", func(token string) {
+			db.DB.Exec("UPDATE sessions SET refresh_expires_at = ? WHERE refresh_hash = ?", time.Now().Add(-time.Minute), tokenHash(token))
+		}},
+		{"logged out", func(token string) {
+			db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE refresh_hash = ?", time.Now(), tokenHash(token))
+		}},
+		{"disabled user", func(string) {
+			SetUserDisabled(user.ID, true)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetUserDisabled(user.ID, false)
+			pair, err := auth.Login(user.Username, testPassword, "198.51.100.6")
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := pair.RefreshToken
+			if tt.name == "unknown token" {
+				token = newToken("srt_")
+			}
+			tt.setup(token)
+			if _, err := auth.Refresh(token, "198.51.100.6"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Refresh = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	auth := NewAuthService()
+	a, _ := auth.Login(user.Username, testPassword, "198.51.100.7")
+	b, _ := auth.Login(user.Username, testPassword, "198.51.100.7")
+
+	if err := auth.Logout(a.AccessToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := auth.Authenticate(a.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("logged out token still works: %v", err)
+	}
+	if _, err := auth.Authenticate(b.AccessToken); err != nil {
+		t.Errorf("other session ended too: %v", err)
+	}
+	if err := auth.Logout(a.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Logout = %v, want ErrInvalidToken", err)
+	}
+
+	if n, err := RevokeUserSessions(user.Username); err != nil || n != 1 {
+		t.Errorf("RevokeUserSessions = %d, %v", n, err)
+	}
+	if _, err := auth.Authenticate(b.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token survived RevokeUserSessions: %v", err)
+	}
+}
+
+func TestSeededPrivilegedUsers(t *testing.T) {
+	auth := NewAuthService()
+	for _, username := range []string{"admin@synapse.bank", "supervisor@synapse.bank", "ingest@synapse.bank"} {
+		t.Run(username, func(t *testing.T) {
+			if _, err := auth.Login(username, "secure", "198.51.100.8"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("public demo password: Login error = %v, want ErrInvalidCredentials", err)
+			}
+			var required bool
+			if err := db.DB.QueryRow("SELECT password_change_required FROM users WHERE username = ?", username).Scan(&required); err != nil {
+				t.Fatal(err)
+			}
+			if !required {
+				t.Error("seeded with an initial password that need not be changed")
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user := newTestUser(t, "analyst")
+	const newPassword = "staple battery horse"
+
+	tests := []struct {
+		name     string
+		username string
+		current  string
+		password string
+		want     error
+	}{
+		{"wrong current password", user.Username, "wrong password", newPassword, ErrInvalidCredentials},
+		{"unknown user", "nobody@test.bank", testPassword, newPassword, ErrUserNotFound},
+		{"too short", user.Username, testPassword, "short", errAny},
+		{"unchanged", user.Username, testPassword, testPassword, errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ChangePassword(tt.username, tt.current, tt.password)
+			if err == nil || (tt.want != errAny && !errors.Is(err, tt.want)) {
+				t.Errorf("ChangePassword error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("required change", func(t *testing.T) {
+		if _, err := db.DB.Exec("UPDATE users SET password_change_required = 1 WHERE id = ?", user.ID); err != nil {
+			t.Fatal(err)
+		}
+		auth := NewAuthService()
+		pair, err := auth.Login(user.Username, testPassword, "198.51.100.9")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pair.PasswordChangeRequired {
+			t.Error("pair does not ask for a password change")
+		}
+		if u, err := auth.Authenticate(pair.AccessToken); err != nil || !u.PasswordChangeRequired {
+			t.Errorf("Authenticate = %+v, %v, want a password change required", u, err)
+		}
+
+		if err := ChangePassword(user.Username, testPassword, newPassword); err != nil {
+			t.Fatalf("ChangePassword: %v", err)
+		}
+		if u, err := auth.Authenticate(pair.AccessToken); err != nil || u.PasswordChangeRequired {
+			t.Errorf("Authenticate after the change = %+v, %v", u, err)
+		}
+		if _, err := auth.Login(user.Username, testPassword, "198.51.100.9"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("old password: Login error = %v, want ErrInvalidCredentials", err)
+		}
+		if _, err := auth.Login(user.Username, newPassword, "198.51.100.9"); err != nil {
+			t.Errorf("new password: Login error = %v", err)
+		}
+	})
+}
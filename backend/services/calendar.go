@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// thaiHolidays are the announced public holidays before substitution days are added.
+// Lunar holidays move every year, so deployments should load the official list
+// for the years they cover.
+var thaiHolidays = []models.Holiday{
+	{Date: "2025-01-01", Name: "New Year's Day"},
+	{Date: "2025-02-12", Name: "Makha Bucha Day"},
+	{Date: "2025-04-06", Name: "Chakri Memorial Day"},
+	{Date: "2025-04-13", Name: "Songkran Festival"},
+	{Date: "2025-04-14", Name: "Songkran Festival"},
+	{Date: "2025-04-15", Name: "Songkran Festival"},
+	{Date: "2025-05-01", Name: "National Labour Day"},
+	{Date: "2025-05-04", Name: "Coronation Day"},
+	{Date: "2025-05-11", Name: "Visakha Bucha Day"},
+	{Date: "2025-06-03", Name: "Queen Suthida's Birthday"},
+	{Date: "2025-07-10", Name: "Asarnha Bucha Day"},
+	{Date: "2025-07-11", Name: "Buddhist Lent Day"},
+	{Date: "2025-07-28", Name: "King Vajiralongkorn's Birthday"},
+	{Date: "2025-08-12", Name: "Queen Mother's Birthday"},
+	{Date: "2025-10-13", Name: "King Bhumibol Memorial Day"},
+	{Date: "2025-10-23", Name: "Chulalongkorn Day"},
+	{Date: "2025-12-05", Name: "King Bhumibol's Birthday"},
+	{Date: "2025-12-10", Name: "Constitution Day"},
+	{Date: "2025-12-31", Name: "New Year's Eve"},
+	{Date: "2026-01-01", Name: "New Year's Day"},
+	{Date: "2026-03-03", Name: "Makha Bucha Day"},
+	{Date: "2026-04-06", Name: "Chakri Memorial Day"},
+	{Date: "2026-04-13", Name: "Songkran Festival"},
+	{Date: "2026-04-14", Name: "Songkran Festival"},
+	{Date: "2026-04-15", Name: "Songkran Festival"},
+	{Date: "2026-05-01", Name: "National Labour Day"},
+	{Date: "2026-05-04", Name: "Coronation Day"},
+	{Date: "2026-05-31", Name: "Visakha Bucha Day"},
+	{Date: "2026-06-03", Name: "Queen Suthida's Birthday"},
+	{Date: "2026-07-28", Name: "King Vajiralongkorn's Birthday"},
+	{Date: "2026-07-29", Name: "Asarnha Bucha Day"},
+	{Date: "2026-07-30", Name: "Buddhist Lent Day"},
+	{Date: "2026-08-12", Name: "Queen Mother's Birthday"},
+	{Date: "2026-10-13", Name: "King Bhumibol Memorial Day"},
+	{Date: "2026-10-23", Name: "Chulalongkorn Day"},
+	{Date: "2026-12-05", Name: "King Bhumibol's Birthday"},
+	{Date: "2026-12-10", Name: "Constitution Day"},
+	{Date: "2026-12-31", Name: "New Year's Eve"},
+}
+
+// BusinessCalendar classifies timestamps as night, weekend or public holiday in local time
+type BusinessCalendar struct {
+	Location *time.Location
+	// Night runs from NightStartHour to NightEndHour local time, wrapping past midnight
+	NightStartHour int
+	NightEndHour   int
+	// ActivityWindow is how far back per-account off-hours ratios look
+	ActivityWindow time.Duration
+
+	mu       sync.RWMutex
+	holidays map[string]string
+}
+
+func NewBusinessCalendar() *BusinessCalendar {
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		// No tz database in the image; Thailand has no daylight saving so a fixed offset is exact
+		loc = time.FixedZone("ICT", 7*60*60)
+	}
+	c := &BusinessCalendar{
+		Location:       loc,
+		NightStartHour: 22,
+		NightEndHour:   6,
+		ActivityWindow: 90 * 24 * time.Hour,
+	}
+	c.setHolidays(WithSubstitutionDays(thaiHolidays))
+	return c
+}
+
+// WithSubstitutionDays adds a substitution day on the next working day for every
+// holiday that falls on a weekend, as the Thai cabinet does. Consecutive holidays
+// such as Songkran push the substitution past the whole block.
+func WithSubstitutionDays(holidays []models.Holiday) []models.Holiday {
+	taken := map[string]bool{}
+	for _, h := range holidays {
+		taken[h.Date] = true
+	}
+	out := append([]models.Holiday{}, holidays...)
+	for _, h := range holidays {
+		d, err := time.Parse(dateLayout, h.Date)
+		if err != nil || !isWeekend(d.Weekday()) {
+			continue
+		}
+		for {
+			d = d.AddDate(0, 0, 1)
+			if !isWeekend(d.Weekday()) && !taken[d.Format(dateLayout)] {
+				break
+			}
+		}
+		taken[d.Format(dateLayout)] = true
+		out = append(out, models.Holiday{Date: d.Format(dateLayout), Name: "Substitution for " + h.Name})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	return out
+}
+
+func isWeekend(d time.Weekday) bool {
+	return d == time.Saturday || d == time.Sunday
+}
+
+// Classify returns the calendar flags for t in the calendar's time zone
+func (c *BusinessCalendar) Classify(t time.Time) models.CalendarFlags {
+	local := t.In(c.Location)
+	flags := models.CalendarFlags{
+		LocalTime: local.Format(time.RFC3339),
+		IsWeekend: isWeekend(local.Weekday()),
+	}
+	hour := local.Hour()
+	if c.NightStartHour > c.NightEndHour {
+		flags.IsNight = hour >= c.NightStartHour || hour < c.NightEndHour
+	} else {
+		flags.IsNight = hour >= c.NightStartHour && hour < c.NightEndHour
+	}
+
+	c.mu.RLock()
+	flags.HolidayName, flags.IsHoliday = c.holidays[local.Format(dateLayout)]
+	c.mu.RUnlock()
+
+	flags.IsOffHours = flags.IsNight || flags.IsWeekend || flags.IsHoliday
+	return flags
+}
+
+// Holidays returns the loaded holidays in date order
+func (c *BusinessCalendar) Holidays() []models.Holiday {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	holidays := make([]models.Holiday, 0, len(c.holidays))
+	for date, name := range c.holidays {
+		holidays = append(holidays, models.Holiday{Date: date, Name: name})
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+	return holidays
+}
+
+func (c *BusinessCalendar) setHolidays(holidays []models.Holiday) {
+	m := make(map[string]string, len(holidays))
+	for _, h := range holidays {
+		if name, ok := m[h.Date]; ok && name != h.Name {
+			m[h.Date] = name + " / " + h.Name
+			continue
+		}
+		m[h.Date] = h.Name
+	}
+	c.mu.Lock()
+	c.holidays = m
+	c.mu.Unlock()
+}
+
+// ReplaceHolidays validates and stores a new holiday list, used as given
+// (substitution days must already be included)
+func (c *BusinessCalendar) ReplaceHolidays(holidays []models.Holiday) error {
+	for i, h := range holidays {
+		if _, err := time.Parse(dateLayout, h.Date); err != nil {
+			return fmt.Errorf("holiday %d: date %q is not YYYY-MM-DD", i+1, h.Date)
+		}
+		holidays[i].Name = strings.TrimSpace(h.Name)
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM holidays"); err != nil {
+		return err
+	}
+	for _, h := range holidays {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO holidays (date, name) VALUES (?, ?)`, h.Date, h.Name); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.setHolidays(holidays)
+	return nil
+}
+
+// LoadHolidaysFile replaces the holiday list from a JSON array of {date, name}
+// or a CSV of date,name rows
+func (c *BusinessCalendar) LoadHolidaysFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	holidays, err := ParseHolidays(data)
+	if err != nil {
+		return err
+	}
+	return c.ReplaceHolidays(holidays)
+}
+
+// ParseHolidays reads a JSON array of {date, name} or CSV date,name rows
+func ParseHolidays(data []byte) ([]models.Holiday, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var holidays []models.Holiday
+		err := json.Unmarshal(data, &holidays)
+		return holidays, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var holidays []models.Holiday
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := time.Parse(dateLayout, rec[0]); err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: date %q is not YYYY-MM-DD", line, rec[0])
+		}
+		h := models.Holiday{Date: rec[0]}
+		if len(rec) > 1 {
+			h.Name = rec[1]
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, nil
+}
+
+// LoadStoredHolidays uses holidays saved by an earlier upload, keeping the
+// built-in list when none were saved
+func (c *BusinessCalendar) LoadStoredHolidays() error {
+	rows, err := db.DB.Query(`SELECT date, name FROM holidays`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var holidays []models.Holiday
+	for rows.Next() {
+		var h models.Holiday
+		if err := rows.Scan(&h.Date, &h.Name); err != nil {
+			continue
+		}
+		holidays = append(holidays, h)
+	}
+	if len(holidays) > 0 {
+		c.setHolidays(holidays)
+	}
+	return nil
+}
+
+// AddActivityFeatures adds the share of an account's recent transfers within
+// tenant, sent or received, that happened at night, at weekends, on holidays or
+// off-hours overall
+func (c *BusinessCalendar) AddActivityFeatures(tenant, accountID, prefix string, riskContext map[string]any) {
+	rows, err := db.DB.Query(`
+		SELECT timestamp FROM graph_transactions
+		WHERE tenant_id = ? AND (sender_account = ? OR receiver_account = ?) AND timestamp >= ?
+		ORDER BY timestamp DESC
+		LIMIT 1000
+	`, tenant, accountID, accountID, time.Now().Add(-c.ActivityWindow))
+	if err != nil {
+		log.Printf("Calendar features failed for %s: %v", accountID, err)
+		return
+	}
+	defer rows.Close()
+
+	var total, night, weekend, holiday, offHours int
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			continue
+		}
+		f := c.Classify(ts)
+		total++
+		if f.IsNight {
+			night++
+		}
+		if f.IsWeekend {
+			weekend++
+		}
+		if f.IsHoliday {
+			holiday++
+		}
+		if f.IsOffHours {
+			offHours++
+		}
+	}
+
+	ratio := func(n int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) / float64(total)
+	}
+	riskContext[prefix+"night_activity_ratio"] = ratio(night)
+	riskContext[prefix+"weekend_activity_ratio"] = ratio(weekend)
+	riskContext[prefix+"holiday_activity_ratio"] = ratio(holiday)
+	riskContext[prefix+"off_hours_ratio"] = ratio(offHours)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestWithSubstitutionDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		holidays []string
+		want     []string // substitution dates added
+	}{
+		{"weekday holiday", []string{"2025-10-13"}, nil},
+		{"Sunday moves to Monday", []string{"2026-05-31"}, []string{"2026-06-01"}},
+		{"Saturday moves to Monday", []string{"2026-12-05"}, []string{"2026-12-07"}},
+		{"Songkran pushes past the block", []string{"2025-04-13", "2025-04-14", "2025-04-15"}, []string{"2025-04-16"}},
+		{"weekend pair takes two days", []string{"2026-12-05", "2026-12-06"}, []string{"2026-12-07", "2026-12-08"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in []models.Holiday
+			given := map[string]bool{}
+			for _, d := range tt.holidays {
+				in = append(in, models.Holiday{Date: d, Name: "Holiday " + d})
+				given[d] = true
+			}
+			var added []string
+			for _, h := range WithSubstitutionDays(in) {
+				if !given[h.Date] {
+					added = append(added, h.Date)
+				}
+			}
+			if !reflect.DeepEqual(added, tt.want) {
+				t.Errorf("substitution days = %v, want %v", added, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	c := NewBusinessCalendar()
+	tests := []struct {
+		name string
+		at   string // UTC
+		want models.CalendarFlags
+	}{
+		{"weekday afternoon", "2025-10-14T07:00:00Z", models.CalendarFlags{}},
+		{"late evening in Bangkok", "2025-10-14T15:30:00Z", models.CalendarFlags{IsNight: true, IsOffHours: true}},
+		{"early morning in Bangkok", "2025-10-14T22:59:00Z", models.CalendarFlags{IsNight: true, IsOffHours: true}},
+		{"night ends at six", "2025-10-14T23:00:00Z", models.CalendarFlags{}},
+		{"Saturday", "2025-10-18T03:00:00Z", models.CalendarFlags{IsWeekend: true, IsOffHours: true}},
+		{"holiday", "2025-10-13T03:00:00Z",
+			models.CalendarFlags{IsHoliday: true, HolidayName: "King Bhumibol Memorial Day", IsOffHours: true}},
+		{"substitution day", "2026-06-01T03:00:00Z",
+			models.CalendarFlags{IsHoliday: true, HolidayName: "Substitution for Visakha Bucha Day", IsOffHours: true}},
+		{"UTC date before a Bangkok holiday", "2025-10-12T23:30:00Z",
+			models.CalendarFlags{IsHoliday: true, HolidayName: "King Bhumibol Memorial Day", IsOffHours: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.Classify(at)
+			got.LocalTime = ""
+			if got != tt.want {
+				t.Errorf("Classify(%s) = %+v, want %+v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHolidays(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []models.Holiday
+		wantErr bool
+	}{
+		{"JSON", `[{"date": "2027-01-01", "name": "New Year's Day"}]`,
+			[]models.Holiday{{Date: "2027-01-01", Name: "New Year's Day"}}, false},
+		{"CSV with a header and comment", "date,name\n# bank holidays\n2027-01-01,New Year's Day\n2027-04-06\n",
+			[]models.Holiday{{Date: "2027-01-01", Name: "New Year's Day"}, {Date: "2027-04-06"}}, false},
+		{"bad date", "2027-01-01,New Year's Day\n01/04/2027,Chakri\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHolidays([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("holidays = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceHolidays(t *testing.T) {
+	c := NewBusinessCalendar()
+	if err := c.ReplaceHolidays([]models.Holiday{{Date: "2027-13-01", Name: "x"}}); err == nil {
+		t.Error("invalid date accepted")
+	}
+	if len(c.Holidays()) == 0 {
+		t.Error("a rejected upload cleared the holidays")
+	}
+
+	upload := []models.Holiday{{Date: "2027-01-01", Name: " New Year's Day "}, {Date: "2027-01-01", Name: "Bank Holiday"}}
+	if err := c.ReplaceHolidays(upload); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Holiday{{Date: "2027-01-01", Name: "New Year's Day / Bank Holiday"}}
+	if got := c.Holidays(); !reflect.DeepEqual(got, want) {
+		t.Errorf("holidays = %+v, want %+v", got, want)
+	}
+
+	stored := NewBusinessCalendar()
+	if err := stored.LoadStoredHolidays(); err != nil {
+		t.Fatal(err)
+	}
+	if f := stored.Classify(time.Date(2027, 1, 1, 12, 0, 0, 0, stored.Location)); !f.IsHoliday {
+		t.Error("uploaded holiday not loaded")
+	}
+	if f := stored.Classify(time.Date(2025, 10, 13, 12, 0, 0, 0, stored.Location)); f.IsHoliday {
+		t.Error("built-in holidays kept after an upload")
+	}
+}
+
+func TestAddActivityFeatures(t *testing.T) {
+	c := NewBusinessCalendar()
+	account := testID("CAL")
+	// A Tuesday afternoon about two weeks ago, that night, and the Saturday afternoon after
+	day := time.Now().In(c.Location).AddDate(0, 0, -14)
+	for day.Weekday() != time.Tuesday || c.Classify(day).IsHoliday || c.Classify(day.AddDate(0, 0, 4)).IsHoliday {
+		day = day.AddDate(0, 0, 1)
+	}
+	afternoon := time.Date(day.Year(), day.Month(), day.Day(), 14, 0, 0, 0, c.Location)
+	addTransfer(t, account, testID("CAL"), 100, afternoon)
+	addTransfer(t, testID("CAL"), account, 100, afternoon.Add(9*time.Hour))
+	addTransfer(t, account, testID("CAL"), 100, afternoon.AddDate(0, 0, 4))
+	addTransfer(t, account, testID("CAL"), 100, afternoon.AddDate(-1, 0, 0)) // outside the window
+
+	riskContext := map[string]any{}
+	c.AddActivityFeatures(DefaultTenant, account, "sender_", riskContext)
+	want := map[string]any{
+		"sender_night_activity_ratio":   1.0 / 3,
+		"sender_weekend_activity_ratio": 1.0 / 3,
+		"sender_holiday_activity_ratio": 0.0,
+		"sender_off_hours_ratio":        2.0 / 3,
+	}
+	if !reflect.DeepEqual(riskContext, want) {
+		t.Errorf("features = %v, want %v", riskContext, want)
+	}
+
+	empty := map[string]any{}
+	c.AddActivityFeatures(DefaultTenant, testID("CAL"), "", empty)
+	if empty["off_hours_ratio"] != 0.0 {
+		t.Errorf("account without transfers = %v", empty)
+	}
+}
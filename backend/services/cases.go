@@ -0,0 +1,422 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var (
+	ErrCaseNotFound      = errors.New("case not found")
+	ErrInvalidTransition = errors.New("invalid case status change")
+)
+
+// severityRank orders severities (and case priorities) from lowest to highest
+var severityRank = map[string]int{
+	models.SeverityLow:      0,
+	models.SeverityMedium:   1,
+	models.SeverityHigh:     2,
+	models.SeverityCritical: 3,
+}
+
+// caseTransitions lists the statuses a case may move to from each status.
+// Closing has its own call so a resolution is always recorded.
+var caseTransitions = map[string][]string{
+	models.CaseOpen:          {models.CaseInvestigating, models.CaseEscalated},
+	models.CaseInvestigating: {models.CaseOpen, models.CaseEscalated},
+	models.CaseEscalated:     {models.CaseInvestigating},
+	models.CaseClosed:        {models.CaseOpen},
+}
+
+// caseMu serialises alert filing so two alerts for the same group cannot open two cases
+var caseMu sync.Mutex
+
+// RaiseAlert files an alert into the open case for the account's community, or
+// for the account itself, opening a case when there is none. Cases belong to
+// the alert's tenant and only ever gather that tenant's alerts. Repeats are
+// dropped: the same alert type on the same transaction, or for detectors the
+// same type on the same account while its case is still open.
+func RaiseAlert(a models.Alert) (*models.Alert, error) {
+	if a.AccountID == "" {
+		return nil, errors.New("alert needs an account")
+	}
+	if a.TenantID == "" {
+		return nil, errors.New("alert needs a tenant")
+	}
+	if _, ok := severityRank[a.Severity]; !ok {
+		a.Severity = models.SeverityMedium
+	}
+	a.CreatedAt = time.Now().UTC()
+
+	groupType, groupKey, title := "account", a.AccountID, "Account "+a.AccountID
+	if community, err := GetAccountCommunity(a.TenantID, a.AccountID); err == nil && community.Size > 1 {
+		groupType, groupKey, title = "community", community.CommunityID, fmt.Sprintf("Community %s (%d accounts)", community.CommunityID, community.Size)
+	}
+
+	caseMu.Lock()
+	defer caseMu.Unlock()
+
+	var dup int
+	if a.Source == models.AlertSourceDetector {
+		db.DB.QueryRow(`
+			SELECT count(*) FROM alerts al JOIN cases c ON c.case_id = al.case_id
+			WHERE al.tenant_id = ? AND al.type = ? AND al.account_id = ? AND c.status != ?
+		`, a.TenantID, a.Type, a.AccountID, models.CaseClosed).Scan(&dup)
+	} else {
+		db.DB.QueryRow("SELECT count(*) FROM alerts WHERE tenant_id = ? AND type = ? AND account_id = ? AND txn_id = ?",
+			a.TenantID, a.Type, a.AccountID, a.TransactionID).Scan(&dup)
+	}
+	if dup > 0 {
+		return nil, nil
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT case_id FROM cases
+		WHERE tenant_id = ? AND status != ? AND ((group_type = ? AND group_key = ?) OR (group_type = 'account' AND group_key = ?))
+		ORDER BY group_type = 'community' DESC, created_at DESC
+		LIMIT 1
+	`, a.TenantID, models.CaseClosed, groupType, groupKey, a.AccountID).Scan(&a.CaseID)
+	if err == sql.ErrNoRows {
+		a.CaseID = newCaseID()
+		_, err = tx.Exec(`
+			INSERT INTO cases (case_id, tenant_id, title, group_type, group_key, status, priority, alert_count, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
+		`, a.CaseID, a.TenantID, title, groupType, groupKey, models.CaseOpen, a.Severity, a.CreatedAt, a.CreatedAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO alerts (tenant_id, source, type, severity, txn_id, account_id, summary, case_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.TenantID, a.Source, a.Type, a.Severity, a.TransactionID, a.AccountID, a.Summary, a.CaseID, a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.AlertID, _ = res.LastInsertId()
+
+	if err := linkCase(tx, a.CaseID, []string{a.TransactionID}, []string{a.AccountID}); err != nil {
+		return nil, err
+	}
+	var priority string
+	if err := tx.QueryRow("SELECT priority FROM cases WHERE case_id = ?", a.CaseID).Scan(&priority); err != nil {
+		return nil, err
+	}
+	if severityRank[a.Severity] > severityRank[priority] {
+		priority = a.Severity
+	}
+	if _, err := tx.Exec("UPDATE cases SET alert_count = alert_count + 1, priority = ?, updated_at = ? WHERE case_id = ?",
+		priority, a.CreatedAt, a.CaseID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func newCaseID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return "CASE-" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+func linkCase(tx *sql.Tx, caseID string, txnIDs, accounts []string) error {
+	for kind, refs := range map[string][]string{"transaction": txnIDs, "account": accounts} {
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			if _, err := tx.Exec("INSERT OR IGNORE INTO case_links (case_id, link_type, ref) VALUES (?, ?, ?)", caseID, kind, ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CaseFilter narrows ListCases; empty fields match everything except
+// TenantID, which is required
+type CaseFilter struct {
+	TenantID  string
+	Status    string
+	Assignee  string
+	Priority  string
+	AccountID string // cases linked to this account
+	Limit     int
+}
+
+// ListCases returns cases matching the filter, highest priority then most recently updated first
+func ListCases(f CaseFilter) ([]models.Case, error) {
+	query := `SELECT ` + caseColumns + ` FROM cases WHERE tenant_id = ?`
+	args := []any{f.TenantID}
+	if f.Status != "" {
+		query += " AND status = ?"
+		args = append(args, strings.ToUpper(f.Status))
+	}
+	if f.Assignee != "" {
+		query += " AND assignee = ?"
+		args = append(args, f.Assignee)
+	}
+	if f.Priority != "" {
+		query += " AND priority = ?"
+		args = append(args, strings.ToUpper(f.Priority))
+	}
+	if f.AccountID != "" {
+		query += " AND case_id IN (SELECT case_id FROM case_links WHERE link_type = 'account' AND ref = ?)"
+		args = append(args, f.AccountID)
+	}
+	query += ` ORDER BY CASE priority WHEN 'CRITICAL' THEN 3 WHEN 'HIGH' THEN 2 WHEN 'MEDIUM' THEN 1 ELSE 0 END DESC,
+		updated_at DESC LIMIT ?`
+	args = append(args, f.Limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cases := []models.Case{}
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// GetCase loads one of tenant's cases with its linked transactions, accounts and
+// reports, notes and alerts. Another tenant's case is reported as not found.
+func GetCase(tenant, caseID string) (*models.Case, error) {
+	c, err := scanCase(db.DB.QueryRow(`SELECT `+caseColumns+` FROM cases WHERE case_id = ? AND tenant_id = ?`, caseID, tenant))
+	if err == sql.ErrNoRows {
+		return nil, ErrCaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query("SELECT link_type, ref FROM case_links WHERE case_id = ? ORDER BY rowid", caseID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var kind, ref string
+		if rows.Scan(&kind, &ref) != nil {
+			continue
+		}
+		switch kind {
+		case "transaction":
+			c.Transactions = append(c.Transactions, ref)
+		case "report":
+			c.Reports = append(c.Reports, ref)
+		default:
+			c.Accounts = append(c.Accounts, ref)
+		}
+	}
+	rows.Close()
+
+	rows, err = db.DB.Query("SELECT note_id, case_id, author, text, created_at FROM case_notes WHERE case_id = ? ORDER BY note_id", caseID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var n models.CaseNote
+		if rows.Scan(&n.NoteID, &n.CaseID, &n.Author, &n.Text, &n.CreatedAt) == nil {
+			c.Notes = append(c.Notes, n)
+		}
+	}
+	rows.Close()
+
+	c.Alerts, err = ListAlerts(tenant, "", "", caseID, 1000)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AssignCase sets the analyst working the case
+func AssignCase(tenant, caseID, assignee, by string) (*models.Case, error) {
+	if _, err := updateCase(tenant, caseID, "assignee = ?", assignee); err != nil {
+		return nil, err
+	}
+	RecordAudit("", fmt.Sprintf("CASE_ASSIGNED %s to %s by %s", caseID, assignee, by))
+	return GetCase(tenant, caseID)
+}
+
+// SetCasePriority overrides the priority derived from the case's alerts
+func SetCasePriority(tenant, caseID, priority, by string) (*models.Case, error) {
+	priority = strings.ToUpper(priority)
+	if _, ok := severityRank[priority]; !ok {
+		return nil, fmt.Errorf("priority must be LOW, MEDIUM, HIGH or CRITICAL")
+	}
+	if _, err := updateCase(tenant, caseID, "priority = ?", priority); err != nil {
+		return nil, err
+	}
+	RecordAudit("", fmt.Sprintf("CASE_PRIORITY %s %s by %s", caseID, priority, by))
+	return GetCase(tenant, caseID)
+}
+
+// SetCaseStatus moves a case through open, investigating and escalated, or
+// reopens a closed case
+func SetCaseStatus(tenant, caseID, status, by string) (*models.Case, error) {
+	status = strings.ToUpper(status)
+	current, err := updateCase(tenant, caseID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, next := range caseTransitions[current.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current.Status, status)
+	}
+	if _, err := updateCase(tenant, caseID, "status = ?, closed_at = NULL", status); err != nil {
+		return nil, err
+	}
+	RecordAudit("", fmt.Sprintf("CASE_STATUS %s %s -> %s by %s", caseID, current.Status, status, by))
+	return GetCase(tenant, caseID)
+}
+
+// CloseCase closes a case with a resolution
+func CloseCase(tenant, caseID, resolution, by string) (*models.Case, error) {
+	current, err := updateCase(tenant, caseID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == models.CaseClosed {
+		return nil, fmt.Errorf("%w: case is already closed", ErrInvalidTransition)
+	}
+	now := time.Now().UTC()
+	res, err := db.DB.Exec("UPDATE cases SET status = ?, resolution = ?, closed_at = ?, updated_at = ? WHERE case_id = ? AND tenant_id = ?",
+		models.CaseClosed, resolution, now, now, caseID, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrCaseNotFound
+	}
+	RecordAudit("", fmt.Sprintf("CASE_CLOSED %s by %s: %s", caseID, by, resolution))
+	return GetCase(tenant, caseID)
+}
+
+// AddCaseNote appends an analyst note
+func AddCaseNote(tenant, caseID, author, text string) (*models.CaseNote, error) {
+	if _, err := updateCase(tenant, caseID, "", nil); err != nil {
+		return nil, err
+	}
+	n := models.CaseNote{CaseID: caseID, Author: author, Text: text, CreatedAt: time.Now().UTC()}
+	res, err := db.DB.Exec("INSERT INTO case_notes (case_id, author, text, created_at) VALUES (?, ?, ?, ?)",
+		n.CaseID, n.Author, n.Text, n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	n.NoteID, _ = res.LastInsertId()
+	_, _ = db.DB.Exec("UPDATE cases SET updated_at = ? WHERE case_id = ? AND tenant_id = ?", n.CreatedAt, caseID, tenant)
+	return &n, nil
+}
+
+// LinkCaseItems attaches further transactions and accounts to a case
+func LinkCaseItems(tenant, caseID string, txnIDs, accounts []string, by string) (*models.Case, error) {
+	if _, err := updateCase(tenant, caseID, "", nil); err != nil {
+		return nil, err
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := linkCase(tx, caseID, txnIDs, accounts); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE cases SET updated_at = ? WHERE case_id = ?", time.Now().UTC(), caseID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	RecordAudit("", fmt.Sprintf("CASE_LINKED %s %d transactions, %d accounts by %s", caseID, len(txnIDs), len(accounts), by))
+	return GetCase(tenant, caseID)
+}
+
+// updateCase applies set (a SET clause with one placeholder) to one of tenant's
+// cases and returns the case as it was before. An empty set only checks the
+// case exists.
+func updateCase(tenant, caseID, set string, value any) (*models.Case, error) {
+	c, err := scanCase(db.DB.QueryRow(`SELECT `+caseColumns+` FROM cases WHERE case_id = ? AND tenant_id = ?`, caseID, tenant))
+	if err == sql.ErrNoRows {
+		return nil, ErrCaseNotFound
+	}
+	if err != nil || set == "" {
+		return &c, err
+	}
+	_, err = db.DB.Exec("UPDATE cases SET "+set+", updated_at = ? WHERE case_id = ? AND tenant_id = ?", value, time.Now().UTC(), caseID, tenant)
+	return &c, err
+}
+
+// ListAlerts returns tenant's alerts newest first, optionally filtered by source, account and case
+func ListAlerts(tenant, source, accountID, caseID string, limit int) ([]models.Alert, error) {
+	query := `SELECT alert_id, tenant_id, source, type, severity, coalesce(txn_id, ''), account_id, summary, case_id, created_at FROM alerts WHERE tenant_id = ?`
+	args := []any{tenant}
+	if source != "" {
+		query += " AND source = ?"
+		args = append(args, strings.ToUpper(source))
+	}
+	if accountID != "" {
+		query += " AND account_id = ?"
+		args = append(args, accountID)
+	}
+	if caseID != "" {
+		query += " AND case_id = ?"
+		args = append(args, caseID)
+	}
+	query += " ORDER BY alert_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := []models.Alert{}
+	for rows.Next() {
+		var a models.Alert
+		if err := rows.Scan(&a.AlertID, &a.TenantID, &a.Source, &a.Type, &a.Severity, &a.TransactionID, &a.AccountID, &a.Summary, &a.CaseID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// caseColumns is the column list read by scanCase
+const caseColumns = `case_id, tenant_id, title, group_type, group_key, status, priority, coalesce(assignee, ''), alert_count,
+	coalesce(resolution, ''), created_at, updated_at, closed_at`
+
+func scanCase(row interface{ Scan(...any) error }) (models.Case, error) {
+	var c models.Case
+	var closed sql.NullTime
+	err := row.Scan(&c.CaseID, &c.TenantID, &c.Title, &c.GroupType, &c.GroupKey, &c.Status, &c.Priority, &c.Assignee, &c.AlertCount,
+		&c.Resolution, &c.CreatedAt, &c.UpdatedAt, &closed)
+	if closed.Valid {
+		c.ClosedAt = &closed.Time
+	}
+	return c, err
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// raise files an alert and fails the test on error
+func raise(t *testing.T, a models.Alert) *models.Alert {
+	t.Helper()
+	got, err := RaiseAlert(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestRaiseAlert(t *testing.T) {
+	account := testID("CASE-ACC")
+	first := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "REVIEW", Severity: models.SeverityMedium,
+		TransactionID: testID("TXN"), AccountID: account, Summary: "scored Review"})
+	if first == nil || first.CaseID == "" {
+		t.Fatalf("first alert = %+v", first)
+	}
+
+	tests := []struct {
+		name     string
+		alert    models.Alert
+		filed    bool
+		priority string
+		alerts   int
+	}{
+		{"another transaction joins the case",
+			models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "BLOCK", Severity: models.SeverityHigh, TransactionID: testID("TXN")},
+			true, models.SeverityHigh, 2},
+		{"the same alert on the same transaction",
+			models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "REVIEW", Severity: models.SeverityCritical, TransactionID: first.TransactionID},
+			false, models.SeverityHigh, 2},
+		{"a lower severity keeps the priority",
+			models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDetector, Type: "FAN_IN", Severity: models.SeverityLow, TransactionID: testID("TXN")},
+			true, models.SeverityHigh, 3},
+		{"a detector repeating on a new transaction",
+			models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDetector, Type: "FAN_IN", Severity: models.SeverityCritical, TransactionID: testID("TXN")},
+			false, models.SeverityHigh, 3},
+		{"unknown severity counts as medium",
+			models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceWatchlist, Type: "WATCHLIST_HIT", Severity: "URGENT", TransactionID: testID("TXN")},
+			true, models.SeverityHigh, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.alert.AccountID = account
+			got := raise(t, tt.alert)
+			if (got != nil) != tt.filed {
+				t.Fatalf("RaiseAlert = %+v", got)
+			}
+			if got != nil && got.CaseID != first.CaseID {
+				t.Errorf("filed into %s, want %s", got.CaseID, first.CaseID)
+			}
+			c, err := GetCase(DefaultTenant, first.CaseID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Priority != tt.priority || c.AlertCount != tt.alerts || len(c.Alerts) != tt.alerts {
+				t.Errorf("case = %+v", c)
+			}
+		})
+	}
+
+	c, _ := GetCase(DefaultTenant, first.CaseID)
+	if c.GroupType != "account" || c.GroupKey != account || !reflect.DeepEqual(c.Accounts, []string{account}) || len(c.Transactions) != 4 {
+		t.Errorf("case = %+v", c)
+	}
+	if c.Alerts[0].Severity != models.SeverityMedium {
+		t.Errorf("newest alert = %+v", c.Alerts[0])
+	}
+
+	// Once the case is closed the detector may fire again, into a new case
+	if _, err := CloseCase(DefaultTenant, first.CaseID, "no fraud", "analyst"); err != nil {
+		t.Fatal(err)
+	}
+	again := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDetector, Type: "FAN_IN", TransactionID: testID("TXN"), AccountID: account})
+	if again == nil || again.CaseID == first.CaseID {
+		t.Errorf("alert after closing = %+v", again)
+	}
+
+	if _, err := RaiseAlert(models.Alert{TenantID: DefaultTenant, Type: "REVIEW"}); err == nil {
+		t.Error("alert without an account filed")
+	}
+	if _, err := RaiseAlert(models.Alert{Type: "REVIEW", AccountID: account}); err == nil {
+		t.Error("alert without a tenant filed")
+	}
+}
+
+func TestRaiseAlertGroupsCommunity(t *testing.T) {
+	a, b, c := testID("CASE-COM"), testID("CASE-COM"), testID("CASE-COM")
+	at := time.Now().Add(-time.Hour)
+	addTransfer(t, a, b, 100, at)
+	addTransfer(t, b, c, 100, at.Add(time.Minute))
+	addTransfer(t, c, a, 100, at.Add(2*time.Minute))
+	if _, err := NewCommunityJob(nil).Recompute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	first := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "REVIEW", TransactionID: testID("TXN"), AccountID: a})
+	second := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "REVIEW", TransactionID: testID("TXN"), AccountID: c})
+	if first.CaseID != second.CaseID {
+		t.Fatalf("alerts in one community filed into %s and %s", first.CaseID, second.CaseID)
+	}
+	cs, err := GetCase(DefaultTenant, first.CaseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.GroupType != "community" || len(cs.Accounts) != 2 {
+		t.Errorf("case = %+v", cs)
+	}
+	if linked, _ := ListCases(CaseFilter{TenantID: DefaultTenant, AccountID: c, Limit: 10}); len(linked) != 1 || linked[0].CaseID != cs.CaseID {
+		t.Errorf("cases of %s = %+v", c, linked)
+	}
+}
+
+func TestCaseWorkflow(t *testing.T) {
+	alert := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "BLOCK", TransactionID: testID("TXN"), AccountID: testID("CASE-ACC")})
+	caseID := alert.CaseID
+
+	steps := []struct {
+		status string
+		ok     bool
+	}{
+		{models.CaseEscalated, true},
+		{models.CaseOpen, false},
+		{"investigating", true},
+		{models.CaseClosed, false}, // closing needs a resolution
+		{models.CaseOpen, true},
+	}
+	for _, s := range steps {
+		c, err := SetCaseStatus(DefaultTenant, caseID, s.status, "analyst")
+		if s.ok != (err == nil) {
+			t.Fatalf("SetCaseStatus(%s) = %v", s.status, err)
+		}
+		if !s.ok && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("SetCaseStatus(%s) = %v", s.status, err)
+		}
+		if s.ok && c.Status != strings.ToUpper(s.status) {
+			t.Errorf("status = %s after %s", c.Status, s.status)
+		}
+	}
+
+	if c, err := AssignCase(DefaultTenant, caseID, "somchai", "supervisor"); err != nil || c.Assignee != "somchai" {
+		t.Errorf("AssignCase = %+v, %v", c, err)
+	}
+	if _, err := SetCasePriority(DefaultTenant, caseID, "urgent", "supervisor"); err == nil {
+		t.Error("invalid priority accepted")
+	}
+	if c, err := SetCasePriority(DefaultTenant, caseID, "critical", "supervisor"); err != nil || c.Priority != models.SeverityCritical {
+		t.Errorf("SetCasePriority = %+v, %v", c, err)
+	}
+	if _, err := AddCaseNote(DefaultTenant, caseID, "somchai", "called the customer"); err != nil {
+		t.Fatal(err)
+	}
+	linked := testID("CASE-ACC")
+	c, err := LinkCaseItems(DefaultTenant, caseID, []string{testID("TXN")}, []string{linked}, "somchai")
+	if err != nil || len(c.Accounts) != 2 || len(c.Transactions) != 2 || len(c.Notes) != 1 {
+		t.Errorf("LinkCaseItems = %+v, %v", c, err)
+	}
+	if cases, _ := ListCases(CaseFilter{TenantID: DefaultTenant, Assignee: "somchai", Priority: "CRITICAL", AccountID: linked, Limit: 10}); len(cases) != 1 {
+		t.Errorf("filtered cases = %+v", cases)
+	}
+
+	c, err = CloseCase(DefaultTenant, caseID, "confirmed mule", "somchai")
+	if err != nil || c.Status != models.CaseClosed || c.ClosedAt == nil || c.Resolution != "confirmed mule" {
+		t.Fatalf("CloseCase = %+v, %v", c, err)
+	}
+	if _, err := CloseCase(DefaultTenant, caseID, "again", "somchai"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("closing a closed case: %v", err)
+	}
+	if auditCount(t, "CASE_STATUS "+caseID) != 3 || auditCount(t, "CASE_CLOSED "+caseID) != 1 {
+		t.Error("status changes not audited")
+	}
+	if c, err := SetCaseStatus(DefaultTenant, caseID, models.CaseOpen, "supervisor"); err != nil || c.ClosedAt != nil {
+		t.Errorf("reopened case = %+v, %v", c, err)
+	}
+
+	if _, err := GetCase(DefaultTenant, "CASE-NONE"); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("GetCase of a missing case: %v", err)
+	}
+	if _, err := AssignCase(DefaultTenant, "CASE-NONE", "x", "y"); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("AssignCase of a missing case: %v", err)
+	}
+}
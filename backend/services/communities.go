@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// CommunityJob periodically clusters the account graph with label propagation.
+// It runs entirely in Go over the SQLite record so it does not need Neo4j GDS.
+type CommunityJob struct {
+	Neo4j         *Neo4jService
+	Interval      time.Duration
+	Window        time.Duration // only transfers this recent shape the communities
+	MaxIterations int
+
+	mu            sync.Mutex
+	lastSignature string
+}
+
+func NewCommunityJob(neo4j *Neo4jService) *CommunityJob {
+	return &CommunityJob{
+		Neo4j:         neo4j,
+		Interval:      5 * time.Minute,
+		Window:        30 * 24 * time.Hour,
+		MaxIterations: 20,
+	}
+}
+
+// Start recomputes communities on every tick, skipping ticks where the graph has not changed
+func (j *CommunityJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+		for {
+			if signature, changed, err := j.graphChanged(); err != nil {
+				log.Printf("Community job: %v", err)
+			} else if changed {
+				if n, err := j.Recompute(ctx); err != nil {
+					log.Printf("Community detection failed: %v", err)
+				} else {
+					j.markComputed(signature)
+					log.Printf("Community detection found %d communities", n)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// graphChanged compares a cheap signature of graph_transactions against the
+// last successful run and returns it for markComputed
+func (j *CommunityJob) graphChanged() (string, bool, error) {
+	var count, confirmed int64
+	err := db.DB.QueryRow(`
+		SELECT count(*), coalesce(sum(verification_status = 'CONFIRMED_FRAUD'), 0)
+		FROM graph_transactions
+	`).Scan(&count, &confirmed)
+	if err != nil {
+		return "", false, err
+	}
+	signature := fmt.Sprintf("%d/%d", count, confirmed)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return signature, signature != j.lastSignature, nil
+}
+
+// markComputed records the signature of a graph whose communities were saved,
+// so a failed run is tried again on the next tick
+func (j *CommunityJob) markComputed(signature string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastSignature = signature
+}
+
+// Recompute clusters every tenant's graph separately, so a community is built
+// only from the transfers one tenant submitted. Those reach accounts at any
+// bank, and so can its communities. Returns the number of communities found
+// across all tenants.
+func (j *CommunityJob) Recompute(ctx context.Context) (int, error) {
+	tenants, err := graphTenants()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, tenant := range tenants {
+		n, err := j.RecomputeTenant(ctx, tenant)
+		if err != nil {
+			return total, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// RecomputeTenant runs label propagation over one tenant's recent transfers
+// and stores the result. Returns the number of communities found.
+func (j *CommunityJob) RecomputeTenant(ctx context.Context, tenant string) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	edges, err := loadEdgesSince(tenant, time.Now().Add(-j.Window))
+	if err != nil {
+		return 0, err
+	}
+	labels := labelPropagation(edges, j.MaxIterations)
+	communities := summarizeCommunities(edges, labels)
+
+	if err := saveCommunities(tenant, communities); err != nil {
+		return 0, err
+	}
+	if j.Neo4j != nil && j.Neo4j.Connected {
+		if err := j.Neo4j.SaveAccountCommunities(ctx, tenant, communities); err != nil {
+			log.Printf("Failed to write communities to Neo4j: %v", err)
+		}
+	}
+	return len(communities), nil
+}
+
+// labelPropagation assigns every account the label carried by most of its
+// (transfer-count weighted) neighbours until labels stop changing
+func labelPropagation(edges []models.TransferEdge, maxIterations int) map[string]string {
+	neighbours := map[string]map[string]float64{}
+	link := func(a, b string) {
+		if neighbours[a] == nil {
+			neighbours[a] = map[string]float64{}
+		}
+		neighbours[a][b]++
+	}
+	for _, e := range edges {
+		if e.From == e.To {
+			continue
+		}
+		link(e.From, e.To)
+		link(e.To, e.From)
+	}
+
+	nodes := make([]string, 0, len(neighbours))
+	labels := make(map[string]string, len(neighbours))
+	for n := range neighbours {
+		nodes = append(nodes, n)
+		labels[n] = n
+	}
+	sort.Strings(nodes)
+
+	// Fixed seed keeps community IDs stable between runs over the same graph
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < maxIterations; i++ {
+		rng.Shuffle(len(nodes), func(a, b int) { nodes[a], nodes[b] = nodes[b], nodes[a] })
+		changed := false
+		for _, n := range nodes {
+			weights := map[string]float64{}
+			for m, w := range neighbours[n] {
+				weights[labels[m]] += w
+			}
+			best, bestWeight := labels[n], weights[labels[n]]
+			for label, w := range weights {
+				if w > bestWeight || (w == bestWeight && label < best && best != labels[n]) {
+					best, bestWeight = label, w
+				}
+			}
+			if best != labels[n] {
+				labels[n] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return labels
+}
+
+func summarizeCommunities(edges []models.TransferEdge, labels map[string]string) []models.Community {
+	byID := map[string]*models.Community{}
+	riskTotals := map[string]float64{}
+	get := func(label string) *models.Community {
+		id := "COMM-" + label
+		if byID[id] == nil {
+			byID[id] = &models.Community{CommunityID: id}
+		}
+		return byID[id]
+	}
+
+	for account, label := range labels {
+		c := get(label)
+		c.Members = append(c.Members, account)
+		c.Size++
+	}
+	for _, e := range edges {
+		if _, ok := labels[e.From]; !ok {
+			continue // self-transfers only, never labelled
+		}
+		from, to := get(labels[e.From]), get(labels[e.To])
+		if from == to {
+			from.Volume += e.Amount
+			from.TxnCount++
+			riskTotals[from.CommunityID] += e.RiskScore
+		}
+		if e.VerificationStatus == "CONFIRMED_FRAUD" {
+			from.FraudConfirmedCount++
+			if to != from {
+				to.FraudConfirmedCount++
+			}
+		}
+	}
+
+	now := time.Now()
+	communities := make([]models.Community, 0, len(byID))
+	for _, c := range byID {
+		if c.TxnCount > 0 {
+			c.AvgRisk = riskTotals[c.CommunityID] / float64(c.TxnCount)
+		}
+		c.UpdatedAt = now
+		sort.Strings(c.Members)
+		communities = append(communities, *c)
+	}
+	return communities
+}
+
+// saveCommunities replaces a tenant's stored clustering in SQLite
+func saveCommunities(tenant string, communities []models.Community) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM communities WHERE tenant_id = ?", tenant); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM account_communities WHERE tenant_id = ?", tenant); err != nil {
+		return err
+	}
+	for _, c := range communities {
+		_, err := tx.Exec(`
+			INSERT INTO communities (tenant_id, community_id, size, volume, txn_count, fraud_confirmed_count, avg_risk, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, tenant, c.CommunityID, c.Size, c.Volume, c.TxnCount, c.FraudConfirmedCount, c.AvgRisk, c.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		for _, member := range c.Members {
+			if _, err := tx.Exec("INSERT INTO account_communities (tenant_id, account_id, community_id, updated_at) VALUES (?, ?, ?, ?)", tenant, member, c.CommunityID, c.UpdatedAt); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveAccountCommunities stamps community membership and summary onto a tenant's Account nodes
+func (s *Neo4jService) SaveAccountCommunities(ctx context.Context, tenant string, communities []models.Community) error {
+	var rows []map[string]any
+	for _, c := range communities {
+		for _, member := range c.Members {
+			rows = append(rows, map[string]any{
+				"account_id":            member,
+				"community_id":          c.CommunityID,
+				"size":                  c.Size,
+				"fraud_confirmed_count": c.FraudConfirmedCount,
+				"avg_risk":              c.AvgRisk,
+			})
+		}
+	}
+
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			UNWIND $rows AS row
+			MATCH (a:Account {tenant: $tenant, id: row.account_id})
+			SET a.community_id = row.community_id,
+			    a.community_size = row.size,
+			    a.community_fraud_count = row.fraud_confirmed_count,
+			    a.community_avg_risk = row.avg_risk
+		`
+		return tx.Run(ctx, query, map[string]any{"tenant": tenant, "rows": rows})
+	})
+	return err
+}
+
+// ListCommunities returns a tenant's stored communities with at least minSize
+// members, riskiest first (confirmed fraud, then average risk, then size)
+func ListCommunities(tenant string, minSize, limit int) ([]models.Community, error) {
+	rows, err := db.DB.Query(`
+		SELECT community_id, size, volume, txn_count, fraud_confirmed_count, avg_risk, updated_at
+		FROM communities
+		WHERE tenant_id = ? AND size >= ?
+		ORDER BY fraud_confirmed_count DESC, avg_risk DESC, size DESC
+		LIMIT ?
+	`, tenant, minSize, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	communities := []models.Community{}
+	index := map[string]int{}
+	for rows.Next() {
+		var c models.Community
+		if err := rows.Scan(&c.CommunityID, &c.Size, &c.Volume, &c.TxnCount, &c.FraudConfirmedCount, &c.AvgRisk, &c.UpdatedAt); err != nil {
+			continue
+		}
+		c.Members = []string{}
+		index[c.CommunityID] = len(communities)
+		communities = append(communities, c)
+	}
+	rows.Close()
+
+	memberRows, err := db.DB.Query("SELECT account_id, community_id FROM account_communities WHERE tenant_id = ? ORDER BY account_id", tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer memberRows.Close()
+	for memberRows.Next() {
+		var account, community string
+		if err := memberRows.Scan(&account, &community); err != nil {
+			continue
+		}
+		if i, ok := index[community]; ok {
+			communities[i].Members = append(communities[i].Members, account)
+		}
+	}
+	return communities, nil
+}
+
+// GetAccountCommunity returns the stored community an account belongs to
+// within tenant, or nil
+func GetAccountCommunity(tenant, accountID string) (*models.Community, error) {
+	var c models.Community
+	err := db.DB.QueryRow(`
+		SELECT c.community_id, c.size, c.volume, c.txn_count, c.fraud_confirmed_count, c.avg_risk, c.updated_at
+		FROM account_communities ac
+		JOIN communities c ON c.tenant_id = ac.tenant_id AND c.community_id = ac.community_id
+		WHERE ac.tenant_id = ? AND ac.account_id = ?
+	`, tenant, accountID).Scan(&c.CommunityID, &c.Size, &c.Volume, &c.TxnCount, &c.FraudConfirmedCount, &c.AvgRisk, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// edgeList builds transfers of 100 from "from>to" pairs, a second apart
+func edgeList(pairs ...string) []models.TransferEdge {
+	out := make([]models.TransferEdge, 0, len(pairs))
+	for i, p := range pairs {
+		from, to, _ := strings.Cut(p, ">")
+		out = append(out, models.TransferEdge{TxnID: testID("E"), From: from, To: to, Amount: 100, RiskScore: 0.5,
+			Timestamp: time.Unix(int64(i), 0)})
+	}
+	return out
+}
+
+func TestLabelPropagation(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    []models.TransferEdge
+		together [][]string // accounts that must share a label
+		apart    [][2]string
+	}{
+		{"two triangles",
+			edgeList("a>b", "b>c", "c>a", "x>y", "y>z", "z>x"),
+			[][]string{{"a", "b", "c"}, {"x", "y", "z"}},
+			[][2]string{{"a", "x"}}},
+		{"dense clusters joined by one transfer",
+			edgeList("a>b", "b>c", "c>a", "a>c", "b>a", "x>y", "y>z", "z>x", "x>z", "y>x", "c>x"),
+			[][]string{{"a", "b", "c"}, {"x", "y", "z"}},
+			[][2]string{{"a", "y"}}},
+		{"chain", edgeList("a>b", "b>c"), [][]string{{"a", "b", "c"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := labelPropagation(tt.edges, 20)
+			for _, group := range tt.together {
+				for _, account := range group[1:] {
+					if labels[account] != labels[group[0]] {
+						t.Errorf("%s and %s have labels %q and %q", group[0], account, labels[group[0]], labels[account])
+					}
+				}
+			}
+			for _, pair := range tt.apart {
+				if labels[pair[0]] == labels[pair[1]] {
+					t.Errorf("%s and %s share label %q", pair[0], pair[1], labels[pair[0]])
+				}
+			}
+		})
+	}
+
+	if labels := labelPropagation(edgeList("a>a"), 20); len(labels) != 0 {
+		t.Errorf("self transfer labelled %v", labels)
+	}
+
+	// The same graph always gets the same labels
+	g := edgeList("a>b", "b>c", "c>a", "c>d", "d>e", "e>f", "f>d")
+	first := labelPropagation(g, 20)
+	for i := 0; i < 5; i++ {
+		for account, label := range labelPropagation(g, 20) {
+			if first[account] != label {
+				t.Fatalf("run %d labelled %s %q, first run %q", i, account, label, first[account])
+			}
+		}
+	}
+}
+
+func TestSummarizeCommunities(t *testing.T) {
+	e := edgeList("a>b", "b>a", "x>y", "b>x")
+	e[1].VerificationStatus = "CONFIRMED_FRAUD"
+	e[3].VerificationStatus = "CONFIRMED_FRAUD"
+	labels := map[string]string{"a": "a", "b": "a", "x": "x", "y": "x"}
+
+	byID := map[string]models.Community{}
+	for _, c := range summarizeCommunities(e, labels) {
+		byID[c.CommunityID] = c
+	}
+	tests := []struct {
+		id      string
+		size    int
+		txns    int
+		volume  float64
+		fraud   int
+		members []string
+	}{
+		// b>x leaves COMM-a: its fraud counts on both sides, its volume on neither
+		{"COMM-a", 2, 2, 200, 2, []string{"a", "b"}},
+		{"COMM-x", 2, 1, 100, 1, []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		c, ok := byID[tt.id]
+		if !ok {
+			t.Fatalf("no community %s in %v", tt.id, byID)
+		}
+		if c.Size != tt.size || c.TxnCount != tt.txns || c.Volume != tt.volume || c.FraudConfirmedCount != tt.fraud ||
+			len(c.Members) != len(tt.members) || c.Members[0] != tt.members[0] || c.AvgRisk != 0.5 {
+			t.Errorf("%s = %+v", tt.id, c)
+		}
+	}
+}
+
+func TestCommunityJobRecompute(t *testing.T) {
+	// Accounts at different banks, seen in one tenant's transfers
+	a, b, c := testID("SCB-COM"), testID("KBANK-COM"), testID("KTB-COM")
+	now := time.Now().Add(-time.Hour)
+	addTransfer(t, a, b, 100, now)
+	addTransfer(t, b, c, 100, now.Add(time.Minute))
+	addTransfer(t, c, a, 100, now.Add(2*time.Minute))
+
+	job := NewCommunityJob(nil)
+	signature, changed, err := job.graphChanged()
+	if err != nil || !changed || signature == "" {
+		t.Fatalf("graphChanged = %q, %v, %v on the first run", signature, changed, err)
+	}
+	// A run that did not save its communities is tried again
+	if _, changed, _ := job.graphChanged(); !changed {
+		t.Error("graphChanged forgot a change that was never computed")
+	}
+	job.markComputed(signature)
+	if _, changed, _ := job.graphChanged(); changed {
+		t.Error("graphChanged reports a change with no new transfers")
+	}
+	if _, err := job.Recompute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	community, err := GetAccountCommunity(DefaultTenant, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []string{b, c} {
+		other, err := GetAccountCommunity(DefaultTenant, account)
+		if err != nil || other.CommunityID != community.CommunityID {
+			t.Errorf("%s is in %v, %s in %s", account, other, a, community.CommunityID)
+		}
+	}
+	if community.Size < 3 || community.TxnCount < 3 {
+		t.Errorf("community = %+v", community)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// CycleOptions bounds round-trip detection so it cannot run away on hub accounts
+type CycleOptions struct {
+	MaxLength int           // maximum number of hops in a cycle
+	Window    time.Duration // edges must be recent and the whole cycle must fit in this window
+	MaxFanOut int           // intermediaries with more outgoing transfers than this are not expanded
+	MaxCycles int           // stop after this many cycles
+	Timeout   time.Duration // give up on the search after this long; 0 = no limit
+	CacheTTL  time.Duration // scoring reuses an account's cycles for this long; 0 = search every time
+}
+
+func DefaultCycleOptions() CycleOptions {
+	return CycleOptions{
+		MaxLength: 5,
+		Window:    72 * time.Hour,
+		MaxFanOut: 25,
+		MaxCycles: 50,
+		Timeout:   2 * time.Second,
+		CacheTTL:  time.Minute,
+	}
+}
+
+// maxCycleExpansions caps the total number of edges the Go search will follow
+const maxCycleExpansions = 20000
+
+// FindCycles returns time-respecting cycles over TRANSFERRED edges that start
+// and end at accountID within tenant
+func (s *Neo4jService) FindCycles(ctx context.Context, tenant, accountID string, opts CycleOptions) ([]models.Cycle, error) {
+	if opts.MaxLength < 2 {
+		opts.MaxLength = 2
+	}
+	// Scoring waits on this search, so it gets a deadline
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if !s.Connected {
+		return searchCycles(ctx, accountID, opts, sqliteEdges(tenant))
+	}
+
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		return searchCycles(ctx, accountID, opts, neo4jEdges(ctx, tx, tenant))
+	}, txTimeout(opts.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.Cycle), nil
+}
+
+// AccountCycles returns the cycles through one of tenant's accounts for
+// scoring, reusing a search made within the last CacheTTL. Every ingest scores
+// its receiver, and a mule receives transfers in bursts, so most lookups skip
+// the search. A cycle closed by a new transfer shows up once the entry
+// expires. Failed searches are not kept.
+func (s *Neo4jService) AccountCycles(ctx context.Context, tenant, accountID string) ([]models.Cycle, error) {
+	ttl := s.Cycles.CacheTTL
+	if ttl <= 0 {
+		return s.FindCycles(ctx, tenant, accountID, s.Cycles)
+	}
+	key := cycleCacheKey(tenant, accountID)
+	if cycles, ok := s.cycleCache.get(key); ok {
+		return cycles, nil
+	}
+	cycles, err := s.FindCycles(ctx, tenant, accountID, s.Cycles)
+	if err != nil {
+		return nil, err
+	}
+	s.cycleCache.put(key, cycles, ttl)
+	return cycles, nil
+}
+
+// maxCachedCycleAccounts bounds the cycle cache. Expired entries are dropped
+// when it fills, and everything is if that is not enough.
+const maxCachedCycleAccounts = 10000
+
+// cycleCache holds recent AccountCycles results. The zero value is ready to use.
+type cycleCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCycles
+}
+
+type cachedCycles struct {
+	cycles  []models.Cycle
+	expires time.Time
+}
+
+func cycleCacheKey(tenant, accountID string) string {
+	return tenant + "\x00" + accountID
+}
+
+func (c *cycleCache) get(key string) ([]models.Cycle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return nil, false
+	}
+	return e.cycles, true
+}
+
+func (c *cycleCache) put(key string, cycles []models.Cycle, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = map[string]cachedCycles{}
+	}
+	if len(c.entries) >= maxCachedCycleAccounts {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedCycleAccounts {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = cachedCycles{cycles: cycles, expires: now.Add(ttl)}
+}
+
+// searchCycles is an iterative depth-first search that loads each account's
+// transfers as it reaches it. Hubs with more than MaxFanOut recent transfers
+// are never expanded, so the work stays bounded on dense graphs. Each hop must
+// happen no earlier than the previous one and within the window of the first.
+func searchCycles(ctx context.Context, start string, opts CycleOptions, load edgeLoader) ([]models.Cycle, error) {
+	since := time.Now().Add(-opts.Window)
+
+	// Outgoing edges are loaded lazily and cached; hubs are marked with a nil entry
+	adjacency := map[string][]models.TransferEdge{}
+	outgoing := func(account string) ([]models.TransferEdge, error) {
+		if edges, ok := adjacency[account]; ok {
+			return edges, nil
+		}
+		edges, err := load(account, since, opts.MaxFanOut+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(edges) > opts.MaxFanOut {
+			if account == start {
+				edges = edges[len(edges)-opts.MaxFanOut:]
+			} else {
+				edges = nil
+			}
+		}
+		adjacency[account] = edges
+		return edges, nil
+	}
+
+	type frame struct {
+		edges []models.TransferEdge
+		next  int
+	}
+
+	rootEdges, err := outgoing(start)
+	if err != nil {
+		return nil, err
+	}
+
+	var cycles []models.Cycle
+	var path []models.TransferEdge
+	onPath := map[string]bool{start: true}
+	stack := []*frame{{edges: rootEdges}}
+	expansions := 0
+
+	for len(stack) > 0 && len(cycles) < opts.MaxCycles && expansions < maxCycleExpansions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		if top.next >= len(top.edges) {
+			stack = stack[:len(stack)-1]
+			if len(path) > 0 {
+				delete(onPath, path[len(path)-1].To)
+				path = path[:len(path)-1]
+			}
+			continue
+		}
+		e := top.edges[top.next]
+		top.next++
+		expansions++
+
+		if len(path) > 0 {
+			prev := path[len(path)-1]
+			if e.Timestamp.Before(prev.Timestamp) || e.Timestamp.Sub(path[0].Timestamp) > opts.Window {
+				continue
+			}
+		}
+
+		if e.To == start {
+			if len(path) > 0 {
+				hops := append(append([]models.TransferEdge{}, path...), e)
+				cycles = append(cycles, newCycle(hops))
+			}
+			continue
+		}
+		if onPath[e.To] || len(path)+1 >= opts.MaxLength {
+			continue
+		}
+
+		next, err := outgoing(e.To)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, e)
+		onPath[e.To] = true
+		stack = append(stack, &frame{edges: next})
+	}
+	return cycles, nil
+}
+
+func newCycle(edges []models.TransferEdge) models.Cycle {
+	c := models.Cycle{Edges: edges}
+	if len(edges) == 0 {
+		return c
+	}
+	c.Accounts = append(c.Accounts, edges[0].From)
+	for _, e := range edges {
+		c.Accounts = append(c.Accounts, e.To)
+		c.TotalAmount += e.Amount
+	}
+	c.StartedAt = edges[0].Timestamp
+	c.EndedAt = edges[len(edges)-1].Timestamp
+	c.DurationSeconds = c.EndedAt.Sub(c.StartedAt).Seconds()
+	return c
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestFindCyclesSQLite(t *testing.T) {
+	type hop struct {
+		from, to string
+		minutes  int // after the first transfer
+	}
+	opts := CycleOptions{MaxLength: 4, Window: 24 * time.Hour, MaxFanOut: 3, MaxCycles: 50}
+
+	tests := []struct {
+		name   string
+		hops   []hop
+		change func(o *CycleOptions)
+		want   [][]string // account IDs of each cycle, in search order
+	}{
+		{"two hops", []hop{{"A", "B", 0}, {"B", "A", 5}}, nil, [][]string{{"A", "B", "A"}}},
+		{"triangle", []hop{{"A", "B", 0}, {"B", "C", 5}, {"C", "A", 10}}, nil, [][]string{{"A", "B", "C", "A"}}},
+		{"hop earlier than the previous one", []hop{{"A", "B", 10}, {"B", "C", 5}, {"C", "A", 20}}, nil, nil},
+		{"longer than MaxLength", []hop{{"A", "B", 0}, {"B", "C", 1}, {"C", "D", 2}, {"D", "E", 3}, {"E", "A", 4}}, nil, nil},
+		{"within a raised MaxLength", []hop{{"A", "B", 0}, {"B", "C", 1}, {"C", "D", 2}, {"D", "E", 3}, {"E", "A", 4}},
+			func(o *CycleOptions) { o.MaxLength = 5 }, [][]string{{"A", "B", "C", "D", "E", "A"}}},
+		{"intermediary revisited", []hop{{"A", "B", 0}, {"B", "C", 1}, {"C", "B", 2}, {"B", "A", 3}}, nil, [][]string{{"A", "B", "A"}}},
+		{"hub is not expanded", []hop{{"A", "H", 0}, {"H", "X1", 1}, {"H", "X2", 2}, {"H", "X3", 3}, {"H", "A", 4}}, nil, nil},
+		{"hub within a raised fan-out", []hop{{"A", "H", 0}, {"H", "X1", 1}, {"H", "X2", 2}, {"H", "X3", 3}, {"H", "A", 4}},
+			func(o *CycleOptions) { o.MaxFanOut = 4 }, [][]string{{"A", "H", "A"}}},
+		{"MaxCycles", []hop{{"A", "B", 0}, {"A", "C", 1}, {"A", "D", 2}, {"B", "A", 3}, {"C", "A", 4}, {"D", "A", 5}},
+			func(o *CycleOptions) { o.MaxCycles = 2 }, [][]string{{"A", "B", "A"}, {"A", "C", "A"}}},
+		{"no way back", []hop{{"A", "B", 0}, {"B", "C", 1}}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Account IDs are made unique per case so cases share the database
+			prefix := testID("CYC")
+			id := func(name string) string { return prefix + "-" + name }
+			start := time.Now().Add(-2 * time.Hour)
+			for _, h := range tt.hops {
+				addTransfer(t, id(h.from), id(h.to), 1000, start.Add(time.Duration(h.minutes)*time.Minute))
+			}
+			o := opts
+			if tt.change != nil {
+				tt.change(&o)
+			}
+
+			cycles, err := (&Neo4jService{}).FindCycles(context.Background(), DefaultTenant, id("A"), o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for _, c := range cycles {
+				accounts := make([]string, len(c.Accounts))
+				for i, a := range c.Accounts {
+					accounts[i] = a[len(prefix)+1:]
+				}
+				got = append(got, accounts)
+				if c.TotalAmount != float64(1000*len(c.Edges)) || c.EndedAt.Before(c.StartedAt) {
+					t.Errorf("cycle %v totals %v from %v to %v", accounts, c.TotalAmount, c.StartedAt, c.EndedAt)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cycles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCyclesIgnoresOldTransfers(t *testing.T) {
+	a, b := testID("CYC-A"), testID("CYC-B")
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	addTransfer(t, a, b, 500, old)
+	addTransfer(t, b, a, 500, old.Add(time.Minute))
+
+	cycles, err := (&Neo4jService{}).FindCycles(context.Background(), DefaultTenant, a, DefaultCycleOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 0 {
+		t.Errorf("found %d cycles outside the window", len(cycles))
+	}
+}
+
+func TestAccountCycles(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := testID("CYC-A"), testID("CYC-B"), testID("CYC-C")
+	at := time.Now().Add(-time.Hour)
+	addTransfer(t, a, b, 500, at)
+	addTransfer(t, b, a, 500, at.Add(time.Minute))
+
+	s := &Neo4jService{Cycles: DefaultCycleOptions()}
+	uncached := &Neo4jService{Cycles: DefaultCycleOptions()}
+	uncached.Cycles.CacheTTL = 0
+	count := func(s *Neo4jService) int {
+		t.Helper()
+		cycles, err := s.AccountCycles(ctx, DefaultTenant, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(cycles)
+	}
+
+	if n := count(s); n != 1 {
+		t.Fatalf("first search found %d cycles, want 1", n)
+	}
+	addTransfer(t, a, c, 500, at.Add(2*time.Minute))
+	addTransfer(t, c, a, 500, at.Add(3*time.Minute))
+	if n := count(s); n != 1 {
+		t.Errorf("found %d cycles within CacheTTL, want the cached 1", n)
+	}
+	if n := count(uncached); n != 2 {
+		t.Errorf("found %d cycles without a cache, want 2", n)
+	}
+	if _, ok := s.cycleCache.get(cycleCacheKey(DefaultTenant, b)); ok {
+		t.Error("another account has a cached search")
+	}
+
+	key := cycleCacheKey(DefaultTenant, a)
+	s.cycleCache.entries[key] = cachedCycles{expires: time.Now().Add(-time.Second)}
+	if n := count(s); n != 2 {
+		t.Errorf("found %d cycles after the entry expired, want 2", n)
+	}
+}
+
+func TestCycleCacheBounded(t *testing.T) {
+	var c cycleCache
+	for i := 0; i < maxCachedCycleAccounts; i++ {
+		c.put(fmt.Sprint(i), nil, time.Minute)
+	}
+	c.put("expired", nil, -time.Second)
+	if len(c.entries) > maxCachedCycleAccounts {
+		t.Errorf("cache holds %d entries, bound is %d", len(c.entries), maxCachedCycleAccounts)
+	}
+	c.put("new", []models.Cycle{{}}, time.Minute)
+	if cycles, ok := c.get("new"); !ok || len(cycles) != 1 {
+		t.Errorf("get(new) = %v, %v after the cache filled", cycles, ok)
+	}
+}
+
+// memEdges is an edgeLoader over an in-memory list of transfers. It counts
+// the accounts it is asked about in loads.
+func memEdges(edges []models.TransferEdge, loads map[string]int) edgeLoader {
+	return func(account string, since time.Time, limit int) ([]models.TransferEdge, error) {
+		loads[account]++
+		var out []models.TransferEdge
+		for _, e := range edges {
+			if e.From == account && !e.Timestamp.Before(since) {
+				out = append(out, e)
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp.Before(out[j].Timestamp) })
+		if len(out) > limit {
+			out = out[len(out)-limit:]
+		}
+		return out, nil
+	}
+}
+
+func TestSearchCycles(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	hop := func(from, to string, minutes int) models.TransferEdge {
+		return models.TransferEdge{TxnID: from + to, From: from, To: to, Amount: 100, Timestamp: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	opts := CycleOptions{MaxLength: 4, Window: 24 * time.Hour, MaxFanOut: 2, MaxCycles: 50}
+
+	tests := []struct {
+		name      string
+		edges     []models.TransferEdge
+		want      []string // transaction IDs of each cycle, joined
+		notLoaded []string // accounts the search must not expand
+	}{
+		{"triangle", []models.TransferEdge{hop("A", "B", 0), hop("B", "C", 1), hop("C", "A", 2)}, []string{"AB BC CA"}, nil},
+		{"start keeps its most recent transfers", []models.TransferEdge{hop("A", "X", 0), hop("X", "A", 1), hop("A", "B", 2), hop("A", "C", 3), hop("B", "A", 4), hop("C", "A", 5)},
+			[]string{"AB BA", "AC CA"}, []string{"X"}},
+		{"hub is not expanded", []models.TransferEdge{hop("A", "H", 0), hop("H", "X", 1), hop("H", "Y", 2), hop("H", "A", 3), hop("X", "A", 4)},
+			nil, []string{"X", "Y"}},
+		{"each account loaded once", []models.TransferEdge{hop("A", "B", 0), hop("A", "C", 1), hop("B", "D", 2), hop("C", "D", 3), hop("D", "A", 4)},
+			[]string{"AB BD DA", "AC CD DA"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := map[string]int{}
+			cycles, err := searchCycles(context.Background(), "A", opts, memEdges(tt.edges, loads))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range cycles {
+				ids := ""
+				for i, e := range c.Edges {
+					if i > 0 {
+						ids += " "
+					}
+					ids += e.TxnID
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cycles = %v, want %v", got, tt.want)
+			}
+			for account, n := range loads {
+				if n > 1 {
+					t.Errorf("%s loaded %d times", account, n)
+				}
+			}
+			for _, account := range tt.notLoaded {
+				if loads[account] > 0 {
+					t.Errorf("%s was expanded", account)
+				}
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		edges := []models.TransferEdge{hop("A", "B", 0), hop("B", "A", 1)}
+		if _, err := searchCycles(ctx, "A", opts, memEdges(edges, map[string]int{})); !errors.Is(err, context.Canceled) {
+			t.Errorf("searchCycles = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		failed := errors.New("store down")
+		load := func(account string, since time.Time, limit int) ([]models.TransferEdge, error) {
+			if account == "B" {
+				return nil, failed
+			}
+			return []models.TransferEdge{hop("A", "B", 0)}, nil
+		}
+		if _, err := searchCycles(context.Background(), "A", opts, load); !errors.Is(err, failed) {
+			t.Errorf("searchCycles = %v, want the load error", err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Entity types shared between accounts. Each maps to a Neo4j label and
+// relationship type and to the entity_type column of account_entities.
+const (
+	EntityDevice = "DEVICE"
+	EntityIP     = "IP"
+)
+
+var entityGraphSchema = map[string]struct{ label, rel string }{
+	EntityDevice: {"Device", "USED_DEVICE"},
+	EntityIP:     {"IPAddress", "FROM_IP"},
+}
+
+// saveEntityLinksSQLite records which accounts used the transaction's device and IPs
+func saveEntityLinksSQLite(txn models.Transaction) error {
+	links := [][3]string{
+		{EntityDevice, txn.DeviceID, txn.SenderAccount},
+		{EntityIP, txn.SenderIP, txn.SenderAccount},
+		{EntityIP, txn.ReceiverIP, txn.ReceiverAccount},
+	}
+	for _, l := range links {
+		if l[1] == "" || l[2] == "" {
+			continue
+		}
+		_, err := db.DB.Exec(`
+			INSERT INTO account_entities (tenant_id, entity_type, entity_id, account_id, first_seen, last_seen, txn_count)
+			VALUES (?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT(tenant_id, entity_type, entity_id, account_id) DO UPDATE SET
+				first_seen = min(first_seen, excluded.first_seen),
+				last_seen = max(last_seen, excluded.last_seen),
+				txn_count = txn_count + 1
+		`, txn.TenantID, l[0], l[1], l[2], txn.Timestamp, txn.Timestamp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveEntityLinksNeo4j merges Device and IPAddress nodes for a transaction whose accounts already exist
+func saveEntityLinksNeo4j(ctx context.Context, tx neo4j.ManagedTransaction, txn models.Transaction) error {
+	query := `
+		MATCH (s:Account {tenant: $tenant, id: $sender_acc}), (r:Account {tenant: $tenant, id: $receiver_acc})
+		FOREACH (_ IN CASE WHEN $device_id <> '' THEN [1] ELSE [] END |
+			MERGE (d:Device {tenant: $tenant, id: $device_id})
+			MERGE (s)-[u:USED_DEVICE]->(d)
+			ON CREATE SET u.first_seen = $timestamp, u.txn_count = 0
+			SET u.last_seen = $timestamp, u.txn_count = u.txn_count + 1)
+		FOREACH (_ IN CASE WHEN $sender_ip <> '' THEN [1] ELSE [] END |
+			MERGE (ip:IPAddress {tenant: $tenant, id: $sender_ip})
+			MERGE (s)-[f:FROM_IP]->(ip)
+			ON CREATE SET f.first_seen = $timestamp, f.txn_count = 0
+			SET f.last_seen = $timestamp, f.txn_count = f.txn_count + 1)
+		FOREACH (_ IN CASE WHEN $receiver_ip <> '' THEN [1] ELSE [] END |
+			MERGE (ip:IPAddress {tenant: $tenant, id: $receiver_ip})
+			MERGE (r)-[f:FROM_IP]->(ip)
+			ON CREATE SET f.first_seen = $timestamp, f.txn_count = 0
+			SET f.last_seen = $timestamp, f.txn_count = f.txn_count + 1)
+	`
+	_, err := tx.Run(ctx, query, map[string]any{
+		"tenant":       txn.TenantID,
+		"sender_acc":   txn.SenderAccount,
+		"receiver_acc": txn.ReceiverAccount,
+		"device_id":    txn.DeviceID,
+		"sender_ip":    txn.SenderIP,
+		"receiver_ip":  txn.ReceiverIP,
+		"timestamp":    txn.Timestamp.Format(time.RFC3339Nano),
+	})
+	return err
+}
+
+// GetLinkedAccounts lists a tenant's accounts that used a device or IP address at or after since
+func (s *Neo4jService) GetLinkedAccounts(ctx context.Context, tenant, entityType, entityID string, since time.Time) ([]models.LinkedAccount, error) {
+	schema, ok := entityGraphSchema[entityType]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	if !s.Connected {
+		rows, err := db.DB.Query(`
+			SELECT account_id, first_seen, last_seen, txn_count
+			FROM account_entities
+			WHERE tenant_id = ? AND entity_type = ? AND entity_id = ? AND last_seen >= ?
+			ORDER BY last_seen DESC
+		`, tenant, entityType, entityID, since)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		accounts := []models.LinkedAccount{}
+		for rows.Next() {
+			var a models.LinkedAccount
+			if err := rows.Scan(&a.AccountID, &a.FirstSeen, &a.LastSeen, &a.TxnCount); err != nil {
+				continue
+			}
+			accounts = append(accounts, a)
+		}
+		return accounts, nil
+	}
+
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		// Label and relationship type come from entityGraphSchema, never from input
+		query := fmt.Sprintf(`
+			MATCH (a:Account {tenant: $tenant})-[l:%s]->(:%s {tenant: $tenant, id: $entity_id})
+			WHERE datetime(l.last_seen) >= datetime($since)
+			RETURN a.id as account_id, l.first_seen as first_seen, l.last_seen as last_seen, l.txn_count as txn_count
+			ORDER BY l.last_seen DESC
+		`, schema.rel, schema.label)
+		res, err := tx.Run(ctx, query, map[string]any{
+			"tenant":    tenant,
+			"entity_id": entityID,
+			"since":     since.Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		accounts := []models.LinkedAccount{}
+		for res.Next(ctx) {
+			rec := res.Record()
+			accountID, _ := rec.Get("account_id")
+			firstSeen, _ := rec.Get("first_seen")
+			lastSeen, _ := rec.Get("last_seen")
+			txnCount, _ := rec.Get("txn_count")
+
+			a := models.LinkedAccount{TxnCount: int64(toFloat(txnCount))}
+			a.AccountID, _ = accountID.(string)
+			if ts, ok := firstSeen.(string); ok {
+				a.FirstSeen, _ = time.Parse(time.RFC3339Nano, ts)
+			}
+			if ts, ok := lastSeen.(string); ok {
+				a.LastSeen, _ = time.Parse(time.RFC3339Nano, ts)
+			}
+			accounts = append(accounts, a)
+		}
+		return accounts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.LinkedAccount), nil
+}
+
+// countOtherLinkedAccounts counts a tenant's accounts other than accountID sharing the entity within the window
+func (s *Neo4jService) countOtherLinkedAccounts(ctx context.Context, tenant, entityType, entityID, accountID string) int64 {
+	if entityID == "" {
+		return 0
+	}
+	accounts, err := s.GetLinkedAccounts(ctx, tenant, entityType, entityID, time.Now().Add(-s.SharedEntityWindow))
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, a := range accounts {
+		if a.AccountID != accountID {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestSharedEntities(t *testing.T) {
+	ctx := context.Background()
+	s := &Neo4jService{SharedEntityWindow: 30 * 24 * time.Hour}
+	device, ip, oldDevice := testID("DEV"), testID("10.0.0"), testID("DEV-OLD")
+	a, b, c, d := testID("ENT-A"), testID("ENT-B"), testID("ENT-C"), testID("ENT-D")
+	now := time.Now().Add(-time.Hour)
+
+	for _, txn := range []models.Transaction{
+		{SenderAccount: a, ReceiverAccount: c, DeviceID: device, SenderIP: ip, Timestamp: now},
+		{SenderAccount: a, ReceiverAccount: c, DeviceID: device, SenderIP: ip, Timestamp: now.Add(time.Minute)},
+		{SenderAccount: b, ReceiverAccount: c, DeviceID: device, Timestamp: now.Add(2 * time.Minute)},
+		{SenderAccount: d, ReceiverAccount: c, ReceiverIP: ip, Timestamp: now},
+		{SenderAccount: d, ReceiverAccount: c, DeviceID: oldDevice, Timestamp: now.Add(-60 * 24 * time.Hour)},
+	} {
+		txn.TenantID, txn.TransactionID = DefaultTenant, testID("TXN")
+		if err := s.SaveTransaction(ctx, txn, models.AnalysisResult{RiskScore: 0.1, Action: "APPROVE"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		entityType string
+		entityID   string
+		since      time.Time
+		want       map[string]int64 // account to transaction count
+	}{
+		{"device used by two senders", EntityDevice, device, time.Time{}, map[string]int64{a: 2, b: 1}},
+		{"IP of a sender and a receiver", EntityIP, ip, time.Time{}, map[string]int64{a: 2, c: 1}},
+		{"since", EntityDevice, device, now.Add(90 * time.Second), map[string]int64{b: 1}},
+		{"unknown device", EntityDevice, "no-such-device", time.Time{}, map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := s.GetLinkedAccounts(ctx, DefaultTenant, tt.entityType, tt.entityID, tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int64{}
+			for _, la := range accounts {
+				got[la.AccountID] = la.TxnCount
+				if la.LastSeen.Before(la.FirstSeen) {
+					t.Errorf("%s last seen %v before first seen %v", la.AccountID, la.LastSeen, la.FirstSeen)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("linked accounts = %v, want %v", got, tt.want)
+			}
+			for account, n := range tt.want {
+				if got[account] != n {
+					t.Errorf("%s has %d transactions, want %d", account, got[account], n)
+				}
+			}
+		})
+	}
+
+	if _, err := s.GetLinkedAccounts(ctx, DefaultTenant, "EMAIL", "x@y", time.Time{}); err == nil {
+		t.Error("unknown entity type accepted")
+	}
+
+	features := map[string]any{}
+	s.AddTransactionFeatures(ctx, models.Transaction{TenantID: DefaultTenant, SenderAccount: a, DeviceID: device, SenderIP: ip}, features)
+	if features["device_shared_account_count"] != int64(1) || features["sender_ip_shared_account_count"] != int64(1) {
+		t.Errorf("features = %v", features)
+	}
+	s.AddTransactionFeatures(ctx, models.Transaction{TenantID: DefaultTenant, SenderAccount: a, DeviceID: oldDevice}, features)
+	if features["device_shared_account_count"] != int64(0) {
+		t.Errorf("device use outside the window counted: %v", features)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// What to do with a transaction in a currency that has no FX rate
+const (
+	UnknownCurrencyReject = "reject" // refuse the transaction
+	UnknownCurrencyReview = "review" // score it unconverted and hold it for review
+	UnknownCurrencyAccept = "accept" // score it unconverted as if it were in the base currency
+)
+
+var ErrUnknownCurrency = errors.New("no FX rate for currency")
+
+// FXService normalises transaction amounts into the base currency using
+// dated rate tables, so thresholds and aggregates compare like with like
+type FXService struct {
+	BaseCurrency  string
+	UnknownPolicy string
+
+	mu    sync.RWMutex
+	rates map[string][]models.FXRate // by currency, oldest effective date first
+}
+
+func NewFXService() *FXService {
+	return &FXService{
+		BaseCurrency:  "THB",
+		UnknownPolicy: UnknownCurrencyReview,
+		rates:         map[string][]models.FXRate{},
+	}
+}
+
+// Load reads every stored rate into memory
+func (f *FXService) Load() error {
+	rows, err := db.DB.Query(`SELECT currency, effective_date, rate, source, uploaded_at FROM fx_rates`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	rates := map[string][]models.FXRate{}
+	for rows.Next() {
+		var r models.FXRate
+		if err := rows.Scan(&r.Currency, &r.EffectiveDate, &r.Rate, &r.Source, &r.UploadedAt); err != nil {
+			continue
+		}
+		rates[r.Currency] = append(rates[r.Currency], r)
+	}
+	for c := range rates {
+		sortRates(rates[c])
+	}
+	f.mu.Lock()
+	f.rates = rates
+	f.mu.Unlock()
+	return nil
+}
+
+func sortRates(rates []models.FXRate) {
+	sort.Slice(rates, func(i, j int) bool { return rates[i].EffectiveDate < rates[j].EffectiveDate })
+}
+
+// Rate returns the rate for currency in effect on the date of at
+func (f *FXService) Rate(currency string, at time.Time) (float64, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == f.BaseCurrency {
+		return 1, true
+	}
+	day := at.UTC().Format(dateLayout)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	rates := f.rates[currency]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].EffectiveDate > day }) - 1
+	if i < 0 {
+		return 0, false
+	}
+	return rates[i].Rate, true
+}
+
+// Normalize converts txn.Amount into the base currency, keeping the submitted
+// amount and currency in OriginalAmount and OriginalCurrency. It reports whether
+// the currency had no rate; under the reject policy that is ErrUnknownCurrency.
+func (f *FXService) Normalize(txn *models.Transaction) (bool, error) {
+	currency := strings.ToUpper(strings.TrimSpace(txn.Currency))
+	if currency == "" {
+		currency = f.BaseCurrency
+	}
+	txn.OriginalAmount = txn.Amount
+	txn.OriginalCurrency = currency
+
+	rate, ok := f.Rate(currency, txn.Timestamp)
+	if !ok {
+		if f.UnknownPolicy == UnknownCurrencyReject {
+			return true, fmt.Errorf("%w %s", ErrUnknownCurrency, currency)
+		}
+		txn.Currency = currency
+		txn.FXRate = 0
+		return true, nil
+	}
+	txn.FXRate = rate
+	txn.Amount = math.Round(txn.Amount*rate*100) / 100
+	txn.Currency = f.BaseCurrency
+	return false, nil
+}
+
+// Rates returns every stored rate, by currency then effective date
+func (f *FXService) Rates() []models.FXRate {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	currencies := make([]string, 0, len(f.rates))
+	for c := range f.rates {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	all := []models.FXRate{}
+	for _, c := range currencies {
+		all = append(all, f.rates[c]...)
+	}
+	return all
+}
+
+// SaveRates validates and upserts a rate table; rates for other dates are kept
+func (f *FXService) SaveRates(rates []models.FXRate, source string) error {
+	now := time.Now()
+	for i := range rates {
+		r := &rates[i]
+		r.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+		if len(r.Currency) != 3 {
+			return fmt.Errorf("rate %d: currency %q is not an ISO 4217 code", i+1, r.Currency)
+		}
+		if _, err := time.Parse(dateLayout, r.EffectiveDate); err != nil {
+			return fmt.Errorf("rate %d: effective_date %q is not YYYY-MM-DD", i+1, r.EffectiveDate)
+		}
+		if r.Rate <= 0 || math.IsInf(r.Rate, 0) || math.IsNaN(r.Rate) {
+			return fmt.Errorf("rate %d: rate must be positive", i+1)
+		}
+		if r.Source == "" {
+			r.Source = source
+		}
+		r.UploadedAt = now
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range rates {
+		_, err := tx.Exec(`
+			INSERT INTO fx_rates (currency, effective_date, rate, source, uploaded_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(currency, effective_date) DO UPDATE SET
+				rate = excluded.rate, source = excluded.source, uploaded_at = excluded.uploaded_at
+		`, r.Currency, r.EffectiveDate, r.Rate, r.Source, r.UploadedAt)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return f.Load()
+}
+
+// LoadRatesFile upserts rates from a JSON array or CSV file
+func (f *FXService) LoadRatesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rates, err := ParseFXRates(data)
+	if err != nil {
+		return err
+	}
+	return f.SaveRates(rates, "file:"+path)
+}
+
+// ParseFXRates reads a JSON array of {currency, effective_date, rate} or CSV
+// currency,effective_date,rate rows
+func ParseFXRates(data []byte) ([]models.FXRate, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var rates []models.FXRate
+		err := json.Unmarshal(data, &rates)
+		return rates, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var rates []models.FXRate
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rate float64
+		if len(rec) >= 3 {
+			rate, err = strconv.ParseFloat(strings.TrimSpace(rec[2]), 64)
+		}
+		if len(rec) < 3 || err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: expected currency,effective_date,rate", line)
+		}
+		rates = append(rates, models.FXRate{Currency: rec[0], EffectiveDate: strings.TrimSpace(rec[1]), Rate: rate})
+	}
+	return rates, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// newTestFX returns an FX service with USD rates from 2025-01-01 and 2025-07-01
+func newTestFX(t *testing.T) *FXService {
+	t.Helper()
+	f := NewFXService()
+	err := f.SaveRates([]models.FXRate{
+		{Currency: "usd", EffectiveDate: "2025-07-01", Rate: 32.5},
+		{Currency: "USD", EffectiveDate: "2025-01-01", Rate: 34.25},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFXRate(t *testing.T) {
+	f := newTestFX(t)
+	tests := []struct {
+		currency string
+		at       string
+		rate     float64
+		ok       bool
+	}{
+		{"USD", "2024-12-31T23:59:59Z", 0, false},
+		{"USD", "2025-01-01T00:00:00Z", 34.25, true},
+		{"usd", "2025-06-30T12:00:00Z", 34.25, true},
+		{"USD", "2025-07-01T00:00:00Z", 32.5, true},
+		{"USD", "2026-03-01T00:00:00Z", 32.5, true},
+		{"USD", "2025-06-30T20:00:00-07:00", 32.5, true}, // already 1 July in UTC
+		{"THB", "2020-01-01T00:00:00Z", 1, true},
+		{"", "2020-01-01T00:00:00Z", 1, true},
+		{"XTS", "2025-07-01T00:00:00Z", 0, false},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse(time.RFC3339, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rate, ok := f.Rate(tt.currency, at); rate != tt.rate || ok != tt.ok {
+			t.Errorf("Rate(%q, %s) = %v, %v; want %v, %v", tt.currency, tt.at, rate, ok, tt.rate, tt.ok)
+		}
+	}
+}
+
+func TestFXNormalize(t *testing.T) {
+	at := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		policy   string
+		in       models.Transaction
+		want     models.Transaction
+		unknown  bool
+		rejected bool
+	}{
+		{"converted", UnknownCurrencyReview,
+			models.Transaction{Amount: 100.01, Currency: "usd", Timestamp: at},
+			models.Transaction{Amount: 3250.33, Currency: "THB", OriginalAmount: 100.01, OriginalCurrency: "USD", FXRate: 32.5, Timestamp: at},
+			false, false},
+		{"base currency", UnknownCurrencyReview,
+			models.Transaction{Amount: 500, Timestamp: at},
+			models.Transaction{Amount: 500, Currency: "THB", OriginalAmount: 500, OriginalCurrency: "THB", FXRate: 1, Timestamp: at},
+			false, false},
+		{"unknown currency is kept for review", UnknownCurrencyReview,
+			models.Transaction{Amount: 100, Currency: "xts", Timestamp: at},
+			models.Transaction{Amount: 100, Currency: "XTS", OriginalAmount: 100, OriginalCurrency: "XTS", Timestamp: at},
+			true, false},
+		{"unknown currency rejected", UnknownCurrencyReject,
+			models.Transaction{Amount: 100, Currency: "XTS", Timestamp: at},
+			models.Transaction{Amount: 100, Currency: "XTS", OriginalAmount: 100, OriginalCurrency: "XTS", Timestamp: at},
+			true, true},
+	}
+	f := newTestFX(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.UnknownPolicy = tt.policy
+			txn := tt.in
+			unknown, err := f.Normalize(&txn)
+			if unknown != tt.unknown || errors.Is(err, ErrUnknownCurrency) != tt.rejected {
+				t.Errorf("Normalize = %v, %v", unknown, err)
+			}
+			if !reflect.DeepEqual(txn, tt.want) {
+				t.Errorf("transaction = %+v, want %+v", txn, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveRatesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		rate models.FXRate
+	}{
+		{"currency code", models.FXRate{Currency: "US", EffectiveDate: "2025-01-01", Rate: 1}},
+		{"date", models.FXRate{Currency: "EUR", EffectiveDate: "1/1/2025", Rate: 1}},
+		{"zero rate", models.FXRate{Currency: "EUR", EffectiveDate: "2025-01-01", Rate: 0}},
+		{"negative rate", models.FXRate{Currency: "EUR", EffectiveDate: "2025-01-01", Rate: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFXService()
+			if err := f.SaveRates([]models.FXRate{{Currency: "JPY", EffectiveDate: "2025-01-01", Rate: 0.22}, tt.rate}, "test"); err == nil {
+				t.Fatal("invalid rate accepted")
+			}
+			if err := f.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := f.Rate("JPY", time.Now()); ok {
+				t.Error("rate from a rejected table was stored")
+			}
+		})
+	}
+}
+
+func TestParseFXRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []models.FXRate
+		wantErr bool
+	}{
+		{"JSON", `[{"currency": "USD", "effective_date": "2025-01-01", "rate": 34.25}]`,
+			[]models.FXRate{{Currency: "USD", EffectiveDate: "2025-01-01", Rate: 34.25}}, false},
+		{"CSV with a header", "currency,effective_date,rate\nUSD, 2025-01-01, 34.25\n# euro\nEUR,2025-01-01,37.1\n",
+			[]models.FXRate{{Currency: "USD", EffectiveDate: "2025-01-01", Rate: 34.25}, {Currency: "EUR", EffectiveDate: "2025-01-01", Rate: 37.1}}, false},
+		{"missing rate", "USD,2025-01-01,34.25\nEUR,2025-01-01\n", nil, true},
+		{"rate not a number", "USD,2025-01-01,34.25\nEUR,2025-01-01,abc\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFXRates([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rates = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// Subjects whose last known location is tracked in last_locations
+const (
+	LocationSubjectAccount = "ACCOUNT"
+	LocationSubjectDevice  = "DEVICE"
+)
+
+type place struct {
+	country  string
+	lat, lon float64
+}
+
+// knownPlaces maps normalised city names to coordinates. Thai cities cover the
+// locations the channels send today; the rest are common foreign origins.
+var knownPlaces = map[string]place{
+	"bangkok":    {"TH", 13.7563, 100.5018},
+	"krung thep": {"TH", 13.7563, 100.5018},
+	"กรุงเทพ":    {"TH", 13.7563, 100.5018},
+	"กรุงเทพมหานคร": {"TH", 13.7563, 100.5018},
+	"nonthaburi":          {"TH", 13.8621, 100.5144},
+	"pathum thani":        {"TH", 14.0208, 100.5250},
+	"samut prakan":        {"TH", 13.5991, 100.5998},
+	"chiang mai":          {"TH", 18.7883, 98.9853},
+	"เชียงใหม่":           {"TH", 18.7883, 98.9853},
+	"chiang rai":          {"TH", 19.9105, 99.8406},
+	"phuket":              {"TH", 7.8804, 98.3923},
+	"ภูเก็ต":              {"TH", 7.8804, 98.3923},
+	"pattaya":             {"TH", 12.9236, 100.8825},
+	"chon buri":           {"TH", 13.3611, 100.9847},
+	"chonburi":            {"TH", 13.3611, 100.9847},
+	"khon kaen":           {"TH", 16.4322, 102.8236},
+	"ขอนแก่น":             {"TH", 16.4322, 102.8236},
+	"udon thani":          {"TH", 17.4138, 102.7872},
+	"nakhon ratchasima":   {"TH", 14.9799, 102.0978},
+	"korat":               {"TH", 14.9799, 102.0978},
+	"hat yai":             {"TH", 7.0086, 100.4747},
+	"songkhla":            {"TH", 7.1898, 100.5954},
+	"surat thani":         {"TH", 9.1382, 99.3217},
+	"ubon ratchathani":    {"TH", 15.2287, 104.8564},
+	"hua hin":             {"TH", 12.5684, 99.9577},
+	"ayutthaya":           {"TH", 14.3532, 100.5689},
+	"rayong":              {"TH", 12.6814, 101.2816},
+	"krabi":               {"TH", 8.0863, 98.9063},
+	"phitsanulok":         {"TH", 16.8211, 100.2659},
+	"nakhon si thammarat": {"TH", 8.4304, 99.9631},
+	"singapore":           {"SG", 1.3521, 103.8198},
+	"kuala lumpur":        {"MY", 3.1390, 101.6869},
+	"phnom penh":          {"KH", 11.5564, 104.9282},
+	"poipet":              {"KH", 13.6593, 102.5631},
+	"vientiane":           {"LA", 17.9757, 102.6331},
+	"yangon":              {"MM", 16.8409, 96.1735},
+	"myawaddy":            {"MM", 16.6886, 98.5083},
+	"hanoi":               {"VN", 21.0278, 105.8342},
+	"ho chi minh city":    {"VN", 10.8231, 106.6297},
+	"manila":              {"PH", 14.5995, 120.9842},
+	"jakarta":             {"ID", -6.2088, 106.8456},
+	"hong kong":           {"HK", 22.3193, 114.1694},
+	"macau":               {"MO", 22.1987, 113.5439},
+	"taipei":              {"TW", 25.0330, 121.5654},
+	"shanghai":            {"CN", 31.2304, 121.4737},
+	"beijing":             {"CN", 39.9042, 116.4074},
+	"shenzhen":            {"CN", 22.5431, 114.0579},
+	"tokyo":               {"JP", 35.6762, 139.6503},
+	"seoul":               {"KR", 37.5665, 126.9780},
+	"mumbai":              {"IN", 19.0760, 72.8777},
+	"new delhi":           {"IN", 28.6139, 77.2090},
+	"dubai":               {"AE", 25.2048, 55.2708},
+	"london":              {"GB", 51.5072, -0.1276},
+	"paris":               {"FR", 48.8566, 2.3522},
+	"frankfurt":           {"DE", 50.1109, 8.6821},
+	"amsterdam":           {"NL", 52.3676, 4.9041},
+	"moscow":              {"RU", 55.7558, 37.6173},
+	"new york":            {"US", 40.7128, -74.0060},
+	"los angeles":         {"US", 34.0522, -118.2437},
+	"sydney":              {"AU", -33.8688, 151.2093},
+}
+
+// countryNames maps normalised country names to ISO codes
+var countryNames = map[string]string{
+	"thailand": "TH", "ประเทศไทย": "TH", "ไทย": "TH", "singapore": "SG", "malaysia": "MY",
+	"cambodia": "KH", "laos": "LA", "lao pdr": "LA", "myanmar": "MM", "burma": "MM",
+	"vietnam": "VN", "viet nam": "VN", "philippines": "PH", "indonesia": "ID",
+	"hong kong": "HK", "macau": "MO", "taiwan": "TW", "china": "CN", "japan": "JP",
+	"south korea": "KR", "korea": "KR", "india": "IN", "united arab emirates": "AE", "uae": "AE",
+	"united kingdom": "GB", "uk": "GB", "france": "FR", "germany": "DE", "netherlands": "NL",
+	"russia": "RU", "united states": "US", "usa": "US", "australia": "AU",
+}
+
+// ParseLocation understands "City", "City, Country", "City, CC", a bare country,
+// and "lat,lon" coordinates. It reports false when nothing is recognised.
+func ParseLocation(s string) (models.GeoLocation, bool) {
+	loc := models.GeoLocation{Source: "location"}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return loc, false
+	}
+
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	if len(parts) == 2 {
+		lat, errLat := strconv.ParseFloat(parts[0], 64)
+		lon, errLon := strconv.ParseFloat(parts[1], 64)
+		if errLat == nil && errLon == nil && math.Abs(lat) <= 90 && math.Abs(lon) <= 180 {
+			loc.Latitude, loc.Longitude, loc.HasCoordinates = lat, lon, true
+			return loc, true
+		}
+	}
+
+	// The last part may name the country
+	if len(parts) > 1 {
+		if code := countryCode(parts[len(parts)-1]); code != "" {
+			loc.Country = code
+			parts = parts[:len(parts)-1]
+		}
+	}
+	for _, p := range parts {
+		if known, ok := knownPlaces[strings.ToLower(p)]; ok {
+			loc.City = p
+			if loc.Country == "" {
+				loc.Country = known.country
+			}
+			if loc.Country == known.country {
+				loc.Latitude, loc.Longitude, loc.HasCoordinates = known.lat, known.lon, true
+			}
+			return loc, true
+		}
+	}
+	if loc.Country == "" && len(parts) == 1 {
+		loc.Country = countryCode(parts[0])
+	}
+	if loc.Country == "" {
+		return loc, false
+	}
+	if loc.City == "" && len(parts) > 0 && countryCode(parts[0]) == "" {
+		loc.City = parts[0]
+	}
+	return loc, true
+}
+
+func countryCode(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if code, ok := countryNames[s]; ok {
+		return code
+	}
+	if len(s) == 2 && s[0] >= 'a' && s[0] <= 'z' && s[1] >= 'a' && s[1] <= 'z' {
+		return strings.ToUpper(s)
+	}
+	return ""
+}
+
+// haversineKm is the great-circle distance between two points
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// GeoService turns a transaction's declared location and sender IP into
+// location features, tracking where each account and device was last seen
+type GeoService struct {
+	IPs IPLocator // nil when no offline IP database is configured
+
+	// MaxSpeedKmh is the travel speed above which consecutive locations are impossible
+	MaxSpeedKmh float64
+	// MinDistanceKm ignores movements smaller than geolocation precision
+	MinDistanceKm float64
+
+	mu sync.Mutex
+}
+
+func NewGeoService() *GeoService {
+	return &GeoService{MaxSpeedKmh: 900, MinDistanceKm: 50}
+}
+
+// LocateIP resolves a public IP address; private, loopback and unparseable addresses are unknown
+func (g *GeoService) LocateIP(ip string) (models.GeoLocation, bool) {
+	if g.IPs == nil {
+		return models.GeoLocation{}, false
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return models.GeoLocation{}, false
+	}
+	return g.IPs.Lookup(addr)
+}
+
+// AddLocationFeatures adds location features for the transaction to riskContext,
+// records the transaction's location as the sender's and device's latest, and
+// returns human-readable evidence for any anomaly found
+func (g *GeoService) AddLocationFeatures(txn models.Transaction, riskContext map[string]any) []string {
+	var evidence []string
+	declared, hasDeclared := ParseLocation(txn.Location)
+	ipLoc, hasIP := g.LocateIP(txn.SenderIP)
+
+	riskContext["location_country"] = declared.Country
+	riskContext["ip_country"] = ipLoc.Country
+	mismatch := hasDeclared && hasIP && declared.Country != "" && ipLoc.Country != "" && declared.Country != ipLoc.Country
+	riskContext["ip_location_country_mismatch"] = mismatch
+	if mismatch {
+		evidence = append(evidence, fmt.Sprintf("Sender IP %s geolocates to %s but declared location %q is in %s",
+			txn.SenderIP, ipLoc.Country, txn.Location, declared.Country))
+	}
+
+	// Travel is measured on the declared location when it has coordinates, else on the IP
+	current := declared
+	if !declared.HasCoordinates {
+		current = ipLoc
+	}
+	riskContext["account_travel_speed_kmh"] = 0.0
+	riskContext["device_travel_speed_kmh"] = 0.0
+	riskContext["impossible_travel"] = false
+	if !current.HasCoordinates {
+		return evidence
+	}
+
+	at := txn.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	subjects := []struct{ kind, id, feature string }{
+		{LocationSubjectAccount, txn.SenderAccount, "account_travel_speed_kmh"},
+		{LocationSubjectDevice, txn.DeviceID, "device_travel_speed_kmh"},
+	}
+	for _, subj := range subjects {
+		if subj.id == "" {
+			continue
+		}
+		last, err := GetLastLocation(txn.TenantID, subj.kind, subj.id)
+		if err == nil && last != nil && at.After(last.SeenAt.Add(-time.Minute)) {
+			distance := haversineKm(last.Latitude, last.Longitude, current.Latitude, current.Longitude)
+			// Clamp to one minute so simultaneous transactions do not divide by zero
+			hours := math.Max(at.Sub(last.SeenAt).Hours(), 1.0/60)
+			speed := distance / hours
+			riskContext[subj.feature] = math.Round(speed)
+			if distance >= g.MinDistanceKm && speed > g.MaxSpeedKmh {
+				riskContext["impossible_travel"] = true
+				evidence = append(evidence, fmt.Sprintf("Impossible travel: %s %s moved %.0f km from %s to %s in %s (%.0f km/h)",
+					strings.ToLower(subj.kind), subj.id, distance, describePlace(last.GeoLocation), describePlace(current),
+					at.Sub(last.SeenAt).Round(time.Minute), speed))
+			}
+		}
+		if err := saveLastLocation(txn.TenantID, subj.kind, subj.id, txn.TransactionID, current, at); err != nil {
+			continue
+		}
+	}
+	return evidence
+}
+
+func describePlace(loc models.GeoLocation) string {
+	switch {
+	case loc.City != "" && loc.Country != "":
+		return loc.City + ", " + loc.Country
+	case loc.City != "":
+		return loc.City
+	case loc.Country != "":
+		return loc.Country
+	}
+	return fmt.Sprintf("%.4f,%.4f", loc.Latitude, loc.Longitude)
+}
+
+// GetLastLocation returns where tenant last saw an account or device, or nil if never
+func GetLastLocation(tenant, subjectType, subjectID string) (*models.LastLocation, error) {
+	var l models.LastLocation
+	var country, city, source sql.NullString
+	err := db.DB.QueryRow(`
+		SELECT country, city, latitude, longitude, source, txn_id, seen_at
+		FROM last_locations
+		WHERE tenant_id = ? AND subject_type = ? AND subject_id = ?
+	`, tenant, subjectType, subjectID).Scan(&country, &city, &l.Latitude, &l.Longitude, &source, &l.TransactionID, &l.SeenAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Country, l.City, l.Source = country.String, city.String, source.String
+	l.HasCoordinates = true
+	return &l, nil
+}
+
+// saveLastLocation keeps the newest location per subject, ignoring late-arriving older transactions
+func saveLastLocation(tenant, subjectType, subjectID, txnID string, loc models.GeoLocation, at time.Time) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO last_locations (tenant_id, subject_type, subject_id, country, city, latitude, longitude, source, txn_id, seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, subject_type, subject_id) DO UPDATE SET
+			country = excluded.country, city = excluded.city,
+			latitude = excluded.latitude, longitude = excluded.longitude,
+			source = excluded.source, txn_id = excluded.txn_id, seen_at = excluded.seen_at
+		WHERE excluded.seen_at >= last_locations.seen_at
+	`, tenant, subjectType, subjectID, loc.Country, loc.City, loc.Latitude, loc.Longitude, loc.Source, txnID, at)
+	return err
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"math"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		in      string
+		ok      bool
+		country string
+		city    string
+		coords  bool
+	}{
+		{"Bangkok", true, "TH", "Bangkok", true},
+		{"Chiang Mai, Thailand", true, "TH", "Chiang Mai", true},
+		{"เชียงใหม่", true, "TH", "เชียงใหม่", true},
+		{"Singapore, SG", true, "SG", "Singapore", true},
+		{"Bangkok, US", true, "US", "Bangkok", false}, // the country wins over the city's
+		{"Cambodia", true, "KH", "", false},
+		{"Springfield, US", true, "US", "Springfield", false},
+		{"13.75, 100.50", true, "", "", true},
+		{"123, 456", false, "", "", false},
+		{"Atlantis", false, "", "", false},
+		{"", false, "", "", false},
+	}
+	for _, tt := range tests {
+		loc, ok := ParseLocation(tt.in)
+		if ok != tt.ok || loc.Country != tt.country || loc.City != tt.city || loc.HasCoordinates != tt.coords {
+			t.Errorf("ParseLocation(%q) = %+v, %v", tt.in, loc, ok)
+		}
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	bkk, cnx := knownPlaces["bangkok"], knownPlaces["chiang mai"]
+	if d := haversineKm(bkk.lat, bkk.lon, cnx.lat, cnx.lon); math.Abs(d-583) > 5 {
+		t.Errorf("Bangkok to Chiang Mai = %.0f km", d)
+	}
+	if d := haversineKm(bkk.lat, bkk.lon, bkk.lat, bkk.lon); d != 0 {
+		t.Errorf("distance to itself = %v", d)
+	}
+}
+
+func TestIPRangesCSV(t *testing.T) {
+	ranges, err := parseIPRangesCSV(strings.NewReader(`# test ranges
+start,end,country,city,lat,lon
+203.0.113.0,203.0.113.255,th,Bangkok,13.7563,100.5018
+198.51.100.0/24,GB,London
+2001:db8::/32,SG
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip      string
+		ok      bool
+		country string
+		coords  bool
+	}{
+		{"203.0.113.7", true, "TH", true},
+		{"203.0.113.255", true, "TH", true},
+		{"198.51.100.1", true, "GB", false},
+		{"::ffff:198.51.100.1", true, "GB", false},
+		{"2001:db8::1", true, "SG", false},
+		{"203.0.114.1", false, "", false},
+		{"1.1.1.1", false, "", false},
+	}
+	for _, tt := range tests {
+		loc, ok := ranges.Lookup(netip.MustParseAddr(tt.ip))
+		if ok != tt.ok || loc.Country != tt.country || loc.HasCoordinates != tt.coords {
+			t.Errorf("Lookup(%s) = %+v, %v", tt.ip, loc, ok)
+		}
+	}
+
+	if _, err := parseIPRangesCSV(strings.NewReader("203.0.113.0,203.0.113.255,TH\nnot-an-ip,TH\n")); err == nil {
+		t.Error("invalid range accepted")
+	}
+}
+
+func TestAddLocationFeatures(t *testing.T) {
+	ranges, err := parseIPRangesCSV(strings.NewReader("203.0.113.0/24,GB,London,51.5072,-0.1276\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGeoService()
+	g.IPs = ranges
+	start := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		location   string
+		ip         string
+		after      time.Duration // since the account's previous transaction
+		impossible bool
+		mismatch   bool
+	}{
+		{"drive to a nearby city", "Nonthaburi", "", 2 * time.Hour, false, false},
+		{"fly to Chiang Mai", "Chiang Mai", "", 2 * time.Hour, false, false},
+		{"London an hour later", "London", "", time.Hour, true, false},
+		{"IP in London, declared Bangkok", "Bangkok", "203.0.113.9", 48 * time.Hour, false, true},
+		{"private IP is not located", "Bangkok", "10.0.0.1", 48 * time.Hour, false, false},
+		{"travel measured on the IP", "", "203.0.113.9", time.Hour, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := testID("GEO")
+			first := models.Transaction{TenantID: DefaultTenant, TransactionID: testID("TXN"), SenderAccount: account, Location: "Bangkok", Timestamp: start}
+			g.AddLocationFeatures(first, map[string]any{})
+
+			riskContext := map[string]any{}
+			evidence := g.AddLocationFeatures(models.Transaction{
+				TenantID:      DefaultTenant,
+				TransactionID: testID("TXN"),
+				SenderAccount: account,
+				Location:      tt.location,
+				SenderIP:      tt.ip,
+				Timestamp:     start.Add(tt.after),
+			}, riskContext)
+			if riskContext["impossible_travel"] != tt.impossible || riskContext["ip_location_country_mismatch"] != tt.mismatch {
+				t.Errorf("features = %v", riskContext)
+			}
+			if (tt.impossible || tt.mismatch) != (len(evidence) > 0) {
+				t.Errorf("evidence = %q", evidence)
+			}
+		})
+	}
+
+	// A late-arriving older transaction does not move the account back
+	account := testID("GEO")
+	g.AddLocationFeatures(models.Transaction{TenantID: DefaultTenant, TransactionID: testID("TXN"), SenderAccount: account, Location: "Phuket", Timestamp: start}, map[string]any{})
+	g.AddLocationFeatures(models.Transaction{TenantID: DefaultTenant, TransactionID: testID("TXN"), SenderAccount: account, Location: "Bangkok", Timestamp: start.Add(-time.Hour)}, map[string]any{})
+	last, err := GetLastLocation(DefaultTenant, LocationSubjectAccount, account)
+	if err != nil || last == nil || last.City != "Phuket" {
+		t.Errorf("last location = %+v, %v", last, err)
+	}
+	if last, err := GetLastLocation(DefaultTenant, LocationSubjectDevice, testID("DEV")); last != nil || err != nil {
+		t.Errorf("unseen device located at %+v, %v", last, err)
+	}
+}
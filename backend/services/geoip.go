@@ -0,0 +1,417 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bank-fraud-demo/models"
+)
+
+// Offline IP geolocation databases. Two formats are supported: MaxMind DB
+// (.mmdb, e.g. GeoLite2-City or GeoLite2-Country) and CSV ranges with rows of
+//
+//	start_ip,end_ip,country[,city,latitude,longitude]
+//	cidr,country[,city,latitude,longitude]
+//
+// Lines starting with # and a header row are skipped.
+
+// IPLocator looks up the location of a public IP address
+type IPLocator interface {
+	Lookup(ip netip.Addr) (models.GeoLocation, bool)
+}
+
+// LoadIPDatabase opens an offline IP database, choosing the format by file extension
+func LoadIPDatabase(path string) (IPLocator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".mmdb") {
+		return newMMDB(data)
+	}
+	return parseIPRangesCSV(bytes.NewReader(data))
+}
+
+// ---- CSV ranges ----
+
+type ipRange struct {
+	start, end netip.Addr
+	loc        models.GeoLocation
+}
+
+type ipRanges []ipRange
+
+func parseIPRangesCSV(r io.Reader) (ipRanges, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var ranges ipRanges
+	line := 0
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+
+		var start, end netip.Addr
+		rest := rec[1:]
+		if prefix, err := netip.ParsePrefix(rec[0]); err == nil {
+			prefix = prefix.Masked()
+			start, end = prefix.Addr(), lastAddr(prefix)
+		} else {
+			var errStart, errEnd error
+			start, errStart = netip.ParseAddr(rec[0])
+			if len(rec) > 1 {
+				end, errEnd = netip.ParseAddr(rec[1])
+			}
+			if errStart != nil || len(rec) < 2 || errEnd != nil {
+				if line == 1 {
+					continue // header
+				}
+				return nil, fmt.Errorf("line %d: invalid IP range %q", line, strings.Join(rec, ","))
+			}
+			rest = rec[2:]
+		}
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("line %d: missing country", line)
+		}
+
+		loc := models.GeoLocation{Country: strings.ToUpper(strings.TrimSpace(rest[0])), Source: "ip"}
+		if len(rest) > 1 {
+			loc.City = strings.TrimSpace(rest[1])
+		}
+		if len(rest) > 3 {
+			lat, errLat := strconv.ParseFloat(strings.TrimSpace(rest[2]), 64)
+			lon, errLon := strconv.ParseFloat(strings.TrimSpace(rest[3]), 64)
+			if errLat == nil && errLon == nil {
+				loc.Latitude, loc.Longitude, loc.HasCoordinates = lat, lon, true
+			}
+		}
+		ranges = append(ranges, ipRange{start: start.Unmap(), end: end.Unmap(), loc: loc})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return ranges, nil
+}
+
+func (r ipRanges) Lookup(ip netip.Addr) (models.GeoLocation, bool) {
+	ip = ip.Unmap()
+	// Last range starting at or before ip
+	i := sort.Search(len(r), func(i int) bool { return ip.Less(r[i].start) }) - 1
+	if i < 0 || r[i].end.Less(ip) || r[i].start.Is4() != ip.Is4() {
+		return models.GeoLocation{}, false
+	}
+	return r[i].loc, true
+}
+
+// lastAddr returns the highest address in a masked prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// ---- MaxMind DB ----
+
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+type mmdb struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+}
+
+func newMMDB(buf []byte) (*mmdb, error) {
+	at := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if at < 0 {
+		return nil, errors.New("mmdb: metadata marker not found")
+	}
+	metaStart := uint(at + len(mmdbMetadataMarker))
+	meta, _, err := (&mmdb{buf: buf}).decode(metaStart, metaStart)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb: metadata: %w", err)
+	}
+	m, ok := meta.(map[string]any)
+	if !ok {
+		return nil, errors.New("mmdb: metadata is not a map")
+	}
+
+	db := &mmdb{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+	}
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("mmdb: unsupported record size %d", db.recordSize)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	db.dataStart = treeSize + 16
+	if db.dataStart > uint(at) {
+		return nil, errors.New("mmdb: search tree larger than file")
+	}
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// record reads the left (bit 0) or right (bit 1) record of a search tree node
+func (db *mmdb) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := db.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := db.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(db.buf[off : off+4]))
+	}
+}
+
+func (db *mmdb) Lookup(ip netip.Addr) (models.GeoLocation, bool) {
+	ip = ip.Unmap()
+	var bits []byte
+	node := uint(0)
+	switch {
+	case ip.Is4() && db.ipVersion == 6:
+		b := ip.As4()
+		bits, node = b[:], db.ipv4Start
+	case ip.Is4():
+		b := ip.As4()
+		bits = b[:]
+	case db.ipVersion == 6:
+		b := ip.As16()
+		bits = b[:]
+	default:
+		return models.GeoLocation{}, false
+	}
+
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-i%8)) & 1
+		if node*db.recordSize/4+db.recordSize/4 > uint(len(db.buf)) {
+			return models.GeoLocation{}, false
+		}
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return models.GeoLocation{}, false
+	}
+
+	offset := db.dataStart + (node - db.nodeCount - 16)
+	value, _, err := db.decode(offset, db.dataStart)
+	if err != nil {
+		return models.GeoLocation{}, false
+	}
+	record, _ := value.(map[string]any)
+	return geoFromMMDBRecord(record), true
+}
+
+// geoFromMMDBRecord reads the GeoIP2/GeoLite2 City or Country record layout
+func geoFromMMDBRecord(record map[string]any) models.GeoLocation {
+	loc := models.GeoLocation{Source: "ip"}
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := record[key].(map[string]any); ok {
+			if iso, ok := c["iso_code"].(string); ok && iso != "" {
+				loc.Country = iso
+				break
+			}
+		}
+	}
+	if city, ok := record["city"].(map[string]any); ok {
+		if names, ok := city["names"].(map[string]any); ok {
+			loc.City, _ = names["en"].(string)
+		}
+	}
+	if l, ok := record["location"].(map[string]any); ok {
+		lat, okLat := l["latitude"].(float64)
+		lon, okLon := l["longitude"].(float64)
+		if okLat && okLon {
+			loc.Latitude, loc.Longitude, loc.HasCoordinates = lat, lon, true
+		}
+	}
+	return loc
+}
+
+// decode reads one data field at offset. Pointers are relative to base.
+// It returns the value and the offset just past the field.
+func (db *mmdb) decode(offset, base uint) (any, uint, error) {
+	buf := db.buf
+	if offset >= uint(len(buf)) {
+		return nil, 0, errors.New("mmdb: offset out of range")
+	}
+	ctrl := buf[offset]
+	offset++
+	kind := uint(ctrl >> 5)
+
+	if kind == 1 { // pointer
+		ss := uint(ctrl>>3) & 3
+		v := uint(ctrl & 7)
+		if offset+ss+1 > uint(len(buf)) {
+			return nil, 0, errors.New("mmdb: truncated pointer")
+		}
+		var p uint
+		b := buf[offset : offset+ss+1]
+		switch ss {
+		case 0:
+			p = v<<8 | uint(b[0])
+		case 1:
+			p = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 2:
+			p = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		case 3:
+			p = uint(binary.BigEndian.Uint32(b))
+		}
+		value, _, err := db.decode(base+p, base)
+		return value, offset + ss + 1, err
+	}
+
+	if kind == 0 { // extended type
+		if offset >= uint(len(buf)) {
+			return nil, 0, errors.New("mmdb: truncated type")
+		}
+		kind = 7 + uint(buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(buf)) {
+			return nil, 0, errors.New("mmdb: truncated size")
+		}
+		var extra uint
+		for _, c := range buf[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch kind {
+	case 7: // map
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := db.decode(offset, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("mmdb: map key is not a string")
+			}
+			v, next, err := db.decode(next, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := db.decode(offset, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case 14: // boolean, value is in the size bits
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(buf)) {
+		return nil, 0, errors.New("mmdb: truncated field")
+	}
+	b := buf[offset : offset+size]
+	next := offset + size
+	switch kind {
+	case 2: // utf8 string
+		return string(b), next, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, errors.New("mmdb: bad double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, errors.New("mmdb: bad float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case 4: // bytes
+		return append([]byte(nil), b...), next, nil
+	case 5, 6, 9, 10: // uint16, uint32, uint64, uint128 (truncated to 64 bits)
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case 8: // int32
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(v)), next, nil
+		}
+		return int64(v), next, nil
+	default:
+		return nil, 0, fmt.Errorf("mmdb: unsupported data type %d", kind)
+	}
+}
+
+func toUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	}
+	return 0
+}
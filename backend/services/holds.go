@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// What happens to a hold nobody decided within the SLA
+const (
+	HoldTimeoutRelease = "release"
+	HoldTimeoutReject  = "reject"
+)
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+	ErrHoldDecided  = errors.New("hold has already been decided")
+)
+
+// HoldService parks Review transactions until an analyst or the timeout policy
+// releases or rejects them, then posts the decision to the core banking system.
+// Callbacks that fail are retried by the sweep until MaxCallbackAttempts.
+type HoldService struct {
+	SLA                 time.Duration
+	TimeoutPolicy       string
+	Interval            time.Duration // how often overdue holds and failed callbacks are swept
+	WebhookURL          string        // used when the transaction carries no callback_url
+	WebhookSecret       string        // signs callbacks with HMAC-SHA256 in X-Signature when set
+	MaxCallbackAttempts int
+	Client              *http.Client
+}
+
+func NewHoldService() *HoldService {
+	return &HoldService{
+		SLA:                 30 * time.Minute,
+		TimeoutPolicy:       HoldTimeoutReject,
+		Interval:            30 * time.Second,
+		MaxCallbackAttempts: 5,
+		Client:              &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Start decides overdue holds and retries failed callbacks on every tick
+func (s *HoldService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.TimeoutHolds(); err != nil {
+				log.Printf("Hold timeout sweep failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Timed out %d holds (%s)", n, s.TimeoutPolicy)
+			}
+			s.retryCallbacks()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Place holds a transaction that scored Review. Re-submitting a held
+// transaction keeps the original hold.
+func (s *HoldService) Place(txn models.Transaction, analysis models.AnalysisResult) (*models.Hold, error) {
+	now := time.Now().UTC()
+	callbackURL := txn.CallbackURL
+	if callbackURL == "" {
+		callbackURL = s.WebhookURL
+	}
+	reasons, _ := json.Marshal(analysis.Reasons)
+	_, err := db.DB.Exec(`
+		INSERT INTO holds (txn_id, tenant_id, status, sender_account, receiver_account, amount, currency, risk_score, reasons,
+			held_at, due_at, callback_url, callback_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(txn_id) DO NOTHING
+	`, txn.TransactionID, txn.TenantID, models.HoldHeld, txn.SenderAccount, txn.ReceiverAccount, txn.Amount, txn.Currency,
+		analysis.RiskScore, string(reasons), now, now.Add(s.SLA), callbackURL, models.CallbackNone)
+	if err != nil {
+		return nil, err
+	}
+	return GetHold(txn.TenantID, txn.TransactionID)
+}
+
+// Decide releases or rejects one of tenant's held transactions and notifies the
+// core banking system
+func (s *HoldService) Decide(tenant, txnID, status, decidedBy, note string) (*models.Hold, error) {
+	if status != models.HoldReleased && status != models.HoldRejected {
+		return nil, fmt.Errorf("decision must be %s or %s", models.HoldReleased, models.HoldRejected)
+	}
+	hold, err := GetHold(tenant, txnID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldHeld {
+		return hold, ErrHoldDecided
+	}
+
+	now := time.Now().UTC()
+	callbackStatus := models.CallbackNone
+	if hold.CallbackURL != "" {
+		callbackStatus = models.CallbackPending
+	}
+	res, err := db.DB.Exec(`
+		UPDATE holds SET status = ?, decided_by = ?, note = ?, decided_at = ?, callback_status = ?
+		WHERE txn_id = ? AND status = ?
+	`, status, decidedBy, note, now, callbackStatus, txnID, models.HoldHeld)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return hold, ErrHoldDecided
+	}
+	hold.Status, hold.DecidedBy, hold.Note, hold.DecidedAt, hold.CallbackStatus = status, decidedBy, note, &now, callbackStatus
+	RecordAudit(txnID, fmt.Sprintf("HOLD_%s by %s", status, decidedBy))
+
+	if hold.CallbackURL != "" {
+		go s.notify(*hold)
+	}
+	return hold, nil
+}
+
+// TimeoutHolds decides every hold past its SLA, in every tenant, according to TimeoutPolicy
+func (s *HoldService) TimeoutHolds() (int, error) {
+	rows, err := db.DB.Query("SELECT tenant_id, txn_id FROM holds WHERE status = ? AND due_at <= ?", models.HoldHeld, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due [][2]string
+	for rows.Next() {
+		var tenant, id string
+		if rows.Scan(&tenant, &id) == nil {
+			due = append(due, [2]string{tenant, id})
+		}
+	}
+	rows.Close()
+
+	status := models.HoldRejected
+	if s.TimeoutPolicy == HoldTimeoutRelease {
+		status = models.HoldReleased
+	}
+	n := 0
+	for _, d := range due {
+		_, err := s.Decide(d[0], d[1], status, "timeout", fmt.Sprintf("No decision within the %s SLA", s.SLA))
+		if errors.Is(err, ErrHoldDecided) {
+			continue // an analyst got there first
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// notify posts the decision to the hold's callback URL and records the outcome
+func (s *HoldService) notify(hold models.Hold) {
+	body, _ := json.Marshal(models.HoldDecision{
+		TransactionID: hold.TransactionID,
+		Decision:      hold.Status,
+		DecidedBy:     hold.DecidedBy,
+		Note:          hold.Note,
+		DecidedAt:     *hold.DecidedAt,
+	})
+
+	err := func() error {
+		req, err := http.NewRequest(http.MethodPost, hold.CallbackURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if s.WebhookSecret != "" {
+			mac := hmac.New(sha256.New, []byte(s.WebhookSecret))
+			mac.Write(body)
+			req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("callback returned %s", resp.Status)
+		}
+		return nil
+	}()
+
+	status, errText := models.CallbackDelivered, ""
+	if err != nil {
+		status, errText = models.CallbackFailed, err.Error()
+		log.Printf("Hold callback for %s failed: %v", hold.TransactionID, err)
+	}
+	if _, err := db.DB.Exec(`
+		UPDATE holds SET callback_status = ?, callback_attempts = callback_attempts + 1, callback_error = ?
+		WHERE txn_id = ?
+	`, status, errText, hold.TransactionID); err != nil {
+		log.Printf("Failed to record callback for %s: %v", hold.TransactionID, err)
+	}
+}
+
+// retryCallbacks re-sends failed callbacks that have attempts left, and pending
+// ones left behind by a restart
+func (s *HoldService) retryCallbacks() {
+	rows, err := db.DB.Query(`
+		SELECT `+holdColumns+` FROM holds
+		WHERE (callback_status = ? OR (callback_status = ? AND decided_at <= ?)) AND callback_attempts < ?
+	`, models.CallbackFailed, models.CallbackPending, time.Now().UTC().Add(-time.Minute), s.MaxCallbackAttempts)
+	if err != nil {
+		log.Printf("Hold callback retry failed: %v", err)
+		return
+	}
+	var retry []models.Hold
+	for rows.Next() {
+		if hold, err := scanHold(rows); err == nil {
+			retry = append(retry, hold)
+		}
+	}
+	rows.Close()
+	for _, hold := range retry {
+		s.notify(hold)
+	}
+}
+
+// GetHold loads the hold on one of tenant's transactions
+func GetHold(tenant, txnID string) (*models.Hold, error) {
+	hold, err := scanHold(db.DB.QueryRow(`SELECT `+holdColumns+` FROM holds WHERE txn_id = ? AND tenant_id = ?`, txnID, tenant))
+	if err == sql.ErrNoRows {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ListHolds returns tenant's holds oldest first, optionally only those with the
+// given status and held for at least olderThan
+func ListHolds(tenant, status string, olderThan time.Duration, limit int) ([]models.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE tenant_id = ? AND held_at <= ?`
+	args := []any{tenant, time.Now().UTC().Add(-olderThan)}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, strings.ToUpper(status))
+	}
+	query += " ORDER BY held_at ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holds := []models.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// holdColumns is the column list read by scanHold
+const holdColumns = `txn_id, coalesce(tenant_id, ''), status, sender_account, receiver_account, amount, coalesce(currency, ''), risk_score,
+	coalesce(reasons, '[]'), held_at, due_at, coalesce(decided_by, ''), coalesce(note, ''), decided_at,
+	coalesce(callback_url, ''), callback_status, callback_attempts, coalesce(callback_error, '')`
+
+func scanHold(row interface{ Scan(...any) error }) (models.Hold, error) {
+	var hold models.Hold
+	var reasons string
+	var decided sql.NullTime
+	err := row.Scan(&hold.TransactionID, &hold.TenantID, &hold.Status, &hold.SenderAccount, &hold.ReceiverAccount, &hold.Amount,
+		&hold.Currency, &hold.RiskScore, &reasons, &hold.HeldAt, &hold.DueAt, &hold.DecidedBy, &hold.Note, &decided,
+		&hold.CallbackURL, &hold.CallbackStatus, &hold.CallbackAttempts, &hold.CallbackError)
+	if err != nil {
+		return hold, err
+	}
+	_ = json.Unmarshal([]byte(reasons), &hold.Reasons)
+	if hold.Reasons == nil {
+		hold.Reasons = []string{}
+	}
+	if decided.Valid {
+		hold.DecidedAt = &decided.Time
+	}
+	return hold, nil
+}
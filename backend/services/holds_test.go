@@ -0,0 +1,158 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// placeTestHold holds a new transaction and returns its ID
+func placeTestHold(t *testing.T, s *HoldService, callbackURL string) string {
+	t.Helper()
+	txn := models.Transaction{TenantID: DefaultTenant, TransactionID: testID("HOLD"), SenderAccount: testID("ACC"), ReceiverAccount: testID("ACC"),
+		Amount: 25000, Currency: "THB", CallbackURL: callbackURL}
+	hold, err := s.Place(txn, models.AnalysisResult{RiskScore: 72, Action: "Review", Reasons: []string{"new payee"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldHeld || hold.CallbackStatus != models.CallbackNone || len(hold.Reasons) != 1 {
+		t.Fatalf("hold = %+v", hold)
+	}
+	return txn.TransactionID
+}
+
+// waitForCallback waits until the hold's callback is no longer pending
+func waitForCallback(t *testing.T, txnID string) *models.Hold {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hold, err := GetHold(DefaultTenant, txnID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.CallbackStatus != models.CallbackPending || time.Now().After(deadline) {
+			return hold
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHoldDecide(t *testing.T) {
+	s := NewHoldService()
+	txnID := placeTestHold(t, s, "")
+
+	again, err := s.Place(models.Transaction{TenantID: DefaultTenant, TransactionID: txnID, Amount: 1}, models.AnalysisResult{})
+	if err != nil || again.Amount != 25000 {
+		t.Errorf("re-submitted hold = %+v, %v", again, err)
+	}
+
+	if _, err := s.Decide(DefaultTenant, txnID, "APPROVED", "analyst", ""); err == nil {
+		t.Error("invalid decision accepted")
+	}
+	hold, err := s.Decide(DefaultTenant, txnID, models.HoldReleased, "analyst", "customer confirmed")
+	if err != nil || hold.Status != models.HoldReleased || hold.DecidedAt == nil || hold.CallbackStatus != models.CallbackNone {
+		t.Fatalf("Decide = %+v, %v", hold, err)
+	}
+	if _, err := s.Decide(DefaultTenant, txnID, models.HoldRejected, "analyst", ""); !errors.Is(err, ErrHoldDecided) {
+		t.Errorf("second decision: %v", err)
+	}
+	if _, err := s.Decide(DefaultTenant, testID("HOLD"), models.HoldRejected, "analyst", ""); !errors.Is(err, ErrHoldNotFound) {
+		t.Errorf("unknown hold: %v", err)
+	}
+	if n := auditCount(t, "HOLD_RELEASED by analyst"); n < 1 {
+		t.Error("decision not audited")
+	}
+
+	held, err := ListHolds(DefaultTenant, models.HoldHeld, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range held {
+		if h.TransactionID == txnID {
+			t.Error("decided hold listed as held")
+		}
+	}
+}
+
+func TestHoldTimeout(t *testing.T) {
+	s := NewHoldService()
+	s.SLA = -time.Second
+	s.TimeoutPolicy = HoldTimeoutRelease
+	overdue := placeTestHold(t, s, "")
+	s.SLA = time.Hour
+	notDue := placeTestHold(t, s, "")
+
+	if n, err := s.TimeoutHolds(); err != nil || n < 1 {
+		t.Fatalf("TimeoutHolds = %d, %v", n, err)
+	}
+	if hold, _ := GetHold(DefaultTenant, overdue); hold.Status != models.HoldReleased || hold.DecidedBy != "timeout" {
+		t.Errorf("overdue hold = %+v", hold)
+	}
+	if hold, _ := GetHold(DefaultTenant, notDue); hold.Status != models.HoldHeld {
+		t.Errorf("hold within its SLA = %+v", hold)
+	}
+}
+
+func TestHoldCallback(t *testing.T) {
+	decisions := make(chan models.HoldDecision, 10)
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if r.Header.Get("X-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		var d models.HoldDecision
+		_ = json.Unmarshal(body, &d)
+		decisions <- d
+	}))
+	defer srv.Close()
+
+	s := NewHoldService()
+	s.WebhookSecret = "secret"
+	txnID := placeTestHold(t, s, srv.URL)
+	if _, err := s.Decide(DefaultTenant, txnID, models.HoldRejected, "analyst", "mule account"); err != nil {
+		t.Fatal(err)
+	}
+	if d := <-decisions; d.TransactionID != txnID || d.Decision != models.HoldRejected || d.Note != "mule account" {
+		t.Errorf("callback = %+v", d)
+	}
+	if hold := waitForCallback(t, txnID); hold.CallbackStatus != models.CallbackDelivered || hold.CallbackAttempts != 1 {
+		t.Errorf("hold = %+v", hold)
+	}
+
+	// A failed callback is retried until it is delivered
+	fail.Store(true)
+	s.WebhookURL = srv.URL
+	failing := placeTestHold(t, s, "")
+	if _, err := s.Decide(DefaultTenant, failing, models.HoldReleased, "analyst", ""); err != nil {
+		t.Fatal(err)
+	}
+	if hold := waitForCallback(t, failing); hold.CallbackStatus != models.CallbackFailed || hold.CallbackError == "" {
+		t.Fatalf("hold = %+v", hold)
+	}
+	fail.Store(false)
+	s.retryCallbacks()
+	if d := <-decisions; d.TransactionID != failing {
+		t.Errorf("retried callback = %+v", d)
+	}
+	if hold, _ := GetHold(DefaultTenant, failing); hold.CallbackStatus != models.CallbackDelivered || hold.CallbackAttempts != 2 {
+		t.Errorf("hold after retry = %+v", hold)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// TestMain runs the package's tests against a fresh SQLite database in a
+// temporary directory, since db.InitDB opens ./data/synapse.db
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "synapse-services-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	db.InitDB()
+	if err := SeedRoles(); err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	db.DB.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var seq atomic.Int64
+
+// testID returns an identifier no other test uses, so tests can share the
+// database and run with -count above one
+func testID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, seq.Add(1))
+}
+
+// addTransfer records a scored transfer of DefaultTenant in
+// graph_transactions, the way SaveTransaction does without Neo4j, and returns
+// its transaction ID
+func addTransfer(t *testing.T, from, to string, amount float64, at time.Time) string {
+	t.Helper()
+	return addTenantTransfer(t, DefaultTenant, from, to, amount, at)
+}
+
+// addTenantTransfer is addTransfer for a transfer submitted by tenant
+func addTenantTransfer(t *testing.T, tenant, from, to string, amount float64, at time.Time) string {
+	t.Helper()
+	id := testID("TXN")
+	_, err := db.DB.Exec(`
+		INSERT INTO graph_transactions (txn_id, tenant_id, sender_account, receiver_account, amount, timestamp, risk_score, action, reasons)
+		VALUES (?, ?, ?, ?, ?, ?, 0.1, 'APPROVE', '[]')
+	`, id, tenant, from, to, amount, at.UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+const testPassword = "correct horse battery"
+
+// testUsername returns a username no other test uses
+func testUsername(prefix string) string {
+	return testID(prefix) + "@test.bank"
+}
+
+// newTestUser creates a user with testPassword and a username no other test uses
+func newTestUser(t *testing.T, role string) *models.User {
+	t.Helper()
+	user, err := CreateUser(testUsername(role), testPassword, role, DefaultTenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var (
+	ErrMFAInvalidCode     = errors.New("invalid verification code")
+	ErrMFANotEnrolled     = errors.New("multi-factor authentication is not set up")
+	ErrMFAAlreadyEnrolled = errors.New("multi-factor authentication is already enabled")
+	ErrMFARequiredByRole  = errors.New("your role requires multi-factor authentication")
+)
+
+// MFARequiredError is returned by Login when the password was right but a
+// second factor is still needed
+type MFARequiredError struct {
+	Challenge models.MFAChallenge
+}
+
+func (e *MFARequiredError) Error() string {
+	return "second factor required"
+}
+
+// TOTP parameters (RFC 6238), the defaults every authenticator app supports
+const (
+	totpDigits        = 6
+	totpPeriod        = 30
+	recoveryCodeCount = 10
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService handles TOTP second factors: enrolment, code checks with replay
+// protection, single-use recovery codes and the login challenges that sit
+// between a correct password and a session.
+type MFAService struct {
+	Issuer        string   // account label shown in authenticator apps
+	RequiredRoles []string // roles that cannot sign in with a password alone
+	ChallengeTTL  time.Duration
+	MaxAttempts   int // wrong codes before a login challenge is discarded
+	Skew          int // periods either side of now a code is accepted for
+
+	mu         sync.Mutex
+	challenges map[string]*mfaChallenge // by token hash
+}
+
+type mfaChallenge struct {
+	user     models.User
+	ip       string
+	enroll   bool
+	expires  time.Time
+	attempts int
+}
+
+func NewMFAService() *MFAService {
+	return &MFAService{
+		Issuer:        "Synapse RiskOps",
+		RequiredRoles: []string{"supervisor", "admin"},
+		ChallengeTTL:  5 * time.Minute,
+		MaxAttempts:   5,
+		Skew:          1,
+		challenges:    map[string]*mfaChallenge{},
+	}
+}
+
+// RoleRequiresMFA reports whether the policy requires a second factor for the role
+func (m *MFAService) RoleRequiresMFA(role string) bool {
+	return contains(m.RequiredRoles, role)
+}
+
+type mfaRecord struct {
+	secret    string
+	confirmed sql.NullTime
+	lastStep  int64
+}
+
+func loadMFA(username string) (*mfaRecord, error) {
+	var r mfaRecord
+	err := db.DB.QueryRow("SELECT secret, confirmed_at, coalesce(last_used_step, 0) FROM user_mfa WHERE username = ?", username).
+		Scan(&r.secret, &r.confirmed, &r.lastStep)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &r, err
+}
+
+// Status reports a user's MFA state
+func (m *MFAService) Status(username, role string) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{Username: username, Required: m.RoleRequiresMFA(role)}
+	rec, err := loadMFA(username)
+	if err != nil || rec == nil {
+		return status, err
+	}
+	status.Enabled = rec.confirmed.Valid
+	status.Pending = !rec.confirmed.Valid
+	if rec.confirmed.Valid {
+		status.ConfirmedAt = &rec.confirmed.Time
+	}
+	err = db.DB.QueryRow("SELECT count(*) FROM mfa_recovery_codes WHERE username = ? AND used_at IS NULL", username).Scan(&status.RecoveryCodesLeft)
+	return status, err
+}
+
+// Enroll generates a new TOTP secret for the user. It stays inactive until
+// Confirm sees a code from it; starting again replaces an unconfirmed secret.
+func (m *MFAService) Enroll(username string) (*models.MFAEnrollment, error) {
+	rec, err := loadMFA(username)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil && rec.confirmed.Valid {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	key := make([]byte, 20)
+	_, _ = rand.Read(key)
+	secret := base32NoPad.EncodeToString(key)
+	_, err = db.DB.Exec(`
+		INSERT INTO user_mfa (username, secret, confirmed_at, last_used_step, created_at) VALUES (?, ?, NULL, 0, ?)
+		ON CONFLICT(username) DO UPDATE SET secret = excluded.secret, confirmed_at = NULL, last_used_step = 0, created_at = excluded.created_at
+	`, username, secret, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	label := url.PathEscape(m.Issuer + ":" + username)
+	q := url.Values{
+		"secret":    {secret},
+		"issuer":    {m.Issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return &models.MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURI: "otpauth://totp/" + label + "?" + q.Encode(),
+		Digits:     totpDigits,
+		Period:     totpPeriod,
+	}, nil
+}
+
+// Confirm turns MFA on once the user proves their app produces codes, and
+// returns their recovery codes
+func (m *MFAService) Confirm(username, code string) ([]string, error) {
+	rec, err := loadMFA(username)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if rec.confirmed.Valid {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	if err := m.checkTOTP(username, rec, code); err != nil {
+		return nil, err
+	}
+	if _, err := db.DB.Exec("UPDATE user_mfa SET confirmed_at = ? WHERE username = ?", time.Now().UTC(), username); err != nil {
+		return nil, err
+	}
+	return newRecoveryCodes(username)
+}
+
+// Verify accepts a current TOTP code or an unused recovery code
+func (m *MFAService) Verify(username, code string) error {
+	rec, err := loadMFA(username)
+	if err != nil {
+		return err
+	}
+	if rec == nil || !rec.confirmed.Valid {
+		return ErrMFANotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return m.checkTOTP(username, rec, code)
+	}
+	res, err := db.DB.Exec("UPDATE mfa_recovery_codes SET used_at = ? WHERE username = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now().UTC(), username, tokenHash(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+// checkTOTP accepts a code from the current period or Skew periods either
+// side. Each period's code works once: the step is recorded and older or
+// equal steps are refused afterwards.
+func (m *MFAService) checkTOTP(username string, rec *mfaRecord, code string) error {
+	key, err := base32NoPad.DecodeString(rec.secret)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix() / totpPeriod
+	for d := -m.Skew; d <= m.Skew; d++ {
+		step := now + int64(d)
+		if step <= rec.lastStep || !hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			continue
+		}
+		res, err := db.DB.Exec("UPDATE user_mfa SET last_used_step = ? WHERE username = ? AND coalesce(last_used_step, 0) < ?", step, username, step)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			break // the same code was just used by another request
+		}
+		return nil
+	}
+	return ErrMFAInvalidCode
+}
+
+// totpCode is the RFC 6238 code for a time step (HMAC-SHA1, RFC 4226 truncation)
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// newRecoveryCodes replaces the user's recovery codes. Each has 60 random
+// bits, so a SHA-256 hash is enough to keep them useless if the table leaks.
+func newRecoveryCodes(username string) ([]string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE username = ?", username); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		raw := strings.ToLower(base32NoPad.EncodeToString(b))[:12]
+		codes[i] = raw[:4] + "-" + raw[4:8] + "-" + raw[8:]
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (username, code_hash) VALUES (?, ?)", username, tokenHash(raw)); err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit()
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// RegenerateRecoveryCodes issues a new set of recovery codes after a valid code
+func (m *MFAService) RegenerateRecoveryCodes(username, code string) ([]string, error) {
+	if err := m.Verify(username, code); err != nil {
+		return nil, err
+	}
+	return newRecoveryCodes(username)
+}
+
+// Disable turns MFA off after a valid code, unless the user's role requires it
+func (m *MFAService) Disable(username, role, code string) error {
+	if m.RoleRequiresMFA(role) {
+		return ErrMFARequiredByRole
+	}
+	if err := m.Verify(username, code); err != nil {
+		return err
+	}
+	_, err := ResetMFA(username)
+	return err
+}
+
+// ResetMFA removes a user's secret and recovery codes without a code, for an
+// admin helping a user who lost their device. It reports whether MFA was set.
+func ResetMFA(username string) (bool, error) {
+	res, err := db.DB.Exec("DELETE FROM user_mfa WHERE username = ?", username)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.DB.Exec("DELETE FROM mfa_recovery_codes WHERE username = ?", username); err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// challengeFor decides whether a user who got the password right still needs
+// a second factor, and opens a login challenge if so
+func (m *MFAService) challengeFor(user models.User, ip string) (*models.MFAChallenge, error) {
+	rec, err := loadMFA(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	enrolled := rec != nil && rec.confirmed.Valid
+	if !enrolled && !m.RoleRequiresMFA(user.Role) {
+		return nil, nil
+	}
+
+	token := newToken("mfa_")
+	now := time.Now()
+	m.mu.Lock()
+	for k, c := range m.challenges {
+		if now.After(c.expires) {
+			delete(m.challenges, k)
+		}
+	}
+	m.challenges[tokenHash(token)] = &mfaChallenge{user: user, ip: ip, enroll: !enrolled, expires: now.Add(m.ChallengeTTL)}
+	m.mu.Unlock()
+	return &models.MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: !enrolled,
+		MFAToken:           token,
+		ExpiresIn:          int(m.ChallengeTTL.Seconds()),
+	}, nil
+}
+
+// challenge returns a copy of a live login challenge
+func (m *MFAService) challenge(token string) (mfaChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.challenges[tokenHash(token)]
+	if !ok || time.Now().After(c.expires) {
+		delete(m.challenges, tokenHash(token))
+		return mfaChallenge{}, ErrInvalidToken
+	}
+	return *c, nil
+}
+
+// ChallengeUsername names the user a live login challenge belongs to, for the
+// audit trail
+func (m *MFAService) ChallengeUsername(token string) string {
+	c, _ := m.challenge(token)
+	return c.user.Username
+}
+
+// failChallenge counts a wrong code, discarding the challenge after MaxAttempts
+func (m *MFAService) failChallenge(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.challenges[tokenHash(token)]; ok {
+		c.attempts++
+		if c.attempts >= m.MaxAttempts {
+			delete(m.challenges, tokenHash(token))
+		}
+	}
+}
+
+func (m *MFAService) endChallenge(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.challenges, tokenHash(token))
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, truncated to six digits
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+// currentCode is the user's TOTP code offset periods from now
+func currentCode(t *testing.T, username string, offset int64) string {
+	t.Helper()
+	rec, err := loadMFA(username)
+	if err != nil || rec == nil {
+		t.Fatalf("loadMFA(%s) = %v, %v", username, rec, err)
+	}
+	key, err := base32NoPad.DecodeString(rec.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, time.Now().Unix()/totpPeriod+offset)
+}
+
+// enrollMFA sets up and confirms MFA for the user with the current code,
+// returning the recovery codes
+func enrollMFA(t *testing.T, m *MFAService, username string) []string {
+	t.Helper()
+	if _, err := m.Enroll(username); err != nil {
+		t.Fatal(err)
+	}
+	codes, err := m.Confirm(username, currentCode(t, username, 0))
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes", len(codes))
+	}
+	return codes
+}
+
+func TestMFAVerify(t *testing.T) {
+	m := NewMFAService()
+	user := newTestUser(t, "analyst")
+	if err := m.Verify(user.Username, "123456"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("Verify before enrolment = %v, want ErrMFANotEnrolled", err)
+	}
+	if _, err := m.Enroll(user.Username); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Verify(user.Username, currentCode(t, user.Username, 0)); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("Verify before Confirm = %v, want ErrMFANotEnrolled", err)
+	}
+	if _, err := m.Confirm(user.Username, "000000"); !errors.Is(err, ErrMFAInvalidCode) {
+		t.Fatalf("Confirm with a wrong code = %v", err)
+	}
+	confirmCode := currentCode(t, user.Username, 0)
+	recovery, err := m.Confirm(user.Username, confirmCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Enroll(user.Username); !errors.Is(err, ErrMFAAlreadyEnrolled) {
+		t.Errorf("second Enroll = %v, want ErrMFAAlreadyEnrolled", err)
+	}
+
+	// The steps run in order: each accepted code raises last_used_step
+	next := currentCode(t, user.Username, 1)
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"code used to confirm", confirmCode, ErrMFAInvalidCode},
+		{"outside the skew", currentCode(t, user.Username, 2), ErrMFAInvalidCode},
+		{"wrong code", "000000", ErrMFAInvalidCode},
+		{"next period", next, nil},
+		{"next period again", next, ErrMFAInvalidCode},
+		{"earlier period after a later one", confirmCode, ErrMFAInvalidCode},
+		{"recovery code", recovery[0], nil},
+		{"recovery code again", recovery[0], ErrMFAInvalidCode},
+		{"recovery code in capitals without dashes", strings.ToUpper(strings.ReplaceAll(recovery[1], "-", "")), nil},
+		{"normalized recovery code again", recovery[1], ErrMFAInvalidCode},
+		{"recovery code with spaces", " " + strings.ReplaceAll(recovery[2], "-", " ") + " ", nil},
+		{"unknown recovery code", "aaaa-bbbb-cccc", ErrMFAInvalidCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Verify(user.Username, tt.code); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify(%q) = %v, want %v", tt.code, err, tt.want)
+			}
+		})
+	}
+
+	status, err := m.Status(user.Username, user.Role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.RecoveryCodesLeft != recoveryCodeCount-3 {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestMFADisable(t *testing.T) {
+	m := NewMFAService()
+	analyst := newTestUser(t, "analyst")
+	supervisor := newTestUser(t, "supervisor")
+	recovery := enrollMFA(t, m, analyst.Username)
+	enrollMFA(t, m, supervisor.Username)
+
+	if err := m.Disable(supervisor.Username, supervisor.Role, currentCode(t, supervisor.Username, 1)); !errors.Is(err, ErrMFARequiredByRole) {
+		t.Errorf("supervisor turned MFA off: %v", err)
+	}
+	if err := m.Disable(analyst.Username, analyst.Role, "000000"); !errors.Is(err, ErrMFAInvalidCode) {
+		t.Errorf("Disable with a wrong code = %v", err)
+	}
+	if err := m.Disable(analyst.Username, analyst.Role, recovery[0]); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if err := m.Verify(analyst.Username, recovery[1]); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("recovery codes outlived MFA: %v", err)
+	}
+}
+
+// loginChallenge logs in with the right password and returns the challenge
+func loginChallenge(t *testing.T, auth *AuthService, username string) models.MFAChallenge {
+	t.Helper()
+	_, err := auth.Login(username, testPassword, "198.51.100.20")
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("Login = %v, want *MFARequiredError", err)
+	}
+	return mfaErr.Challenge
+}
+
+func failedLogins(t *testing.T, userID int64) int {
+	t.Helper()
+	var n int
+	if err := db.DB.QueryRow("SELECT coalesce(failed_logins, 0) FROM users WHERE id = ?", userID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLoginRequiresMFA(t *testing.T) {
+	auth := NewAuthService()
+	tests := []struct {
+		name       string
+		role       string
+		enrolled   bool
+		wantMFA    bool
+		wantEnroll bool
+	}{
+		{"enrolled analyst", "analyst", true, true, false},
+		{"analyst without MFA", "analyst", false, false, false},
+		{"supervisor without MFA", "supervisor", false, true, true},
+		{"enrolled admin", "admin", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, tt.role)
+			if tt.enrolled {
+				enrollMFA(t, auth.MFA, user.Username)
+			}
+			pair, err := auth.Login(user.Username, testPassword, "198.51.100.21")
+			var mfaErr *MFARequiredError
+			if errors.As(err, &mfaErr) != tt.wantMFA {
+				t.Fatalf("Login = %v, %v", pair, err)
+			}
+			if !tt.wantMFA {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if mfaErr.Challenge.EnrollmentRequired != tt.wantEnroll || !strings.HasPrefix(mfaErr.Challenge.MFAToken, "mfa_") {
+				t.Errorf("challenge = %+v", mfaErr.Challenge)
+			}
+		})
+	}
+}
+
+func TestCompleteMFA(t *testing.T) {
+	auth := NewAuthService()
+
+	t.Run("enrolment at login", func(t *testing.T) {
+		user := newTestUser(t, "supervisor")
+		challenge := loginChallenge(t, auth, user.Username)
+		if _, err := auth.MFA.Enroll(user.Username); err != nil {
+			t.Fatal(err)
+		}
+		pair, err := auth.CompleteMFA(challenge.MFAToken, currentCode(t, user.Username, 0))
+		if err != nil {
+			t.Fatalf("CompleteMFA: %v", err)
+		}
+		if len(pair.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("got %d recovery codes", len(pair.RecoveryCodes))
+		}
+		if _, err := auth.CompleteMFA(challenge.MFAToken, currentCode(t, user.Username, 1)); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("challenge reused: %v", err)
+		}
+	})
+
+	t.Run("disabled during the challenge", func(t *testing.T) {
+		user := newTestUser(t, "analyst")
+		enrollMFA(t, auth.MFA, user.Username)
+		challenge := loginChallenge(t, auth, user.Username)
+		if _, err := SetUserDisabled(user.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.CompleteMFA(challenge.MFAToken, currentCode(t, user.Username, 1)); !errors.Is(err, ErrAccountDisabled) {
+			t.Errorf("CompleteMFA for a disabled user = %v, want ErrAccountDisabled", err)
+		}
+	})
+
+	t.Run("reset by an admin", func(t *testing.T) {
+		user := newTestUser(t, "analyst")
+		enrollMFA(t, auth.MFA, user.Username)
+		if reset, err := ResetMFA(user.Username); err != nil || !reset {
+			t.Fatalf("ResetMFA = %v, %v", reset, err)
+		}
+		if pair, err := auth.Login(user.Username, testPassword, "198.51.100.24"); err != nil || pair == nil {
+			t.Errorf("Login after reset = %v, %v", pair, err)
+		}
+		if reset, err := ResetMFA(user.Username); err != nil || reset {
+			t.Errorf("second ResetMFA = %v, %v", reset, err)
+		}
+	})
+
+	t.Run("success clears failed logins", func(t *testing.T) {
+		user := newTestUser(t, "analyst")
+		enrollMFA(t, auth.MFA, user.Username)
+		auth.Login(user.Username, "wrong password", "198.51.100.22")
+		challenge := loginChallenge(t, auth, user.Username)
+		if n := failedLogins(t, user.ID); n != 1 {
+			t.Fatalf("failed_logins = %d after the password step, want 1", n)
+		}
+		if _, err := auth.CompleteMFA(challenge.MFAToken, "000000"); !errors.Is(err, ErrMFAInvalidCode) {
+			t.Fatalf("wrong code: %v", err)
+		}
+		if n := failedLogins(t, user.ID); n != 2 {
+			t.Fatalf("failed_logins = %d after a wrong code, want 2", n)
+		}
+		if _, err := auth.CompleteMFA(challenge.MFAToken, currentCode(t, user.Username, 1)); err != nil {
+			t.Fatalf("CompleteMFA: %v", err)
+		}
+		if n := failedLogins(t, user.ID); n != 0 {
+			t.Errorf("failed_logins = %d after MFA succeeded", n)
+		}
+	})
+
+	t.Run("wrong codes lock the account", func(t *testing.T) {
+		auth := NewAuthService()
+		auth.MaxFailures = 3
+		user := newTestUser(t, "analyst")
+		enrollMFA(t, auth.MFA, user.Username)
+		challenge := loginChallenge(t, auth, user.Username)
+		other := loginChallenge(t, auth, user.Username)
+		for i := 0; i < auth.MaxFailures; i++ {
+			if _, err := auth.CompleteMFA(challenge.MFAToken, "000000"); !errors.Is(err, ErrMFAInvalidCode) {
+				t.Fatalf("attempt %d: %v", i+1, err)
+			}
+		}
+		if _, err := auth.CompleteMFA(other.MFAToken, currentCode(t, user.Username, 1)); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("right code while locked: %v, want ErrAccountLocked", err)
+		}
+		if _, err := auth.CompleteMFA(other.MFAToken, currentCode(t, user.Username, 1)); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("challenge survived the lockout: %v", err)
+		}
+		if _, err := auth.Login(user.Username, testPassword, "198.51.100.23"); !errors.Is(err, ErrAccountLocked) {
+			t.Errorf("password login while locked: %v", err)
+		}
+	})
+
+	t.Run("challenge discarded after MaxAttempts", func(t *testing.T) {
+		auth := NewAuthService()
+		auth.MFA.MaxAttempts = 2
+		user := newTestUser(t, "analyst")
+		enrollMFA(t, auth.MFA, user.Username)
+		challenge := loginChallenge(t, auth, user.Username)
+		for i := 0; i < auth.MFA.MaxAttempts; i++ {
+			auth.CompleteMFA(challenge.MFAToken, "000000")
+		}
+		if _, err := auth.CompleteMFA(challenge.MFAToken, currentCode(t, user.Username, 1)); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("CompleteMFA after %d wrong codes = %v, want ErrInvalidToken", auth.MFA.MaxAttempts, err)
+		}
+	})
+}
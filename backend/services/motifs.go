@@ -0,0 +1,343 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// MotifOptions sets the burst thresholds for temporal motif detection
+type MotifOptions struct {
+	FanInMin         int           // distinct senders to one account within Window
+	FanOutMin        int           // distinct receivers from one account within Window
+	ScatterGatherMin int           // distinct intermediaries between one source and one sink within Window
+	Window           time.Duration // sliding window ending at the transaction being scored
+}
+
+func DefaultMotifOptions() MotifOptions {
+	return MotifOptions{
+		FanInMin:         5,
+		FanOutMin:        5,
+		ScatterGatherMin: 3,
+		Window:           time.Hour,
+	}
+}
+
+// motifMu serialises motif upserts so concurrent ingests extend one burst instead of duplicating it
+var motifMu sync.Mutex
+
+// DetectMotifs looks for fan-in and scatter-gather bursts ending at the transaction's
+// receiver and fan-out bursts starting at its sender, counting the transaction itself.
+// Detected motifs are recorded (extending an overlapping earlier burst of the same
+// shape) and the burst sizes are returned as scoring features.
+func (s *Neo4jService) DetectMotifs(txn models.Transaction) (map[string]any, error) {
+	opts := s.Motifs
+	end := txn.Timestamp
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := end.Add(-opts.Window)
+	current := models.TransferEdge{
+		TxnID:     txn.TransactionID,
+		From:      txn.SenderAccount,
+		To:        txn.ReceiverAccount,
+		Amount:    txn.Amount,
+		Timestamp: end,
+	}
+
+	features := map[string]any{
+		"fan_in_sender_count":         int64(0),
+		"fan_out_receiver_count":      int64(0),
+		"scatter_gather_source_count": int64(0),
+		"fan_in_burst":                false,
+		"fan_out_burst":               false,
+		"scatter_gather_detected":     false,
+	}
+
+	// Fan-in: many senders into the receiver
+	inEdges, err := windowEdges(txn.TenantID, "receiver_account", txn.ReceiverAccount, start, end, current)
+	if err != nil {
+		return features, err
+	}
+	senders := distinctAccounts(inEdges, func(e models.TransferEdge) string { return e.From })
+	features["fan_in_sender_count"] = int64(len(senders))
+	if len(senders) >= opts.FanInMin {
+		features["fan_in_burst"] = true
+		if err := recordMotif(txn.TenantID, newMotif(models.MotifFanIn, txn.ReceiverAccount, "", senders, inEdges), inEdges); err != nil {
+			return features, err
+		}
+	}
+
+	// Fan-out: the sender paying many receivers
+	outEdges, err := windowEdges(txn.TenantID, "sender_account", txn.SenderAccount, start, end, current)
+	if err != nil {
+		return features, err
+	}
+	receivers := distinctAccounts(outEdges, func(e models.TransferEdge) string { return e.To })
+	features["fan_out_receiver_count"] = int64(len(receivers))
+	if len(receivers) >= opts.FanOutMin {
+		features["fan_out_burst"] = true
+		if err := recordMotif(txn.TenantID, newMotif(models.MotifFanOut, txn.SenderAccount, "", receivers, outEdges), outEdges); err != nil {
+			return features, err
+		}
+	}
+
+	// Scatter-gather: one source paid several of the receiver's senders before they paid the receiver
+	motifs, err := scatterGatherInto(txn.TenantID, txn.ReceiverAccount, inEdges, start, end, opts.ScatterGatherMin)
+	if err != nil {
+		return features, err
+	}
+	features["scatter_gather_source_count"] = int64(len(motifs))
+	features["scatter_gather_detected"] = len(motifs) > 0
+	for _, m := range motifs {
+		if err := recordMotif(txn.TenantID, m.Motif, m.edges); err != nil {
+			return features, err
+		}
+	}
+	return features, nil
+}
+
+// windowEdges loads tenant's transfers where column = account within [start, end], plus
+// the transaction being scored, which has not been saved yet
+func windowEdges(tenant, column, account string, start, end time.Time, current models.TransferEdge) ([]models.TransferEdge, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+transferEdgeColumns+`
+		FROM graph_transactions
+		WHERE tenant_id = ? AND `+column+` = ? AND timestamp >= ? AND timestamp <= ? AND txn_id <> ?
+		ORDER BY timestamp ASC
+	`, tenant, account, start, end, current.TxnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return append(scanTransferEdges(rows), current), nil
+}
+
+// scatterGather is a detected scatter-gather motif with the transfers it was built from
+type scatterGather struct {
+	models.Motif
+	edges []models.TransferEdge
+}
+
+// scatterGatherInto finds sources that paid at least minIntermediaries of the sink's
+// senders within the window, each before that intermediary paid the sink
+func scatterGatherInto(tenant, sink string, gatherEdges []models.TransferEdge, start, end time.Time, minIntermediaries int) ([]scatterGather, error) {
+	// Latest time each intermediary paid the sink
+	gatheredAt := map[string]time.Time{}
+	gatherBy := map[string][]models.TransferEdge{}
+	for _, e := range gatherEdges {
+		if e.From == sink {
+			continue
+		}
+		if e.Timestamp.After(gatheredAt[e.From]) {
+			gatheredAt[e.From] = e.Timestamp
+		}
+		gatherBy[e.From] = append(gatherBy[e.From], e)
+	}
+	if len(gatheredAt) < minIntermediaries {
+		return nil, nil
+	}
+
+	mids := make([]string, 0, len(gatheredAt))
+	for m := range gatheredAt {
+		mids = append(mids, m)
+	}
+	args := append([]any{tenant}, stringArgs(mids)...)
+	args = append(args, start, end)
+	rows, err := db.DB.Query(`
+		SELECT `+transferEdgeColumns+`
+		FROM graph_transactions
+		WHERE tenant_id = ? AND receiver_account IN (`+placeholders(len(mids))+`) AND timestamp >= ? AND timestamp <= ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	scatter := scanTransferEdges(rows)
+	rows.Close()
+
+	bySource := map[string]map[string][]models.TransferEdge{}
+	for _, e := range scatter {
+		if e.From == sink || !gatheredAt[e.From].IsZero() || e.Timestamp.After(gatheredAt[e.To]) {
+			continue // round trips, intermediaries paying each other, or money arriving after it left
+		}
+		if bySource[e.From] == nil {
+			bySource[e.From] = map[string][]models.TransferEdge{}
+		}
+		bySource[e.From][e.To] = append(bySource[e.From][e.To], e)
+	}
+
+	var motifs []scatterGather
+	for source, byMid := range bySource {
+		if len(byMid) < minIntermediaries {
+			continue
+		}
+		var participants []string
+		var edges []models.TransferEdge
+		for mid, scatterEdges := range byMid {
+			participants = append(participants, mid)
+			edges = append(edges, scatterEdges...)
+			edges = append(edges, gatherBy[mid]...)
+		}
+		sort.Strings(participants)
+		motifs = append(motifs, scatterGather{newMotif(models.MotifScatterGather, sink, source, participants, edges), edges})
+	}
+	return motifs, nil
+}
+
+func distinctAccounts(edges []models.TransferEdge, side func(models.TransferEdge) string) []string {
+	seen := map[string]bool{}
+	var accounts []string
+	for _, e := range edges {
+		if a := side(e); !seen[a] {
+			seen[a] = true
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+func newMotif(motifType, center, source string, participants []string, edges []models.TransferEdge) models.Motif {
+	sortEdgesByTime(edges)
+	m := models.Motif{
+		Type:          motifType,
+		CenterAccount: center,
+		SourceAccount: source,
+		Participants:  participants,
+		TxnCount:      len(edges),
+		DetectedAt:    time.Now(),
+	}
+	for _, e := range edges {
+		m.TxnIDs = append(m.TxnIDs, e.TxnID)
+		m.TotalAmount += e.Amount
+	}
+	if len(edges) > 0 {
+		m.StartedAt = edges[0].Timestamp
+		m.EndedAt = edges[len(edges)-1].Timestamp
+	}
+	return m
+}
+
+// recordMotif stores a motif built from edges, merging it into an overlapping motif of the
+// same shape if one exists so a burst that keeps growing is reported once
+func recordMotif(tenant string, m models.Motif, edges []models.TransferEdge) error {
+	motifMu.Lock()
+	defer motifMu.Unlock()
+
+	var id int64
+	var participantsJSON, txnIDsJSON string
+	var total float64
+	var startedAt, endedAt time.Time
+	err := db.DB.QueryRow(`
+		SELECT id, participants, txn_ids, total_amount, started_at, ended_at
+		FROM motifs
+		WHERE tenant_id = ? AND motif_type = ? AND center_account = ? AND source_account = ? AND ended_at >= ?
+		ORDER BY ended_at DESC
+		LIMIT 1
+	`, tenant, m.Type, m.CenterAccount, m.SourceAccount, m.StartedAt).Scan(&id, &participantsJSON, &txnIDsJSON, &total, &startedAt, &endedAt)
+	if err == sql.ErrNoRows {
+		participants, _ := json.Marshal(m.Participants)
+		txnIDs, _ := json.Marshal(m.TxnIDs)
+		_, err = db.DB.Exec(`
+			INSERT INTO motifs (tenant_id, motif_type, center_account, source_account, participants, txn_ids, txn_count, total_amount, started_at, ended_at, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, tenant, m.Type, m.CenterAccount, m.SourceAccount, string(participants), string(txnIDs), m.TxnCount, m.TotalAmount, m.StartedAt, m.EndedAt, m.DetectedAt)
+		if err != nil {
+			return err
+		}
+		_, err = RaiseAlert(models.Alert{
+			TenantID:      tenant,
+			Source:        models.AlertSourceDetector,
+			Type:          m.Type,
+			Severity:      models.SeverityHigh,
+			TransactionID: m.TxnIDs[len(m.TxnIDs)-1],
+			AccountID:     m.CenterAccount,
+			Summary: fmt.Sprintf("%s around %s: %d accounts, %d transfers totalling %.2f between %s and %s",
+				m.Type, m.CenterAccount, len(m.Participants), m.TxnCount, m.TotalAmount,
+				m.StartedAt.Format(time.RFC3339), m.EndedAt.Format(time.RFC3339)),
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	var participants, txnIDs []string
+	_ = json.Unmarshal([]byte(participantsJSON), &participants)
+	_ = json.Unmarshal([]byte(txnIDsJSON), &txnIDs)
+	known := map[string]bool{}
+	for _, t := range txnIDs {
+		known[t] = true
+	}
+	for _, e := range edges {
+		if !known[e.TxnID] {
+			known[e.TxnID] = true
+			txnIDs = append(txnIDs, e.TxnID)
+			total += e.Amount
+		}
+	}
+	inMotif := map[string]bool{}
+	for _, p := range participants {
+		inMotif[p] = true
+	}
+	for _, p := range m.Participants {
+		if !inMotif[p] {
+			inMotif[p] = true
+			participants = append(participants, p)
+		}
+	}
+	sort.Strings(participants)
+	if m.StartedAt.Before(startedAt) {
+		startedAt = m.StartedAt
+	}
+	if m.EndedAt.After(endedAt) {
+		endedAt = m.EndedAt
+	}
+
+	participantsOut, _ := json.Marshal(participants)
+	txnIDsOut, _ := json.Marshal(txnIDs)
+	_, err = db.DB.Exec(`
+		UPDATE motifs SET participants = ?, txn_ids = ?, txn_count = ?, total_amount = ?, started_at = ?, ended_at = ?, detected_at = ?
+		WHERE id = ?
+	`, string(participantsOut), string(txnIDsOut), len(txnIDs), total, startedAt, endedAt, m.DetectedAt, id)
+	return err
+}
+
+// ListMotifs returns tenant's recorded motifs, most recent first, optionally filtered by
+// type and by an account that is the centre, source or a participant
+func ListMotifs(tenant, motifType, accountID string, limit int) ([]models.Motif, error) {
+	query := `
+		SELECT id, motif_type, center_account, source_account, participants, txn_ids, txn_count, total_amount, started_at, ended_at, detected_at
+		FROM motifs
+		WHERE tenant_id = ?
+		  AND (? = '' OR motif_type = ?)
+		  AND (? = '' OR center_account = ? OR source_account = ? OR EXISTS (SELECT 1 FROM json_each(motifs.participants) WHERE value = ?))
+		ORDER BY ended_at DESC
+		LIMIT ?
+	`
+	rows, err := db.DB.Query(query, tenant, motifType, motifType, accountID, accountID, accountID, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	motifs := []models.Motif{}
+	for rows.Next() {
+		var m models.Motif
+		var participants, txnIDs string
+		if err := rows.Scan(&m.MotifID, &m.Type, &m.CenterAccount, &m.SourceAccount, &participants, &txnIDs,
+			&m.TxnCount, &m.TotalAmount, &m.StartedAt, &m.EndedAt, &m.DetectedAt); err != nil {
+			continue
+		}
+		_ = json.Unmarshal([]byte(participants), &m.Participants)
+		_ = json.Unmarshal([]byte(txnIDs), &m.TxnIDs)
+		motifs = append(motifs, m)
+	}
+	return motifs, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestDetectMotifs(t *testing.T) {
+	s := &Neo4jService{Motifs: MotifOptions{FanInMin: 3, FanOutMin: 3, ScatterGatherMin: 2, Window: time.Hour}}
+	type hop struct {
+		from, to string
+		minutes  int // before the transaction being scored
+	}
+
+	tests := []struct {
+		name     string
+		history  []hop
+		from, to string
+		want     map[string]any
+	}{
+		{"fan-in", []hop{{"p1", "r", 10}, {"p2", "r", 5}}, "p3", "r",
+			map[string]any{"fan_in_sender_count": int64(3), "fan_in_burst": true}},
+		{"fan-in below the minimum", []hop{{"p1", "r", 10}}, "p2", "r",
+			map[string]any{"fan_in_sender_count": int64(2), "fan_in_burst": false}},
+		{"senders outside the window", []hop{{"p1", "r", 90}, {"p2", "r", 61}}, "p3", "r",
+			map[string]any{"fan_in_sender_count": int64(1), "fan_in_burst": false}},
+		{"a repeated sender counts once", []hop{{"p1", "r", 10}, {"p1", "r", 5}}, "p1", "r",
+			map[string]any{"fan_in_sender_count": int64(1), "fan_in_burst": false}},
+		{"fan-out", []hop{{"x", "a", 20}, {"x", "b", 10}}, "x", "c",
+			map[string]any{"fan_out_receiver_count": int64(3), "fan_out_burst": true}},
+		{"scatter-gather", []hop{{"src", "m1", 30}, {"src", "m2", 30}, {"m1", "sink", 10}}, "m2", "sink",
+			map[string]any{"scatter_gather_source_count": int64(1), "scatter_gather_detected": true}},
+		{"scatter after the gather", []hop{{"src", "m1", 5}, {"src", "m2", 30}, {"m1", "sink", 10}}, "m2", "sink",
+			map[string]any{"scatter_gather_source_count": int64(0), "scatter_gather_detected": false}},
+		{"round trip is not a scatter", []hop{{"sink", "m1", 30}, {"sink", "m2", 30}, {"m1", "sink", 10}}, "m2", "sink",
+			map[string]any{"scatter_gather_source_count": int64(0), "scatter_gather_detected": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := testID("MOT")
+			id := func(name string) string { return prefix + "-" + name }
+			now := time.Now().Add(-time.Hour)
+			for _, h := range tt.history {
+				addTransfer(t, id(h.from), id(h.to), 100, now.Add(-time.Duration(h.minutes)*time.Minute))
+			}
+			features, err := s.DetectMotifs(models.Transaction{
+				TenantID:        DefaultTenant,
+				TransactionID:   testID("TXN"),
+				SenderAccount:   id(tt.from),
+				ReceiverAccount: id(tt.to),
+				Amount:          100,
+				Timestamp:       now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if features[k] != v {
+					t.Errorf("%s = %v, want %v", k, features[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordMotifExtendsBurst(t *testing.T) {
+	s := &Neo4jService{Motifs: MotifOptions{FanInMin: 2, FanOutMin: 100, ScatterGatherMin: 100, Window: time.Hour}}
+	prefix := testID("MOT")
+	id := func(name string) string { return prefix + "-" + name }
+	now := time.Now().Add(-time.Hour)
+
+	// Each arriving transfer is scored, then saved, as on ingest
+	for i, sender := range []string{"p1", "p2", "p3"} {
+		txn := models.Transaction{
+			TenantID:        DefaultTenant,
+			TransactionID:   testID("TXN"),
+			SenderAccount:   id(sender),
+			ReceiverAccount: id("r"),
+			Amount:          100,
+			Timestamp:       now.Add(time.Duration(i) * time.Minute),
+		}
+		if _, err := s.DetectMotifs(txn); err != nil {
+			t.Fatal(err)
+		}
+		addTransfer(t, txn.SenderAccount, txn.ReceiverAccount, txn.Amount, txn.Timestamp)
+	}
+
+	motifs, err := ListMotifs(DefaultTenant, models.MotifFanIn, id("r"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 1 {
+		t.Fatalf("%d motifs for one growing burst: %+v", len(motifs), motifs)
+	}
+	m := motifs[0]
+	var participants []string
+	for _, p := range m.Participants {
+		participants = append(participants, strings.TrimPrefix(p, prefix+"-"))
+	}
+	if !reflect.DeepEqual(participants, []string{"p1", "p2", "p3"}) || m.CenterAccount != id("r") {
+		t.Errorf("motif = %+v", m)
+	}
+	if !m.EndedAt.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("burst ended at %v, want %v", m.EndedAt, now.Add(2*time.Minute))
+	}
+
+	byParticipant, err := ListMotifs(DefaultTenant, "", id("p2"), 10)
+	if err != nil || len(byParticipant) != 1 || byParticipant[0].MotifID != m.MotifID {
+		t.Errorf("motifs of a participant = %+v, %v", byParticipant, err)
+	}
+	if other, _ := ListMotifs(DefaultTenant, models.MotifFanOut, id("r"), 10); len(other) != 0 {
+		t.Errorf("fan-out motifs for a fan-in centre: %+v", other)
+	}
+}
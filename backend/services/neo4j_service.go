@@ -14,6 +14,13 @@ import (
 type Neo4jService struct {
 	Driver    neo4j.DriverWithContext
 	Connected bool
+	Cycles    CycleOptions
+	Motifs    MotifOptions
+
+	// SharedEntityWindow is how far back device and IP sharing is counted in risk context
+	SharedEntityWindow time.Duration
+
+	cycleCache cycleCache
 }
 
 func NewNeo4jService(uri, username, password string) (*Neo4jService, error) {
@@ -29,7 +36,7 @@ func NewNeo4jService(uri, username, password string) (*Neo4jService, error) {
 		log.Printf("Warning: Could not connect to Neo4j: %v. Using SQLite fallback.", err)
 		connected = false
 	}
-	return &Neo4jService{Driver: driver, Connected: connected}, nil
+	return &Neo4jService{Driver: driver, Connected: connected, Cycles: DefaultCycleOptions(), Motifs: DefaultMotifOptions(), SharedEntityWindow: 30 * 24 * time.Hour}, nil
 }
 
 func (s *Neo4jService) Close(ctx context.Context) error {
@@ -39,18 +46,53 @@ func (s *Neo4jService) Close(ctx context.Context) error {
 	return nil
 }
 
+// BackfillTenant puts graph data written before tenants existed into the
+// operator tenant, as db.InitDB does for SQLite; every query matches on the
+// tenant property, so untagged nodes and relationships would be invisible.
+// It only touches what has no tenant, so running it on every start is safe.
+func (s *Neo4jService) BackfillTenant(ctx context.Context) error {
+	if !s.Connected {
+		return nil
+	}
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	queries := []string{
+		`MATCH (n) WHERE n.tenant IS NULL
+		 CALL { WITH n SET n.tenant = $tenant } IN TRANSACTIONS OF 10000 ROWS`,
+		`MATCH ()-[r]->() WHERE r.tenant IS NULL
+		 CALL { WITH r SET r.tenant = $tenant } IN TRANSACTIONS OF 10000 ROWS`,
+	}
+	for _, query := range queries {
+		// CALL ... IN TRANSACTIONS needs an auto-commit transaction
+		result, err := session.Run(ctx, query, map[string]any{"tenant": DefaultTenant})
+		if err != nil {
+			return err
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Neo4jService) SaveTransaction(ctx context.Context, txn models.Transaction, analysis models.AnalysisResult) error {
 	reasonsJSON, _ := json.Marshal(analysis.Reasons)
 	
 	// Always save to SQLite as a local primary/fallback record
 	_, sqliteErr := db.DB.Exec(`
-		INSERT INTO graph_transactions (txn_id, sender_account, receiver_account, amount, timestamp, risk_score, action, reasons)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO graph_transactions (txn_id, sender_account, receiver_account, amount, currency, original_amount, original_currency, fx_rate, timestamp, risk_score, action, reasons, api_key_id, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(txn_id) DO UPDATE SET
 			risk_score = excluded.risk_score,
 			action = excluded.action,
 			reasons = excluded.reasons
-	`, txn.TransactionID, txn.SenderAccount, txn.ReceiverAccount, txn.Amount, txn.Timestamp, analysis.RiskScore, analysis.Action, string(reasonsJSON))
+		WHERE graph_transactions.tenant_id = excluded.tenant_id
+	`, txn.TransactionID, txn.SenderAccount, txn.ReceiverAccount, txn.Amount, txn.Currency, txn.OriginalAmount, txn.OriginalCurrency, txn.FXRate,
+		txn.Timestamp, analysis.RiskScore, analysis.Action, string(reasonsJSON), txn.APIKeyID, txn.TenantID)
+	if sqliteErr == nil {
+		sqliteErr = saveEntityLinksSQLite(txn)
+	}
 
 	if !s.Connected {
 		return sqliteErr
@@ -61,50 +103,66 @@ func (s *Neo4jService) SaveTransaction(ctx context.Context, txn models.Transacti
 
 	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
-			MERGE (s:Account {id: $sender_acc})
-			MERGE (r:Account {id: $receiver_acc})
+			MERGE (s:Account {tenant: $tenant, id: $sender_acc})
+			MERGE (r:Account {tenant: $tenant, id: $receiver_acc})
 			CREATE (t:Transaction {
 				id: $txn_id,
+				tenant: $tenant,
 				amount: $amount,
+				currency: $currency,
+				original_amount: $original_amount,
+				original_currency: $original_currency,
+				fx_rate: $fx_rate,
 				timestamp: $timestamp,
 				risk_score: $risk_score,
 				action: $action,
-				reasons: $reasons
+				reasons: $reasons,
+				api_key_id: $api_key_id
 			})
-			CREATE (s)-[:TRANSFERRED {amount: $amount, timestamp: $timestamp, txn_id: $txn_id, risk_score: $risk_score, reasons: $reasons}]->(r)
+			CREATE (s)-[:TRANSFERRED {tenant: $tenant, amount: $amount, original_amount: $original_amount, original_currency: $original_currency, timestamp: $timestamp, txn_id: $txn_id, risk_score: $risk_score, reasons: $reasons}]->(r)
 			CREATE (t)-[:FROM]->(s)
 			CREATE (t)-[:TO]->(r)
 			RETURN t.id
 		`
 		params := map[string]any{
-			"sender_acc":   txn.SenderAccount,
-			"receiver_acc": txn.ReceiverAccount,
-			"txn_id":       txn.TransactionID,
-			"amount":       txn.Amount,
-			"timestamp":    txn.Timestamp.Format(time.RFC3339Nano),
-			"risk_score":   analysis.RiskScore,
-			"action":       analysis.Action,
-			"reasons":      analysis.Reasons,
+			"sender_acc":        txn.SenderAccount,
+			"receiver_acc":      txn.ReceiverAccount,
+			"txn_id":            txn.TransactionID,
+			"amount":            txn.Amount,
+			"currency":          txn.Currency,
+			"original_amount":   txn.OriginalAmount,
+			"original_currency": txn.OriginalCurrency,
+			"fx_rate":           txn.FXRate,
+			"timestamp":         txn.Timestamp.Format(time.RFC3339Nano),
+			"risk_score":        analysis.RiskScore,
+			"action":            analysis.Action,
+			"reasons":           analysis.Reasons,
+			"api_key_id":        txn.APIKeyID,
+			"tenant":            txn.TenantID,
 		}
 		result, err := tx.Run(ctx, query, params)
 		if err != nil {
 			return nil, err
 		}
-		return result.Collect(ctx)
+		records, err := result.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return records, saveEntityLinksNeo4j(ctx, tx, txn)
 	})
 	return err
 }
 
-func (s *Neo4jService) GetRecentTransactions(ctx context.Context, limit int, minRisk float64) ([]map[string]any, error) {
+func (s *Neo4jService) GetRecentTransactions(ctx context.Context, tenant string, limit int, minRisk float64) ([]map[string]any, error) {
 	if !s.Connected {
 		// Fallback to SQLite
 		rows, err := db.DB.Query(`
 			SELECT sender_account, receiver_account, amount, timestamp, txn_id, risk_score, reasons
 			FROM graph_transactions
-			WHERE risk_score >= ?
+			WHERE tenant_id = ? AND risk_score >= ?
 			ORDER BY timestamp DESC
 			LIMIT ?
-		`, minRisk, limit)
+		`, tenant, minRisk, limit)
 		if err != nil {
 			return nil, err
 		}
@@ -143,13 +201,14 @@ func (s *Neo4jService) GetRecentTransactions(ctx context.Context, limit int, min
 
 	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
-			MATCH (s:Account)-[r:TRANSFERRED]->(recv:Account)
+			MATCH (s:Account {tenant: $tenant})-[r:TRANSFERRED]->(recv:Account)
 			WHERE r.risk_score >= $min_risk
 			RETURN s.id as sender, recv.id as receiver, r.amount as amount, r.timestamp as timestamp, r.txn_id as txn_id, r.risk_score as risk_score
 			ORDER BY r.timestamp DESC
 			LIMIT $limit
 		`
 		res, err := tx.Run(ctx, query, map[string]any{
+			"tenant": tenant,
 			"limit": limit,
 			"min_risk": minRisk,
 		})
@@ -187,7 +246,7 @@ func (s *Neo4jService) GetRecentTransactions(ctx context.Context, limit int, min
 	return result.([]map[string]any), nil
 }
 
-func (s *Neo4jService) GetAccountHistory(ctx context.Context, accountID string) (map[string]any, error) {
+func (s *Neo4jService) GetAccountHistory(ctx context.Context, tenant, accountID string) (map[string]any, error) {
 	if !s.Connected {
 		// Fallback to SQLite
 		rows, err := db.DB.Query(`
@@ -195,10 +254,10 @@ func (s *Neo4jService) GetAccountHistory(ctx context.Context, accountID string)
 				   CASE WHEN sender_account = ? THEN receiver_account ELSE sender_account END as other_acc,
 				   sender_account = ? as is_sender
 			FROM graph_transactions
-			WHERE sender_account = ? OR receiver_account = ?
+			WHERE tenant_id = ? AND (sender_account = ? OR receiver_account = ?)
 			ORDER BY timestamp DESC
 			LIMIT 20
-		`, accountID, accountID, accountID, accountID)
+		`, accountID, accountID, tenant, accountID, accountID)
 		if err != nil {
 			return nil, err
 		}
@@ -260,7 +319,7 @@ func (s *Neo4jService) GetAccountHistory(ctx context.Context, accountID string)
 	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		// Fetch transactions via the Transaction node to get canonical properties (including verification_status)
 		query := `
-			MATCH (a:Account {id: $acc_id})<-[:FROM|TO]-(t:Transaction)
+			MATCH (a:Account {tenant: $tenant, id: $acc_id})<-[:FROM|TO]-(t:Transaction)
 			MATCH (t)-[:FROM]->(sender:Account)
 			MATCH (t)-[:TO]->(receiver:Account)
 			RETURN 
@@ -276,7 +335,7 @@ func (s *Neo4jService) GetAccountHistory(ctx context.Context, accountID string)
 			ORDER BY t.timestamp DESC
 			LIMIT 20
 		`
-		res, err := tx.Run(ctx, query, map[string]any{"acc_id": accountID})
+		res, err := tx.Run(ctx, query, map[string]any{"tenant": tenant, "acc_id": accountID})
 		if err != nil {
 			return nil, err
 		}
@@ -343,6 +402,25 @@ func (s *Neo4jService) GetAccountHistory(ctx context.Context, accountID string)
 func (s *Neo4jService) ResetDatabase(ctx context.Context) error {
 	// Always reset SQLite
 	_, _ = db.DB.Exec("DELETE FROM graph_transactions")
+	_, _ = db.DB.Exec("DELETE FROM communities")
+	_, _ = db.DB.Exec("DELETE FROM account_communities")
+	_, _ = db.DB.Exec("DELETE FROM account_risk")
+	_, _ = db.DB.Exec("DELETE FROM account_entities")
+	_, _ = db.DB.Exec("DELETE FROM parties")
+	_, _ = db.DB.Exec("DELETE FROM party_name_tokens")
+	_, _ = db.DB.Exec("DELETE FROM party_accounts")
+	_, _ = db.DB.Exec("DELETE FROM motifs")
+	_, _ = db.DB.Exec("DELETE FROM last_locations")
+	_, _ = db.DB.Exec("DELETE FROM watchlist_hits")
+	_, _ = db.DB.Exec("DELETE FROM account_status")
+	_, _ = db.DB.Exec("DELETE FROM account_status_history")
+	_, _ = db.DB.Exec("DELETE FROM holds")
+	_, _ = db.DB.Exec("DELETE FROM alerts")
+	_, _ = db.DB.Exec("DELETE FROM cases")
+	_, _ = db.DB.Exec("DELETE FROM case_notes")
+	_, _ = db.DB.Exec("DELETE FROM case_links")
+	_, _ = db.DB.Exec("DELETE FROM approvals WHERE kind = 'VERDICT'")
+	_, _ = db.DB.Exec("DELETE FROM str_reports")
 
 	if !s.Connected {
 		return nil
@@ -358,9 +436,8 @@ func (s *Neo4jService) ResetDatabase(ctx context.Context) error {
 	return err
 }
 
-// GetAccountRiskContext returns aggregated risk signals for an account
-// Used for graph-aware compound risk scoring
-func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, accountID string) (map[string]any, error) {
+// getIncomingRiskContext returns aggregated signals over an account's incoming transfers
+func (s *Neo4jService) getIncomingRiskContext(ctx context.Context, tenant, accountID string) (map[string]any, error) {
 	if !s.Connected {
 		// Fallback to SQLite (simplified context)
 		var incomingCount, uniqueSenders, clusteringCount int64
@@ -369,8 +446,8 @@ func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, accountID stri
 		err := db.DB.QueryRow(`
 			SELECT count(*), count(DISTINCT sender_account), coalesce(sum(amount), 0), coalesce(avg(risk_score), 0)
 			FROM graph_transactions
-			WHERE receiver_account = ?
-		`, accountID).Scan(&incomingCount, &uniqueSenders, &totalVolume, &avgRisk)
+			WHERE tenant_id = ? AND receiver_account = ?
+		`, tenant, accountID).Scan(&incomingCount, &uniqueSenders, &totalVolume, &avgRisk)
 		
 		if err != nil {
 			// Graceful degradation
@@ -397,7 +474,7 @@ func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, accountID stri
 
 	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
-			MATCH (target:Account {id: $account_id})<-[r:TRANSFERRED]-(sender:Account)
+			MATCH (target:Account {tenant: $tenant, id: $account_id})<-[r:TRANSFERRED]-(sender:Account)
 			WITH target, r, sender
 			RETURN 
 				count(r) as incoming_tx_count,
@@ -406,7 +483,7 @@ func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, accountID stri
 				coalesce(avg(r.risk_score), 0) as avg_incoming_risk,
 				size([x IN collect(r.amount) WHERE x IN [100.0, 200.0, 300.0, 500.0, 1000.0, 1500.0]]) as clustering_amount_count
 		`
-		res, err := tx.Run(ctx, query, map[string]any{"account_id": accountID})
+		res, err := tx.Run(ctx, query, map[string]any{"tenant": tenant, "account_id": accountID})
 		if err != nil {
 			return nil, err
 		}
@@ -444,9 +521,9 @@ func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, accountID stri
 	return result.(map[string]any), nil
 }
 
-func (s *Neo4jService) UpdateTransactionVerification(ctx context.Context, txnID string, verdict string) error {
+func (s *Neo4jService) UpdateTransactionVerification(ctx context.Context, tenant, txnID string, verdict string) error {
 	// Always update SQLite
-	_, _ = db.DB.Exec("UPDATE graph_transactions SET verification_status = ? WHERE txn_id = ?", verdict, txnID)
+	_, _ = db.DB.Exec("UPDATE graph_transactions SET verification_status = ? WHERE tenant_id = ? AND txn_id = ?", verdict, tenant, txnID)
 
 	if !s.Connected {
 		return nil
@@ -457,16 +534,17 @@ func (s *Neo4jService) UpdateTransactionVerification(ctx context.Context, txnID
 
 	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
 		query := `
-			MATCH (t:Transaction {id: $txn_id})
+			MATCH (t:Transaction {tenant: $tenant, id: $txn_id})
 			SET t.verification_status = $verdict, 
 			    t.verified_at = $timestamp
 			WITH t
-			MATCH ()-[r:TRANSFERRED {txn_id: $txn_id}]->()
+			MATCH ()-[r:TRANSFERRED {tenant: $tenant, txn_id: $txn_id}]->()
 			SET r.verification_status = $verdict,
 			    r.verified_at = $timestamp
 			RETURN t.id
 		`
 		params := map[string]any{
+			"tenant":    tenant,
 			"txn_id":    txnID,
 			"verdict":   verdict,
 			"timestamp": time.Now().Format(time.RFC3339),
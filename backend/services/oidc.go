@@ -0,0 +1,530 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // SHA-384 and SHA-512 for RS384/RS512/ES384/ES512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOIDCDisabled = errors.New("single sign-on is not configured")
+	ErrOIDCState    = errors.New("unknown or expired sign-on attempt, please start again")
+	ErrOIDCNoRole   = errors.New("none of your identity provider groups grants access to Synapse")
+	ErrOIDCEmail    = errors.New("identity provider has not verified your email address")
+)
+
+// GroupRole maps an identity provider group to a Synapse role
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+// OIDCIdentity is the user an ID token vouches for
+type OIDCIdentity struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Username string   `json:"username"`
+	Name     string   `json:"name,omitempty"`
+	Groups   []string `json:"groups"`
+	Role     string   `json:"role"`
+}
+
+// OIDCService signs users in through an OpenID Connect provider with the
+// authorization-code flow and PKCE. The provider's endpoints come from its
+// discovery document and ID tokens are checked against its JWKS. Users are
+// matched on the token's issuer and subject, named by UsernameClaim, and get
+// the role of the first GroupRoles entry whose group they are in, or
+// DefaultRole when none matches.
+type OIDCService struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string // empty for public clients
+	RedirectURL   string // this backend's /api/oidc/callback as registered with the provider
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+	GroupRoles    []GroupRole // first match wins
+	DefaultRole   string      // empty refuses users without a mapped group
+	Tenant        string      // bank that users signing in for the first time join
+	ProviderName  string      // shown on the login button
+	PostLoginURL  string      // front-end page that receives the tokens in its URL fragment
+	LoginTTL      time.Duration
+	ClockSkew     time.Duration
+	Client        *http.Client
+
+	cookieKey []byte // signs the state cookie; per process, like pending
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	pending     map[string]pendingLogin
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// pendingLogin is what the callback needs from the redirect that started it
+type pendingLogin struct {
+	verifier string
+	nonce    string
+	started  time.Time
+}
+
+func NewOIDCService() *OIDCService {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return &OIDCService{
+		Scopes:        []string{"openid", "profile", "email"},
+		UsernameClaim: "email",
+		GroupsClaim:   "groups",
+		ProviderName:  "Corporate SSO",
+		Tenant:        DefaultTenant,
+		PostLoginURL:  "/login",
+		LoginTTL:      10 * time.Minute,
+		ClockSkew:     time.Minute,
+		Client:        &http.Client{Timeout: 10 * time.Second},
+		pending:       map[string]pendingLogin{},
+		cookieKey:     key,
+	}
+}
+
+// Enabled reports whether a provider is configured
+func (o *OIDCService) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != "" && o.RedirectURL != ""
+}
+
+// AuthCodeURL starts a sign-on: it remembers a fresh state, nonce and PKCE
+// verifier and returns the provider URL to send the browser to, along with
+// the value of the state cookie that ties the sign-on to this browser
+func (o *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	if !o.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	disc, err := o.getDiscovery(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	state, nonce, verifier := newToken(""), newToken(""), newToken("")
+	challenge := sha256.Sum256([]byte(verifier))
+
+	o.mu.Lock()
+	now := time.Now()
+	for s, p := range o.pending {
+		if now.Sub(p.started) > o.LoginTTL {
+			delete(o.pending, s)
+		}
+	}
+	o.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, started: now}
+	o.mu.Unlock()
+
+	u, err := url.Parse(disc.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", o.ClientID)
+	q.Set("redirect_uri", o.RedirectURL)
+	q.Set("scope", strings.Join(o.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), o.stateCookie(state), nil
+}
+
+// stateCookie is state signed with the per-process cookie key
+func (o *OIDCService) stateCookie(state string) string {
+	mac := hmac.New(sha256.New, o.cookieKey)
+	mac.Write([]byte(state))
+	return state + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Exchange completes a sign-on: it checks the browser's state cookie against
+// the returned state, redeems the code with the PKCE verifier, validates the
+// ID token and maps the user's groups to a role. A callback arriving in a
+// browser that did not start the sign-on is refused before the code is used.
+func (o *OIDCService) Exchange(ctx context.Context, cookie, state, code string) (*OIDCIdentity, error) {
+	if !o.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+	if state == "" || !hmac.Equal([]byte(cookie), []byte(o.stateCookie(state))) {
+		return nil, ErrOIDCState
+	}
+	o.mu.Lock()
+	login, ok := o.pending[state]
+	delete(o.pending, state) // single use, even when the exchange fails
+	o.mu.Unlock()
+	if !ok || time.Since(login.started) > o.LoginTTL {
+		return nil, ErrOIDCState
+	}
+	if code == "" {
+		return nil, errors.New("identity provider returned no authorization code")
+	}
+	disc, err := o.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.RedirectURL},
+		"client_id":     {o.ClientID},
+		"code_verifier": {login.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token request rejected: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := o.verifyIDToken(ctx, disc, tokens.IDToken, login.nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	return o.identity(claims)
+}
+
+// identity reads the username and groups from validated claims and picks the
+// role. An email is only used as the username once the provider has verified it.
+func (o *OIDCService) identity(claims map[string]any) (*OIDCIdentity, error) {
+	id := &OIDCIdentity{Groups: []string{}}
+	id.Issuer, _ = claims["iss"].(string)
+	id.Subject, _ = claims["sub"].(string)
+	id.Name, _ = claims["name"].(string)
+	if id.Subject == "" {
+		return nil, errors.New("ID token has no sub claim")
+	}
+	claim := o.UsernameClaim
+	id.Username, _ = claims[claim].(string)
+	if id.Username == "" {
+		claim = "preferred_username"
+		id.Username, _ = claims[claim].(string)
+	}
+	if id.Username == "" {
+		return nil, fmt.Errorf("ID token has no %q claim", o.UsernameClaim)
+	}
+	if claim == "email" && !emailVerified(claims) {
+		return id, ErrOIDCEmail
+	}
+	switch groups := claims[o.GroupsClaim].(type) {
+	case string:
+		id.Groups = append(id.Groups, groups)
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+
+	id.Role = o.DefaultRole
+	for _, gr := range o.GroupRoles {
+		if contains(id.Groups, gr.Group) {
+			id.Role = gr.Role
+			break
+		}
+	}
+	if id.Role == "" {
+		return id, ErrOIDCNoRole
+	}
+	return id, nil
+}
+
+// emailVerified reads the email_verified claim, which some providers send as a
+// string
+func emailVerified(claims map[string]any) bool {
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyIDToken checks the signature against the provider's JWKS and the
+// issuer, audience, expiry and nonce, returning the claims
+func (o *OIDCService) verifyIDToken(ctx context.Context, disc *oidcDiscovery, raw, nonce string) (map[string]any, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	key, err := o.signingKey(ctx, disc, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWS(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != disc.Issuer {
+		return nil, fmt.Errorf("issuer %q does not match %q", iss, disc.Issuer)
+	}
+	var audience []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+	}
+	if !contains(audience, o.ClientID) {
+		return nil, errors.New("token was not issued for this client")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != o.ClientID {
+		return nil, errors.New("token was issued to another party")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(o.ClockSkew)) {
+		return nil, errors.New("token has expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(o.ClockSkew)) {
+		return nil, errors.New("token was issued in the future")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("nonce does not match")
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifyJWS checks a JWS signature. Only asymmetric algorithms are accepted,
+// so "none" and HMAC tokens keyed with a public key are refused.
+func verifyJWS(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("key type does not match algorithm %q", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, sig); err != nil {
+			return errors.New("bad signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return fmt.Errorf("key type does not match algorithm %q", alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("bad signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+func (o *OIDCService) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	disc := o.discovery
+	o.mu.Unlock()
+	if disc != nil {
+		return disc, nil
+	}
+
+	disc = &oidcDiscovery{}
+	if err := o.getJSON(ctx, strings.TrimSuffix(o.Issuer, "/")+"/.well-known/openid-configuration", disc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(disc.Issuer, "/") != strings.TrimSuffix(o.Issuer, "/") {
+		return nil, fmt.Errorf("discovery: provider reports issuer %q, expected %q", disc.Issuer, o.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, errors.New("discovery: document lacks authorization, token or jwks endpoint")
+	}
+	o.mu.Lock()
+	o.discovery = disc
+	o.mu.Unlock()
+	return disc, nil
+}
+
+// signingKey returns the JWKS key with the ID, fetching the key set again
+// (at most once a minute) when the provider has rotated to an unknown key
+func (o *OIDCService) signingKey(ctx context.Context, disc *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	key, ok := o.keys[kid]
+	stale := time.Since(o.keysFetched) > time.Minute
+	o.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, disc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(pub.X, pub.Y) {
+				continue
+			}
+			keys[k.Kid] = pub
+		}
+	}
+
+	o.mu.Lock()
+	o.keys, o.keysFetched = keys, time.Now()
+	o.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (o *OIDCService) getJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
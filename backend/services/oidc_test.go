@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// testProvider is an OpenID provider serving discovery, a JWKS with one RSA
+// and one EC key, and a token endpoint that checks the PKCE verifier against
+// the challenge of the last authorization request
+type testProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	challenge string                    // code_challenge of the last AuthCodeURL
+	idToken   func(nonce string) string // what the token endpoint returns
+	nonce     string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	p := &testProvider{}
+	var err error
+	if p.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if p.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(p.rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(p.rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(p.ecKey.X.FillBytes(make([]byte, 32))), "y": b64(p.ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(p.rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || b64(sum[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.idToken(p.nonce)})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testProvider) service() *OIDCService {
+	o := NewOIDCService()
+	o.Issuer = p.URL
+	o.ClientID = "synapse"
+	o.RedirectURL = "https://synapse.test/api/oidc/callback"
+	o.GroupRoles = []GroupRole{{Group: "fraud-leads", Role: "supervisor"}, {Group: "fraud-team", Role: "analyst"}}
+	return o
+}
+
+// claims returns a valid set of ID token claims for nonce
+func (p *testProvider) claims(nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            p.URL,
+		"sub":            "user-1",
+		"aud":            "synapse",
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "alice@bank.example",
+		"email_verified": true,
+		"groups":         []string{"fraud-team"},
+	}
+}
+
+// sign builds a compact JWS. HS256 is keyed with the RSA public key, the
+// classic algorithm confusion attack, and none carries no signature.
+func (p *testProvider) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "HS256":
+		pub, _ := x509.MarshalPKIXPublicKey(&p.rsaKey.PublicKey)
+		mac := hmac.New(sha256.New, pub)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "none":
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	p := newTestProvider(t)
+	o := p.service()
+	ctx := context.Background()
+	disc, err := o.getDiscovery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const nonce = "the-nonce"
+	with := func(key string, value any) map[string]any {
+		c := p.claims(nonce)
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr string
+	}{
+		{"RS256", func() string { return p.sign(t, "RS256", "rsa", p.claims(nonce)) }, ""},
+		{"ES256", func() string { return p.sign(t, "ES256", "ec", p.claims(nonce)) }, ""},
+		{"audience list", func() string { return p.sign(t, "RS256", "rsa", with("aud", []string{"other", "synapse"})) }, ""},
+		{"matching azp", func() string { return p.sign(t, "RS256", "rsa", with("azp", "synapse")) }, ""},
+		{"alg none", func() string { return p.sign(t, "none", "rsa", p.claims(nonce)) }, "unsupported signing algorithm"},
+		{"HS256 with the public key", func() string { return p.sign(t, "HS256", "rsa", p.claims(nonce)) }, "unsupported signing algorithm"},
+		{"RS256 header on the EC key", func() string {
+			parts := strings.Split(p.sign(t, "ES256", "ec", p.claims(nonce)), ".")
+			h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "ec"})
+			return base64.RawURLEncoding.EncodeToString(h) + "." + parts[1] + "." + parts[2]
+		}, "does not match algorithm"},
+		{"tampered claims", func() string {
+			parts := strings.Split(p.sign(t, "RS256", "rsa", p.claims(nonce)), ".")
+			c, _ := json.Marshal(with("sub", "admin"))
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(c) + "." + parts[2]
+		}, "bad signature"},
+		{"unknown kid", func() string { return p.sign(t, "RS256", "rotated", p.claims(nonce)) }, "unknown signing key"},
+		{"encryption key", func() string { return p.sign(t, "RS256", "enc", p.claims(nonce)) }, "unknown signing key"},
+		{"wrong issuer", func() string { return p.sign(t, "RS256", "rsa", with("iss", "https://evil.example")) }, "issuer"},
+		{"wrong audience", func() string { return p.sign(t, "RS256", "rsa", with("aud", "other-app")) }, "not issued for this client"},
+		{"no audience", func() string { return p.sign(t, "RS256", "rsa", with("aud", nil)) }, "not issued for this client"},
+		{"other azp", func() string { return p.sign(t, "RS256", "rsa", with("azp", "other-app")) }, "another party"},
+		{"expired", func() string {
+			return p.sign(t, "RS256", "rsa", with("exp", time.Now().Add(-2*time.Minute).Unix()))
+		}, "expired"},
+		{"expired within skew", func() string {
+			return p.sign(t, "RS256", "rsa", with("exp", time.Now().Add(-30*time.Second).Unix()))
+		}, ""},
+		{"no exp", func() string { return p.sign(t, "RS256", "rsa", with("exp", nil)) }, "expired"},
+		{"issued in the future", func() string {
+			return p.sign(t, "RS256", "rsa", with("iat", time.Now().Add(time.Hour).Unix()))
+		}, "future"},
+		{"wrong nonce", func() string { return p.sign(t, "RS256", "rsa", with("nonce", "replayed")) }, "nonce"},
+		{"no nonce", func() string { return p.sign(t, "RS256", "rsa", with("nonce", nil)) }, "nonce"},
+		{"not a JWT", func() string { return "abc.def" }, "not a JWT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := o.verifyIDToken(ctx, disc, tt.token(), nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verifyIDToken: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Errorf("claims = %v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verifyIDToken error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCIdentity(t *testing.T) {
+	o := NewOIDCService()
+	o.GroupRoles = []GroupRole{{Group: "fraud-leads", Role: "supervisor"}, {Group: "fraud-team", Role: "analyst"}}
+	base := func() map[string]any {
+		return map[string]any{"iss": "https://idp.example", "sub": "user-1", "email": "alice@bank.example", "email_verified": true}
+	}
+
+	tests := []struct {
+		name        string
+		change      func(c map[string]any)
+		defaultRole string
+		wantUser    string
+		wantRole    string
+		wantErr     error
+	}{
+		{"first matching group wins", func(c map[string]any) { c["groups"] = []any{"fraud-team", "fraud-leads"} }, "", "alice@bank.example", "supervisor", nil},
+		{"single group string", func(c map[string]any) { c["groups"] = "fraud-team" }, "", "alice@bank.example", "analyst", nil},
+		{"no mapped group", func(c map[string]any) { c["groups"] = []any{"marketing"} }, "", "", "", ErrOIDCNoRole},
+		{"no groups claim", func(c map[string]any) {}, "", "", "", ErrOIDCNoRole},
+		{"default role", func(c map[string]any) { c["groups"] = []any{"marketing"} }, "viewer", "alice@bank.example", "viewer", nil},
+		{"email_verified as string", func(c map[string]any) { c["email_verified"] = "true"; c["groups"] = "fraud-team" }, "", "alice@bank.example", "analyst", nil},
+		{"email not verified", func(c map[string]any) { c["email_verified"] = false; c["groups"] = "fraud-team" }, "", "", "", ErrOIDCEmail},
+		{"email_verified missing", func(c map[string]any) { delete(c, "email_verified"); c["groups"] = "fraud-team" }, "", "", "", ErrOIDCEmail},
+		{"preferred_username needs no verification", func(c map[string]any) {
+			delete(c, "email")
+			delete(c, "email_verified")
+			c["preferred_username"] = "alice"
+			c["groups"] = "fraud-team"
+		}, "", "alice", "analyst", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o.DefaultRole = tt.defaultRole
+			claims := base()
+			tt.change(claims)
+			id, err := o.identity(claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("identity error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if id.Username != tt.wantUser || id.Role != tt.wantRole || id.Issuer != "https://idp.example" || id.Subject != "user-1" {
+				t.Errorf("identity = %+v", id)
+			}
+		})
+	}
+
+	if _, err := o.identity(map[string]any{"sub": "user-1", "groups": "fraud-team"}); err == nil {
+		t.Error("identity accepted claims without a username")
+	}
+	if _, err := o.identity(map[string]any{"email": "alice@bank.example", "email_verified": true, "groups": "fraud-team"}); err == nil {
+		t.Error("identity accepted claims without sub")
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	p := newTestProvider(t)
+	o := p.service()
+	ctx := context.Background()
+	p.idToken = func(nonce string) string { return p.sign(t, "RS256", "rsa", p.claims(nonce)) }
+
+	// start reproduces the browser leaving for the provider and returns the
+	// state and state cookie
+	start := func(t *testing.T) (string, string) {
+		t.Helper()
+		authURL, cookie, err := o.AuthCodeURL(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(authURL)
+		q := u.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "synapse" || q.Get("redirect_uri") != o.RedirectURL {
+			t.Fatalf("authorization URL %s", authURL)
+		}
+		p.challenge, p.nonce = q.Get("code_challenge"), q.Get("nonce")
+		return q.Get("state"), cookie
+	}
+
+	tests := []struct {
+		name    string
+		run     func(t *testing.T, state, cookie string) (*OIDCIdentity, error)
+		wantErr error
+	}{
+		{"completes", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			return o.Exchange(ctx, cookie, state, "good-code")
+		}, nil},
+		{"no cookie", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			return o.Exchange(ctx, "", state, "good-code")
+		}, ErrOIDCState},
+		{"another browser's cookie", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			_, other := start(t)
+			return o.Exchange(ctx, other, state, "good-code")
+		}, ErrOIDCState},
+		{"forged cookie", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			return o.Exchange(ctx, state+".AAAA", state, "good-code")
+		}, ErrOIDCState},
+		{"unknown state", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			forged := "forged"
+			return o.Exchange(ctx, o.stateCookie(forged), forged, "good-code")
+		}, ErrOIDCState},
+		{"state used twice", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			if _, err := o.Exchange(ctx, cookie, state, "good-code"); err != nil {
+				t.Fatal(err)
+			}
+			return o.Exchange(ctx, cookie, state, "good-code")
+		}, ErrOIDCState},
+		{"expired sign-on", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			o.mu.Lock()
+			login := o.pending[state]
+			login.started = time.Now().Add(-o.LoginTTL - time.Second)
+			o.pending[state] = login
+			o.mu.Unlock()
+			return o.Exchange(ctx, cookie, state, "good-code")
+		}, ErrOIDCState},
+		{"no code", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			return o.Exchange(ctx, cookie, state, "")
+		}, errAny},
+		{"wrong PKCE verifier", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			p.challenge = "someone-else"
+			return o.Exchange(ctx, cookie, state, "good-code")
+		}, errAny},
+		{"token for another sign-on", func(t *testing.T, state, cookie string) (*OIDCIdentity, error) {
+			p.nonce = "other-nonce"
+			return o.Exchange(ctx, cookie, state, "good-code")
+		}, errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, cookie := start(t)
+			id, err := tt.run(t, state, cookie)
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Fatal("Exchange succeeded")
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("Exchange error = %v, want %v", err, tt.wantErr)
+			case err == nil && (id.Subject != "user-1" || id.Issuer != p.URL || id.Username != "alice@bank.example" || id.Role != "analyst"):
+				t.Errorf("identity = %+v", id)
+			}
+		})
+	}
+
+	if _, _, err := NewOIDCService().AuthCodeURL(ctx); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("unconfigured AuthCodeURL = %v", err)
+	}
+}
+
+func TestProvisionExternalUser(t *testing.T) {
+	local := newTestUser(t, "admin")
+	identity := func(sub, username, role string) *OIDCIdentity {
+		return &OIDCIdentity{Issuer: "https://idp.example", Subject: sub, Username: username, Role: role}
+	}
+	bob := testUsername("bob")
+	bobSub, robert := "sub-"+bob, testUsername("robert")
+
+	// A provider user named like a local account must not get into it
+	if _, err := ProvisionExternalUser(identity("sub-"+local.Username, local.Username, "viewer"), DefaultTenant, "oidc"); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("local account adopted: %v", err)
+	}
+
+	first, err := ProvisionExternalUser(identity(bobSub, bob, "analyst"), "SCB", "oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.AuthSource != "oidc" || first.TenantID != "SCB" || first.Role != "analyst" {
+		t.Errorf("provisioned %+v", first)
+	}
+	if _, err := NewAuthService().Login(bob, "", "198.51.100.20"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("password login as a provisioned user: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		identity *OIDCIdentity
+		wantID   bool // the same user as first
+		wantRole string
+		wantErr  error
+	}{
+		{"same subject", identity(bobSub, bob, "analyst"), true, "analyst", nil},
+		{"role synced", identity(bobSub, bob, "supervisor"), true, "supervisor", nil},
+		{"renamed at the provider", identity(bobSub, robert, "supervisor"), true, "supervisor", nil},
+		{"other subject, same name", identity("sub-"+testUsername("mallory"), bob, "admin"), false, "", ErrUserExists},
+		{"other issuer, same subject", &OIDCIdentity{Issuer: "https://evil.example", Subject: bobSub, Username: bob, Role: "admin"}, false, "", ErrUserExists},
+		{"no subject", identity("", bob, "analyst"), false, "", errAny},
+		{"unknown role", identity(bobSub, bob, "root"), false, "", ErrUnknownRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := ProvisionExternalUser(tt.identity, DefaultTenant, "oidc")
+			if tt.wantErr == errAny {
+				if err == nil {
+					t.Fatalf("ProvisionExternalUser = %+v", user)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProvisionExternalUser error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if tt.wantID != (user.ID == first.ID) || user.Role != tt.wantRole || user.TenantID != "SCB" {
+				t.Errorf("got %+v, want user %d with role %s", user, first.ID, tt.wantRole)
+			}
+		})
+	}
+	if n := auditCount(t, "USER_ROLE_SYNCED "+bob+" "); n != 1 {
+		t.Errorf("%d role sync audit entries", n)
+	}
+
+	if _, err := SetUserDisabled(first.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProvisionExternalUser(identity(bobSub, bob, "analyst"), DefaultTenant, "oidc"); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("disabled user signed in: %v", err)
+	}
+	if _, err := NewAuthService().LoginExternal(models.User{Username: bob, Disabled: true}, "198.51.100.20"); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("LoginExternal of a disabled user: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var (
+	ErrOverrideNotFound = errors.New("override not found")
+	ErrOverrideInactive = errors.New("override is no longer active")
+)
+
+// overrideActions ranks the override actions from weakest to strictest
+var overrideActions = map[string]int{"Allow": 0, "Review": 1, "Block": 2}
+
+// OverrideService stores analyst allow/review/block overrides and expires them.
+// Lookups also check expires_at, so an override stops applying the moment it
+// expires even if the sweep that marks it EXPIRED has not run yet.
+type OverrideService struct {
+	Interval time.Duration // how often expired overrides are swept and audited
+}
+
+func NewOverrideService() *OverrideService {
+	return &OverrideService{Interval: time.Minute}
+}
+
+// Start sweeps expired overrides on every tick
+func (o *OverrideService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(o.Interval)
+		defer ticker.Stop()
+		for {
+			if n, err := o.ExpireOverrides(); err != nil {
+				log.Printf("Override expiry failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Expired %d overrides", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ValidateOverride checks and normalises a proposed override without storing it
+func ValidateOverride(ov models.Override) (models.Override, error) {
+	ov.EntityType = strings.ToLower(strings.TrimSpace(ov.EntityType))
+	switch ov.EntityType {
+	case "account", "device", "ip":
+	default:
+		return ov, fmt.Errorf("entity_type must be account, device or ip")
+	}
+	ov.EntityID = strings.TrimSpace(ov.EntityID)
+	if ov.EntityID == "" {
+		return ov, errors.New("entity_id is required")
+	}
+	action := strings.ToUpper(strings.TrimSpace(ov.Action))
+	ov.Action = ""
+	for a := range overrideActions {
+		if strings.ToUpper(a) == action {
+			ov.Action = a
+		}
+	}
+	if ov.Action == "" {
+		return ov, fmt.Errorf("action must be Allow, Review or Block")
+	}
+	ov.Reason = strings.TrimSpace(ov.Reason)
+	ov.Author = strings.TrimSpace(ov.Author)
+	if ov.Reason == "" || ov.Author == "" {
+		return ov, errors.New("reason and author are required")
+	}
+	if ov.ExpiresAt != nil {
+		if !ov.ExpiresAt.After(time.Now()) {
+			return ov, errors.New("expires_at must be in the future")
+		}
+		t := ov.ExpiresAt.UTC()
+		ov.ExpiresAt = &t
+	}
+	return ov, nil
+}
+
+// CreateOverride validates and stores a new active override for ov.TenantID
+func (o *OverrideService) CreateOverride(ov models.Override) (models.Override, error) {
+	ov, err := ValidateOverride(ov)
+	if err != nil {
+		return ov, err
+	}
+	if ov.TenantID == "" {
+		return ov, errors.New("override needs a tenant")
+	}
+	ov.CreatedAt = time.Now().UTC()
+	ov.Status = models.OverrideActive
+	ov.RevokedBy, ov.RevokedAt = "", nil
+
+	res, err := db.DB.Exec(`
+		INSERT INTO overrides (tenant_id, entity_type, entity_id, action, reason, author, status, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, ov.TenantID, ov.EntityType, ov.EntityID, ov.Action, ov.Reason, ov.Author, ov.Status, nullTime(ov.ExpiresAt), ov.CreatedAt)
+	if err != nil {
+		return ov, err
+	}
+	if ov.OverrideID, err = res.LastInsertId(); err != nil {
+		return ov, err
+	}
+
+	until := "no expiry"
+	if ov.ExpiresAt != nil {
+		until = "until " + ov.ExpiresAt.Format(time.RFC3339)
+	}
+	RecordAudit("", fmt.Sprintf("OVERRIDE_CREATED #%d %s:%s %s by %s (%s): %s",
+		ov.OverrideID, ov.EntityType, ov.EntityID, ov.Action, ov.Author, until, ov.Reason))
+	return ov, nil
+}
+
+// RevokeOverride ends one of tenant's active overrides early
+func (o *OverrideService) RevokeOverride(tenant string, id int64, by string) (*models.Override, error) {
+	ov, err := GetOverride(tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if ov.Status != models.OverrideActive {
+		return ov, ErrOverrideInactive
+	}
+	now := time.Now().UTC()
+	res, err := db.DB.Exec(`
+		UPDATE overrides SET status = ?, revoked_by = ?, revoked_at = ?
+		WHERE override_id = ? AND status = ?
+	`, models.OverrideRevoked, by, now, id, models.OverrideActive)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ov, ErrOverrideInactive
+	}
+	ov.Status, ov.RevokedBy, ov.RevokedAt = models.OverrideRevoked, by, &now
+	RecordAudit("", fmt.Sprintf("OVERRIDE_REVOKED #%d %s:%s %s by %s", ov.OverrideID, ov.EntityType, ov.EntityID, ov.Action, by))
+	return ov, nil
+}
+
+// ExpireOverrides marks active overrides past their expiry as EXPIRED and audits each
+func (o *OverrideService) ExpireOverrides() (int, error) {
+	now := time.Now().UTC()
+	rows, err := db.DB.Query(`SELECT `+overrideColumns+` FROM overrides WHERE status = ? AND expires_at IS NOT NULL AND expires_at <= ?`,
+		models.OverrideActive, now)
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.Override
+	for rows.Next() {
+		if ov, err := scanOverride(rows); err == nil {
+			expired = append(expired, ov)
+		}
+	}
+	rows.Close()
+
+	n := 0
+	for _, ov := range expired {
+		res, err := db.DB.Exec("UPDATE overrides SET status = ? WHERE override_id = ? AND status = ?",
+			models.OverrideExpired, ov.OverrideID, models.OverrideActive)
+		if err != nil {
+			return n, err
+		}
+		if changed, _ := res.RowsAffected(); changed > 0 {
+			n++
+			RecordAudit("", fmt.Sprintf("OVERRIDE_EXPIRED #%d %s:%s %s", ov.OverrideID, ov.EntityType, ov.EntityID, ov.Action))
+		}
+	}
+	return n, nil
+}
+
+// MatchOverrides returns the active overrides the transaction's tenant holds on
+// its accounts, device and IPs, strictest first
+func (o *OverrideService) MatchOverrides(txn models.Transaction) ([]models.Override, error) {
+	type entity struct{ kind, id string }
+	var entities []entity
+	for _, e := range []entity{
+		{"account", txn.SenderAccount},
+		{"account", txn.ReceiverAccount},
+		{"device", txn.DeviceID},
+		{"ip", txn.SenderIP},
+		{"ip", txn.ReceiverIP},
+	} {
+		if e.id != "" {
+			entities = append(entities, e)
+		}
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	conds := make([]string, len(entities))
+	args := []any{txn.TenantID, models.OverrideActive, time.Now().UTC()}
+	for i, e := range entities {
+		conds[i] = "(entity_type = ? AND entity_id = ?)"
+		args = append(args, e.kind, e.id)
+	}
+	rows, err := db.DB.Query(`
+		SELECT `+overrideColumns+` FROM overrides
+		WHERE tenant_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?) AND (`+strings.Join(conds, " OR ")+`)
+		ORDER BY override_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matched []models.Override
+	for rows.Next() {
+		if ov, err := scanOverride(rows); err == nil {
+			matched = append(matched, ov)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return overrideActions[matched[i].Action] > overrideActions[matched[j].Action]
+	})
+	return matched, rows.Err()
+}
+
+// ListOverrides returns tenant's overrides newest first, optionally for one
+// entity and only those still in force
+func ListOverrides(tenant, entityType, entityID string, activeOnly bool, limit int) ([]models.Override, error) {
+	query := `SELECT ` + overrideColumns + ` FROM overrides WHERE tenant_id = ?`
+	args := []any{tenant}
+	if entityType != "" {
+		query += " AND entity_type = ?"
+		args = append(args, strings.ToLower(entityType))
+	}
+	if entityID != "" {
+		query += " AND entity_id = ?"
+		args = append(args, entityID)
+	}
+	if activeOnly {
+		query += " AND status = ? AND (expires_at IS NULL OR expires_at > ?)"
+		args = append(args, models.OverrideActive, time.Now().UTC())
+	}
+	query += " ORDER BY override_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	overrides := []models.Override{}
+	for rows.Next() {
+		ov, err := scanOverride(rows)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, ov)
+	}
+	return overrides, rows.Err()
+}
+
+// GetOverride loads one of tenant's overrides by ID
+func GetOverride(tenant string, id int64) (*models.Override, error) {
+	ov, err := scanOverride(db.DB.QueryRow(`SELECT `+overrideColumns+` FROM overrides WHERE override_id = ? AND tenant_id = ?`, id, tenant))
+	if err == sql.ErrNoRows {
+		return nil, ErrOverrideNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ov, nil
+}
+
+// overrideColumns is the column list read by scanOverride
+const overrideColumns = `override_id, coalesce(tenant_id, ''), entity_type, entity_id, action, reason, author, status, expires_at, created_at,
+	coalesce(revoked_by, ''), revoked_at`
+
+func scanOverride(row interface{ Scan(...any) error }) (models.Override, error) {
+	var ov models.Override
+	var expires, revoked sql.NullTime
+	err := row.Scan(&ov.OverrideID, &ov.TenantID, &ov.EntityType, &ov.EntityID, &ov.Action, &ov.Reason, &ov.Author, &ov.Status,
+		&expires, &ov.CreatedAt, &ov.RevokedBy, &revoked)
+	if expires.Valid {
+		ov.ExpiresAt = &expires.Time
+	}
+	if revoked.Valid {
+		ov.RevokedAt = &revoked.Time
+	}
+	return ov, err
+}
+
+func nullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
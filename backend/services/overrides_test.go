@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// auditCount returns how many audit entries start with prefix
+func auditCount(t *testing.T, prefix string) int {
+	t.Helper()
+	var n int
+	if err := db.DB.QueryRow("SELECT count(*) FROM audit_logs WHERE action LIKE ?", prefix+"%").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCreateOverride(t *testing.T) {
+	o := NewOverrideService()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	valid := models.Override{TenantID: DefaultTenant, EntityType: "account", EntityID: "ACC-1", Action: "block", Reason: "mule", Author: "analyst"}
+	tests := []struct {
+		name   string
+		change func(*models.Override)
+		ok     bool
+	}{
+		{"valid", func(*models.Override) {}, true},
+		{"IP with expiry", func(ov *models.Override) { ov.EntityType, ov.ExpiresAt = " IP ", &future }, true},
+		{"unknown entity type", func(ov *models.Override) { ov.EntityType = "email" }, false},
+		{"no entity", func(ov *models.Override) { ov.EntityID = " " }, false},
+		{"unknown action", func(ov *models.Override) { ov.Action = "Approve" }, false},
+		{"no reason", func(ov *models.Override) { ov.Reason = "" }, false},
+		{"no author", func(ov *models.Override) { ov.Author = "" }, false},
+		{"no tenant", func(ov *models.Override) { ov.TenantID = "" }, false},
+		{"expiry in the past", func(ov *models.Override) { ov.ExpiresAt = &past }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ov := valid
+			ov.EntityID = testID("OVR")
+			tt.change(&ov)
+			got, err := o.CreateOverride(ov)
+			if (err == nil) != tt.ok {
+				t.Fatalf("CreateOverride = %+v, %v", got, err)
+			}
+			if !tt.ok {
+				return
+			}
+			if got.OverrideID == 0 || got.Status != models.OverrideActive || got.Action != "Block" {
+				t.Errorf("override = %+v", got)
+			}
+			if n := auditCount(t, fmt.Sprintf("OVERRIDE_CREATED #%d ", got.OverrideID)); n != 1 {
+				t.Errorf("%d audit entries", n)
+			}
+		})
+	}
+}
+
+func TestMatchOverrides(t *testing.T) {
+	o := NewOverrideService()
+	sender, receiver, device, ip := testID("OVR"), testID("OVR"), testID("DEV"), testID("IP")
+	create := func(entityType, entityID, action string) models.Override {
+		ov, err := o.CreateOverride(models.Override{TenantID: DefaultTenant, EntityType: entityType, EntityID: entityID, Action: action, Reason: "test", Author: "analyst"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ov
+	}
+	allow := create("account", sender, "Allow")
+	review := create("device", device, "Review")
+	block := create("ip", ip, "Block")
+	revoked := create("account", receiver, "Block")
+	if _, err := o.RevokeOverride(DefaultTenant, revoked.OverrideID, "supervisor"); err != nil {
+		t.Fatal(err)
+	}
+	create("account", device, "Block") // same ID, different entity type
+
+	tests := []struct {
+		name string
+		txn  models.Transaction
+		want []int64
+	}{
+		{"strictest first", models.Transaction{TenantID: DefaultTenant, SenderAccount: sender, ReceiverAccount: receiver, DeviceID: device, ReceiverIP: ip},
+			[]int64{block.OverrideID, review.OverrideID, allow.OverrideID}},
+		{"sender only", models.Transaction{TenantID: DefaultTenant, SenderAccount: sender, ReceiverAccount: testID("OVR")}, []int64{allow.OverrideID}},
+		{"revoked override", models.Transaction{TenantID: DefaultTenant, SenderAccount: testID("OVR"), ReceiverAccount: receiver}, nil},
+		{"nothing to match", models.Transaction{TenantID: DefaultTenant}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := o.MatchOverrides(tt.txn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, ov := range matched {
+				got = append(got, ov.OverrideID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideLifecycle(t *testing.T) {
+	o := NewOverrideService()
+	account := testID("OVR")
+	expiry := time.Now().Add(time.Hour)
+	ov, err := o.CreateOverride(models.Override{TenantID: DefaultTenant, EntityType: "account", EntityID: account, Action: "Block", Reason: "test", Author: "analyst", ExpiresAt: &expiry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := models.Transaction{TenantID: DefaultTenant, SenderAccount: account}
+
+	// Past its expiry the override stops applying before the sweep marks it
+	if _, err := db.DB.Exec("UPDATE overrides SET expires_at = ? WHERE override_id = ?", time.Now().Add(-time.Second).UTC(), ov.OverrideID); err != nil {
+		t.Fatal(err)
+	}
+	if matched, _ := o.MatchOverrides(txn); len(matched) != 0 {
+		t.Errorf("expired override matched: %+v", matched)
+	}
+	if active, _ := ListOverrides(DefaultTenant, "account", account, true, 10); len(active) != 0 {
+		t.Errorf("expired override listed as active: %+v", active)
+	}
+
+	if n, err := o.ExpireOverrides(); err != nil || n < 1 {
+		t.Fatalf("ExpireOverrides = %d, %v", n, err)
+	}
+	got, err := GetOverride(DefaultTenant, ov.OverrideID)
+	if err != nil || got.Status != models.OverrideExpired {
+		t.Fatalf("override = %+v, %v", got, err)
+	}
+	if n := auditCount(t, fmt.Sprintf("OVERRIDE_EXPIRED #%d ", ov.OverrideID)); n != 1 {
+		t.Errorf("%d expiry audit entries", n)
+	}
+	if _, err := o.RevokeOverride(DefaultTenant, ov.OverrideID, "supervisor"); !errors.Is(err, ErrOverrideInactive) {
+		t.Errorf("revoking an expired override: %v", err)
+	}
+	if _, err := o.RevokeOverride(DefaultTenant, -1, "supervisor"); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("revoking a missing override: %v", err)
+	}
+
+	all, err := ListOverrides(DefaultTenant, "ACCOUNT", account, false, 10)
+	if err != nil || len(all) != 1 || all[0].OverrideID != ov.OverrideID {
+		t.Errorf("ListOverrides = %+v, %v", all, err)
+	}
+}
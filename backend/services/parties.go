@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// fuzzyNameThreshold is the Jaro-Winkler similarity needed to link on name alone
+const fuzzyNameThreshold = 0.93
+
+// partyMu serialises resolution so concurrent ingests cannot create duplicate parties
+var partyMu sync.Mutex
+
+type partyRecord struct {
+	id, nationalID, name, normalizedName, phone, proxy string
+}
+
+// ResolveParty links an account to the party described by attrs, matching an
+// existing party by national ID hash, PromptPay proxy or phone first and by fuzzy
+// name second, or creating a new one. When a shared identifier shows two parties
+// are the same person they are merged. Returns the party ID, or "" when
+// attrs carry nothing to resolve on.
+//
+// Resolution is per tenant. A party gathers the accounts one tenant has sent
+// attributes for, and those may be held at any bank, so one bank still sees a
+// herder's SCB, KBANK and KTB accounts as one person. What two tenants know
+// about the same person is never linked: that would hand each bank the
+// other's customer data. Across tenants only the consortium view's shared
+// signals are exchanged (see ConsortiumAccounts).
+func (s *Neo4jService) ResolveParty(ctx context.Context, tenant, accountID string, attrs models.PartyAttributes) (string, error) {
+	in := partyRecord{
+		nationalID:     strings.ToLower(strings.TrimSpace(attrs.NationalIDHash)),
+		name:           strings.TrimSpace(attrs.Name),
+		normalizedName: normalizeName(attrs.Name),
+		phone:          normalizePhone(attrs.Phone),
+		proxy:          normalizeProxy(attrs.PromptPayProxy),
+	}
+	if accountID == "" || (in.nationalID == "" && in.normalizedName == "" && in.phone == "" && in.proxy == "") {
+		return "", nil
+	}
+
+	partyMu.Lock()
+	defer partyMu.Unlock()
+
+	existing, _ := partyIDForAccount(tenant, accountID)
+	partyID, rule, confidence, err := matchParty(tenant, in)
+	if err != nil {
+		return "", err
+	}
+
+	var merged string
+	switch {
+	case existing != "" && (partyID == "" || rule == "fuzzy_name"):
+		// A name match alone is not strong enough to merge two known parties
+		partyID = existing
+	case partyID == "":
+		partyID, rule, confidence = newPartyID(), "new", 1.0
+		if _, err := db.DB.Exec("INSERT INTO parties (party_id, tenant_id, created_at) VALUES (?, ?, ?)", partyID, tenant, time.Now()); err != nil {
+			return "", err
+		}
+	case existing != "" && existing != partyID:
+		if err := mergePartiesSQLite(existing, partyID); err != nil {
+			return "", err
+		}
+		merged = existing
+	}
+
+	if err := updatePartyAttributes(partyID, in); err != nil {
+		return "", err
+	}
+	if existing == "" || merged != "" {
+		_, err := db.DB.Exec(`
+			INSERT INTO party_accounts (tenant_id, account_id, party_id, match_rule, confidence, linked_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(tenant_id, account_id) DO UPDATE SET
+				party_id = excluded.party_id,
+				match_rule = excluded.match_rule,
+				confidence = excluded.confidence,
+				linked_at = excluded.linked_at
+		`, tenant, accountID, partyID, rule, confidence, time.Now())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if s.Connected {
+		if err := s.savePartyNeo4j(ctx, tenant, partyID, merged, accountID); err != nil {
+			return partyID, err
+		}
+	}
+	return partyID, nil
+}
+
+// matchParty finds an existing party of tenant for the attributes, deterministic keys first
+func matchParty(tenant string, in partyRecord) (string, string, float64, error) {
+	keys := []struct{ column, value, rule string }{
+		{"national_id_hash", in.nationalID, "national_id"},
+		{"promptpay_proxy", in.proxy, "promptpay"},
+		{"phone", in.phone, "phone"},
+	}
+	for _, k := range keys {
+		if k.value == "" {
+			continue
+		}
+		var id string
+		err := db.DB.QueryRow("SELECT party_id FROM parties WHERE tenant_id = ? AND "+k.column+" = ? ORDER BY created_at LIMIT 1", tenant, k.value).Scan(&id)
+		if err == nil {
+			return id, k.rule, 1.0, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", "", 0, err
+		}
+	}
+	if in.normalizedName == "" {
+		return "", "", 0, nil
+	}
+
+	// Block on shared name tokens, then score against every name variant seen for the party
+	tokens := nameTokens(in.name)
+	rows, err := db.DB.Query(`
+		SELECT DISTINCT p.party_id, coalesce(p.national_id_hash, ''), t.normalized_name, coalesce(p.phone, ''), coalesce(p.promptpay_proxy, '')
+		FROM party_name_tokens t
+		JOIN parties p ON p.party_id = t.party_id
+		WHERE p.tenant_id = ? AND t.token IN (`+placeholders(len(tokens))+`)
+	`, append([]any{tenant}, stringArgs(tokens)...)...)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer rows.Close()
+
+	best, bestScore := "", 0.0
+	for rows.Next() {
+		var c partyRecord
+		if err := rows.Scan(&c.id, &c.nationalID, &c.normalizedName, &c.phone, &c.proxy); err != nil {
+			continue
+		}
+		if conflicts(in, c) {
+			continue
+		}
+		if score := jaroWinkler(in.normalizedName, c.normalizedName); score >= fuzzyNameThreshold && score > bestScore {
+			best, bestScore = c.id, score
+		}
+	}
+	if best == "" {
+		return "", "", 0, nil
+	}
+	return best, "fuzzy_name", bestScore, nil
+}
+
+// conflicts reports whether two parties carry different values for a strong identifier
+func conflicts(a, b partyRecord) bool {
+	differ := func(x, y string) bool { return x != "" && y != "" && x != y }
+	return differ(a.nationalID, b.nationalID) || differ(a.phone, b.phone) || differ(a.proxy, b.proxy)
+}
+
+// updatePartyAttributes fills in attributes the party does not have yet and records the name variant
+func updatePartyAttributes(partyID string, in partyRecord) error {
+	_, err := db.DB.Exec(`
+		UPDATE parties SET
+			national_id_hash = coalesce(nullif(national_id_hash, ''), nullif(?, '')),
+			name = coalesce(nullif(name, ''), nullif(?, '')),
+			normalized_name = coalesce(nullif(normalized_name, ''), nullif(?, '')),
+			phone = coalesce(nullif(phone, ''), nullif(?, '')),
+			promptpay_proxy = coalesce(nullif(promptpay_proxy, ''), nullif(?, ''))
+		WHERE party_id = ?
+	`, in.nationalID, in.name, in.normalizedName, in.phone, in.proxy, partyID)
+	if err != nil {
+		return err
+	}
+	return addPartyNameVariant(partyID, in.name)
+}
+
+// addPartyNameVariant indexes a name under each of its tokens, so a party
+// known by both Thai and Latin spellings can be matched by either
+func addPartyNameVariant(partyID, name string) error {
+	normalized := normalizeName(name)
+	for _, t := range nameTokens(name) {
+		if _, err := db.DB.Exec("INSERT OR IGNORE INTO party_name_tokens (token, party_id, normalized_name) VALUES (?, ?, ?)", t, partyID, normalized); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePartiesSQLite moves every account and name token of drop onto keep
+func mergePartiesSQLite(drop, keep string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"UPDATE party_accounts SET party_id = ? WHERE party_id = ?",
+		"UPDATE OR IGNORE party_name_tokens SET party_id = ? WHERE party_id = ?",
+	}
+	for _, q := range statements {
+		if _, err := tx.Exec(q, keep, drop); err != nil {
+			return err
+		}
+	}
+	var d partyRecord
+	err = tx.QueryRow(`
+		SELECT coalesce(national_id_hash, ''), coalesce(name, ''), coalesce(normalized_name, ''), coalesce(phone, ''), coalesce(promptpay_proxy, '')
+		FROM parties WHERE party_id = ?
+	`, drop).Scan(&d.nationalID, &d.name, &d.normalizedName, &d.phone, &d.proxy)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM party_name_tokens WHERE party_id = ?", drop); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM parties WHERE party_id = ?", drop); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return updatePartyAttributes(keep, d)
+}
+
+func (s *Neo4jService) savePartyNeo4j(ctx context.Context, tenant, partyID, merged, accountID string) error {
+	party, err := GetParty(tenant, partyID)
+	if err != nil {
+		return err
+	}
+	link := models.PartyAccount{}
+	for _, a := range party.Accounts {
+		if a.AccountID == accountID {
+			link = a
+		}
+	}
+
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	_, err = session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		if merged != "" {
+			mergeQuery := `
+				MATCH (drop:Party {id: $drop})
+				MERGE (keep:Party {id: $keep})
+				WITH drop, keep
+				OPTIONAL MATCH (drop)-[o:OWNS]->(a:Account)
+				FOREACH (_ IN CASE WHEN a IS NULL THEN [] ELSE [1] END |
+					MERGE (keep)-[n:OWNS]->(a)
+					SET n.match_rule = o.match_rule, n.confidence = o.confidence)
+				WITH DISTINCT drop
+				DETACH DELETE drop
+			`
+			if _, err := tx.Run(ctx, mergeQuery, map[string]any{"drop": merged, "keep": partyID}); err != nil {
+				return nil, err
+			}
+		}
+		query := `
+			MERGE (p:Party {id: $party_id})
+			SET p.tenant = $tenant,
+			    p.national_id_hash = $national_id_hash,
+			    p.name = $name,
+			    p.phone = $phone,
+			    p.promptpay_proxy = $promptpay_proxy
+			MERGE (a:Account {tenant: $tenant, id: $account_id})
+			WITH p, a
+			OPTIONAL MATCH (other:Party)-[old:OWNS]->(a)
+			WHERE other.id <> $party_id
+			DELETE old
+			WITH DISTINCT p, a
+			MERGE (p)-[o:OWNS]->(a)
+			SET o.match_rule = $match_rule, o.confidence = $confidence
+		`
+		return tx.Run(ctx, query, map[string]any{
+			"tenant":           tenant,
+			"party_id":         party.PartyID,
+			"national_id_hash": party.NationalIDHash,
+			"name":             party.Name,
+			"phone":            party.Phone,
+			"promptpay_proxy":  party.PromptPayProxy,
+			"account_id":       accountID,
+			"match_rule":       link.MatchRule,
+			"confidence":       link.Confidence,
+		})
+	})
+	return err
+}
+
+func newPartyID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "PTY-" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+func partyIDForAccount(tenant, accountID string) (string, error) {
+	var id string
+	err := db.DB.QueryRow("SELECT party_id FROM party_accounts WHERE tenant_id = ? AND account_id = ?", tenant, accountID).Scan(&id)
+	return id, err
+}
+
+// GetParty loads one of tenant's parties with its linked accounts
+func GetParty(tenant, partyID string) (*models.Party, error) {
+	p := models.Party{PartyID: partyID, Accounts: []models.PartyAccount{}}
+	var createdAt sql.NullTime
+	err := db.DB.QueryRow(`
+		SELECT coalesce(national_id_hash, ''), coalesce(name, ''), coalesce(phone, ''), coalesce(promptpay_proxy, ''), created_at
+		FROM parties WHERE party_id = ? AND tenant_id = ?
+	`, partyID, tenant).Scan(&p.NationalIDHash, &p.Name, &p.Phone, &p.PromptPayProxy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	p.CreatedAt = createdAt.Time
+
+	rows, err := db.DB.Query(`
+		SELECT account_id, coalesce(match_rule, ''), coalesce(confidence, 0), linked_at
+		FROM party_accounts WHERE tenant_id = ? AND party_id = ? ORDER BY account_id
+	`, tenant, partyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.PartyAccount
+		var linkedAt sql.NullTime
+		if err := rows.Scan(&a.AccountID, &a.MatchRule, &a.Confidence, &linkedAt); err != nil {
+			continue
+		}
+		a.LinkedAt = linkedAt.Time
+		p.Accounts = append(p.Accounts, a)
+	}
+	return &p, nil
+}
+
+// GetAccountParty returns the party that owns one of tenant's accounts, or nil if unresolved
+func GetAccountParty(tenant, accountID string) (*models.Party, error) {
+	partyID, err := partyIDForAccount(tenant, accountID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetParty(tenant, partyID)
+}
+
+// PartyRiskSummary aggregates tenant's transaction risk across every account of a party
+func PartyRiskSummary(tenant string, party *models.Party) (map[string]any, error) {
+	accounts := make([]string, len(party.Accounts))
+	for i, a := range party.Accounts {
+		accounts[i] = a.AccountID
+	}
+	summary := map[string]any{
+		"account_count":         int64(len(accounts)),
+		"total_txns":            int64(0),
+		"incoming_tx_count":     int64(0),
+		"incoming_volume":       0.0,
+		"outgoing_volume":       0.0,
+		"avg_risk":              0.0,
+		"high_risk_txns":        int64(0),
+		"confirmed_fraud_count": int64(0),
+	}
+	if len(accounts) == 0 {
+		return summary, nil
+	}
+
+	in := placeholders(len(accounts))
+	args := append(stringArgs(accounts), stringArgs(accounts)...)
+	args = append(args, stringArgs(accounts)...)
+	args = append(args, tenant)
+	args = append(args, stringArgs(accounts)...)
+	args = append(args, stringArgs(accounts)...)
+
+	var total, incoming, highRisk, confirmed int64
+	var inVolume, outVolume, avgRisk float64
+	err := db.DB.QueryRow(fmt.Sprintf(`
+		SELECT count(*),
+		       coalesce(sum(receiver_account IN (%[1]s)), 0),
+		       coalesce(sum(CASE WHEN receiver_account IN (%[1]s) THEN amount ELSE 0 END), 0),
+		       coalesce(sum(CASE WHEN sender_account IN (%[1]s) THEN amount ELSE 0 END), 0),
+		       coalesce(avg(risk_score), 0),
+		       coalesce(sum(risk_score > 80), 0),
+		       coalesce(sum(verification_status = 'CONFIRMED_FRAUD'), 0)
+		FROM graph_transactions
+		WHERE tenant_id = ? AND (sender_account IN (%[1]s) OR receiver_account IN (%[1]s))
+	`, in), args...).Scan(&total, &incoming, &inVolume, &outVolume, &avgRisk, &highRisk, &confirmed)
+	if err != nil {
+		return summary, err
+	}
+
+	summary["total_txns"] = total
+	summary["incoming_tx_count"] = incoming
+	summary["incoming_volume"] = inVolume
+	summary["outgoing_volume"] = outVolume
+	summary["avg_risk"] = avgRisk
+	summary["high_risk_txns"] = highRisk
+	summary["confirmed_fraud_count"] = confirmed
+	return summary, nil
+}
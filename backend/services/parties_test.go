@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+// testPhone returns a mobile number no other test uses
+func testPhone() string {
+	return fmt.Sprintf("09%08d", seq.Add(1))
+}
+
+// testSurname returns a Latin family name no other test uses, different
+// enough that fuzzy name matching cannot link parties across tests or runs
+func testSurname() string {
+	sum := sha256.Sum256([]byte(testID("surname")))
+	name := make([]byte, 12)
+	for i := range name {
+		name[i] = 'a' + sum[i]%26
+	}
+	return string(name)
+}
+
+func TestResolveParty(t *testing.T) {
+	ctx := context.Background()
+	s := &Neo4jService{}
+	surname, phone, otherPhone := testSurname(), testPhone(), testPhone()
+	account := func() string { return testID("PTY-ACC") }
+
+	first := account()
+	party, err := s.ResolveParty(ctx, DefaultTenant, first, models.PartyAttributes{Name: "Somchai " + surname, Phone: phone})
+	if err != nil || party == "" {
+		t.Fatalf("ResolveParty = %q, %v", party, err)
+	}
+
+	tests := []struct {
+		name  string
+		attrs models.PartyAttributes
+		same  bool
+		rule  string
+	}{
+		{"phone in international form", models.PartyAttributes{Phone: "+66" + phone[1:]}, true, "phone"},
+		{"title and misspelt name", models.PartyAttributes{Name: "Mr. Somchay " + surname}, true, "fuzzy_name"},
+		{"name with a different phone", models.PartyAttributes{Name: "Somchai " + surname, Phone: otherPhone}, false, "new"},
+		{"different given name", models.PartyAttributes{Name: "Anan " + surname}, false, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := account()
+			got, err := s.ResolveParty(ctx, DefaultTenant, acc, tt.attrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == party) != tt.same {
+				t.Errorf("resolved to %s, first account's party %s", got, party)
+			}
+			p, err := GetAccountParty(DefaultTenant, acc)
+			if err != nil || p == nil {
+				t.Fatalf("GetAccountParty = %v, %v", p, err)
+			}
+			if len(p.Accounts) == 0 || p.PartyID != got {
+				t.Fatalf("party = %+v", p)
+			}
+			for _, a := range p.Accounts {
+				if a.AccountID == acc && a.MatchRule != tt.rule {
+					t.Errorf("matched by %q, want %q", a.MatchRule, tt.rule)
+				}
+			}
+		})
+	}
+
+	if got, err := s.ResolveParty(ctx, DefaultTenant, account(), models.PartyAttributes{Name: "Mr."}); got != "" || err != nil {
+		t.Errorf("title-only name resolved to %q, %v", got, err)
+	}
+	if p, err := GetAccountParty(DefaultTenant, account()); p != nil || err != nil {
+		t.Errorf("unresolved account has party %v, %v", p, err)
+	}
+}
+
+func TestResolvePartyPerTenant(t *testing.T) {
+	ctx := context.Background()
+	s := &Neo4jService{}
+	bank, other := newTestTenant(t), newTestTenant(t)
+	nationalID := testID("NID")
+	attrs := models.PartyAttributes{NationalIDHash: nationalID}
+
+	// One tenant links the person's accounts at different banks
+	party, err := s.ResolveParty(ctx, bank, testID("SCB"), attrs)
+	if err != nil || party == "" {
+		t.Fatalf("ResolveParty = %q, %v", party, err)
+	}
+	if got, err := s.ResolveParty(ctx, bank, testID("KBANK"), attrs); err != nil || got != party {
+		t.Errorf("account at another bank resolved to %q, %v, want %s", got, err, party)
+	}
+
+	// but never to what another tenant knows about them
+	theirs, err := s.ResolveParty(ctx, other, testID("KTB"), attrs)
+	if err != nil || theirs == "" || theirs == party {
+		t.Errorf("other tenant's account resolved to %q, %v", theirs, err)
+	}
+	if _, err := GetParty(other, party); err == nil {
+		t.Error("GetParty returned another tenant's party")
+	}
+	p, err := GetParty(bank, party)
+	if err != nil || len(p.Accounts) != 2 {
+		t.Errorf("GetParty = %+v, %v, want two accounts", p, err)
+	}
+}
+
+func TestResolvePartyMerges(t *testing.T) {
+	ctx := context.Background()
+	s := &Neo4jService{}
+	nationalID, proxy := testID("nid"), testPhone()
+	a, b := testID("PTY-ACC"), testID("PTY-ACC")
+
+	keep, err := s.ResolveParty(ctx, DefaultTenant, a, models.PartyAttributes{NationalIDHash: nationalID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop, err := s.ResolveParty(ctx, DefaultTenant, b, models.PartyAttributes{PromptPayProxy: proxy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keep == drop {
+		t.Fatalf("unrelated accounts share party %s", keep)
+	}
+
+	// b turns out to carry a's national ID, so the two parties are one person
+	got, err := s.ResolveParty(ctx, DefaultTenant, b, models.PartyAttributes{NationalIDHash: nationalID, PromptPayProxy: proxy})
+	if err != nil || got != keep {
+		t.Fatalf("ResolveParty = %q, %v; want %s", got, err, keep)
+	}
+	if _, err := GetParty(DefaultTenant, drop); err == nil {
+		t.Errorf("merged party %s still exists", drop)
+	}
+	p, err := GetParty(DefaultTenant, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Accounts) != 2 || p.PromptPayProxy != proxy || p.NationalIDHash != nationalID {
+		t.Errorf("merged party = %+v", p)
+	}
+
+	// Another account with the proxy now finds the merged party
+	if got, _ := s.ResolveParty(ctx, DefaultTenant, testID("PTY-ACC"), models.PartyAttributes{PromptPayProxy: proxy}); got != keep {
+		t.Errorf("proxy resolved to %s, want %s", got, keep)
+	}
+}
+
+func TestPartyRiskSummary(t *testing.T) {
+	ctx := context.Background()
+	s := &Neo4jService{}
+	nationalID := testID("nid")
+	a, b, other := testID("PTY-ACC"), testID("PTY-ACC"), testID("PTY-ACC")
+	for _, acc := range []string{a, b} {
+		if _, err := s.ResolveParty(ctx, DefaultTenant, acc, models.PartyAttributes{NationalIDHash: nationalID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := time.Now().Add(-time.Hour)
+	addTransfer(t, other, a, 1000, at)
+	addTransfer(t, a, b, 400, at)
+	addTransfer(t, b, other, 300, at)
+
+	party, err := GetAccountParty(DefaultTenant, a)
+	if err != nil || party == nil {
+		t.Fatalf("GetAccountParty = %v, %v", party, err)
+	}
+	summary, err := PartyRiskSummary(DefaultTenant, party)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"account_count":     int64(2),
+		"total_txns":        int64(3),
+		"incoming_tx_count": int64(2),
+		"incoming_volume":   1400.0,
+		"outgoing_volume":   700.0,
+	}
+	for k, v := range want {
+		if summary[k] != v {
+			t.Errorf("%s = %v, want %v", k, summary[k], v)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// PathOptions bounds a money path search
+type PathOptions struct {
+	MaxHops   int
+	K         int           // number of paths to return, shortest first
+	Since     time.Time     // ignore transfers before this, zero for no limit
+	MaxFanOut int           // most recent outgoing transfers considered per account
+	Timeout   time.Duration // give up on the search after this long; 0 = no limit
+}
+
+func DefaultPathOptions() PathOptions {
+	return PathOptions{MaxHops: 6, K: 1, MaxFanOut: 100, Timeout: 5 * time.Second}
+}
+
+// maxPathExpansions caps the number of partial paths the Go search will extend
+const maxPathExpansions = 50000
+
+// FindMoneyPaths returns up to opts.K time-respecting paths from one account to another
+// within tenant, fewest hops first and, for equal hops, earliest arrival first
+func (s *Neo4jService) FindMoneyPaths(ctx context.Context, tenant, from, to string, opts PathOptions) ([]models.MoneyPath, error) {
+	if from == to {
+		return nil, fmt.Errorf("source and destination accounts must differ")
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if !s.Connected {
+		return searchMoneyPaths(ctx, from, to, opts, sqliteEdges(tenant))
+	}
+
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		return searchMoneyPaths(ctx, from, to, opts, neo4jEdges(ctx, tx, tenant))
+	}, txTimeout(opts.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.MoneyPath), nil
+}
+
+// searchMoneyPaths is a breadth-first search over partial paths, so paths are
+// found in order of hop count. Only the MaxFanOut most recent transfers of an
+// account are followed, and a partial path is pruned when its account has
+// already been reached K times no later than it, since it cannot beat those.
+func searchMoneyPaths(ctx context.Context, from, to string, opts PathOptions, load edgeLoader) ([]models.MoneyPath, error) {
+	adjacency := map[string][]models.TransferEdge{}
+	outgoing := func(account string) ([]models.TransferEdge, error) {
+		if edges, ok := adjacency[account]; ok {
+			return edges, nil
+		}
+		edges, err := load(account, opts.Since, opts.MaxFanOut)
+		if err != nil {
+			return nil, err
+		}
+		adjacency[account] = edges
+		return edges, nil
+	}
+
+	type partial struct {
+		edges []models.TransferEdge
+	}
+	arrivals := map[string][]time.Time{}
+	dominated := func(account string, at time.Time) bool {
+		n := 0
+		for _, t := range arrivals[account] {
+			if !t.After(at) {
+				n++
+			}
+		}
+		return n >= opts.K
+	}
+
+	paths := []models.MoneyPath{}
+	queue := []partial{{}}
+	expansions := 0
+	for len(queue) > 0 && len(paths) < opts.K && expansions < maxPathExpansions {
+		// Drain one hop level at a time so shorter paths always come first
+		level := queue
+		queue = nil
+		var found []models.MoneyPath
+		for _, p := range level {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			account := from
+			var after time.Time
+			if len(p.edges) > 0 {
+				last := p.edges[len(p.edges)-1]
+				account, after = last.To, last.Timestamp
+			}
+			edges, err := outgoing(account)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range edges {
+				expansions++
+				if e.Timestamp.Before(after) || onPath(p.edges, from, e.To) {
+					continue
+				}
+				if dominated(e.To, e.Timestamp) {
+					continue
+				}
+				arrivals[e.To] = append(arrivals[e.To], e.Timestamp)
+
+				next := append(append([]models.TransferEdge{}, p.edges...), e)
+				if e.To == to {
+					found = append(found, newMoneyPath(next))
+				} else if len(next) < opts.MaxHops {
+					queue = append(queue, partial{edges: next})
+				}
+			}
+		}
+		sortPathsByArrival(found)
+		for _, p := range found {
+			if len(paths) < opts.K {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// onPath reports whether account already appears on a partial path starting at from
+func onPath(edges []models.TransferEdge, from, account string) bool {
+	if account == from {
+		return true
+	}
+	for _, e := range edges {
+		if e.To == account {
+			return true
+		}
+	}
+	return false
+}
+
+func sortPathsByArrival(paths []models.MoneyPath) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].EndedAt.Before(paths[j].EndedAt)
+	})
+}
+
+func newMoneyPath(edges []models.TransferEdge) models.MoneyPath {
+	p := models.MoneyPath{Edges: edges, HopCount: len(edges)}
+	if len(edges) == 0 {
+		return p
+	}
+	p.Accounts = append(p.Accounts, edges[0].From)
+	p.MinAmount = edges[0].Amount
+	for _, e := range edges {
+		p.Accounts = append(p.Accounts, e.To)
+		if e.Amount < p.MinAmount {
+			p.MinAmount = e.Amount
+		}
+	}
+	p.StartedAt = edges[0].Timestamp
+	p.EndedAt = edges[len(edges)-1].Timestamp
+	p.DurationSeconds = p.EndedAt.Sub(p.StartedAt).Seconds()
+	return p
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/models"
+)
+
+func TestFindMoneyPaths(t *testing.T) {
+	prefix := testID("PATH")
+	id := func(name string) string { return prefix + "-" + name }
+	at := time.Now().Add(-24 * time.Hour)
+	for _, h := range []struct {
+		from, to string
+		minutes  int
+	}{
+		{"s", "t", 50},                // direct, but late
+		{"s", "a", 0}, {"a", "t", 10}, // two hops, arrives at 10
+		{"s", "b", 0}, {"b", "t", 20}, // two hops, arrives at 20
+		{"s", "c", 30}, {"c", "t", 5}, // second hop is earlier than the first
+		{"s", "d", 0}, {"d", "e", 1}, {"e", "t", 2}, // three hops
+		{"s", "x", 0}, {"s", "y", 1}, {"x", "z", 2}, {"y", "z", 3}, {"z", "u", 4}, // z is reached twice
+	} {
+		addTransfer(t, id(h.from), id(h.to), 1000, at.Add(time.Duration(h.minutes)*time.Minute))
+	}
+
+	tests := []struct {
+		name string
+		to   string
+		opts PathOptions
+		want []string // accounts of each path, joined
+	}{
+		{"fewest hops first", "t", PathOptions{MaxHops: 6, K: 1, MaxFanOut: 100}, []string{"s t"}},
+		{"equal hops by arrival", "t", PathOptions{MaxHops: 6, K: 3, MaxFanOut: 100}, []string{"s t", "s a t", "s b t"}},
+		{"every time-respecting path", "t", PathOptions{MaxHops: 6, K: 10, MaxFanOut: 100}, []string{"s t", "s a t", "s b t", "s d e t"}},
+		{"hop limit", "t", PathOptions{MaxHops: 2, K: 10, MaxFanOut: 100}, []string{"s t", "s a t", "s b t"}},
+		{"since", "t", PathOptions{MaxHops: 6, K: 10, MaxFanOut: 100, Since: at.Add(5 * time.Minute)}, []string{"s t"}},
+		{"later arrival is dominated", "u", PathOptions{MaxHops: 6, K: 1, MaxFanOut: 100}, []string{"s x z u"}},
+		{"both arrivals kept for K=2", "u", PathOptions{MaxHops: 6, K: 2, MaxFanOut: 100}, []string{"s x z u", "s y z u"}},
+		{"unreachable", "c", PathOptions{MaxHops: 6, K: 1, MaxFanOut: 100, Since: at.Add(40 * time.Minute)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := (&Neo4jService{}).FindMoneyPaths(context.Background(), DefaultTenant, id("s"), id(tt.to), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range paths {
+				got = append(got, strings.ReplaceAll(strings.Join(p.Accounts, " "), prefix+"-", ""))
+				if p.HopCount != len(p.Edges) || p.MinAmount != 1000 || p.EndedAt.Before(p.StartedAt) {
+					t.Errorf("path %+v", p)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&Neo4jService{}).FindMoneyPaths(context.Background(), DefaultTenant, id("s"), id("s"), DefaultPathOptions()); err == nil {
+		t.Error("a path from an account to itself was searched")
+	}
+}
+
+func TestSearchMoneyPaths(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	hop := func(from, to string, minutes int) models.TransferEdge {
+		return models.TransferEdge{TxnID: from + to, From: from, To: to, Amount: 100, Timestamp: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	accounts := func(paths []models.MoneyPath) []string {
+		var got []string
+		for _, p := range paths {
+			got = append(got, strings.Join(p.Accounts, " "))
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		edges []models.TransferEdge
+		opts  PathOptions
+		want  []string
+	}{
+		{"fan-out keeps the most recent transfers", []models.TransferEdge{hop("s", "a", 0), hop("a", "t", 1), hop("s", "b", 2), hop("b", "t", 3)},
+			PathOptions{MaxHops: 6, K: 10, MaxFanOut: 1}, []string{"s b t"}},
+		{"within a raised fan-out", []models.TransferEdge{hop("s", "a", 0), hop("a", "t", 1), hop("s", "b", 2), hop("b", "t", 3)},
+			PathOptions{MaxHops: 6, K: 10, MaxFanOut: 2}, []string{"s a t", "s b t"}},
+		{"no cycles through the source", []models.TransferEdge{hop("s", "a", 0), hop("a", "s", 1), hop("s", "t", 2)},
+			PathOptions{MaxHops: 6, K: 10, MaxFanOut: 5}, []string{"s t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := map[string]int{}
+			paths, err := searchMoneyPaths(context.Background(), "s", "t", tt.opts, memEdges(tt.edges, loads))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := accounts(paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+			for account, n := range loads {
+				if n > 1 {
+					t.Errorf("%s loaded %d times", account, n)
+				}
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		edges := []models.TransferEdge{hop("s", "t", 0)}
+		if _, err := searchMoneyPaths(ctx, "s", "t", DefaultPathOptions(), memEdges(edges, map[string]int{})); !errors.Is(err, context.Canceled) {
+			t.Errorf("searchMoneyPaths = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		failed := errors.New("store down")
+		load := func(account string, since time.Time, limit int) ([]models.TransferEdge, error) {
+			if account == "a" {
+				return nil, failed
+			}
+			return []models.TransferEdge{hop("s", "a", 0)}, nil
+		}
+		if _, err := searchMoneyPaths(context.Background(), "s", "t", DefaultPathOptions(), load); !errors.Is(err, failed) {
+			t.Errorf("searchMoneyPaths = %v, want the load error", err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"bank-fraud-demo/models"
+)
+
+// GetAccountRiskContext returns aggregated risk signals for an account as tenant sees it
+// Used for graph-aware compound risk scoring
+func (s *Neo4jService) GetAccountRiskContext(ctx context.Context, tenant, accountID string) (map[string]any, error) {
+	riskContext, err := s.getIncomingRiskContext(ctx, tenant, accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.addGraphFeatures(ctx, tenant, accountID, riskContext)
+	return riskContext, nil
+}
+
+// addGraphFeatures adds structural features computed over the tenant's wider transfer graph.
+// Each feature degrades to its zero value on error so scoring is never blocked.
+func (s *Neo4jService) addGraphFeatures(ctx context.Context, tenant, accountID string, riskContext map[string]any) {
+	cycles, err := s.AccountCycles(ctx, tenant, accountID)
+	if err != nil {
+		log.Printf("Cycle detection failed for %s: %v", accountID, err)
+	}
+	shortest := 0
+	for _, c := range cycles {
+		if shortest == 0 || len(c.Edges) < shortest {
+			shortest = len(c.Edges)
+		}
+	}
+	riskContext["round_trip_cycle_count"] = int64(len(cycles))
+	riskContext["shortest_cycle_length"] = int64(shortest)
+
+	var communitySize, communityFraud int64
+	communityRisk := 0.0
+	if community, err := GetAccountCommunity(tenant, accountID); err == nil {
+		communitySize = int64(community.Size)
+		communityFraud = int64(community.FraudConfirmedCount)
+		communityRisk = community.AvgRisk
+	}
+	riskContext["community_size"] = communitySize
+	riskContext["community_fraud_count"] = communityFraud
+	riskContext["community_avg_risk"] = communityRisk
+
+	// Diffused from confirmed fraud by RiskPropagationJob
+	riskContext["propagated_risk"] = GetPropagatedRisk(tenant, accountID)
+
+	// Aggregate across every account the owning party holds, at any bank
+	party, _ := GetAccountParty(tenant, accountID)
+	if party == nil {
+		party = &models.Party{}
+	}
+	partySummary, _ := PartyRiskSummary(tenant, party)
+	for key, value := range partySummary {
+		riskContext["party_"+key] = value
+	}
+}
+
+// AddTransactionFeatures adds signals that depend on the transaction itself rather
+// than only on the receiving account
+func (s *Neo4jService) AddTransactionFeatures(ctx context.Context, txn models.Transaction, riskContext map[string]any) {
+	riskContext["device_shared_account_count"] = s.countOtherLinkedAccounts(ctx, txn.TenantID, EntityDevice, txn.DeviceID, txn.SenderAccount)
+	riskContext["sender_ip_shared_account_count"] = s.countOtherLinkedAccounts(ctx, txn.TenantID, EntityIP, txn.SenderIP, txn.SenderAccount)
+
+	motifFeatures, err := s.DetectMotifs(txn)
+	if err != nil {
+		log.Printf("Motif detection failed for %s: %v", txn.TransactionID, err)
+	}
+	for k, v := range motifFeatures {
+		riskContext[k] = v
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// RiskPropagationJob diffuses risk outward from accounts that received
+// confirmed fraud, using personalized PageRank over the transfer graph
+type RiskPropagationJob struct {
+	Neo4j         *Neo4jService
+	Damping       float64 // probability of following an edge instead of jumping back to a seed
+	MaxIterations int
+	Tolerance     float64       // stop once the L1 change between iterations drops below this
+	Timeout       time.Duration // hard bound on a single run
+	Window        time.Duration // only transfers this recent are walked
+
+	trigger chan struct{}
+}
+
+func NewRiskPropagationJob(neo4j *Neo4jService) *RiskPropagationJob {
+	return &RiskPropagationJob{
+		Neo4j:         neo4j,
+		Damping:       0.85,
+		MaxIterations: 50,
+		Tolerance:     1e-6,
+		Timeout:       30 * time.Second,
+		Window:        90 * 24 * time.Hour,
+		trigger:       make(chan struct{}, 1),
+	}
+}
+
+// Start runs propagation once, then again whenever Trigger is called.
+// Triggers arriving during a run are coalesced into a single follow-up run.
+func (j *RiskPropagationJob) Start(ctx context.Context) {
+	j.Trigger()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-j.trigger:
+				runCtx, cancel := context.WithTimeout(ctx, j.Timeout)
+				if n, err := j.Run(runCtx); err != nil {
+					log.Printf("Risk propagation failed: %v", err)
+				} else {
+					log.Printf("Risk propagation scored %d accounts", n)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Trigger schedules a propagation run without blocking the caller
+func (j *RiskPropagationJob) Trigger() {
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run computes propagated_risk for every account in the window and stores it.
+// Each tenant's graph is walked on its own, so risk never crosses banks.
+// Returns the number of accounts scored.
+func (j *RiskPropagationJob) Run(ctx context.Context) (int, error) {
+	tenants, err := graphTenants()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, tenant := range tenants {
+		edges, err := loadEdgesSince(tenant, time.Now().Add(-j.Window))
+		if err != nil {
+			return total, err
+		}
+		scores := personalizedPageRank(ctx, edges, j.Damping, j.MaxIterations, j.Tolerance)
+
+		if err := saveAccountRisk(tenant, scores); err != nil {
+			return total, err
+		}
+		if j.Neo4j != nil && j.Neo4j.Connected {
+			if err := j.Neo4j.SavePropagatedRisk(ctx, tenant, scores); err != nil {
+				log.Printf("Failed to write propagated risk to Neo4j: %v", err)
+			}
+		}
+		total += len(scores)
+	}
+	return total, nil
+}
+
+// personalizedPageRank returns a 0-100 score per account, seeded from receivers of
+// CONFIRMED_FRAUD transfers. Transfers are treated as undirected associations.
+// Iteration stops early when ctx expires, keeping the best estimate so far.
+func personalizedPageRank(ctx context.Context, edges []models.TransferEdge, damping float64, maxIterations int, tolerance float64) map[string]float64 {
+	index := map[string]int{}
+	var accounts []string
+	id := func(account string) int {
+		if i, ok := index[account]; ok {
+			return i
+		}
+		index[account] = len(accounts)
+		accounts = append(accounts, account)
+		return index[account]
+	}
+
+	type link struct {
+		to     int
+		weight float64
+	}
+	var links [][]link
+	var outWeight []float64
+	seeds := map[int]float64{}
+	for _, e := range edges {
+		from, to := id(e.From), id(e.To)
+		for len(links) < len(accounts) {
+			links = append(links, nil)
+			outWeight = append(outWeight, 0)
+		}
+		if from != to {
+			links[from] = append(links[from], link{to, 1})
+			links[to] = append(links[to], link{from, 1})
+			outWeight[from]++
+			outWeight[to]++
+		}
+		if e.VerificationStatus == "CONFIRMED_FRAUD" {
+			seeds[to]++
+		}
+	}
+
+	scores := make(map[string]float64, len(accounts))
+	for _, a := range accounts {
+		scores[a] = 0
+	}
+	if len(seeds) == 0 {
+		return scores
+	}
+
+	n := len(accounts)
+	personalization := make([]float64, n)
+	var seedTotal float64
+	for _, w := range seeds {
+		seedTotal += w
+	}
+	for i, w := range seeds {
+		personalization[i] = w / seedTotal
+	}
+
+	rank := append([]float64{}, personalization...)
+	next := make([]float64, n)
+	for iter := 0; iter < maxIterations && ctx.Err() == nil; iter++ {
+		// Mass on accounts with no links returns to the seeds
+		dangling := 0.0
+		for i := range next {
+			next[i] = 0
+			if outWeight[i] == 0 {
+				dangling += rank[i]
+			}
+		}
+		for i, ls := range links {
+			if outWeight[i] == 0 {
+				continue
+			}
+			share := damping * rank[i] / outWeight[i]
+			for _, l := range ls {
+				next[l.to] += share * l.weight
+			}
+		}
+		delta := 0.0
+		for i := range next {
+			next[i] += (1-damping)*personalization[i] + damping*dangling*personalization[i]
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < tolerance {
+			break
+		}
+	}
+
+	maxRank := 0.0
+	for _, r := range rank {
+		maxRank = math.Max(maxRank, r)
+	}
+	for i, a := range accounts {
+		if maxRank > 0 {
+			scores[a] = math.Round(rank[i]/maxRank*10000) / 100
+		}
+	}
+	return scores
+}
+
+// saveAccountRisk replaces a tenant's stored propagated risk scores in SQLite
+func saveAccountRisk(tenant string, scores map[string]float64) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM account_risk WHERE tenant_id = ?", tenant); err != nil {
+		return err
+	}
+	now := time.Now()
+	for account, score := range scores {
+		if _, err := tx.Exec("INSERT INTO account_risk (tenant_id, account_id, propagated_risk, updated_at) VALUES (?, ?, ?, ?)", tenant, account, score, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SavePropagatedRisk sets propagated_risk on every Account node in tenant,
+// zero for accounts outside the scores
+func (s *Neo4jService) SavePropagatedRisk(ctx context.Context, tenant string, scores map[string]float64) error {
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	params := make(map[string]any, len(scores))
+	for account, score := range scores {
+		params[account] = score
+	}
+
+	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (a:Account {tenant: $tenant})
+			SET a.propagated_risk = coalesce($scores[a.id], 0.0),
+			    a.propagated_risk_at = $timestamp
+		`
+		return tx.Run(ctx, query, map[string]any{
+			"tenant":    tenant,
+			"scores":    params,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+	return err
+}
+
+// GetPropagatedRisk returns the stored propagated risk for an account within
+// tenant, zero if never scored
+func GetPropagatedRisk(tenant, accountID string) float64 {
+	var score float64
+	_ = db.DB.QueryRow("SELECT propagated_risk FROM account_risk WHERE tenant_id = ? AND account_id = ?", tenant, accountID).Scan(&score)
+	return score
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+func TestPersonalizedPageRank(t *testing.T) {
+	// a > b > c > d is a chain and x > y is unconnected; fraud was confirmed on a > b
+	chain := edgeList("a>b", "b>c", "c>d", "x>y")
+	chain[0].VerificationStatus = "CONFIRMED_FRAUD"
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		edges  []models.TransferEdge
+		higher [][2]string // first account scores above the second
+		want   map[string]float64
+	}{
+		{"risk falls with distance from the fraud", context.Background(), chain,
+			[][2]string{{"b", "a"}, {"b", "c"}, {"c", "d"}, {"d", "x"}},
+			map[string]float64{"b": 100, "x": 0, "y": 0}},
+		{"no confirmed fraud", context.Background(), edgeList("a>b", "b>c"), nil,
+			map[string]float64{"a": 0, "b": 0, "c": 0}},
+		{"expired context keeps the seeds", cancelled, chain, nil,
+			map[string]float64{"a": 0, "b": 100, "c": 0, "d": 0, "x": 0, "y": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := personalizedPageRank(tt.ctx, tt.edges, 0.85, 50, 1e-9)
+			for account, want := range tt.want {
+				if got, ok := scores[account]; !ok || got != want {
+					t.Errorf("%s scored %v, want %v", account, got, want)
+				}
+			}
+			for _, pair := range tt.higher {
+				if scores[pair[0]] <= scores[pair[1]] {
+					t.Errorf("%s scored %v, not above %s with %v", pair[0], scores[pair[0]], pair[1], scores[pair[1]])
+				}
+			}
+		})
+	}
+}
+
+func TestRiskPropagationRun(t *testing.T) {
+	a, b, c, clean := testID("PPR-A"), testID("PPR-B"), testID("PPR-C"), testID("PPR-D")
+	at := time.Now().Add(-time.Hour)
+	fraud := addTransfer(t, a, b, 5000, at)
+	addTransfer(t, b, c, 4900, at.Add(time.Minute))
+	addTransfer(t, clean, testID("PPR-E"), 10, at)
+	if _, err := db.DB.Exec("UPDATE graph_transactions SET verification_status = 'CONFIRMED_FRAUD' WHERE txn_id = ?", fraud); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewRiskPropagationJob(nil).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if GetPropagatedRisk(DefaultTenant, b) <= GetPropagatedRisk(DefaultTenant, c) || GetPropagatedRisk(DefaultTenant, c) <= 0 {
+		t.Errorf("receiver scored %v, next hop %v", GetPropagatedRisk(DefaultTenant, b), GetPropagatedRisk(DefaultTenant, c))
+	}
+	if got := GetPropagatedRisk(DefaultTenant, clean); got != 0 {
+		t.Errorf("unconnected account scored %v", got)
+	}
+	if got := GetPropagatedRisk(DefaultTenant, "never-seen"); got != 0 {
+		t.Errorf("unknown account scored %v", got)
+	}
+}
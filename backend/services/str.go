@@ -0,0 +1,461 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var ErrSTRNotFound = errors.New("STR report not found")
+
+// STRValidationError lists the required fields a report is missing. Nothing
+// is stored until every problem is fixed.
+type STRValidationError struct {
+	Problems []string
+}
+
+func (e *STRValidationError) Error() string {
+	return "STR report is incomplete: " + strings.Join(e.Problems, "; ")
+}
+
+// STRRequest names the subject of a report, a case or a single confirmed
+// transaction, and the analyst's account of why it is suspicious
+type STRRequest struct {
+	CaseID        string `json:"case_id"`
+	TransactionID string `json:"transaction_id"`
+	Analyst       string `json:"analyst"`
+	Narrative     string `json:"narrative"`
+}
+
+// STRService assembles suspicious transaction reports for AMLO. Reports are
+// stored per subject; generating again for the same case or transaction adds
+// a new version rather than overwriting the one already filed.
+type STRService struct {
+	InstitutionName string
+	InstitutionCode string
+}
+
+// strMu serialises version numbering so two generations cannot take the same version
+var strMu sync.Mutex
+
+func NewSTRService() *STRService {
+	return &STRService{InstitutionName: "Synapse Bank"}
+}
+
+// GenerateSTR assembles, validates and stores a new version of the report for
+// the requested case or transaction, filed in tenant's name
+func (s *STRService) GenerateSTR(tenant string, req STRRequest) (*models.STRReport, error) {
+	req.CaseID = strings.TrimSpace(req.CaseID)
+	req.TransactionID = strings.TrimSpace(req.TransactionID)
+	if (req.CaseID == "") == (req.TransactionID == "") {
+		return nil, errors.New("exactly one of case_id or transaction_id is required")
+	}
+	institution, err := s.institution(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &models.STRReport{
+		CaseID:        req.CaseID,
+		TransactionID: req.TransactionID,
+		Institution:   institution,
+		Analyst:       strings.TrimSpace(req.Analyst),
+		Narrative:     strings.TrimSpace(req.Narrative),
+		Reasons:       []string{},
+		Parties:       []models.STRParty{},
+		Accounts:      []models.STRAccount{},
+		Transactions:  []models.STRTransaction{},
+		GeneratedAt:   time.Now().UTC(),
+	}
+
+	var problems []string
+	var subjectAccounts []string
+	if req.CaseID != "" {
+		kase, err := GetCase(tenant, req.CaseID)
+		if err != nil {
+			return nil, err
+		}
+		r.ReportID = "STR-" + kase.CaseID
+		subjectAccounts = kase.Accounts
+		for _, txnID := range kase.Transactions {
+			t, err := loadSTRTransaction(tenant, txnID)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			// A transaction cleared as a false positive is not part of the suspicion
+			if t.VerificationStatus != "FALSE_POSITIVE" {
+				r.Transactions = append(r.Transactions, t)
+			}
+		}
+		for _, a := range kase.Alerts {
+			r.Reasons = appendUnique(r.Reasons, "Alert "+a.Type+": "+a.Summary)
+		}
+	} else {
+		t, err := loadSTRTransaction(tenant, req.TransactionID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transaction %s not found", req.TransactionID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.VerificationStatus != "CONFIRMED_FRAUD" {
+			problems = append(problems, fmt.Sprintf("transaction %s must be verified as CONFIRMED_FRAUD (is %q)", t.TransactionID, t.VerificationStatus))
+		}
+		r.ReportID = "STR-" + t.TransactionID
+		r.Transactions = append(r.Transactions, t)
+		// File the report with the case already investigating this transaction
+		_ = db.DB.QueryRow(`
+			SELECT l.case_id FROM case_links l JOIN cases c ON c.case_id = l.case_id
+			WHERE c.tenant_id = ? AND l.link_type = 'transaction' AND l.ref = ? ORDER BY l.rowid LIMIT 1
+		`, tenant, t.TransactionID).Scan(&r.CaseID)
+	}
+
+	if err := s.assemble(tenant, r, subjectAccounts); err != nil {
+		return nil, err
+	}
+	problems = append(problems, validateSTR(r)...)
+	if len(problems) > 0 {
+		return nil, &STRValidationError{Problems: problems}
+	}
+	if err := saveSTR(tenant, r); err != nil {
+		return nil, err
+	}
+	RecordAudit(r.TransactionID, fmt.Sprintf("STR_GENERATED %s v%d case=%s by %s", r.ReportID, r.Version, r.CaseID, r.Analyst))
+	return r, nil
+}
+
+// institution is the reporting institution for tenant's reports. The operator
+// files under the configured name and code, other banks under their own.
+func (s *STRService) institution(tenant string) (models.STRInstitution, error) {
+	t, err := GetTenant(tenant)
+	if err != nil {
+		return models.STRInstitution{}, err
+	}
+	if t.Operator {
+		return models.STRInstitution{Name: s.InstitutionName, Code: s.InstitutionCode}, nil
+	}
+	return models.STRInstitution{Name: t.Name, Code: t.TenantID}, nil
+}
+
+// assemble fills accounts, parties, reasons and totals from the report's
+// transactions plus any further subject accounts
+func (s *STRService) assemble(tenant string, r *models.STRReport, subjectAccounts []string) error {
+	roles := map[string]string{}
+	var order []string
+	addRole := func(accountID, role string) {
+		if accountID == "" {
+			return
+		}
+		switch current, seen := roles[accountID]; {
+		case !seen:
+			order = append(order, accountID)
+			roles[accountID] = role
+		case current != role && role != "subject" && current != "subject":
+			roles[accountID] = "both"
+		case current == "subject":
+			roles[accountID] = role
+		}
+	}
+
+	for _, t := range r.Transactions {
+		addRole(t.SenderAccount, "sender")
+		addRole(t.ReceiverAccount, "receiver")
+		r.TotalAmount += t.Amount
+		if r.Currency == "" {
+			r.Currency = t.Currency
+		}
+		for _, reason := range t.Reasons {
+			r.Reasons = appendUnique(r.Reasons, reason)
+		}
+	}
+	for _, accountID := range subjectAccounts {
+		addRole(accountID, "subject")
+	}
+
+	parties := map[string]int{}
+	for _, accountID := range order {
+		status, err := GetAccountStatus(tenant, accountID)
+		if err != nil {
+			return err
+		}
+		account := models.STRAccount{AccountID: accountID, Role: roles[accountID], Status: status}
+
+		party, err := GetAccountParty(tenant, accountID)
+		if err != nil {
+			return err
+		}
+		if party != nil {
+			account.PartyID = party.PartyID
+			if i, ok := parties[party.PartyID]; ok {
+				r.Parties[i].Accounts = appendUnique(r.Parties[i].Accounts, accountID)
+			} else {
+				parties[party.PartyID] = len(r.Parties)
+				r.Parties = append(r.Parties, models.STRParty{
+					PartyID:        party.PartyID,
+					Name:           party.Name,
+					NationalIDHash: party.NationalIDHash,
+					Phone:          party.Phone,
+					PromptPayProxy: party.PromptPayProxy,
+					Accounts:       []string{accountID},
+				})
+			}
+		}
+		r.Accounts = append(r.Accounts, account)
+	}
+	return nil
+}
+
+// validateSTR reports the required fields the report is missing
+func validateSTR(r *models.STRReport) []string {
+	var problems []string
+	if r.Institution.Name == "" {
+		problems = append(problems, "reporting institution name is required")
+	}
+	if r.Analyst == "" {
+		problems = append(problems, "analyst is required")
+	}
+	if r.Narrative == "" {
+		problems = append(problems, "narrative is required")
+	}
+	if len(r.Transactions) == 0 {
+		problems = append(problems, "at least one suspicious transaction is required")
+	}
+	if len(r.Accounts) == 0 {
+		problems = append(problems, "at least one account is required")
+	}
+	if len(r.Reasons) == 0 {
+		problems = append(problems, "at least one reason for suspicion is required")
+	}
+	for _, t := range r.Transactions {
+		if t.SenderAccount == "" || t.ReceiverAccount == "" {
+			problems = append(problems, fmt.Sprintf("transaction %s is missing an account", t.TransactionID))
+		}
+		if t.Amount <= 0 {
+			problems = append(problems, fmt.Sprintf("transaction %s has no amount", t.TransactionID))
+		}
+		if t.Timestamp.IsZero() {
+			problems = append(problems, fmt.Sprintf("transaction %s has no timestamp", t.TransactionID))
+		}
+	}
+	return problems
+}
+
+// saveSTR stores the report as the next version of its ReportID and links it
+// to its case
+func saveSTR(tenant string, r *models.STRReport) error {
+	strMu.Lock()
+	defer strMu.Unlock()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("SELECT coalesce(max(version), 0) + 1 FROM str_reports WHERE report_id = ?", r.ReportID).Scan(&r.Version); err != nil {
+		return err
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO str_reports (tenant_id, report_id, version, case_id, txn_id, analyst, body, generated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, tenant, r.ReportID, r.Version, r.CaseID, r.TransactionID, r.Analyst, string(body), r.GeneratedAt); err != nil {
+		return err
+	}
+	if r.CaseID != "" {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO case_links (case_id, link_type, ref) VALUES (?, 'report', ?)", r.CaseID, r.ReportID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE cases SET updated_at = ? WHERE case_id = ?", r.GeneratedAt, r.CaseID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSTR loads one version of one of tenant's reports; version 0 is the latest
+func GetSTR(tenant, reportID string, version int) (*models.STRReport, error) {
+	query := "SELECT body FROM str_reports WHERE tenant_id = ? AND report_id = ? ORDER BY version DESC LIMIT 1"
+	args := []any{tenant, reportID}
+	if version > 0 {
+		query = "SELECT body FROM str_reports WHERE tenant_id = ? AND report_id = ? AND version = ?"
+		args = append(args, version)
+	}
+	var body string
+	err := db.DB.QueryRow(query, args...).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, ErrSTRNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r models.STRReport
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListSTRs returns tenant's stored report versions, newest first, optionally
+// for one case, transaction or report
+func ListSTRs(tenant, caseID, txnID, reportID string, limit int) ([]models.STRSummary, error) {
+	query := `SELECT report_id, version, coalesce(case_id, ''), coalesce(txn_id, ''), analyst, generated_at FROM str_reports WHERE tenant_id = ?`
+	args := []any{tenant}
+	if caseID != "" {
+		query += " AND case_id = ?"
+		args = append(args, caseID)
+	}
+	if txnID != "" {
+		query += " AND txn_id = ?"
+		args = append(args, txnID)
+	}
+	if reportID != "" {
+		query += " AND report_id = ?"
+		args = append(args, reportID)
+	}
+	query += " ORDER BY generated_at DESC, version DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []models.STRSummary{}
+	for rows.Next() {
+		var s models.STRSummary
+		if err := rows.Scan(&s.ReportID, &s.Version, &s.CaseID, &s.TransactionID, &s.Analyst, &s.GeneratedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, s)
+	}
+	return reports, rows.Err()
+}
+
+func loadSTRTransaction(tenant, txnID string) (models.STRTransaction, error) {
+	var t models.STRTransaction
+	var currency, originalCurrency, status, reasons sql.NullString
+	var originalAmount sql.NullFloat64
+	err := db.DB.QueryRow(`
+		SELECT txn_id, sender_account, receiver_account, amount, currency, original_amount, original_currency,
+			timestamp, risk_score, coalesce(action, ''), verification_status, reasons
+		FROM graph_transactions WHERE txn_id = ? AND tenant_id = ?
+	`, txnID, tenant).Scan(&t.TransactionID, &t.SenderAccount, &t.ReceiverAccount, &t.Amount, &currency, &originalAmount, &originalCurrency,
+		&t.Timestamp, &t.RiskScore, &t.Action, &status, &reasons)
+	if err != nil {
+		return t, err
+	}
+	t.Currency = currency.String
+	if originalCurrency.String != "" && originalCurrency.String != currency.String {
+		t.OriginalAmount, t.OriginalCurrency = originalAmount.Float64, originalCurrency.String
+	}
+	t.VerificationStatus = status.String
+	if t.VerificationStatus == "" {
+		t.VerificationStatus = "PENDING"
+	}
+	t.Reasons = []string{}
+	_ = json.Unmarshal([]byte(reasons.String), &t.Reasons)
+	return t, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// RenderSTRHTML writes a printable rendering of the report
+func RenderSTRHTML(w io.Writer, r *models.STRReport) error {
+	sorted := *r
+	sorted.Transactions = append([]models.STRTransaction(nil), r.Transactions...)
+	sort.SliceStable(sorted.Transactions, func(i, j int) bool {
+		return sorted.Transactions[i].Timestamp.Before(sorted.Transactions[j].Timestamp)
+	})
+	return strTemplate.Execute(w, sorted)
+}
+
+var strTemplate = template.Must(template.New("str").Funcs(template.FuncMap{
+	"amount": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"date":   func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Suspicious Transaction Report {{.ReportID}} v{{.Version}}</title>
+<style>
+	body { font-family: "Sarabun", "Helvetica Neue", Arial, sans-serif; font-size: 11pt; color: #111; margin: 2cm; }
+	h1 { font-size: 16pt; margin-bottom: 0; }
+	h2 { font-size: 12pt; border-bottom: 1px solid #444; margin-top: 1.5em; }
+	table { width: 100%; border-collapse: collapse; margin-top: 0.5em; }
+	th, td { border: 1px solid #999; padding: 4px 6px; text-align: left; vertical-align: top; }
+	th { background: #eee; }
+	td.num { text-align: right; }
+	.meta td { border: none; padding: 2px 6px 2px 0; }
+	.narrative { white-space: pre-wrap; }
+	.signature { margin-top: 3em; }
+	@media print { body { margin: 1cm; } h2 { page-break-after: avoid; } tr { page-break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>Suspicious Transaction Report</h1>
+<p>Submitted to the Anti-Money Laundering Office</p>
+<table class="meta">
+	<tr><td>Report</td><td>{{.ReportID}} (version {{.Version}})</td></tr>
+	<tr><td>Reporting institution</td><td>{{.Institution.Name}}{{if .Institution.Code}} ({{.Institution.Code}}){{end}}</td></tr>
+	{{if .CaseID}}<tr><td>Case</td><td>{{.CaseID}}</td></tr>{{end}}
+	{{if .TransactionID}}<tr><td>Transaction</td><td>{{.TransactionID}}</td></tr>{{end}}
+	<tr><td>Generated</td><td>{{date .GeneratedAt}}</td></tr>
+	<tr><td>Total amount</td><td>{{amount .TotalAmount}} {{.Currency}}</td></tr>
+</table>
+
+<h2>Parties</h2>
+{{if .Parties}}<table>
+	<tr><th>Party</th><th>Name</th><th>National ID (hashed)</th><th>Phone</th><th>PromptPay</th><th>Accounts</th></tr>
+	{{range .Parties}}<tr><td>{{.PartyID}}</td><td>{{.Name}}</td><td>{{.NationalIDHash}}</td><td>{{.Phone}}</td><td>{{.PromptPayProxy}}</td><td>{{range $i, $a := .Accounts}}{{if $i}}, {{end}}{{$a}}{{end}}</td></tr>
+	{{end}}</table>{{else}}<p>No parties have been resolved for these accounts.</p>{{end}}
+
+<h2>Accounts</h2>
+<table>
+	<tr><th>Account</th><th>Role</th><th>Status</th><th>Party</th></tr>
+	{{range .Accounts}}<tr><td>{{.AccountID}}</td><td>{{.Role}}</td><td>{{.Status}}</td><td>{{.PartyID}}</td></tr>
+	{{end}}</table>
+
+<h2>Transactions</h2>
+<table>
+	<tr><th>Transaction</th><th>Time</th><th>From</th><th>To</th><th>Amount</th><th>Risk</th><th>Action</th><th>Verification</th></tr>
+	{{range .Transactions}}<tr><td>{{.TransactionID}}</td><td>{{date .Timestamp}}</td><td>{{.SenderAccount}}</td><td>{{.ReceiverAccount}}</td>
+		<td class="num">{{amount .Amount}} {{.Currency}}{{if .OriginalCurrency}}<br>({{amount .OriginalAmount}} {{.OriginalCurrency}}){{end}}</td>
+		<td class="num">{{printf "%.0f" .RiskScore}}</td><td>{{.Action}}</td><td>{{.VerificationStatus}}</td></tr>
+	{{end}}</table>
+
+<h2>Grounds for suspicion</h2>
+<ul>
+	{{range .Reasons}}<li>{{.}}</li>
+	{{end}}</ul>
+
+<h2>Narrative</h2>
+<p class="narrative">{{.Narrative}}</p>
+
+<p class="signature">Prepared by {{.Analyst}}<br><br>Signature ______________________ Date ____________</p>
+</body>
+</html>
+`))
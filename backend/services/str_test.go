@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// setReasons records the scoring reasons of a transfer
+func setReasons(t *testing.T, txnID, reasons string) {
+	t.Helper()
+	if _, err := db.DB.Exec("UPDATE graph_transactions SET reasons = ?, currency = 'THB' WHERE txn_id = ?", reasons, txnID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateSTRForTransaction(t *testing.T) {
+	s := NewSTRService()
+	sender, receiver := testID("STR-ACC"), testID("STR-ACC")
+	txnID := addTransfer(t, sender, receiver, 49000, time.Now().Add(-time.Hour))
+	setReasons(t, txnID, `["new payee","amount just under limit"]`)
+
+	tests := []struct {
+		name     string
+		req      STRRequest
+		problems int // -1 for an error that is not a validation error
+	}{
+		{"no subject", STRRequest{Analyst: "analyst", Narrative: "x"}, -1},
+		{"two subjects", STRRequest{CaseID: "CASE-1", TransactionID: txnID, Analyst: "analyst", Narrative: "x"}, -1},
+		{"unknown transaction", STRRequest{TransactionID: testID("TXN"), Analyst: "analyst", Narrative: "x"}, -1},
+		{"unconfirmed transaction without a narrative", STRRequest{TransactionID: txnID, Analyst: "analyst"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := s.GenerateSTR(DefaultTenant, tt.req)
+			if err == nil {
+				t.Fatalf("GenerateSTR = %+v", r)
+			}
+			var invalid *STRValidationError
+			if errors.As(err, &invalid) != (tt.problems >= 0) || (invalid != nil && len(invalid.Problems) != tt.problems) {
+				t.Errorf("GenerateSTR: %v", err)
+			}
+		})
+	}
+	if reports, _ := ListSTRs(DefaultTenant, "", txnID, "", 10); len(reports) != 0 {
+		t.Errorf("rejected report stored: %+v", reports)
+	}
+
+	setVerification(t, txnID, "CONFIRMED_FRAUD")
+	req := STRRequest{TransactionID: " " + txnID + " ", Analyst: "analyst", Narrative: "Victim reported a scam call."}
+	first, err := s.GenerateSTR(DefaultTenant, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ReportID != "STR-"+txnID || first.Version != 1 || first.TotalAmount != 49000 || first.Currency != "THB" ||
+		len(first.Reasons) != 2 || len(first.Accounts) != 2 || first.Accounts[0].Role != "sender" || first.Accounts[1].Role != "receiver" {
+		t.Errorf("report = %+v", first)
+	}
+
+	req.Narrative = "Victim reported a scam call. Funds moved on within minutes."
+	second, err := s.GenerateSTR(DefaultTenant, req)
+	if err != nil || second.Version != 2 {
+		t.Fatalf("regenerated report = %+v, %v", second, err)
+	}
+	if latest, err := GetSTR(DefaultTenant, first.ReportID, 0); err != nil || latest.Version != 2 || latest.Narrative != req.Narrative {
+		t.Errorf("latest version = %+v, %v", latest, err)
+	}
+	if filed, err := GetSTR(DefaultTenant, first.ReportID, 1); err != nil || filed.Narrative != "Victim reported a scam call." {
+		t.Errorf("version 1 = %+v, %v", filed, err)
+	}
+	if _, err := GetSTR(DefaultTenant, first.ReportID, 3); !errors.Is(err, ErrSTRNotFound) {
+		t.Errorf("missing version: %v", err)
+	}
+	if versions, _ := ListSTRs(DefaultTenant, "", "", first.ReportID, 10); len(versions) != 2 || versions[0].Version != 2 {
+		t.Errorf("versions = %+v", versions)
+	}
+	if n := auditCount(t, "STR_GENERATED "+first.ReportID+" "); n != 2 {
+		t.Errorf("%d audit entries", n)
+	}
+}
+
+func TestGenerateSTRForCase(t *testing.T) {
+	s := NewSTRService()
+	mule, subject := testID("STR-MULE"), testID("STR-ACC")
+	at := time.Now().Add(-time.Hour)
+	confirmed := addTransfer(t, testID("STR-VIC"), mule, 5000, at)
+	cleared := addTransfer(t, testID("STR-VIC"), mule, 900, at.Add(time.Minute))
+	onward := addTransfer(t, mule, subject, 4800, at.Add(2*time.Minute))
+	setReasons(t, confirmed, `["victim reported scam"]`)
+	setReasons(t, onward, `["rapid pass-through"]`)
+	setVerification(t, confirmed, "CONFIRMED_FRAUD")
+	setVerification(t, cleared, "FALSE_POSITIVE")
+
+	alert := raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "BLOCK", TransactionID: confirmed, AccountID: mule, Summary: "scored Block"})
+	raise(t, models.Alert{TenantID: DefaultTenant, Source: models.AlertSourceDecision, Type: "REVIEW", TransactionID: cleared, AccountID: mule, Summary: "scored Review"})
+	extra := testID("STR-ACC")
+	if _, err := LinkCaseItems(DefaultTenant, alert.CaseID, []string{onward}, []string{extra}, "analyst"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.GenerateSTR(DefaultTenant, STRRequest{CaseID: alert.CaseID, Analyst: "analyst", Narrative: "Mule account passing on victim funds."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Transactions) != 2 || r.TotalAmount != 9800 {
+		t.Errorf("transactions = %+v", r.Transactions)
+	}
+	roles := map[string]string{}
+	for _, a := range r.Accounts {
+		roles[a.AccountID] = a.Role
+	}
+	if roles[mule] != "both" || roles[subject] != "receiver" || roles[extra] != "subject" {
+		t.Errorf("roles = %v", roles)
+	}
+	if !strings.Contains(strings.Join(r.Reasons, "|"), "Alert BLOCK: scored Block") || !strings.Contains(strings.Join(r.Reasons, "|"), "rapid pass-through") {
+		t.Errorf("reasons = %v", r.Reasons)
+	}
+
+	kase, err := GetCase(DefaultTenant, alert.CaseID)
+	if err != nil || len(kase.Reports) != 1 || kase.Reports[0] != r.ReportID {
+		t.Errorf("case reports = %+v, %v", kase, err)
+	}
+
+	// A report on a transaction in the case is filed with the case
+	byTxn, err := s.GenerateSTR(DefaultTenant, STRRequest{TransactionID: confirmed, Analyst: "analyst", Narrative: "Victim transfer."})
+	if err != nil || byTxn.CaseID != alert.CaseID {
+		t.Errorf("transaction report = %+v, %v", byTxn, err)
+	}
+	if reports, _ := ListSTRs(DefaultTenant, alert.CaseID, "", "", 10); len(reports) != 2 {
+		t.Errorf("case reports = %+v", reports)
+	}
+}
+
+func TestRenderSTRHTML(t *testing.T) {
+	early, late := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	r := &models.STRReport{
+		ReportID:    "STR-TXN-1",
+		Version:     2,
+		Institution: models.STRInstitution{Name: "Synapse Bank", Code: "099"},
+		Narrative:   "<script>alert(1)</script>",
+		Transactions: []models.STRTransaction{
+			{TransactionID: "TXN-LATE", Timestamp: late, Amount: 10},
+			{TransactionID: "TXN-EARLY", Timestamp: early, Amount: 5, OriginalAmount: 0.15, OriginalCurrency: "USD"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := RenderSTRHTML(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{"STR-TXN-1 (version 2)", "Synapse Bank (099)", "&lt;script&gt;", "(0.15 USD)", "2025-03-01 09:00:00 UTC"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("rendering lacks %q", want)
+		}
+	}
+	if strings.Index(html, "TXN-EARLY") > strings.Index(html, "TXN-LATE") {
+		t.Error("transactions not in time order")
+	}
+	if r.Transactions[0].TransactionID != "TXN-LATE" {
+		t.Error("rendering reordered the report")
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// SubgraphOptions bounds a neighbourhood expansion
+type SubgraphOptions struct {
+	Hops      int
+	Since     time.Time // ignore transfers before this, zero for no limit
+	MinAmount float64
+	MaxNodes  int
+}
+
+// edgesPerNode caps how many transfers a single expansion step may return, relative to MaxNodes
+const edgesPerNode = 10
+
+// GetSubgraph expands breadth-first from the start accounts over tenant's TRANSFERRED
+// edges in either direction, up to opts.Hops away and at most opts.MaxNodes accounts
+func (s *Neo4jService) GetSubgraph(ctx context.Context, tenant string, start []string, opts SubgraphOptions) (*models.Subgraph, error) {
+	expand := func(ids []string, limit int) ([]models.TransferEdge, error) {
+		if s.Connected {
+			return s.expandNeo4j(ctx, tenant, ids, opts, limit)
+		}
+		return expandSQLite(tenant, ids, opts, limit)
+	}
+
+	hops := map[string]int{}
+	var order, current []string
+	for _, id := range start {
+		if _, ok := hops[id]; ok || id == "" {
+			continue
+		}
+		hops[id] = 0
+		order = append(order, id)
+		current = append(current, id)
+	}
+
+	sub := &models.Subgraph{Nodes: []models.SubgraphNode{}, Edges: []models.TransferEdge{}, Frontier: []string{}}
+	expanded := map[string]bool{}
+	partial := map[string]bool{} // expanded, but some neighbours were dropped by the cap
+	edgeSeen := map[string]bool{}
+	limit := opts.MaxNodes * edgesPerNode
+
+	for hop := 0; hop < opts.Hops && len(current) > 0 && !sub.Truncated; hop++ {
+		found, err := expand(current, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range current {
+			expanded[id] = true
+		}
+		if len(found) >= limit {
+			sub.Truncated = true
+			for _, id := range current {
+				partial[id] = true
+			}
+		}
+
+		var next []string
+		for _, e := range found {
+			for _, pair := range [][2]string{{e.From, e.To}, {e.To, e.From}} {
+				account, via := pair[0], pair[1]
+				if _, ok := hops[account]; ok {
+					continue
+				}
+				if len(hops) >= opts.MaxNodes {
+					sub.Truncated = true
+					partial[via] = true
+					continue
+				}
+				hops[account] = hop + 1
+				order = append(order, account)
+				next = append(next, account)
+			}
+			_, fromIn := hops[e.From]
+			_, toIn := hops[e.To]
+			if fromIn && toIn && !edgeSeen[e.TxnID] {
+				edgeSeen[e.TxnID] = true
+				sub.Edges = append(sub.Edges, e)
+			}
+		}
+		current = next
+	}
+
+	maxRisk := map[string]float64{}
+	for _, e := range sub.Edges {
+		if e.RiskScore > maxRisk[e.From] {
+			maxRisk[e.From] = e.RiskScore
+		}
+		if e.RiskScore > maxRisk[e.To] {
+			maxRisk[e.To] = e.RiskScore
+		}
+	}
+	risk, communities := loadNodeAnnotations(tenant, order)
+	for _, id := range order {
+		sub.Nodes = append(sub.Nodes, models.SubgraphNode{
+			ID:             id,
+			Hops:           hops[id],
+			PropagatedRisk: risk[id],
+			CommunityID:    communities[id],
+			MaxEdgeRisk:    maxRisk[id],
+		})
+		if !expanded[id] || partial[id] {
+			sub.Frontier = append(sub.Frontier, id)
+		}
+	}
+	sortEdgesByTime(sub.Edges)
+	return sub, nil
+}
+
+func expandSQLite(tenant string, ids []string, opts SubgraphOptions, limit int) ([]models.TransferEdge, error) {
+	in := placeholders(len(ids))
+	args := append([]any{tenant}, stringArgs(ids)...)
+	args = append(args, stringArgs(ids)...)
+	args = append(args, opts.Since, opts.MinAmount, limit)
+	rows, err := db.DB.Query(`
+		SELECT `+transferEdgeColumns+`
+		FROM graph_transactions
+		WHERE tenant_id = ? AND (sender_account IN (`+in+`) OR receiver_account IN (`+in+`))
+		  AND timestamp >= ? AND amount >= ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTransferEdges(rows), nil
+}
+
+func (s *Neo4jService) expandNeo4j(ctx context.Context, tenant string, ids []string, opts SubgraphOptions, limit int) ([]models.TransferEdge, error) {
+	session := s.Driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	since := ""
+	if !opts.Since.IsZero() {
+		since = opts.Since.Format(time.RFC3339Nano)
+	}
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := `
+			MATCH (a:Account {tenant: $tenant})-[r:TRANSFERRED]-(:Account)
+			WHERE a.id IN $ids
+			  AND r.amount >= $min_amount
+			  AND ($since = '' OR datetime(r.timestamp) >= datetime($since))
+			WITH DISTINCT r
+			RETURN startNode(r).id as sender, endNode(r).id as receiver,
+			       r {.txn_id, .amount, .timestamp, .risk_score, .verification_status} as rel
+			ORDER BY r.timestamp DESC
+			LIMIT $limit
+		`
+		res, err := tx.Run(ctx, query, map[string]any{
+			"tenant":     tenant,
+			"ids":        ids,
+			"min_amount": opts.MinAmount,
+			"since":      since,
+			"limit":      limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var edges []models.TransferEdge
+		for res.Next(ctx) {
+			rec := res.Record()
+			sender, _ := rec.Get("sender")
+			receiver, _ := rec.Get("receiver")
+			rel, _ := rec.Get("rel")
+			from, _ := sender.(string)
+			to, _ := receiver.(string)
+			m, _ := rel.(map[string]any)
+			edges = append(edges, edgeFromRecord(from, to, m))
+		}
+		return edges, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]models.TransferEdge), nil
+}
+
+// loadNodeAnnotations fetches propagated risk and community membership for a set of a tenant's accounts
+func loadNodeAnnotations(tenant string, ids []string) (map[string]float64, map[string]string) {
+	risk := map[string]float64{}
+	communities := map[string]string{}
+	if len(ids) == 0 {
+		return risk, communities
+	}
+	in := placeholders(len(ids))
+	args := append([]any{tenant}, stringArgs(ids)...)
+
+	if rows, err := db.DB.Query("SELECT account_id, propagated_risk FROM account_risk WHERE tenant_id = ? AND account_id IN ("+in+")", args...); err == nil {
+		for rows.Next() {
+			var id string
+			var score float64
+			if rows.Scan(&id, &score) == nil {
+				risk[id] = score
+			}
+		}
+		rows.Close()
+	}
+	if rows, err := db.DB.Query("SELECT account_id, community_id FROM account_communities WHERE tenant_id = ? AND account_id IN ("+in+")", args...); err == nil {
+		for rows.Next() {
+			var id, community string
+			if rows.Scan(&id, &community) == nil {
+				communities[id] = community
+			}
+		}
+		rows.Close()
+	}
+	return risk, communities
+}
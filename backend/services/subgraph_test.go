@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestGetSubgraph(t *testing.T) {
+	// s > a > b > c, d > s, and a small transfer s > e
+	prefix := testID("SUB")
+	id := func(name string) string { return prefix + "-" + name }
+	at := time.Now().Add(-48 * time.Hour)
+	addTransfer(t, id("s"), id("a"), 1000, at)
+	addTransfer(t, id("a"), id("b"), 1000, at.Add(time.Hour))
+	addTransfer(t, id("b"), id("c"), 1000, at.Add(2*time.Hour))
+	addTransfer(t, id("d"), id("s"), 1000, at.Add(30*time.Hour))
+	addTransfer(t, id("s"), id("e"), 5, at.Add(3*time.Hour))
+
+	tests := []struct {
+		name      string
+		opts      SubgraphOptions
+		nodes     map[string]int // account to hops
+		edges     int
+		frontier  []string
+		truncated bool
+	}{
+		{"one hop", SubgraphOptions{Hops: 1, MaxNodes: 50},
+			map[string]int{"s": 0, "a": 1, "d": 1, "e": 1}, 3, []string{"a", "d", "e"}, false},
+		{"two hops", SubgraphOptions{Hops: 2, MaxNodes: 50},
+			map[string]int{"s": 0, "a": 1, "d": 1, "e": 1, "b": 2}, 4, []string{"b"}, false},
+		{"three hops", SubgraphOptions{Hops: 3, MaxNodes: 50},
+			map[string]int{"s": 0, "a": 1, "d": 1, "e": 1, "b": 2, "c": 3}, 5, []string{"c"}, false},
+		{"minimum amount", SubgraphOptions{Hops: 1, MaxNodes: 50, MinAmount: 100},
+			map[string]int{"s": 0, "a": 1, "d": 1}, 2, []string{"a", "d"}, false},
+		{"since", SubgraphOptions{Hops: 3, MaxNodes: 50, Since: at.Add(90 * time.Minute)},
+			map[string]int{"s": 0, "d": 1, "e": 1}, 2, nil, false},
+		{"node cap", SubgraphOptions{Hops: 3, MaxNodes: 3},
+			map[string]int{"s": 0, "d": 1, "e": 1}, 2, []string{"s", "d", "e"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := (&Neo4jService{}).GetSubgraph(context.Background(), DefaultTenant, []string{id("s"), id("s")}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes := map[string]int{}
+			for _, n := range sub.Nodes {
+				nodes[n.ID[len(prefix)+1:]] = n.Hops
+			}
+			var frontier []string
+			for _, f := range sub.Frontier {
+				frontier = append(frontier, f[len(prefix)+1:])
+			}
+			sort.Strings(frontier)
+			sort.Strings(tt.frontier)
+			if !reflect.DeepEqual(nodes, tt.nodes) {
+				t.Errorf("nodes = %v, want %v", nodes, tt.nodes)
+			}
+			if !reflect.DeepEqual(frontier, tt.frontier) {
+				t.Errorf("frontier = %v, want %v", frontier, tt.frontier)
+			}
+			if len(sub.Edges) != tt.edges || sub.Truncated != tt.truncated {
+				t.Errorf("%d edges, truncated %v; want %d, %v", len(sub.Edges), sub.Truncated, tt.edges, tt.truncated)
+			}
+			for i := 1; i < len(sub.Edges); i++ {
+				if sub.Edges[i].Timestamp.Before(sub.Edges[i-1].Timestamp) {
+					t.Errorf("edges are not in time order")
+				}
+			}
+		})
+	}
+}
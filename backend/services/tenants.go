@@ -0,0 +1,214 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultTenant is the operator's own tenant. Data from before tenancy, and
+// users signed in by single sign-on, belong to it.
+const DefaultTenant = "SYNAPSE"
+
+var (
+	ErrTenantNotFound = errors.New("tenant not found")
+	ErrTenantExists   = errors.New("tenant already exists")
+)
+
+// tenantIDPattern is a bank code such as SCB or KBANK
+var tenantIDPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,15}$`)
+
+// ListTenants returns every participating bank, operator first
+func ListTenants() ([]models.Tenant, error) {
+	rows, err := db.DB.Query(`SELECT ` + tenantColumns + ` FROM tenants ORDER BY operator DESC, tenant_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := []models.Tenant{}
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// GetTenant loads one tenant by ID
+func GetTenant(tenantID string) (*models.Tenant, error) {
+	t, err := scanTenant(db.DB.QueryRow(`SELECT `+tenantColumns+` FROM tenants WHERE tenant_id = ?`, tenantID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTenant registers a participating bank. Only the seeded operator
+// tenant runs the platform, so new tenants never are one.
+func CreateTenant(tenantID, name string) (*models.Tenant, error) {
+	tenantID = strings.ToUpper(strings.TrimSpace(tenantID))
+	name = strings.TrimSpace(name)
+	if !tenantIDPattern.MatchString(tenantID) {
+		return nil, fmt.Errorf("tenant_id must be a bank code of 2-16 letters, digits or underscores, got %q", tenantID)
+	}
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	_, err := db.DB.Exec("INSERT INTO tenants (tenant_id, name, operator, created_at) VALUES (?, ?, 0, ?)", tenantID, name, time.Now().UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrTenantExists
+		}
+		return nil, err
+	}
+	return GetTenant(tenantID)
+}
+
+// IsOperatorTenant reports whether the tenant runs the platform. Its admins
+// manage users, keys and tenants of every bank.
+func IsOperatorTenant(tenantID string) bool {
+	t, err := GetTenant(tenantID)
+	return err == nil && t.Operator
+}
+
+// TransactionTenant returns the tenant that submitted txnID, "" when unknown
+func TransactionTenant(txnID string) (string, error) {
+	var tenant string
+	err := db.DB.QueryRow("SELECT coalesce(tenant_id, '') FROM graph_transactions WHERE txn_id = ?", txnID).Scan(&tenant)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return tenant, err
+}
+
+// graphTenants returns every tenant that has submitted transactions, for the
+// batch jobs that analyse each tenant's graph on its own
+func graphTenants() ([]string, error) {
+	rows, err := db.DB.Query("SELECT DISTINCT tenant_id FROM graph_transactions WHERE tenant_id IS NOT NULL ORDER BY tenant_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tenants []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// tenantColumns is the column list read by scanTenant
+const tenantColumns = `tenant_id, name, coalesce(operator, 0), created_at`
+
+func scanTenant(row interface{ Scan(...any) error }) (models.Tenant, error) {
+	var t models.Tenant
+	err := row.Scan(&t.TenantID, &t.Name, &t.Operator, &t.CreatedAt)
+	return t, err
+}
+
+// ---- Consortium view ----
+
+// ConsortiumMinReporters is the fewest other banks that must have seen an
+// account before a participating bank may see its shared signals. The
+// caller's own bank never counts, so the signals always blend at least two
+// other banks' data and never reveal a single one's.
+const ConsortiumMinReporters = 2
+
+// consortiumQuery aggregates the transfers of every bank other than the
+// caller's per account, as sender or receiver, over accounts the caller has
+// itself seen. It reads across tenants, so it must only ever select
+// aggregates. An empty caller is the operator, who sees every bank's data.
+const consortiumQuery = `
+	SELECT e.account_id, count(DISTINCT e.tenant_id), count(*), max(e.risk_score), avg(e.risk_score),
+		sum(CASE WHEN e.verification_status = 'CONFIRMED_FRAUD' THEN 1 ELSE 0 END), max(e.timestamp),
+		(SELECT count(*) FROM account_status s WHERE s.account_id = e.account_id AND s.status = ? AND s.tenant_id != ?)
+	FROM (
+		SELECT tenant_id, sender_account AS account_id, risk_score, verification_status, timestamp FROM graph_transactions WHERE tenant_id != ?
+		UNION ALL
+		SELECT tenant_id, receiver_account, risk_score, verification_status, timestamp FROM graph_transactions WHERE tenant_id != ?
+	) e
+	WHERE (? = '' OR EXISTS (
+		SELECT 1 FROM graph_transactions g
+		WHERE g.tenant_id = ? AND (g.sender_account = e.account_id OR g.receiver_account = e.account_id)
+	))`
+
+// consortiumArgs are the arguments of consortiumQuery for tenant
+func consortiumArgs(tenant string) []any {
+	return []any{models.AccountSuspectedMule, tenant, tenant, tenant, tenant, tenant}
+}
+
+// ConsortiumAccounts returns accounts tenant has seen that at least
+// minReporters other banks have too, riskiest first. Only shared signals are
+// exposed: how many other banks saw the account, its risk and how often it
+// was confirmed as fraud, never which banks or what was transferred. tenant
+// "" is the operator's view of every account and bank.
+func ConsortiumAccounts(tenant string, minReporters, limit int) ([]models.ConsortiumAccount, error) {
+	rows, err := db.DB.Query(consortiumQuery+`
+		GROUP BY e.account_id
+		HAVING count(DISTINCT e.tenant_id) >= ?
+		ORDER BY 6 DESC, 4 DESC, 2 DESC
+		LIMIT ?
+	`, append(consortiumArgs(tenant), minReporters, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []models.ConsortiumAccount{}
+	for rows.Next() {
+		a, err := scanConsortiumAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetConsortiumAccount returns the shared signals for one account, or nil
+// when tenant has not seen it or fewer than minReporters other banks have
+func GetConsortiumAccount(tenant, accountID string, minReporters int) (*models.ConsortiumAccount, error) {
+	a, err := scanConsortiumAccount(db.DB.QueryRow(consortiumQuery+`
+		AND e.account_id = ?
+		GROUP BY e.account_id
+		HAVING count(DISTINCT e.tenant_id) >= ?
+	`, append(consortiumArgs(tenant), accountID, minReporters)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func scanConsortiumAccount(row interface{ Scan(...any) error }) (models.ConsortiumAccount, error) {
+	var a models.ConsortiumAccount
+	var lastSeen string
+	err := row.Scan(&a.AccountID, &a.Reporters, &a.Transactions, &a.MaxRisk, &a.AvgRisk, &a.ConfirmedFraud, &lastSeen, &a.SuspectedMule)
+	if err != nil {
+		return a, err
+	}
+	// max() hands back the stored text rather than a time
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.Parse(layout, lastSeen); err == nil {
+			a.LastSeen = t
+			break
+		}
+	}
+	return a, nil
+}
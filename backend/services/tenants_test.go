@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// newTestTenant registers a bank no other test uses
+func newTestTenant(t *testing.T) string {
+	t.Helper()
+	tenant, err := CreateTenant(strings.ReplaceAll(testID("T"), "-", "_"), "Test Bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenant.TenantID
+}
+
+func TestCreateTenant(t *testing.T) {
+	id := strings.ReplaceAll(testID("t"), "-", "_")
+	created, err := CreateTenant(" "+id+" ", " Test Bank ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.TenantID != strings.ToUpper(id) || created.Name != "Test Bank" || created.Operator {
+		t.Errorf("created %+v", created)
+	}
+
+	tests := []struct {
+		name     string
+		tenantID string
+		bankName string
+		want     error // the specific error expected, if any
+	}{
+		{"taken", id, "Test Bank", ErrTenantExists},
+		{"too short", "X", "Test Bank", nil},
+		{"not a code", "SCB-2", "Test Bank", nil},
+		{"no name", strings.ReplaceAll(testID("T"), "-", "_"), " ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreateTenant(tt.tenantID, tt.bankName); err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Errorf("CreateTenant error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := GetTenant("NOPE"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("GetTenant(NOPE) = %v", err)
+	}
+	if !IsOperatorTenant(DefaultTenant) || IsOperatorTenant(created.TenantID) || IsOperatorTenant("NOPE") {
+		t.Error("only the seeded tenant runs the platform")
+	}
+	tenants, err := ListTenants()
+	if err != nil || len(tenants) < 2 || tenants[0].TenantID != DefaultTenant {
+		t.Errorf("ListTenants = %+v, %v", tenants, err)
+	}
+}
+
+func TestTransactionTenant(t *testing.T) {
+	bank := newTestTenant(t)
+	txnID := addTenantTransfer(t, bank, testID("ACC"), testID("ACC"), 100, time.Now())
+	if got, err := TransactionTenant(txnID); err != nil || got != bank {
+		t.Errorf("TransactionTenant = %q, %v", got, err)
+	}
+	if got, err := TransactionTenant(testID("TXN")); err != nil || got != "" {
+		t.Errorf("unknown transaction = %q, %v", got, err)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	bank, other := newTestTenant(t), newTestTenant(t)
+	account := testID("ACC")
+	alert := raise(t, models.Alert{TenantID: bank, Source: models.AlertSourceDecision, Type: "REVIEW",
+		TransactionID: testID("TXN"), AccountID: account, Summary: "scored Review"})
+
+	if _, err := GetCase(bank, alert.CaseID); err != nil {
+		t.Errorf("own case: %v", err)
+	}
+	if _, err := GetCase(other, alert.CaseID); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("other bank's case: %v", err)
+	}
+	if _, err := AssignCase(other, alert.CaseID, "somchai", "analyst"); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("assigned another bank's case: %v", err)
+	}
+	if cases, _ := ListCases(CaseFilter{TenantID: other, AccountID: account, Limit: 10}); len(cases) != 0 {
+		t.Errorf("other bank lists %+v", cases)
+	}
+
+	// The same account reported by another bank opens that bank's own case
+	theirs := raise(t, models.Alert{TenantID: other, Source: models.AlertSourceDecision, Type: "REVIEW",
+		TransactionID: testID("TXN"), AccountID: account, Summary: "scored Review"})
+	if theirs.CaseID == alert.CaseID {
+		t.Error("alerts of two banks filed into one case")
+	}
+}
+
+func TestConsortiumAccounts(t *testing.T) {
+	caller, a, b := newTestTenant(t), newTestTenant(t), newTestTenant(t)
+	shared, oneOther, unseen := testID("CONS"), testID("CONS"), testID("CONS")
+	now := time.Now().Add(-time.Hour)
+	own := addTenantTransfer(t, caller, testID("ACC"), shared, 100, now)
+	addTenantTransfer(t, a, testID("ACC"), shared, 100, now)
+	addTenantTransfer(t, a, shared, testID("ACC"), 100, now.Add(time.Minute))
+	fraud := addTenantTransfer(t, b, testID("ACC"), shared, 100, now.Add(2*time.Minute))
+	addTenantTransfer(t, caller, testID("ACC"), oneOther, 100, now)
+	addTenantTransfer(t, a, testID("ACC"), oneOther, 100, now)
+	addTenantTransfer(t, a, testID("ACC"), unseen, 100, now)
+	addTenantTransfer(t, b, testID("ACC"), unseen, 100, now)
+	if _, err := db.DB.Exec("UPDATE graph_transactions SET risk_score = 0.9, verification_status = 'CONFIRMED_FRAUD' WHERE txn_id = ?", fraud); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec("UPDATE graph_transactions SET risk_score = 0.95 WHERE txn_id = ?", own); err != nil {
+		t.Fatal(err)
+	}
+	for _, tenant := range []string{caller, a} {
+		if _, err := db.DB.Exec("INSERT INTO account_status (tenant_id, account_id, status) VALUES (?, ?, ?)", tenant, shared, models.AccountSuspectedMule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		tenant       string
+		account      string
+		minReporters int
+		want         *models.ConsortiumAccount // nil when the account must not be shown
+	}{
+		{"caller's own data left out", caller, shared, ConsortiumMinReporters,
+			&models.ConsortiumAccount{Reporters: 2, Transactions: 3, MaxRisk: 0.9, ConfirmedFraud: 1, SuspectedMule: 1}},
+		{"seen from another bank", a, shared, ConsortiumMinReporters,
+			&models.ConsortiumAccount{Reporters: 2, Transactions: 2, MaxRisk: 0.95, ConfirmedFraud: 1, SuspectedMule: 1}},
+		{"operator sees every bank", "", shared, 1,
+			&models.ConsortiumAccount{Reporters: 3, Transactions: 4, MaxRisk: 0.95, ConfirmedFraud: 1, SuspectedMule: 2}},
+		{"one other bank", caller, oneOther, ConsortiumMinReporters, nil},
+		{"not seen by the caller", caller, unseen, ConsortiumMinReporters, nil},
+		{"not seen by the caller without the floor", caller, unseen, 1, nil},
+		{"unseen account", "", testID("CONS"), 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetConsortiumAccount(tt.tenant, tt.account, tt.minReporters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("GetConsortiumAccount = %+v, want nothing", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("GetConsortiumAccount = nil")
+			}
+			if got.Reporters != tt.want.Reporters || got.Transactions != tt.want.Transactions || got.MaxRisk != tt.want.MaxRisk ||
+				got.ConfirmedFraud != tt.want.ConfirmedFraud || got.SuspectedMule != tt.want.SuspectedMule || got.LastSeen.IsZero() {
+				t.Errorf("GetConsortiumAccount = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	accounts, err := ConsortiumAccounts(caller, ConsortiumMinReporters, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := map[string]bool{}
+	for _, acc := range accounts {
+		listed[acc.AccountID] = true
+		if acc.Reporters < ConsortiumMinReporters {
+			t.Errorf("listed %+v seen by too few other banks", acc)
+		}
+	}
+	if !listed[shared] || listed[oneOther] || listed[unseen] {
+		t.Errorf("listed shared = %v, one other = %v, unseen = %v", listed[shared], listed[oneOther], listed[unseen])
+	}
+}
+
+func TestUpdateTransactionVerificationByTenant(t *testing.T) {
+	bank, other := newTestTenant(t), newTestTenant(t)
+	txnID := addTenantTransfer(t, bank, testID("ACC"), testID("ACC"), 100, time.Now())
+	status := func() string {
+		t.Helper()
+		var s string
+		if err := db.DB.QueryRow("SELECT coalesce(verification_status, '') FROM graph_transactions WHERE txn_id = ?", txnID).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := &Neo4jService{}
+	if err := s.UpdateTransactionVerification(context.Background(), other, txnID, "CONFIRMED_FRAUD"); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got == "CONFIRMED_FRAUD" {
+		t.Error("another bank's verdict was applied")
+	}
+	if err := s.UpdateTransactionVerification(context.Background(), bank, txnID, "CONFIRMED_FRAUD"); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "CONFIRMED_FRAUD" {
+		t.Errorf("verification_status = %q", got)
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Name, phone and proxy normalisation plus fuzzy string similarity,
+// shared by party resolution and screening.
+
+// thaiHonorifics are stripped from the start of a name, longest first
+var thaiHonorifics = []string{
+	"เด็กหญิง", "เด็กชาย", "นางสาว", "ด.ญ.", "ด.ช.", "น.ส.", "นาย", "นาง", "คุณ",
+}
+
+var latinTitles = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "dr": true, "khun": true,
+}
+
+// normalizeName lowercases, strips titles, Thai tone marks and punctuation,
+// and sorts tokens so "Mr. Somchai Jaidee" and "JAIDEE, Somchai" compare equal
+func normalizeName(name string) string {
+	return strings.Join(nameTokens(name), " ")
+}
+
+// nameTokens returns the normalised, sorted tokens of a name
+func nameTokens(name string) []string {
+	return tokenizeName(name, false)
+}
+
+// tokenizeName splits a name into sorted tokens. keepSilencer keeps the
+// thanthakhat, which romanisation needs to drop silent consonants.
+func tokenizeName(name string, keepSilencer bool) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, h := range thaiHonorifics {
+		if strings.HasPrefix(name, h) {
+			name = strings.TrimSpace(strings.TrimPrefix(name, h))
+			break
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == 0x0E4C && keepSilencer:
+			b.WriteRune(r)
+		case r >= 0x0E48 && r <= 0x0E4C:
+			// Thai tone marks and thanthakhat are often dropped or misplaced
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	var tokens []string
+	for _, t := range strings.Fields(b.String()) {
+		if !latinTitles[t] {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizePhone reduces Thai numbers to the local 0XXXXXXXXX form
+func normalizePhone(phone string) string {
+	d := digitsOnly(phone)
+	if strings.HasPrefix(d, "66") && len(d) == 11 {
+		d = "0" + d[2:]
+	}
+	return d
+}
+
+// normalizeProxy normalises a PromptPay proxy: phone numbers as phones,
+// national IDs and e-wallet IDs as bare digits
+func normalizeProxy(proxy string) string {
+	d := digitsOnly(proxy)
+	if len(d) == 10 || (len(d) == 11 && strings.HasPrefix(d, "66")) {
+		return normalizePhone(d)
+	}
+	return d
+}
+
+// jaroWinkler returns a similarity in [0, 1] between two strings, compared by rune
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 && len(s2) == 0 {
+		return 1
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	window := max(len(s1), len(s2))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		lo, hi := max(0, i-window), min(len(s2), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s1), len(s2)) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// Thai consonants as syllable initials and finals, roughly following RTGS
+var thaiInitials = map[rune]string{
+	'ก': "k", 'ข': "kh", 'ฃ': "kh", 'ค': "kh", 'ฅ': "kh", 'ฆ': "kh", 'ง': "ng",
+	'จ': "ch", 'ฉ': "ch", 'ช': "ch", 'ซ': "s", 'ฌ': "ch", 'ญ': "y",
+	'ฎ': "d", 'ฏ': "t", 'ฐ': "th", 'ฑ': "th", 'ฒ': "th", 'ณ': "n",
+	'ด': "d", 'ต': "t", 'ถ': "th", 'ท': "th", 'ธ': "th", 'น': "n",
+	'บ': "b", 'ป': "p", 'ผ': "ph", 'ฝ': "f", 'พ': "ph", 'ฟ': "f", 'ภ': "ph", 'ม': "m",
+	'ย': "y", 'ร': "r", 'ล': "l", 'ว': "w", 'ศ': "s", 'ษ': "s", 'ส': "s",
+	'ห': "h", 'ฬ': "l", 'อ': "", 'ฮ': "h",
+}
+
+var thaiFinals = map[rune]string{
+	'ก': "k", 'ข': "k", 'ค': "k", 'ฆ': "k", 'ง': "ng",
+	'จ': "t", 'ช': "t", 'ซ': "t", 'ฎ': "t", 'ฏ': "t", 'ฐ': "t", 'ฑ': "t", 'ฒ': "t",
+	'ด': "t", 'ต': "t", 'ถ': "t", 'ท': "t", 'ธ': "t", 'ศ': "t", 'ษ': "t", 'ส': "t",
+	'ญ': "n", 'ณ': "n", 'น': "n", 'ร': "n", 'ล': "n", 'ฬ': "n",
+	'บ': "p", 'ป': "p", 'พ': "p", 'ฟ': "p", 'ภ': "p", 'ม': "m",
+	'ย': "i", 'ว': "o",
+}
+
+// Vowels written after or above/below their consonant
+var thaiFollowingVowels = map[rune]string{
+	'ะ': "a", 'ั': "a", 'า': "a", 'ำ': "am", 'ิ': "i", 'ี': "i",
+	'ึ': "ue", 'ื': "ue", 'ุ': "u", 'ู': "u", '็': "",
+}
+
+// Vowels written before their consonant
+var thaiLeadingVowels = map[rune]string{
+	'เ': "e", 'แ': "ae", 'โ': "o", 'ใ': "ai", 'ไ': "ai",
+}
+
+// romanizeThai gives an approximate Latin spelling of a Thai word so it can be
+// compared with romanised names. It handles the common syllable shapes
+// (leading vowels, implicit o, finals, silenced consonants), not the full orthography.
+func romanizeThai(word string) string {
+	runes := []rune(word)
+	var b strings.Builder
+	leading := ""
+	hasInitial, hasNucleus := false, false
+
+	isFollowing := func(i int) bool {
+		if i >= len(runes) {
+			return false
+		}
+		_, ok := thaiFollowingVowels[runes[i]]
+		return ok
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if v, ok := thaiLeadingVowels[r]; ok {
+			leading = v
+			hasInitial, hasNucleus = false, false
+			continue
+		}
+		if v, ok := thaiFollowingVowels[r]; ok {
+			b.WriteString(leading + v)
+			leading = ""
+			hasNucleus = true
+			continue
+		}
+		initial, isConsonant := thaiInitials[r]
+		if !isConsonant {
+			if r < 0x0E00 || r > 0x0E7F {
+				b.WriteRune(r)
+			}
+			continue // tone marks and other signs
+		}
+		// A consonant under thanthakhat (possibly after a vowel sign) is silent
+		if i+1 < len(runes) && runes[i+1] == '์' {
+			i++
+			continue
+		}
+		if i+2 < len(runes) && runes[i+2] == '์' && isFollowing(i+1) {
+			i += 2
+			continue
+		}
+
+		switch {
+		case hasInitial && !hasNucleus && r == 'อ' && !isFollowing(i+1):
+			b.WriteString("o") // อ as the vowel "or"
+			hasNucleus = true
+		case hasNucleus && !isFollowing(i+1):
+			b.WriteString(thaiFinals[r])
+			hasInitial, hasNucleus = false, false
+		case hasInitial && !hasNucleus && !isFollowing(i+1) && leading == "":
+			b.WriteString("o" + thaiFinals[r]) // implicit o, as in สม "som"
+			hasInitial, hasNucleus = false, false
+		default:
+			b.WriteString(initial)
+			hasInitial, hasNucleus = true, false
+			if leading != "" && !isFollowing(i+1) {
+				b.WriteString(leading)
+				leading = ""
+				hasNucleus = true
+			}
+		}
+	}
+	if leading != "" {
+		b.WriteString(leading)
+	}
+	return b.String()
+}
+
+func isThai(s string) bool {
+	for _, r := range s {
+		if r >= 0x0E00 && r <= 0x0E7F {
+			return true
+		}
+	}
+	return false
+}
+
+// phoneticReplacements fold common alternative romanisations of Thai names
+// together, e.g. Jaidee/Chaidi, Phongsak/Pongsak, Sook/Suk
+var phoneticReplacements = strings.NewReplacer(
+	"ph", "p", "th", "t", "kh", "k", "ch", "c", "j", "c",
+	"ee", "i", "ii", "i", "oo", "u", "ou", "u", "v", "w", "ay", "ai",
+)
+
+// phoneticKey reduces a name token to a script-independent key for fuzzy comparison
+func phoneticKey(token string) string {
+	if isThai(token) {
+		token = romanizeThai(token)
+	}
+	token = phoneticReplacements.Replace(strings.ToLower(token))
+	var b strings.Builder
+	var prev rune
+	for _, r := range token {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// phoneticKeys returns the phonetic keys of a name's tokens
+func phoneticKeys(name string) []string {
+	tokens := tokenizeName(name, true)
+	keys := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if k := phoneticKey(t); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tokenMatchScore compares two names given as phonetic keys. Each token of the
+// listed name is paired with its best match in the candidate, so extra middle
+// names or reordered tokens on either side cost little; the whole-string
+// Jaro-Winkler score is used when it is higher.
+func tokenMatchScore(candidate, listed []string) float64 {
+	if len(candidate) == 0 || len(listed) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, l := range listed {
+		best := 0.0
+		for _, c := range candidate {
+			if s := jaroWinkler(c, l); s > best {
+				best = s
+			}
+		}
+		sum += best
+	}
+	tokenScore := sum / float64(len(listed))
+	// A single-token candidate matching one token of a longer listed name is weak evidence
+	if len(candidate) < len(listed) {
+		tokenScore *= float64(len(candidate)) / float64(len(listed))
+	}
+	whole := jaroWinkler(strings.Join(candidate, " "), strings.Join(listed, " "))
+	return max(tokenScore, whole)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Mr. Somchai Jaidee", "JAIDEE, Somchai"},
+		{"Khun Somchai Jaidee", "somchai  jaidee"},
+		{"นายสมชาย ใจดี", "สมชาย ใจดี"},
+		{"นางสาวสมหญิง ใจดี", "สมหญิง ใจดี"},
+		{"สมศักดิ์ ใจดี", "สมศักดิ ใจดี"}, // a dropped thanthakhat
+		{"สมชาย ใจดี", "ใจดี สมชาย"},      // family name first
+		{"Dr Anan O'Brien", "anan o brien"},
+	}
+	for _, tt := range tests {
+		if a, b := normalizeName(tt.a), normalizeName(tt.b); a != b {
+			t.Errorf("normalizeName(%q) = %q, normalizeName(%q) = %q", tt.a, a, tt.b, b)
+		}
+	}
+
+	if got := normalizeName("Mr. Mrs."); got != "" {
+		t.Errorf("a name of titles normalised to %q", got)
+	}
+	if a, b := normalizeName("Somchai Jaidee"), normalizeName("Somsak Jaidee"); a == b {
+		t.Errorf("different given names both normalised to %q", a)
+	}
+}
+
+func TestNormalizePhoneAndProxy(t *testing.T) {
+	tests := []struct {
+		fn       func(string) string
+		in, want string
+	}{
+		{normalizePhone, "081-234-5678", "0812345678"},
+		{normalizePhone, "+66 81 234 5678", "0812345678"},
+		{normalizePhone, "6681234567", "6681234567"}, // too short to be +66
+		{normalizeProxy, "+66812345678", "0812345678"},
+		{normalizeProxy, "081 234 5678", "0812345678"},
+		{normalizeProxy, "1-2345-67890-12-3", "1234567890123"},
+		{normalizeProxy, "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("normalise %q = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"MARTHA", "MARHTA", 0.961},
+		{"DWAYNE", "DUANE", 0.840},
+		{"DIXON", "DICKSONX", 0.813},
+		{"somchai", "somchai", 1},
+		{"", "", 1},
+		{"abc", "", 0},
+		{"abc", "xyz", 0},
+		{"สมชาย", "สมชาญ", 0.920},
+	}
+	for _, tt := range tests {
+		got := jaroWinkler(tt.a, tt.b)
+		if math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+		}
+		if back := jaroWinkler(tt.b, tt.a); math.Abs(back-got) > 1e-9 {
+			t.Errorf("jaroWinkler(%q, %q) = %v but %v the other way", tt.a, tt.b, got, back)
+		}
+	}
+}
+
+func TestPhoneticKey(t *testing.T) {
+	tests := []struct {
+		token, romanized, key string
+	}{
+		{"สมชาย", "somchai", "somcai"},
+		{"ใจดี", "chaidi", "caidi"},
+		{"สมศักดิ์", "somsak", "somsak"}, // silenced final consonant
+		{"พงษ์ศักดิ์", "phongsak", "pongsak"},
+		{"วิชัย", "wichai", "wicai"},
+		{"Jaidee", "", "caidi"},
+		{"Chaidi", "", "caidi"},
+		{"Phongsak", "", "pongsak"},
+		{"Sook", "", "suk"},
+	}
+	for _, tt := range tests {
+		if tt.romanized != "" {
+			if got := romanizeThai(tt.token); got != tt.romanized {
+				t.Errorf("romanizeThai(%q) = %q, want %q", tt.token, got, tt.romanized)
+			}
+		}
+		if got := phoneticKey(tt.token); got != tt.key {
+			t.Errorf("phoneticKey(%q) = %q, want %q", tt.token, got, tt.key)
+		}
+	}
+}
+
+func TestTokenMatchScore(t *testing.T) {
+	listed := phoneticKeys("Somchai Jaidee")
+	tests := []struct {
+		candidate string
+		min, max  float64
+	}{
+		{"Somchai Jaidee", 1, 1},
+		{"JAIDEE Somchay", 1, 1},
+		{"สมชาย ใจดี", 1, 1},
+		{"Somchai Boonmee Jaidee", 1, 1}, // an extra middle name
+		{"Somsak Jaidee", 0.9, 0.95},
+		{"Somchai", 0, 0.6}, // one token of two
+		{"Anan Wongsa", 0, 0.6},
+		{"", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tokenMatchScore(phoneticKeys(tt.candidate), listed); got < tt.min || got > tt.max {
+			t.Errorf("tokenMatchScore(%q) = %.3f, want %.2f to %.2f", tt.candidate, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Helpers for walking the TRANSFERRED graph from the SQLite record.
+// graph_transactions is always written, so these work with or without Neo4j.
+
+// loadOutgoingEdges returns up to limit of the most recent transfers sent by
+// accountID within tenant at or after since, ordered oldest first
+func loadOutgoingEdges(tenant, accountID string, since time.Time, limit int) ([]models.TransferEdge, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+transferEdgeColumns+`
+		FROM graph_transactions
+		WHERE tenant_id = ? AND sender_account = ? AND timestamp >= ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, tenant, accountID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edges := scanTransferEdges(rows)
+	sortEdgesByTime(edges)
+	return edges, nil
+}
+
+// edgeLoader returns up to limit of an account's most recent outgoing
+// transfers at or after since, oldest first. The cycle and path searches
+// expand the graph through one, a hop at a time, so their fan-out and
+// expansion bounds hold whichever store answers.
+type edgeLoader func(account string, since time.Time, limit int) ([]models.TransferEdge, error)
+
+// sqliteEdges loads tenant's transfers from the SQLite record
+func sqliteEdges(tenant string) edgeLoader {
+	return func(account string, since time.Time, limit int) ([]models.TransferEdge, error) {
+		return loadOutgoingEdges(tenant, account, since, limit)
+	}
+}
+
+// neo4jEdges loads tenant's transfers from Neo4j inside tx
+func neo4jEdges(ctx context.Context, tx neo4j.ManagedTransaction, tenant string) edgeLoader {
+	return func(account string, since time.Time, limit int) ([]models.TransferEdge, error) {
+		sinceParam := ""
+		if !since.IsZero() {
+			sinceParam = since.Format(time.RFC3339Nano)
+		}
+		res, err := tx.Run(ctx, `
+			MATCH (:Account {tenant: $tenant, id: $account_id})-[r:TRANSFERRED {tenant: $tenant}]->(b:Account)
+			WHERE $since = '' OR datetime(r.timestamp) >= datetime($since)
+			RETURN b.id as to, r {.txn_id, .amount, .timestamp, .risk_score, .verification_status} as edge
+			ORDER BY datetime(r.timestamp) DESC
+			LIMIT $limit
+		`, map[string]any{
+			"tenant":     tenant,
+			"account_id": account,
+			"since":      sinceParam,
+			"limit":      limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var edges []models.TransferEdge
+		for res.Next(ctx) {
+			rec := res.Record()
+			toVal, _ := rec.Get("to")
+			edgeVal, _ := rec.Get("edge")
+			to, _ := toVal.(string)
+			m, _ := edgeVal.(map[string]any)
+			edges = append(edges, edgeFromRecord(account, to, m))
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+		sortEdgesByTime(edges)
+		return edges, nil
+	}
+}
+
+// txTimeout bounds a Neo4j transaction by d, or leaves the server's default
+// when d is 0 (the driver reads 0 as no limit at all)
+func txTimeout(d time.Duration) func(*neo4j.TransactionConfig) {
+	if d <= 0 {
+		return neo4j.WithTxTimeout(math.MinInt)
+	}
+	return neo4j.WithTxTimeout(d)
+}
+
+// loadEdgesSince returns every transfer in tenant at or after since, oldest first
+func loadEdgesSince(tenant string, since time.Time) ([]models.TransferEdge, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+transferEdgeColumns+`
+		FROM graph_transactions
+		WHERE tenant_id = ? AND timestamp >= ?
+		ORDER BY timestamp ASC
+	`, tenant, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTransferEdges(rows), nil
+}
+
+// transferEdgeColumns is the column list read by scanTransferEdges
+const transferEdgeColumns = `txn_id, sender_account, receiver_account, amount, timestamp, risk_score, verification_status`
+
+func scanTransferEdges(rows *sql.Rows) []models.TransferEdge {
+	var edges []models.TransferEdge
+	for rows.Next() {
+		var e models.TransferEdge
+		var status sql.NullString
+		if err := rows.Scan(&e.TxnID, &e.From, &e.To, &e.Amount, &e.Timestamp, &e.RiskScore, &status); err != nil {
+			continue
+		}
+		e.VerificationStatus = status.String
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+func sortEdgesByTime(edges []models.TransferEdge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].Timestamp.Before(edges[j].Timestamp)
+	})
+}
+
+// edgeFromRecord converts a Cypher map projection of a TRANSFERRED relationship
+func edgeFromRecord(from, to string, m map[string]any) models.TransferEdge {
+	e := models.TransferEdge{
+		From:      from,
+		To:        to,
+		Amount:    toFloat(m["amount"]),
+		RiskScore: toFloat(m["risk_score"]),
+	}
+	if id, ok := m["txn_id"].(string); ok {
+		e.TxnID = id
+	}
+	if status, ok := m["verification_status"].(string); ok {
+		e.VerificationStatus = status
+	}
+	if ts, ok := m["timestamp"].(string); ok {
+		e.Timestamp, _ = time.Parse(time.RFC3339Nano, ts)
+	}
+	return e
+}
+
+// placeholders returns "?, ?, ?" for an IN clause of n values
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
+
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// toFloat normalises numeric values coming back from the Neo4j driver
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+func toStringSlice(v any) []string {
+	items, _ := v.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+// Permissions checked by the route groups in main.go
+const (
+	PermRead        = "read"        // graph, accounts, cases, alerts and other read-only views
+	PermIngest      = "ingest"      // submit transactions for scoring
+	PermInvestigate = "investigate" // propose verdicts and overrides, work cases, holds and hits, file STRs
+	PermApprove     = "approve"     // second pair of eyes on proposals
+	PermConfigure   = "configure"   // watchlists, FX rates, holidays, detection rules
+	PermAudit       = "audit"       // read and verify the audit trail
+	PermManageUsers = "manage_users"
+	PermTestBench   = "testbench" // synthetic data generation and reset
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("username is already taken")
+	ErrUnknownRole  = errors.New("unknown role")
+)
+
+// defaultRoles are created on first start. Their permissions live in
+// role_permissions, so an admin can adjust them without a release.
+var defaultRoles = []models.Role{
+	{Role: "viewer", Description: "Read-only access to dashboards and investigations",
+		Permissions: []string{PermRead}},
+	{Role: "analyst", Description: "Investigates alerts and proposes verdicts",
+		Permissions: []string{PermRead, PermInvestigate}},
+	{Role: "supervisor", Description: "Approves analysts' proposals and maintains detection settings",
+		Permissions: []string{PermRead, PermInvestigate, PermApprove, PermConfigure, PermAudit}},
+	{Role: "admin", Description: "Manages users and the platform",
+		Permissions: []string{PermRead, PermInvestigate, PermApprove, PermConfigure, PermAudit, PermManageUsers, PermTestBench}},
+	{Role: "ingest-service", Description: "Core banking integration that submits transactions",
+		Permissions: []string{PermIngest}},
+}
+
+// SeedRoles creates any default role missing from the database. Roles that
+// already exist keep their stored permissions.
+func SeedRoles() error {
+	for _, role := range defaultRoles {
+		res, err := db.DB.Exec("INSERT OR IGNORE INTO roles (role, description) VALUES (?, ?)", role.Role, role.Description)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		for _, perm := range role.Permissions {
+			if _, err := db.DB.Exec("INSERT OR IGNORE INTO role_permissions (role, permission) VALUES (?, ?)", role.Role, perm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role, permission string) bool {
+	var one int
+	err := db.DB.QueryRow("SELECT 1 FROM role_permissions WHERE role = ? AND permission = ?", role, permission).Scan(&one)
+	return err == nil
+}
+
+// ListRoles returns every role with its permissions
+func ListRoles() ([]models.Role, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.role, coalesce(r.description, ''), coalesce(p.permission, '')
+		FROM roles r LEFT JOIN role_permissions p ON p.role = r.role
+		ORDER BY r.role, p.permission
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []models.Role{}
+	for rows.Next() {
+		var name, description, perm string
+		if err := rows.Scan(&name, &description, &perm); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Role != name {
+			roles = append(roles, models.Role{Role: name, Description: description, Permissions: []string{}})
+		}
+		if perm != "" {
+			roles[len(roles)-1].Permissions = append(roles[len(roles)-1].Permissions, perm)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func roleExists(role string) bool {
+	var one int
+	return db.DB.QueryRow("SELECT 1 FROM roles WHERE role = ?", role).Scan(&one) == nil
+}
+
+// ListUsers returns tenant's users, or every user when tenant is empty,
+// without password hashes
+func ListUsers(tenant string) ([]models.User, error) {
+	rows, err := db.DB.Query(`SELECT `+userColumns+` FROM users WHERE ? = '' OR tenant_id = ? ORDER BY username`, tenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUser loads one user by ID
+func GetUser(id int64) (*models.User, error) {
+	u, err := scanUser(db.DB.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateUser adds a user of tenant with a bcrypt-hashed password
+func CreateUser(username, password, role, tenant string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if len(password) < 8 {
+		return nil, errors.New("password must be at least 8 characters")
+	}
+	if !roleExists(role) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRole, role)
+	}
+	if _, err := GetTenant(tenant); err != nil {
+		return nil, err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.DB.Exec("INSERT INTO users (username, password, role, tenant_id, disabled, created_at) VALUES (?, ?, ?, ?, 0, ?)",
+		username, hash, role, tenant, time.Now().UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return GetUser(id)
+}
+
+// ProvisionExternalUser returns the user an identity provider vouches for,
+// matched on the provider's issuer and subject and created in tenant on first
+// sign-in. Local accounts are never taken over, even when the usernames
+// match. The provider is the source of truth for the role, so it is updated
+// on every sign-in; the tenant is kept. External users get a random password
+// nobody knows, which keeps them out of the password login.
+func ProvisionExternalUser(identity *OIDCIdentity, tenant, source string) (*models.User, error) {
+	if identity.Issuer == "" || identity.Subject == "" {
+		return nil, errors.New("identity has no issuer or subject")
+	}
+	if !roleExists(identity.Role) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRole, identity.Role)
+	}
+	username, role := identity.Username, identity.Role
+	u, err := scanUser(db.DB.QueryRow(`SELECT `+userColumns+` FROM users WHERE auth_source = ? AND oidc_issuer = ? AND oidc_subject = ?`,
+		source, identity.Issuer, identity.Subject))
+	if err == sql.ErrNoRows {
+		// users provisioned before subjects were stored are bound on their
+		// next sign-in
+		u, err = scanUser(db.DB.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ? AND auth_source = ? AND oidc_subject IS NULL`,
+			username, source))
+		if err == nil {
+			if err := updateUser(u.ID, "oidc_issuer = ?, oidc_subject = ?", identity.Issuer, identity.Subject); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err == sql.ErrNoRows {
+		hash, err := HashPassword(newToken(""))
+		if err != nil {
+			return nil, err
+		}
+		res, err := db.DB.Exec(`INSERT INTO users (username, password, role, tenant_id, disabled, auth_source, oidc_issuer, oidc_subject, created_at)
+			VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)`,
+			username, hash, role, tenant, source, identity.Issuer, identity.Subject, time.Now().UTC())
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				return nil, ErrUserExists
+			}
+			return nil, err
+		}
+		id, _ := res.LastInsertId()
+		RecordAudit("", fmt.Sprintf("USER_PROVISIONED %s as %s of %s from %s", username, role, tenant, source))
+		return GetUser(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if u.Role != role {
+		if err := updateUser(u.ID, "role = ?", role); err != nil {
+			return nil, err
+		}
+		RecordAudit("", fmt.Sprintf("USER_ROLE_SYNCED %s from %s to %s by %s", u.Username, u.Role, role, source))
+		u.Role = role
+	}
+	return &u, nil
+}
+
+// SetUserRole changes a user's role; it applies from their next request
+func SetUserRole(id int64, role string) (*models.User, error) {
+	if !roleExists(role) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRole, role)
+	}
+	if err := updateUser(id, "role = ?", role); err != nil {
+		return nil, err
+	}
+	return GetUser(id)
+}
+
+// SetUserDisabled blocks or restores sign-in. Disabling also ends every open
+// session of the user.
+func SetUserDisabled(id int64, disabled bool) (*models.User, error) {
+	if err := updateUser(id, "disabled = ?", disabled); err != nil {
+		return nil, err
+	}
+	u, err := GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		if _, err := RevokeUserSessions(u.Username); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+func updateUser(id int64, set string, values ...any) error {
+	res, err := db.DB.Exec("UPDATE users SET "+set+" WHERE id = ?", append(values, id)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// userColumns is the column list read by scanUser
+const userColumns = `id, username, coalesce(role, ''), coalesce(tenant_id, 'SYNAPSE'), coalesce(disabled, 0), coalesce(auth_source, 'local'), created_at, coalesce(password_change_required, 0)`
+
+func scanUser(row interface{ Scan(...any) error }) (models.User, error) {
+	var u models.User
+	var created sql.NullTime
+	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.TenantID, &u.Disabled, &u.AuthSource, &created, &u.PasswordChangeRequired)
+	if created.Valid {
+		u.CreatedAt = &created.Time
+	}
+	return u, err
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"bank-fraud-demo/db"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"viewer", PermRead, true},
+		{"viewer", PermInvestigate, false},
+		{"analyst", PermInvestigate, true},
+		{"analyst", PermApprove, false},
+		{"analyst", PermIngest, false},
+		{"supervisor", PermApprove, true},
+		{"supervisor", PermAudit, true},
+		{"supervisor", PermManageUsers, false},
+		{"admin", PermManageUsers, true},
+		{"admin", PermTestBench, true},
+		{"admin", PermIngest, false},
+		{"ingest-service", PermIngest, true},
+		{"ingest-service", PermRead, false},
+		{"", PermRead, false},
+		{"root", PermRead, false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestSeedRolesKeepsStoredPermissions(t *testing.T) {
+	if _, err := db.DB.Exec("DELETE FROM role_permissions WHERE role = 'viewer' AND permission = ?", PermRead); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Exec("INSERT INTO role_permissions (role, permission) VALUES ('viewer', ?)", PermRead) })
+	if err := SeedRoles(); err != nil {
+		t.Fatal(err)
+	}
+	if HasPermission("viewer", PermRead) {
+		t.Error("SeedRoles restored a permission an admin removed")
+	}
+
+	roles, err := ListRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != len(defaultRoles) {
+		t.Errorf("ListRoles = %+v", roles)
+	}
+	for _, r := range roles {
+		if r.Role == "viewer" && len(r.Permissions) != 0 {
+			t.Errorf("viewer = %+v", r)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	existing := newTestUser(t, "viewer")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		role     string
+		tenant   string
+		want     error // the specific error expected, if any
+		wantErr  bool
+	}{
+		{"valid", testUsername("new-user"), testPassword, "analyst", DefaultTenant, nil, false},
+		{"other tenant", testUsername("scb-user"), testPassword, "analyst", "SCB", nil, false},
+		{"empty username", "  ", testPassword, "analyst", DefaultTenant, nil, true},
+		{"short password", "short@test.bank", "1234567", "analyst", DefaultTenant, nil, true},
+		{"unknown role", "role@test.bank", testPassword, "root", DefaultTenant, ErrUnknownRole, true},
+		{"unknown tenant", "tenant@test.bank", testPassword, "analyst", "NOPE", ErrTenantNotFound, true},
+		{"taken username", existing.Username, testPassword, "analyst", DefaultTenant, ErrUserExists, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := CreateUser(tt.username, tt.password, tt.role, tt.tenant)
+			if tt.wantErr {
+				if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+					t.Fatalf("CreateUser error = %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			if user.Username != tt.username || user.Role != tt.role || user.TenantID != tt.tenant || user.Disabled || user.CreatedAt == nil {
+				t.Errorf("created %+v", user)
+			}
+		})
+	}
+
+}
+
+func TestListUsersByTenant(t *testing.T) {
+	user, err := CreateUser(testUsername("kbank-user"), testPassword, "analyst", "KBANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tenant string
+		want   bool
+	}{
+		{"KBANK", true},
+		{"", true}, // every tenant
+		{DefaultTenant, false},
+		{"SCB", false},
+	}
+	for _, tt := range tests {
+		users, err := ListUsers(tt.tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, u := range users {
+			found = found || u.ID == user.ID
+			if tt.tenant != "" && u.TenantID != tt.tenant {
+				t.Errorf("ListUsers(%q) returned %s of %s", tt.tenant, u.Username, u.TenantID)
+			}
+		}
+		if found != tt.want {
+			t.Errorf("ListUsers(%q) includes the KBANK user = %v, want %v", tt.tenant, found, tt.want)
+		}
+	}
+}
+
+func TestSetUserRole(t *testing.T) {
+	user := newTestUser(t, "viewer")
+	if _, err := SetUserRole(user.ID, "root"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("unknown role: %v", err)
+	}
+	if _, err := SetUserRole(999999, "analyst"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: %v", err)
+	}
+	if IsReviewer(DefaultTenant, user.Username) {
+		t.Error("viewer may approve")
+	}
+	updated, err := SetUserRole(user.ID, "supervisor")
+	if err != nil || updated.Role != "supervisor" || !IsReviewer(DefaultTenant, user.Username) {
+		t.Fatalf("SetUserRole = %+v, %v", updated, err)
+	}
+	if IsReviewer("SCB", user.Username) {
+		t.Error("supervisor may approve for another bank")
+	}
+}
+
+func TestSetUserDisabledEndsSessions(t *testing.T) {
+	user := newTestUser(t, "supervisor")
+	auth := NewAuthService()
+	auth.MFA.RequiredRoles = nil // the MFA policy has its own tests
+	pair, err := auth.Login(user.Username, testPassword, "198.51.100.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetUserDisabled(user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("disabled user's session still works: %v", err)
+	}
+	if _, err := auth.Refresh(pair.RefreshToken, "198.51.100.10"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("disabled user's refresh token still works: %v", err)
+	}
+	if IsReviewer(DefaultTenant, user.Username) {
+		t.Error("disabled supervisor may approve")
+	}
+	if _, err := SetUserDisabled(999999, true); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: %v", err)
+	}
+}
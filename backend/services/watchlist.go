@@ -0,0 +1,671 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bank-fraud-demo/db"
+	"bank-fraud-demo/models"
+)
+
+var (
+	ErrWatchlistNotFound = errors.New("watchlist not found")
+	ErrHitNotFound       = errors.New("watchlist hit not found")
+)
+
+// WatchlistService screens counterparties against imported lists. Entries are
+// held in an in-memory index rebuilt whenever a list changes.
+type WatchlistService struct {
+	// MatchThreshold is the name score at which a hit is raised (the transaction goes to Review)
+	MatchThreshold float64
+	// StrongThreshold is the name score treated as a confident match, applying the list's own action
+	StrongThreshold float64
+
+	mu           sync.RWMutex
+	lists        map[string]models.Watchlist
+	entries      map[int64]models.WatchlistEntry
+	byAccount    map[string][]int64
+	byProxy      map[string][]int64
+	byNationalID map[string][]int64
+	names        []indexedName
+	byInitial    map[byte][]int // first byte of a phonetic key -> indexes into names
+}
+
+// indexedName is one name or alias of an entry, pre-tokenised for matching
+type indexedName struct {
+	entryID int64
+	alias   string // empty for the primary name
+	keys    []string
+}
+
+func NewWatchlistService() *WatchlistService {
+	return &WatchlistService{MatchThreshold: 0.85, StrongThreshold: 0.95}
+}
+
+// Load rebuilds the screening index from the database
+func (w *WatchlistService) Load() error {
+	lists := map[string]models.Watchlist{}
+	rows, err := db.DB.Query(`SELECT list_id, name, source, action, created_at, updated_at FROM watchlists`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var l models.Watchlist
+		if err := rows.Scan(&l.ListID, &l.Name, &l.Source, &l.Action, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			continue
+		}
+		lists[l.ListID] = l
+	}
+	rows.Close()
+
+	rows, err = db.DB.Query(`
+		SELECT entry_id, list_id, coalesce(reference, ''), name, coalesce(aliases, ''), coalesce(account_id, ''),
+			coalesce(promptpay_proxy, ''), coalesce(national_id_hash, ''), added_at
+		FROM watchlist_entries
+	`)
+	if err != nil {
+		return err
+	}
+	entries := map[int64]models.WatchlistEntry{}
+	for rows.Next() {
+		var e models.WatchlistEntry
+		var aliases string
+		if err := rows.Scan(&e.EntryID, &e.ListID, &e.Reference, &e.Name, &aliases, &e.AccountID,
+			&e.PromptPayProxy, &e.NationalIDHash, &e.AddedAt); err != nil {
+			continue
+		}
+		_ = json.Unmarshal([]byte(aliases), &e.Aliases)
+		entries[e.EntryID] = e
+	}
+	rows.Close()
+
+	byAccount := map[string][]int64{}
+	byProxy := map[string][]int64{}
+	byNationalID := map[string][]int64{}
+	var names []indexedName
+	byInitial := map[byte][]int{}
+	for id, e := range entries {
+		l, ok := lists[e.ListID]
+		if !ok {
+			continue
+		}
+		l.EntryCount++
+		lists[e.ListID] = l
+
+		if e.AccountID != "" {
+			byAccount[e.AccountID] = append(byAccount[e.AccountID], id)
+		}
+		if p := normalizeProxy(e.PromptPayProxy); p != "" {
+			byProxy[p] = append(byProxy[p], id)
+		}
+		if e.NationalIDHash != "" {
+			byNationalID[e.NationalIDHash] = append(byNationalID[e.NationalIDHash], id)
+		}
+		for i, name := range append([]string{e.Name}, e.Aliases...) {
+			keys := phoneticKeys(name)
+			if len(keys) == 0 {
+				continue
+			}
+			n := indexedName{entryID: id, keys: keys}
+			if i > 0 {
+				n.alias = name
+			}
+			names = append(names, n)
+			seen := map[byte]bool{}
+			for _, k := range keys {
+				if !seen[k[0]] {
+					seen[k[0]] = true
+					byInitial[k[0]] = append(byInitial[k[0]], len(names)-1)
+				}
+			}
+		}
+	}
+
+	w.mu.Lock()
+	w.lists, w.entries = lists, entries
+	w.byAccount, w.byProxy, w.byNationalID = byAccount, byProxy, byNationalID
+	w.names, w.byInitial = names, byInitial
+	w.mu.Unlock()
+	return nil
+}
+
+// ScreeningSubject is one counterparty to screen
+type ScreeningSubject struct {
+	Role           string `json:"role,omitempty"`
+	AccountID      string `json:"account_id,omitempty"`
+	Name           string `json:"name,omitempty"`
+	PromptPayProxy string `json:"promptpay_proxy,omitempty"`
+	NationalIDHash string `json:"national_id_hash,omitempty"`
+}
+
+// Screen returns the best match per listed entry for a subject, strongest first.
+// Identifier matches score 1; names are matched phonetically so Thai script and
+// common romanisations of the same name meet. Candidates are blocked on the
+// first letter of each name token, so a typo in every first letter is missed.
+func (w *WatchlistService) Screen(subject ScreeningSubject) []models.WatchlistMatch {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	best := map[int64]models.WatchlistMatch{}
+	consider := func(entryID int64, field, value, alias string, score float64) {
+		if cur, ok := best[entryID]; ok && cur.Score >= score {
+			return
+		}
+		e := w.entries[entryID]
+		l := w.lists[e.ListID]
+		action := "Review"
+		if score >= w.StrongThreshold {
+			action = l.Action
+		}
+		best[entryID] = models.WatchlistMatch{
+			ListID:       l.ListID,
+			ListName:     l.Name,
+			EntryID:      entryID,
+			EntryName:    e.Name,
+			MatchedField: field,
+			MatchedValue: value,
+			MatchedAlias: alias,
+			Score:        score,
+			Action:       action,
+		}
+	}
+
+	if subject.AccountID != "" {
+		for _, id := range w.byAccount[subject.AccountID] {
+			consider(id, "account", subject.AccountID, "", 1)
+		}
+	}
+	if p := normalizeProxy(subject.PromptPayProxy); p != "" {
+		for _, id := range w.byProxy[p] {
+			consider(id, "promptpay", subject.PromptPayProxy, "", 1)
+		}
+	}
+	if subject.NationalIDHash != "" {
+		for _, id := range w.byNationalID[subject.NationalIDHash] {
+			consider(id, "national_id", subject.NationalIDHash, "", 1)
+		}
+	}
+
+	if keys := phoneticKeys(subject.Name); len(keys) > 0 {
+		candidates := map[int]bool{}
+		for _, k := range keys {
+			for _, i := range w.byInitial[k[0]] {
+				candidates[i] = true
+			}
+		}
+		for i := range candidates {
+			n := w.names[i]
+			if score := tokenMatchScore(keys, n.keys); score >= w.MatchThreshold {
+				consider(n.entryID, "name", subject.Name, n.alias, score)
+			}
+		}
+	}
+
+	matches := make([]models.WatchlistMatch, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].EntryID < matches[j].EntryID
+	})
+	return matches
+}
+
+// ScreenTransaction screens sender and receiver, using the KYC attributes on the
+// transaction or, when absent, those of the account's resolved party. Matches an
+// analyst has already dismissed for the same value are skipped; the rest are
+// recorded as open hits and returned.
+func (w *WatchlistService) ScreenTransaction(txn models.Transaction) ([]models.WatchlistHit, error) {
+	sides := []struct {
+		role, account string
+		attrs         *models.PartyAttributes
+	}{
+		{"sender", txn.SenderAccount, txn.SenderParty},
+		{"receiver", txn.ReceiverAccount, txn.ReceiverParty},
+	}
+
+	var hits []models.WatchlistHit
+	for _, side := range sides {
+		subject := ScreeningSubject{Role: side.role, AccountID: side.account}
+		if side.attrs != nil {
+			subject.Name, subject.PromptPayProxy, subject.NationalIDHash = side.attrs.Name, side.attrs.PromptPayProxy, side.attrs.NationalIDHash
+		} else if party, err := GetAccountParty(txn.TenantID, side.account); err == nil && party != nil {
+			subject.Name, subject.PromptPayProxy, subject.NationalIDHash = party.Name, party.PromptPayProxy, party.NationalIDHash
+		}
+
+		for _, m := range w.Screen(subject) {
+			dismissed, err := hitDismissed(txn.TenantID, m)
+			if err != nil {
+				return hits, err
+			}
+			if dismissed {
+				continue
+			}
+			hit := models.WatchlistHit{
+				WatchlistMatch: m,
+				TransactionID:  txn.TransactionID,
+				Role:           side.role,
+				AccountID:      side.account,
+				Status:         models.HitOpen,
+				CreatedAt:      time.Now(),
+			}
+			if hit.HitID, err = saveHit(txn.TenantID, hit); err != nil {
+				return hits, err
+			}
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// hitDismissed reports whether one of tenant's analysts dismissed this entry for
+// this value before
+func hitDismissed(tenant string, m models.WatchlistMatch) (bool, error) {
+	var n int
+	err := db.DB.QueryRow(`
+		SELECT count(*) FROM watchlist_hits
+		WHERE tenant_id = ? AND entry_id = ? AND matched_field = ? AND matched_value = ? AND status = ?
+	`, tenant, m.EntryID, m.MatchedField, m.MatchedValue, models.HitDismissed).Scan(&n)
+	return n > 0, err
+}
+
+func saveHit(tenant string, h models.WatchlistHit) (int64, error) {
+	res, err := db.DB.Exec(`
+		INSERT INTO watchlist_hits (tenant_id, txn_id, role, account_id, list_id, list_name, entry_id, entry_name,
+			matched_field, matched_value, matched_alias, score, action, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, tenant, h.TransactionID, h.Role, h.AccountID, h.ListID, h.ListName, h.EntryID, h.EntryName,
+		h.MatchedField, h.MatchedValue, h.MatchedAlias, h.Score, h.Action, h.Status, h.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// WatchlistAction is the strictest action demanded by a set of hits
+func WatchlistAction(hits []models.WatchlistHit) string {
+	action := ""
+	for _, h := range hits {
+		if h.Action == "Block" {
+			return "Block"
+		}
+		action = h.Action
+	}
+	return action
+}
+
+// ---- List management ----
+
+// CreateWatchlist stores a new, empty list
+func (w *WatchlistService) CreateWatchlist(l models.Watchlist) (models.Watchlist, error) {
+	l.Name = strings.TrimSpace(l.Name)
+	if l.Name == "" {
+		return l, errors.New("name is required")
+	}
+	if l.ListID == "" {
+		b := make([]byte, 4)
+		_, _ = rand.Read(b)
+		l.ListID = "WL-" + strings.ToUpper(hex.EncodeToString(b))
+	}
+	l.Source = strings.ToUpper(strings.TrimSpace(l.Source))
+	if l.Source == "" {
+		l.Source = "INTERNAL"
+	}
+	switch l.Action {
+	case "":
+		l.Action = "Review"
+	case "Review", "Block":
+	default:
+		return l, fmt.Errorf("action must be Review or Block")
+	}
+	l.CreatedAt = time.Now()
+	l.UpdatedAt = l.CreatedAt
+
+	_, err := db.DB.Exec(`
+		INSERT INTO watchlists (list_id, name, source, action, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, l.ListID, l.Name, l.Source, l.Action, l.CreatedAt, l.UpdatedAt)
+	if err != nil {
+		return l, err
+	}
+	return l, w.Load()
+}
+
+// Watchlists returns every list with its entry count
+func (w *WatchlistService) Watchlists() []models.Watchlist {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	lists := make([]models.Watchlist, 0, len(w.lists))
+	for _, l := range w.lists {
+		lists = append(lists, l)
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ListID < lists[j].ListID })
+	return lists
+}
+
+func (w *WatchlistService) listExists(listID string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.lists[listID]
+	return ok
+}
+
+// DeleteWatchlist removes a list and its entries. Recorded hits are kept.
+func (w *WatchlistService) DeleteWatchlist(listID string) error {
+	if !w.listExists(listID) {
+		return ErrWatchlistNotFound
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM watchlist_entries WHERE list_id = ?", listID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM watchlists WHERE list_id = ?", listID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return w.Load()
+}
+
+// Entries returns up to limit entries of a list
+func (w *WatchlistService) Entries(listID string, limit int) ([]models.WatchlistEntry, error) {
+	if !w.listExists(listID) {
+		return nil, ErrWatchlistNotFound
+	}
+	w.mu.RLock()
+	entries := []models.WatchlistEntry{}
+	for _, e := range w.entries {
+		if e.ListID == listID {
+			entries = append(entries, e)
+		}
+	}
+	w.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].EntryID < entries[j].EntryID })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// AddEntries appends entries to a list, or replaces its contents when replace is set
+func (w *WatchlistService) AddEntries(listID string, entries []models.WatchlistEntry, replace bool) error {
+	if !w.listExists(listID) {
+		return ErrWatchlistNotFound
+	}
+	for i, e := range entries {
+		if strings.TrimSpace(e.Name) == "" && e.AccountID == "" && e.PromptPayProxy == "" && e.NationalIDHash == "" {
+			return fmt.Errorf("entry %d: needs a name or an identifier", i+1)
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if replace {
+		if _, err := tx.Exec("DELETE FROM watchlist_entries WHERE list_id = ?", listID); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, e := range entries {
+		aliases, _ := json.Marshal(e.Aliases)
+		_, err := tx.Exec(`
+			INSERT INTO watchlist_entries (list_id, reference, name, aliases, account_id, promptpay_proxy, national_id_hash, added_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, listID, e.Reference, strings.TrimSpace(e.Name), string(aliases), e.AccountID, e.PromptPayProxy, e.NationalIDHash, now)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE watchlists SET updated_at = ? WHERE list_id = ?", now, listID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return w.Load()
+}
+
+// DeleteEntry removes one entry from a list
+func (w *WatchlistService) DeleteEntry(listID string, entryID int64) error {
+	res, err := db.DB.Exec("DELETE FROM watchlist_entries WHERE list_id = ? AND entry_id = ?", listID, entryID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWatchlistNotFound
+	}
+	return w.Load()
+}
+
+// ---- Import formats ----
+
+// ParseWatchlistEntries reads a CSV file with a header row (name, aliases,
+// account_id, promptpay_proxy, national_id_hash, reference; aliases separated
+// by ";") or XML, either the UN consolidated sanctions list or
+// <watchlist><entry><name/><alias/>...</entry></watchlist>
+func ParseWatchlistEntries(data []byte) ([]models.WatchlistEntry, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))
+	if bytes.HasPrefix(data, []byte("<")) {
+		return parseWatchlistXML(data)
+	}
+	return parseWatchlistCSV(data)
+}
+
+func parseWatchlistCSV(data []byte) ([]models.WatchlistEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must include a name column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var entries []models.WatchlistEntry
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := models.WatchlistEntry{
+			Name:           field(rec, "name"),
+			AccountID:      field(rec, "account_id"),
+			PromptPayProxy: field(rec, "promptpay_proxy"),
+			NationalIDHash: field(rec, "national_id_hash"),
+			Reference:      field(rec, "reference"),
+		}
+		for _, a := range strings.Split(field(rec, "aliases"), ";") {
+			if a = strings.TrimSpace(a); a != "" {
+				e.Aliases = append(e.Aliases, a)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// unConsolidatedList is the subset of the UN Security Council consolidated list XML used for screening
+type unConsolidatedList struct {
+	Individuals []unParty `xml:"INDIVIDUALS>INDIVIDUAL"`
+	Entities    []unParty `xml:"ENTITIES>ENTITY"`
+}
+
+type unParty struct {
+	Reference  string   `xml:"REFERENCE_NUMBER"`
+	FirstName  string   `xml:"FIRST_NAME"`
+	SecondName string   `xml:"SECOND_NAME"`
+	ThirdName  string   `xml:"THIRD_NAME"`
+	FourthName string   `xml:"FOURTH_NAME"`
+	Original   string   `xml:"NAME_ORIGINAL_SCRIPT"`
+	Aliases    []string `xml:"INDIVIDUAL_ALIAS>ALIAS_NAME"`
+	EntityAKA  []string `xml:"ENTITY_ALIAS>ALIAS_NAME"`
+}
+
+type simpleWatchlist struct {
+	Entries []struct {
+		Reference      string   `xml:"reference"`
+		Name           string   `xml:"name"`
+		Aliases        []string `xml:"alias"`
+		AccountID      string   `xml:"account_id"`
+		PromptPayProxy string   `xml:"promptpay_proxy"`
+		NationalIDHash string   `xml:"national_id_hash"`
+	} `xml:"entry"`
+}
+
+func parseWatchlistXML(data []byte) ([]models.WatchlistEntry, error) {
+	var root struct{ XMLName xml.Name }
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var entries []models.WatchlistEntry
+	if root.XMLName.Local == "CONSOLIDATED_LIST" {
+		var list unConsolidatedList
+		if err := xml.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		for _, p := range append(list.Individuals, list.Entities...) {
+			e := models.WatchlistEntry{
+				Reference: strings.TrimSpace(p.Reference),
+				Name:      strings.Join(strings.Fields(strings.Join([]string{p.FirstName, p.SecondName, p.ThirdName, p.FourthName}, " ")), " "),
+			}
+			for _, a := range append(append([]string{p.Original}, p.Aliases...), p.EntityAKA...) {
+				if a = strings.TrimSpace(a); a != "" {
+					e.Aliases = append(e.Aliases, a)
+				}
+			}
+			if e.Name != "" {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+
+	var list simpleWatchlist
+	if err := xml.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, s := range list.Entries {
+		e := models.WatchlistEntry{
+			Reference:      strings.TrimSpace(s.Reference),
+			Name:           strings.TrimSpace(s.Name),
+			AccountID:      strings.TrimSpace(s.AccountID),
+			PromptPayProxy: strings.TrimSpace(s.PromptPayProxy),
+			NationalIDHash: strings.TrimSpace(s.NationalIDHash),
+		}
+		for _, a := range s.Aliases {
+			if a = strings.TrimSpace(a); a != "" {
+				e.Aliases = append(e.Aliases, a)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ---- Hits ----
+
+const watchlistHitColumns = `hit_id, txn_id, role, account_id, list_id, list_name, entry_id, entry_name,
+	matched_field, matched_value, coalesce(matched_alias, ''), score, action, status,
+	coalesce(adjudicated_by, ''), coalesce(note, ''), adjudicated_at, created_at`
+
+func scanWatchlistHit(row interface{ Scan(...any) error }) (models.WatchlistHit, error) {
+	var h models.WatchlistHit
+	var adjudicatedAt sql.NullTime
+	err := row.Scan(&h.HitID, &h.TransactionID, &h.Role, &h.AccountID, &h.ListID, &h.ListName, &h.EntryID, &h.EntryName,
+		&h.MatchedField, &h.MatchedValue, &h.MatchedAlias, &h.Score, &h.Action, &h.Status,
+		&h.AdjudicatedBy, &h.Note, &adjudicatedAt, &h.CreatedAt)
+	if adjudicatedAt.Valid {
+		h.AdjudicatedAt = &adjudicatedAt.Time
+	}
+	return h, err
+}
+
+// ListWatchlistHits returns tenant's hits, newest first, optionally filtered by
+// status and account
+func ListWatchlistHits(tenant, status, accountID string, limit int) ([]models.WatchlistHit, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+watchlistHitColumns+`
+		FROM watchlist_hits
+		WHERE tenant_id = ? AND (? = '' OR status = ?) AND (? = '' OR account_id = ?)
+		ORDER BY created_at DESC, hit_id DESC
+		LIMIT ?
+	`, tenant, status, status, accountID, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.WatchlistHit{}
+	for rows.Next() {
+		h, err := scanWatchlistHit(rows)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, h)
+	}
+	return hits, nil
+}
+
+// AdjudicateHit records an analyst's decision on one of tenant's hits. Dismissed
+// hits suppress the tenant's future hits for the same entry and matched value.
+func AdjudicateHit(tenant string, hitID int64, status, analyst, note string) (*models.WatchlistHit, error) {
+	if status != models.HitConfirmed && status != models.HitDismissed {
+		return nil, fmt.Errorf("status must be %s or %s", models.HitConfirmed, models.HitDismissed)
+	}
+	res, err := db.DB.Exec(`
+		UPDATE watchlist_hits SET status = ?, adjudicated_by = ?, note = ?, adjudicated_at = ?
+		WHERE hit_id = ? AND tenant_id = ?
+	`, status, analyst, note, time.Now(), hitID, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrHitNotFound
+	}
+	h, err := scanWatchlistHit(db.DB.QueryRow(`SELECT `+watchlistHitColumns+` FROM watchlist_hits WHERE hit_id = ?`, hitID))
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"bank-fraud-demo/models"
+)
+
+// newTestWatchlist creates a list holding entries and deletes it when the test ends
+func newTestWatchlist(t *testing.T, w *WatchlistService, action string, entries ...models.WatchlistEntry) models.Watchlist {
+	t.Helper()
+	l, err := w.CreateWatchlist(models.Watchlist{Name: testID("Test list"), Source: "internal", Action: action})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.DeleteWatchlist(l.ListID) })
+	if err := w.AddEntries(l.ListID, entries, false); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestScreen(t *testing.T) {
+	w := NewWatchlistService()
+	account, nationalID := testID("WL-ACC"), testID("nid")
+	l := newTestWatchlist(t, w, "Block",
+		models.WatchlistEntry{Name: "Somchai Jaidee", Aliases: []string{"Big Sam"}},
+		models.WatchlistEntry{Name: "Mule account", AccountID: account},
+		models.WatchlistEntry{Name: "Proxy holder", PromptPayProxy: "081-234-5678", NationalIDHash: nationalID},
+	)
+	entries, err := w.Entries(l.ListID, 10)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Entries = %v, %v", entries, err)
+	}
+	entryName := map[int64]string{}
+	for _, e := range entries {
+		entryName[e.EntryID] = e.Name
+	}
+
+	type match struct {
+		entry, field, alias, action string
+	}
+	tests := []struct {
+		name    string
+		subject ScreeningSubject
+		want    []match
+	}{
+		{"exact name", ScreeningSubject{Name: "Somchai Jaidee"},
+			[]match{{"Somchai Jaidee", "name", "", "Block"}}},
+		{"another romanisation", ScreeningSubject{Name: "Mr. Somchay Chaidee"},
+			[]match{{"Somchai Jaidee", "name", "", "Block"}}},
+		{"Thai script", ScreeningSubject{Name: "นายสมชาย ใจดี"},
+			[]match{{"Somchai Jaidee", "name", "", "Block"}}},
+		{"alias", ScreeningSubject{Name: "big sam"},
+			[]match{{"Somchai Jaidee", "name", "Big Sam", "Block"}}},
+		{"similar name is only reviewed", ScreeningSubject{Name: "Somsak Jaidee"},
+			[]match{{"Somchai Jaidee", "name", "", "Review"}}},
+		{"given name alone", ScreeningSubject{Name: "Somchai"}, nil},
+		{"unrelated name", ScreeningSubject{Name: "Anan Wongsa"}, nil},
+		{"account", ScreeningSubject{AccountID: account},
+			[]match{{"Mule account", "account", "", "Block"}}},
+		{"proxy in international form", ScreeningSubject{PromptPayProxy: "+66812345678"},
+			[]match{{"Proxy holder", "promptpay", "", "Block"}}},
+		{"national ID and name together", ScreeningSubject{NationalIDHash: nationalID, Name: "Somchai Jaidee"},
+			[]match{{"Somchai Jaidee", "name", "", "Block"}, {"Proxy holder", "national_id", "", "Block"}}},
+		{"nothing to screen", ScreeningSubject{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []match
+			for _, m := range w.Screen(tt.subject) {
+				if m.ListID != l.ListID {
+					continue
+				}
+				got = append(got, match{entryName[m.EntryID], m.MatchedField, m.MatchedAlias, m.Action})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenTransaction(t *testing.T) {
+	w := NewWatchlistService()
+	listed, clean := testID("WL-ACC"), testID("WL-ACC")
+	newTestWatchlist(t, w, "Review", models.WatchlistEntry{Name: "Listed", AccountID: listed})
+
+	txn := models.Transaction{TenantID: DefaultTenant, TransactionID: testID("TXN"), SenderAccount: clean, ReceiverAccount: listed}
+	hits, err := w.ScreenTransaction(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Role != "receiver" || hits[0].Status != models.HitOpen || hits[0].HitID == 0 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if got := WatchlistAction(hits); got != "Review" {
+		t.Errorf("WatchlistAction = %q", got)
+	}
+
+	open, err := ListWatchlistHits(DefaultTenant, models.HitOpen, listed, 10)
+	if err != nil || len(open) != 1 || open[0].HitID != hits[0].HitID {
+		t.Fatalf("open hits = %+v, %v", open, err)
+	}
+
+	if _, err := AdjudicateHit(DefaultTenant, hits[0].HitID, "MAYBE", "analyst", ""); err == nil {
+		t.Error("invalid status accepted")
+	}
+	if _, err := AdjudicateHit(DefaultTenant, -1, models.HitDismissed, "analyst", ""); !errors.Is(err, ErrHitNotFound) {
+		t.Errorf("unknown hit: %v", err)
+	}
+	h, err := AdjudicateHit(DefaultTenant, hits[0].HitID, models.HitDismissed, "analyst", "same name, different person")
+	if err != nil || h.Status != models.HitDismissed || h.AdjudicatedBy != "analyst" || h.AdjudicatedAt == nil {
+		t.Fatalf("AdjudicateHit = %+v, %v", h, err)
+	}
+
+	// A dismissed hit is not raised again for the same value
+	txn.TransactionID = testID("TXN")
+	if hits, err := w.ScreenTransaction(txn); err != nil || len(hits) != 0 {
+		t.Errorf("hits after dismissal = %+v, %v", hits, err)
+	}
+}
+
+func TestWatchlistAction(t *testing.T) {
+	hit := func(action string) models.WatchlistHit {
+		return models.WatchlistHit{WatchlistMatch: models.WatchlistMatch{Action: action}}
+	}
+	tests := []struct {
+		hits []models.WatchlistHit
+		want string
+	}{
+		{nil, ""},
+		{[]models.WatchlistHit{hit("Review")}, "Review"},
+		{[]models.WatchlistHit{hit("Review"), hit("Block"), hit("Review")}, "Block"},
+	}
+	for _, tt := range tests {
+		if got := WatchlistAction(tt.hits); got != tt.want {
+			t.Errorf("WatchlistAction(%v) = %q, want %q", tt.hits, got, tt.want)
+		}
+	}
+}
+
+func TestWatchlistManagement(t *testing.T) {
+	w := NewWatchlistService()
+	if _, err := w.CreateWatchlist(models.Watchlist{Name: " "}); err == nil {
+		t.Error("list without a name created")
+	}
+	if _, err := w.CreateWatchlist(models.Watchlist{Name: "x", Action: "Allow"}); err == nil {
+		t.Error("list with action Allow created")
+	}
+	if err := w.AddEntries("WL-NONE", []models.WatchlistEntry{{Name: "x"}}, false); !errors.Is(err, ErrWatchlistNotFound) {
+		t.Errorf("AddEntries to a missing list: %v", err)
+	}
+
+	l := newTestWatchlist(t, w, "", models.WatchlistEntry{Name: "First"}, models.WatchlistEntry{Name: "Second"})
+	if l.Action != "Review" || l.Source != "INTERNAL" {
+		t.Errorf("list = %+v", l)
+	}
+	if err := w.AddEntries(l.ListID, []models.WatchlistEntry{{Reference: "no name or identifier"}}, false); err == nil {
+		t.Error("empty entry accepted")
+	}
+	if err := w.AddEntries(l.ListID, []models.WatchlistEntry{{Name: "Third"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := w.Entries(l.ListID, 10)
+	if len(entries) != 1 || entries[0].Name != "Third" {
+		t.Fatalf("entries after replace = %+v", entries)
+	}
+	if err := w.DeleteEntry(l.ListID, entries[0].EntryID); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.DeleteEntry(l.ListID, entries[0].EntryID); !errors.Is(err, ErrWatchlistNotFound) {
+		t.Errorf("deleting a deleted entry: %v", err)
+	}
+	if err := w.DeleteWatchlist(l.ListID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Entries(l.ListID, 10); !errors.Is(err, ErrWatchlistNotFound) {
+		t.Errorf("entries of a deleted list: %v", err)
+	}
+}
+
+func TestParseWatchlistEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []models.WatchlistEntry
+		wantErr bool
+	}{
+		{"CSV", "\xEF\xBB\xBFName,Aliases,account_id,reference\nSomchai Jaidee, สมชาย ใจดี;S. Jaidee ,ACC-1,R1\n",
+			[]models.WatchlistEntry{{Name: "Somchai Jaidee", Aliases: []string{"สมชาย ใจดี", "S. Jaidee"}, AccountID: "ACC-1", Reference: "R1"}}, false},
+		{"CSV without a name column", "account_id\nACC-1\n", nil, true},
+		{"UN consolidated list", `<CONSOLIDATED_LIST>
+  <INDIVIDUALS><INDIVIDUAL>
+    <REFERENCE_NUMBER>QDi.001</REFERENCE_NUMBER>
+    <FIRST_NAME>Ahmad</FIRST_NAME><SECOND_NAME>Example</SECOND_NAME>
+    <NAME_ORIGINAL_SCRIPT>أحمد</NAME_ORIGINAL_SCRIPT>
+    <INDIVIDUAL_ALIAS><ALIAS_NAME>Ahmed Ex</ALIAS_NAME></INDIVIDUAL_ALIAS>
+  </INDIVIDUAL></INDIVIDUALS>
+  <ENTITIES><ENTITY>
+    <REFERENCE_NUMBER>QDe.002</REFERENCE_NUMBER><FIRST_NAME>Example Trading Co</FIRST_NAME>
+    <ENTITY_ALIAS><ALIAS_NAME>ETC</ALIAS_NAME></ENTITY_ALIAS>
+  </ENTITY></ENTITIES>
+</CONSOLIDATED_LIST>`,
+			[]models.WatchlistEntry{
+				{Reference: "QDi.001", Name: "Ahmad Example", Aliases: []string{"أحمد", "Ahmed Ex"}},
+				{Reference: "QDe.002", Name: "Example Trading Co", Aliases: []string{"ETC"}},
+			}, false},
+		{"simple XML", `<watchlist><entry><name>Mule</name><alias>M</alias><promptpay_proxy>0812345678</promptpay_proxy></entry></watchlist>`,
+			[]models.WatchlistEntry{{Name: "Mule", Aliases: []string{"M"}, PromptPayProxy: "0812345678"}}, false},
+		{"broken XML", "<watchlist><entry>", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWatchlistEntries([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !production
+
+package main
+
+// testBenchCompiled includes the test bench routes in development builds
+const testBenchCompiled = true
@@ -0,0 +1,6 @@
+//go:build production
+
+package main
+
+// testBenchCompiled leaves the test bench routes out of production builds
+const testBenchCompiled = false
@@ -0,0 +1,17 @@
+//go:build production
+
+package main
+
+import "testing"
+
+// TestProductionBuildExcludesTestBench guards the image build: a binary built
+// with -tags production must not carry the test bench routes at all
+func TestProductionBuildExcludesTestBench(t *testing.T) {
+	if testBenchCompiled {
+		t.Fatal("test bench compiled into a production build")
+	}
+	t.Setenv("TEST_BENCH_ENABLED", "true")
+	if testBenchEnabled() {
+		t.Error("TEST_BENCH_ENABLED turned the test bench on in a production build")
+	}
+}
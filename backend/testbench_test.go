@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestTestBenchEnabled(t *testing.T) {
+	tests := []struct {
+		env  string
+		want bool
+	}{
+		{"", testBenchCompiled},
+		{"true", testBenchCompiled},
+		{"false", false},
+		{"not a bool", testBenchCompiled},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_BENCH_ENABLED", tt.env)
+		if got := testBenchEnabled(); got != tt.want {
+			t.Errorf("TEST_BENCH_ENABLED=%q: testBenchEnabled() = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,29 @@
+import os
 import requests
 import time
 import random
 import datetime
 
 API_URL = "http://localhost:8080/api/bank/transaction"
+LOGIN_URL = "http://localhost:8080/api/login"
+USERNAME = os.environ.get("SYNAPSE_USER", "ingest@synapse.bank")
+# The seeded ingest account has no public password: use the one it was given
+# at first start, after changing it from the initial one
+PASSWORD = os.environ.get("SYNAPSE_PASSWORD")
+# An API key with the ingest scope replaces the login when set
+API_KEY = os.environ.get("SYNAPSE_API_KEY")
+
+session = requests.Session()
+
+def login():
+    if API_KEY:
+        session.headers["X-API-Key"] = API_KEY
+        return
+    if not PASSWORD:
+        raise SystemExit("Set SYNAPSE_API_KEY, or SYNAPSE_PASSWORD for " + USERNAME)
+    resp = requests.post(LOGIN_URL, json={"username": USERNAME, "password": PASSWORD})
+    resp.raise_for_status()
+    session.headers["Authorization"] = f"Bearer {resp.json()['token']}"
 
 # Thai Bank Codes (Mock)
 BANKS = ["KBANK", "SCB", "BBL", "KTB", "TTB", "BAY"]
@@ -51,7 +71,11 @@ def generate_transaction(tx_id, amount, sender, receiver, is_new=False, suspicio
 def send_txn(data):
     try:
         print(f"[{datetime.datetime.now().strftime('%H:%M:%S')}] {data['sender_account']} -> {data['receiver_account']} ({data['amount']:,.0f} THB)...", end="")
-        resp = requests.post(API_URL, json=data)
+        resp = session.post(API_URL, json=data)
+        if resp.status_code == 401 and not API_KEY:
+            # Access tokens are short-lived; sign in again and retry once
+            login()
+            resp = session.post(API_URL, json=data)
         if resp.status_code == 200:
             res = resp.json()
             analysis = res['analysis_result']
@@ -77,6 +101,7 @@ def main():
     print("================================================================")
     print("Simulating PromptPay traffic between: KBANK, SCB, BBL, KTB, TTB")
     time.sleep(2)
+    login()
     
     count = 1000
     
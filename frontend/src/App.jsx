@@ -7,6 +7,7 @@ import PoliciesPage from './pages/Policies';
 import MLExplainerPage from './pages/MLExplainerPage';
 import FuturePlanPage from './pages/FuturePlanPage';
 import Login from './components/Login';
+import { logoutUser } from './api';
 
 const ProtectedRoute = ({ isAuth, children }) => {
   if (!isAuth) {
@@ -33,8 +34,12 @@ const App = () => {
     setIsAuth(true);
   };
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    await logoutUser();
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('username');
+    localStorage.removeItem('role');
     setIsAuth(false);
   };
 
@@ -6,6 +6,46 @@ const BANK_API = `${API_BASE_URL}/api/bank`;
 const TEST_API = `${API_BASE_URL}/api/test`;
 const PLATFORM_API = `${API_BASE_URL}/api`;
 
+// Attach the session token to every request
+axios.interceptors.request.use((config) => {
+    const token = localStorage.getItem('token');
+    if (token) {
+        config.headers.Authorization = `Bearer ${token}`;
+    }
+    return config;
+});
+
+// Access tokens are short-lived: on 401, exchange the refresh token once and
+// retry. If that fails the session is over and the user must sign in again.
+let refreshing = null;
+axios.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+        const original = error.config;
+        const refreshToken = localStorage.getItem('refresh_token');
+        if (error.response?.status !== 401 || !original || original._retried || !refreshToken) {
+            throw error;
+        }
+        original._retried = true;
+        try {
+            refreshing = refreshing || axios.post(`${PLATFORM_API}/token/refresh`, { refresh_token: refreshToken });
+            const { data } = await refreshing;
+            localStorage.setItem('token', data.token);
+            localStorage.setItem('refresh_token', data.refresh_token);
+        } catch (refreshError) {
+            localStorage.removeItem('token');
+            localStorage.removeItem('refresh_token');
+            localStorage.removeItem('username');
+            localStorage.removeItem('role');
+            window.location.assign('/login');
+            throw error;
+        } finally {
+            refreshing = null;
+        }
+        return axios(original);
+    }
+);
+
 export const ingestTransaction = async (transaction) => {
     try {
         const response = await axios.post(`${BANK_API}/transaction`, transaction);
@@ -77,26 +117,64 @@ export const loginUser = async (username, password) => {
     }
 };
 
-export const getVerificationStats = async () => {
+// Second step of a login that answered with mfa_required
+export const completeMfaLogin = async (mfaToken, code) => {
+    const response = await axios.post(`${PLATFORM_API}/login/mfa`, { mfa_token: mfaToken, code });
+    return response.data;
+};
+
+// TOTP secret for a user whose role requires MFA and who has not enrolled yet
+export const enrollMfaAtLogin = async (mfaToken) => {
+    const response = await axios.post(`${PLATFORM_API}/login/mfa/enroll`, { mfa_token: mfaToken });
+    return response.data;
+};
+
+// Replaces an initial password; the token is passed in because sign-in has
+// not finished yet when the backend requires the change
+export const changePassword = async (token, currentPassword, newPassword) => {
+    const response = await axios.post(`${PLATFORM_API}/me/password`,
+        { current_password: currentPassword, new_password: newPassword },
+        { headers: { Authorization: `Bearer ${token}` } });
+    return response.data;
+};
+
+// Sign-in options offered by the backend (password and/or single sign-on)
+export const getAuthMethods = async () => {
     try {
-        const response = await axios.get(`${PLATFORM_API}/stats`);
+        const response = await axios.get(`${PLATFORM_API}/auth/methods`);
         return response.data;
     } catch (error) {
-        return { checked: 0, false_positives: 0 };
+        console.error("Error fetching sign-in options:", error);
+        return { password: true, sso: { enabled: false } };
+    }
+};
+
+// Full-page navigation to the identity provider, via the backend
+export const ssoLoginUrl = () => `${PLATFORM_API}/oidc/login`;
+
+export const logoutUser = async () => {
+    try {
+        await axios.post(`${PLATFORM_API}/logout`);
+    } catch (error) {
+        console.error("Logout failed", error);
     }
 };
 
-export const updateVerificationStats = async (verdict) => {
+export const getVerificationStats = async () => {
     try {
-        await axios.post(`${PLATFORM_API}/stats`, { verdict });
+        const response = await axios.get(`${PLATFORM_API}/stats`);
+        return response.data;
     } catch (error) {
-        console.error("Stats update failed", error);
+        return { checked: 0, false_positives: 0 };
     }
 };
 
 export const verifyTransaction = async (id, verdict) => {
     try {
-        await axios.post(`${BANK_API}/transaction/${id}/verify`, { verdict });
+        // Verdicts are proposals until a supervisor approves them
+        const analyst = localStorage.getItem('username');
+        const response = await axios.post(`${BANK_API}/transaction/${id}/verify`, { verdict, analyst });
+        return response.data;
     } catch (error) {
         console.error("Verification failed", error);
         throw error;
@@ -1,12 +1,46 @@
-import React, { useState } from 'react';
-import { Shield, Lock, ArrowRight, CheckCircle, AlertTriangle } from 'lucide-react';
-import { loginUser } from '../api';
+import React, { useState, useEffect } from 'react';
+import { Shield, Lock, ArrowRight, CheckCircle, AlertTriangle, KeyRound } from 'lucide-react';
+import { loginUser, getAuthMethods, ssoLoginUrl, completeMfaLogin, enrollMfaAtLogin, changePassword } from '../api';
 
 const Login = ({ onLogin }) => {
     const [isLoading, setIsLoading] = useState(false);
     const [error, setError] = useState('');
     const [username, setUsername] = useState('analyst@synapse.bank');
     const [password, setPassword] = useState('secure');
+    const [methods, setMethods] = useState({ password: true, sso: { enabled: false } });
+    const [mfa, setMfa] = useState(null);               // login challenge awaiting a code
+    const [enrollment, setEnrollment] = useState(null); // TOTP secret when enrolling at login
+    const [code, setCode] = useState('');
+    const [recovery, setRecovery] = useState(null);     // tokens held back until codes are saved
+    const [passwordChange, setPasswordChange] = useState(null); // tokens held back until the initial password is replaced
+    const [newPassword, setNewPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+
+    const completeLogin = (data) => {
+        if (data.password_change_required) {
+            setPasswordChange(data);
+            return;
+        }
+        localStorage.setItem('username', data.username);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        localStorage.setItem('role', data.role);
+        onLogin(data.token);
+    };
+
+    useEffect(() => {
+        getAuthMethods().then(setMethods);
+
+        // Single sign-on returns here with the tokens (or an error) in the fragment
+        const params = new URLSearchParams(window.location.hash.slice(1));
+        if (params.has('token') || params.has('error')) {
+            window.history.replaceState(null, '', window.location.pathname);
+            if (params.has('token')) {
+                completeLogin(Object.fromEntries(params));
+            } else {
+                setError(`Single sign-on failed: ${params.get('error')}`);
+            }
+        }
+    }, []);
 
     const handleLogin = async (e) => {
         e.preventDefault();
@@ -15,9 +49,62 @@ const Login = ({ onLogin }) => {
 
         try {
             const data = await loginUser(username, password);
-            onLogin(data.token);
+            if (data.mfa_required) {
+                setMfa(data);
+                if (data.enrollment_required) {
+                    setEnrollment(await enrollMfaAtLogin(data.mfa_token));
+                }
+            } else {
+                completeLogin(data);
+            }
+        } catch (err) {
+            setError(err.response?.data?.error === 'Invalid credentials' || !err.response
+                ? 'Invalid credentials. Try analyst@synapse.bank / secure'
+                : err.response.data.error);
+        } finally {
+            setIsLoading(false);
+        }
+    };
+
+    const handleMfa = async (e) => {
+        e.preventDefault();
+        setIsLoading(true);
+        setError('');
+
+        try {
+            const data = await completeMfaLogin(mfa.mfa_token, code.trim());
+            if (data.recovery_codes?.length) {
+                setRecovery(data);
+            } else {
+                completeLogin(data);
+            }
+        } catch (err) {
+            if (err.response?.status === 401 && err.response.data?.error !== 'invalid verification code') {
+                // Challenge expired or too many wrong codes: start over
+                setMfa(null);
+                setEnrollment(null);
+            }
+            setError(err.response?.data?.error || 'Verification failed');
+        } finally {
+            setCode('');
+            setIsLoading(false);
+        }
+    };
+
+    const handlePasswordChange = async (e) => {
+        e.preventDefault();
+        if (newPassword !== confirmPassword) {
+            setError('The new passwords do not match');
+            return;
+        }
+        setIsLoading(true);
+        setError('');
+
+        try {
+            await changePassword(passwordChange.token, password, newPassword);
+            completeLogin({ ...passwordChange, password_change_required: false });
         } catch (err) {
-            setError('Invalid credentials. Try analyst@synapse.bank / secure');
+            setError(err.response?.data?.error || 'Password change failed');
         } finally {
             setIsLoading(false);
         }
@@ -82,6 +169,94 @@ const Login = ({ onLogin }) => {
                         )}
                     </div>
 
+                    {methods.sso?.enabled && !mfa && (
+                        <a
+                            href={ssoLoginUrl()}
+                            className="w-full mb-5 border border-slate-300 hover:bg-slate-50 text-slate-700 font-semibold py-3 rounded-lg flex items-center justify-center gap-2 transition-all"
+                        >
+                            <KeyRound className="w-5 h-5" /> Sign in with {methods.sso.name}
+                        </a>
+                    )}
+
+                    {passwordChange ? (
+                        <form onSubmit={handlePasswordChange} className="space-y-5">
+                            <p className="text-sm text-slate-600">
+                                This account still has its initial password. Choose a new one of at least 8 characters to continue.
+                            </p>
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-1.5">New password</label>
+                                <input
+                                    autoFocus
+                                    type="password"
+                                    autoComplete="new-password"
+                                    value={newPassword}
+                                    onChange={(e) => setNewPassword(e.target.value)}
+                                    className="w-full px-4 py-2.5 rounded-lg border border-slate-300 focus:ring-2 focus:ring-indigo-500 focus:border-indigo-500 outline-none transition-all"
+                                />
+                            </div>
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-1.5">Confirm new password</label>
+                                <input
+                                    type="password"
+                                    autoComplete="new-password"
+                                    value={confirmPassword}
+                                    onChange={(e) => setConfirmPassword(e.target.value)}
+                                    className="w-full px-4 py-2.5 rounded-lg border border-slate-300 focus:ring-2 focus:ring-indigo-500 focus:border-indigo-500 outline-none transition-all"
+                                />
+                            </div>
+                            <button
+                                type="submit"
+                                disabled={isLoading || newPassword.length < 8}
+                                className="w-full bg-indigo-600 hover:bg-indigo-700 text-white font-bold py-3 rounded-lg flex items-center justify-center gap-2 transition-all"
+                            >
+                                Set password <ArrowRight className="w-5 h-5" />
+                            </button>
+                        </form>
+                    ) : recovery ? (
+                        <div className="space-y-4">
+                            <p className="text-sm text-slate-600">
+                                Two-factor authentication is on. Store these recovery codes somewhere safe; each one signs you in once if you lose your authenticator.
+                            </p>
+                            <div className="grid grid-cols-2 gap-2 font-mono text-sm bg-slate-50 border border-slate-200 rounded-lg p-3">
+                                {recovery.recovery_codes.map((c) => <span key={c}>{c}</span>)}
+                            </div>
+                            <button
+                                onClick={() => completeLogin(recovery)}
+                                className="w-full bg-indigo-600 hover:bg-indigo-700 text-white font-bold py-3 rounded-lg flex items-center justify-center gap-2"
+                            >
+                                I have saved them <ArrowRight className="w-5 h-5" />
+                            </button>
+                        </div>
+                    ) : mfa ? (
+                        <form onSubmit={handleMfa} className="space-y-5">
+                            {enrollment && (
+                                <div className="text-sm text-slate-600 space-y-2">
+                                    <p>Your role requires two-factor authentication. Add this key to your authenticator app, then enter the code it shows.</p>
+                                    <code className="block break-all bg-slate-50 border border-slate-200 rounded p-2 text-xs">{enrollment.secret}</code>
+                                    <a href={enrollment.otpauth_uri} className="text-indigo-600 text-xs underline">Open in authenticator app</a>
+                                </div>
+                            )}
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-1.5">
+                                    {enrollment ? 'Authenticator code' : 'Authenticator or recovery code'}
+                                </label>
+                                <input
+                                    autoFocus
+                                    autoComplete="one-time-code"
+                                    value={code}
+                                    onChange={(e) => setCode(e.target.value)}
+                                    className="w-full px-4 py-2.5 rounded-lg border border-slate-300 focus:ring-2 focus:ring-indigo-500 focus:border-indigo-500 outline-none transition-all tracking-widest"
+                                />
+                            </div>
+                            <button
+                                type="submit"
+                                disabled={isLoading || !code}
+                                className="w-full bg-indigo-600 hover:bg-indigo-700 text-white font-bold py-3 rounded-lg flex items-center justify-center gap-2 transition-all"
+                            >
+                                Verify <ArrowRight className="w-5 h-5" />
+                            </button>
+                        </form>
+                    ) : methods.password && (
                     <form onSubmit={handleLogin} className="space-y-5">
                         <div>
                             <label className="block text-sm font-medium text-slate-700 mb-1.5">Employee ID / Email</label>
@@ -127,6 +302,7 @@ const Login = ({ onLogin }) => {
                             </div>
                         </div>
                     </form>
+                    )}
 
                     <div className="mt-6 pt-6 border-t border-slate-100 text-center text-xs text-slate-400">
                         System Status: <span className="text-green-600 font-medium">Operational</span> • v2.4.0
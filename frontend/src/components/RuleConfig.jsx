@@ -12,7 +12,9 @@ const RuleConfig = () => {
 
     const fetchRules = async () => {
         try {
-            const res = await fetch('/api/ai/rules');
+            const res = await fetch('/api/ai/rules', {
+                headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}` }
+            });
             const data = await res.json();
             setConfig(data.config);
             setGroups(data.groups);
@@ -37,7 +39,10 @@ const RuleConfig = () => {
             // API Call
             await fetch('/api/ai/rules/update', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${localStorage.getItem('token')}`
+                },
                 body: JSON.stringify({ id: ruleId, enabled: !currentEnabled })
             });
         } catch (err) {
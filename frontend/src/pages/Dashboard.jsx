@@ -4,7 +4,7 @@ import TransactionGraph from '../components/Graph';
 import AlertPanel from '../components/AlertPanel';
 import DetailModal from '../components/DetailModal';
 import InvestigationModal from '../components/InvestigationModal';
-import { getGraphData, getAccountDetails, getVerificationStats, updateVerificationStats, verifyTransaction } from '../api';
+import { getGraphData, getAccountDetails, getVerificationStats, verifyTransaction } from '../api';
 
 const Dashboard = () => {
     const [transactions, setTransactions] = useState([]);
@@ -90,7 +90,9 @@ const Dashboard = () => {
             return;
         }
 
-        await updateVerificationStats(verdict);
+        // Proposed like any other verdict; stats move once it is approved
+        const tx = inspectingTransaction;
+        await verifyTransaction(tx.txn_id || tx.transaction_id, verdict);
         const newStats = await getVerificationStats();
         setVerificationStats(newStats);
         setInspectingTransaction(null);
@@ -100,20 +102,17 @@ const Dashboard = () => {
         const tx = selectedTransaction;
         if (!tx) return;
 
-        // 1. Call Backend to save verification
+        // 1. Propose the verdict; a supervisor must approve it before it is applied
         await verifyTransaction(tx.txn_id || tx.transaction_id, verdict);
 
         // 2. Update Local State (to reflect change in UI immediately)
         // Update selected transaction
-        setSelectedTransaction(prev => ({ ...prev, verification_status: verdict }));
+        setSelectedTransaction(prev => ({ ...prev, verification_status: 'PENDING_APPROVAL' }));
 
         // Update graph nodes if it exists in graph
         // (Optional: trigger graph refresh or just update node color if we had complex state)
 
-        // 3. Update Stats
-        await updateVerificationStats(verdict);
-        const newStats = await getVerificationStats();
-        setVerificationStats(newStats);
+        // 3. Stats only move once the verdict is approved
 
         // DO NOT CLOSE MODAL AUTOMATICALLY
         // setSelectedTransaction(null); 
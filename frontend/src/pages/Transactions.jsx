@@ -3,10 +3,20 @@ import TestBench from '../components/TestBench';
 import { Database } from 'lucide-react';
 
 const TransactionsPage = () => {
+    // Only admins may generate synthetic data or reset the database
+    const canUseTestBench = localStorage.getItem('role') === 'admin';
+
     return (
         <div className="flex-1 overflow-auto bg-slate-50 p-6">
             {/* <h2 className="text-2xl font-bold mb-4">Transaction Explorer</h2> */}
-            <TestBench />
+            {canUseTestBench ? (
+                <TestBench />
+            ) : (
+                <div className="flex items-center gap-2 text-sm text-slate-500">
+                    <Database size={16} />
+                    The test bench is available to administrators only.
+                </div>
+            )}
         </div>
     );
 };